@@ -141,7 +141,8 @@ const (
 	ResourceKindGateway         = "gateway"
 
 	// Istio related
-	ResourceKindApp = "app"
+	ResourceKindApp     = "app"
+	ResourceKindRollout = "rollout"
 )
 
 // ClientType represents type of client that is used to perform generic operations on resources.
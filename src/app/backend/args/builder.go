@@ -90,6 +90,18 @@ func (self *holderBuilder) SetHeapsterHost(heapsterHost string) *holderBuilder {
 	return self
 }
 
+// SetPrometheusHost 'prometheus-host' argument of Dashboard binary.
+func (self *holderBuilder) SetPrometheusHost(prometheusHost string) *holderBuilder {
+	self.holder.prometheusHost = prometheusHost
+	return self
+}
+
+// SetClusterDomain 'cluster-domain' argument of Dashboard binary.
+func (self *holderBuilder) SetClusterDomain(clusterDomain string) *holderBuilder {
+	self.holder.clusterDomain = clusterDomain
+	return self
+}
+
 // SetKubeConfigFile 'kubeconfig' argument of Dashboard binary.
 func (self *holderBuilder) SetKubeConfigFile(kubeConfigFile string) *holderBuilder {
 	self.holder.kubeConfigFile = kubeConfigFile
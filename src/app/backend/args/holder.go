@@ -38,6 +38,8 @@ type holder struct {
 	keyFile              string
 	apiServerHost        string
 	heapsterHost         string
+	prometheusHost       string
+	clusterDomain        string
 	kubeConfigFile       string
 	systemBanner         string
 	systemBannerSeverity string
@@ -116,6 +118,16 @@ func (self *holder) GetHeapsterHost() string {
 	return self.heapsterHost
 }
 
+// GetPrometheusHost 'prometheus-host' argument of Dashboard binary.
+func (self *holder) GetPrometheusHost() string {
+	return self.prometheusHost
+}
+
+// GetClusterDomain 'cluster-domain' argument of Dashboard binary.
+func (self *holder) GetClusterDomain() string {
+	return self.clusterDomain
+}
+
 // GetKubeConfigFile 'kubeconfig' argument of Dashboard binary.
 func (self *holder) GetKubeConfigFile() string {
 	return self.kubeConfigFile
@@ -54,6 +54,10 @@ func (self *fakeClientManager) InsecureClient() kubernetes.Interface {
 	return nil
 }
 
+func (self *fakeClientManager) InsecureIstioClient() istio.Interface {
+	return nil
+}
+
 func (self *fakeClientManager) SetTokenManager(manager authApi.TokenManager) {}
 
 func (self *fakeClientManager) Config(req *restful.Request) (*rest.Config, error) {
@@ -47,6 +47,7 @@ type ClientManager interface {
 	VerberClient(req *restful.Request) (ResourceVerber, error)
 	SetTokenManager(manager authApi.TokenManager)
 	IstioClient(req *restful.Request) (istio.Interface, error)
+	InsecureIstioClient() istio.Interface
 }
 
 // ResourceVerber is responsible for performing generic CRUD operations on all supported resources.
@@ -77,6 +77,9 @@ type clientManager struct {
 	// to service account used by dashboard or kubeconfig file if it was passed during dashboard
 	// init.
 	insecureConfig *rest.Config
+	// Istio client created without providing auth info. It uses the same permissions as
+	// insecureClient and is used by background controllers.
+	insecureIstioClient istio.Interface
 }
 
 // Client returns a kubernetes client. In case dashboard login is enabled and option to skip
@@ -376,6 +379,15 @@ func (self *clientManager) initInsecureClient() {
 	}
 
 	self.insecureClient = client
+
+	istioCfg := rest.CopyConfig(self.insecureConfig)
+	istioCfg.ContentType = "application/json"
+	istioClient, err := istio.NewForConfig(istioCfg)
+	if err != nil {
+		panic(err)
+	}
+
+	self.insecureIstioClient = istioClient
 }
 
 func (self *clientManager) initInsecureConfig() {
@@ -407,6 +419,13 @@ func (self *clientManager) IstioClient(req *restful.Request) (istio.Interface, e
 	return client, nil
 }
 
+// InsecureIstioClient returns istio client that was created without providing auth info. It uses
+// permissions granted to service account used by dashboard or kubeconfig file if it was passed
+// during dashboard init.
+func (self *clientManager) InsecureIstioClient() istio.Interface {
+	return self.insecureIstioClient
+}
+
 // NewClientManager creates client manager based on kubeConfigPath and apiserverHost parameters.
 // If both are empty then in-cluster config is used.
 func NewClientManager(kubeConfigPath, apiserverHost string) clientapi.ClientManager {
@@ -39,9 +39,12 @@ import (
 	"github.com/kubernetes/dashboard/src/app/backend/handler"
 	"github.com/kubernetes/dashboard/src/app/backend/integration"
 	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
+	metricprometheus "github.com/kubernetes/dashboard/src/app/backend/integration/metric/prometheus"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/rollout"
 	"github.com/kubernetes/dashboard/src/app/backend/settings"
 	"github.com/kubernetes/dashboard/src/app/backend/sync"
 	"github.com/kubernetes/dashboard/src/app/backend/systembanner"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 var (
@@ -60,6 +63,12 @@ var (
 		"to connect to in the format of protocol://address:port, e.g., "+
 		"http://localhost:8082. If not specified, the assumption is that the binary runs inside a "+
 		"Kubernetes cluster and service proxy will be used.")
+	argPrometheusHost = pflag.String("prometheus-host", "", "The address of the Prometheus server "+
+		"to connect to in the format of protocol://address:port, e.g., "+
+		"http://localhost:9090. If not specified, the assumption is that the binary runs inside a "+
+		"Kubernetes cluster and service proxy to the Prometheus of Istio will be used.")
+	argClusterDomain = pflag.String("cluster-domain", "cluster.local", "The domain of the cluster, used to resolve "+
+		"the full qualified names of the services, e.g. reviews.default.svc.cluster.local. Default: 'cluster.local'.")
 	argKubeConfigFile     = pflag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information.")
 	argTokenTTL           = pflag.Int("token-ttl", int(authApi.DefaultTokenTTL), "Expiration time (in seconds) of JWE tokens generated by dashboard. Default: 15 min. 0 - never expires")
 	argAuthenticationMode = pflag.StringSlice("authentication-mode", []string{authApi.Token.String()}, "Enables authentication options that will be reflected on login screen. Supported values: token, basic. Default: token."+
@@ -117,7 +126,29 @@ func main() {
 	// Init integrations
 	integrationManager := integration.NewIntegrationManager(clientManager)
 	integrationManager.Metric().ConfigureHeapster(args.Holder.GetHeapsterHost()).
-		EnableWithRetry(integrationapi.HeapsterIntegrationID, time.Duration(args.Holder.GetMetricClientCheckPeriod()))
+		ConfigureMetricsServer().
+		ConfigurePrometheus(args.Holder.GetPrometheusHost()).
+		EnableWithRetry([]integrationapi.IntegrationID{
+			integrationapi.HeapsterIntegrationID,
+			integrationapi.MetricsServerIntegrationID,
+			integrationapi.PrometheusIntegrationID,
+		}, time.Duration(args.Holder.GetMetricClientCheckPeriod()))
+
+	// Init istio rollout controller
+	// The owners of the rollouts are signed with the csrf key shared by the dashboard replicas.
+	// The error rate gate is skipped without Prometheus. Pass an untyped nil in that case, as a nil
+	// client in the interface would not be detected by the controller.
+	var errorRate rollout.ErrorRateSource
+	prometheusClient, err := metricprometheus.CreatePrometheusClient(args.Holder.GetPrometheusHost(),
+		clientManager.InsecureClient())
+	if err != nil {
+		log.Printf("Failed to create Prometheus client, rollouts skip the error rate gate: %s", err)
+	} else {
+		errorRate = prometheusClient
+	}
+	rolloutController := rollout.NewController(clientManager.InsecureClient(), clientManager.InsecureIstioClient(),
+		errorRate, settingsManager, clientManager.CSRFKey())
+	go rolloutController.Run(rollout.DefaultPeriod, wait.NeverStop)
 
 	apiHandler, err := handler.CreateHTTPAPIHandler(
 		integrationManager,
@@ -219,6 +250,8 @@ func initArgHolder() {
 	builder.SetKeyFile(*argKeyFile)
 	builder.SetApiServerHost(*argApiserverHost)
 	builder.SetHeapsterHost(*argHeapsterHost)
+	builder.SetPrometheusHost(*argPrometheusHost)
+	builder.SetClusterDomain(*argClusterDomain)
 	builder.SetKubeConfigFile(*argKubeConfigFile)
 	builder.SetSystemBanner(*argSystemBanner)
 	builder.SetSystemBannerSeverity(*argSystemBannerSeverity)
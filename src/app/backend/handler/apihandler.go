@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	restful "github.com/emicklei/go-restful"
 	"github.com/kubernetes/dashboard/src/app/backend/api"
@@ -53,6 +54,7 @@ import (
 	"github.com/kubernetes/dashboard/src/app/backend/resource/pod"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/replicaset"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/replicationcontroller"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/rightsizing"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/secret"
 	resourceService "github.com/kubernetes/dashboard/src/app/backend/resource/service"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/serviceentry"
@@ -64,6 +66,7 @@ import (
 	"github.com/kubernetes/dashboard/src/app/backend/systembanner"
 	"github.com/kubernetes/dashboard/src/app/backend/validation"
 	"golang.org/x/net/xsrftoken"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/remotecommand"
 )
@@ -120,7 +123,7 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, cManager clie
 	systemBannerHandler := systembanner.NewSystemBannerHandler(sbManager)
 	systemBannerHandler.Install(apiV1Ws)
 
-	istioHandler := istio.NewIstioHandler(cManager)
+	istioHandler := istio.NewIstioHandler(cManager, sManager)
 	istioHandler.Install(apiV1Ws)
 
 	apiV1Ws.Route(
@@ -467,6 +470,19 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, cManager clie
 		apiV1Ws.GET("/serviceentry/{namespace}/{name}").
 			To(apiHandler.handleGetServiceEntryDetail).
 			Writes(serviceentry.ServiceEntry{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/serviceentry/{namespace}").
+			To(apiHandler.handleCreateServiceEntry).
+			Reads(serviceentry.ServiceEntrySpec{}).
+			Writes(serviceentry.ServiceEntry{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/serviceentry/{namespace}/{name}").
+			To(apiHandler.handleUpdateServiceEntry).
+			Reads(serviceentry.ServiceEntrySpec{}).
+			Writes(serviceentry.ServiceEntry{}))
+	apiV1Ws.Route(
+		apiV1Ws.DELETE("/serviceentry/{namespace}/{name}").
+			To(apiHandler.handleDeleteServiceEntry))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/gateway").
@@ -618,6 +634,11 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, cManager clie
 			To(apiHandler.handleLogFile).
 			Writes(logs.LogDetails{}))
 
+	apiV1Ws.Route(
+		apiV1Ws.GET("/rightsizing/{namespace}").
+			To(apiHandler.handleGetRightSizingReport).
+			Writes(rightsizing.RightSizingReport{}))
+
 	return wsContainer, nil
 }
 
@@ -629,7 +650,11 @@ func (apiHandler *APIHandler) handleGetClusterRoleList(request *restful.Request,
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := clusterrole.GetClusterRoleList(k8sClient, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -683,8 +708,12 @@ func (apiHandler *APIHandler) handleGetStatefulSetList(request *restful.Request,
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := statefulset.GetStatefulSetList(k8sClient, namespace, dataSelect,
 		apiHandler.iManager.Metric().Client())
 	if err != nil {
@@ -703,7 +732,13 @@ func (apiHandler *APIHandler) handleGetStatefulSetDetail(request *restful.Reques
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("statefulset")
-	result, err := statefulset.GetStatefulSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := statefulset.GetStatefulSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -721,8 +756,12 @@ func (apiHandler *APIHandler) handleGetStatefulSetPods(request *restful.Request,
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("statefulset")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := statefulset.GetStatefulSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name, namespace)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -740,7 +779,11 @@ func (apiHandler *APIHandler) handleGetStatefulSetEvents(request *restful.Reques
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("statefulset")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := event.GetResourceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -757,7 +800,11 @@ func (apiHandler *APIHandler) handleGetServiceList(request *restful.Request, res
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := resourceService.GetServiceList(k8sClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -792,8 +839,12 @@ func (apiHandler *APIHandler) handleGetServiceDetail(request *restful.Request, r
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("service")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := resourceService.GetServiceDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -816,7 +867,11 @@ func (apiHandler *APIHandler) handleGetVirtualServiceList(request *restful.Reque
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := virtualservice.GetVirtualServiceList(client, istioClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -864,7 +919,11 @@ func (apiHandler *APIHandler) handleGetServiceEntryList(request *restful.Request
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dsQuery := parseDataSelectPathParameter(request)
+	dsQuery, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := serviceentry.GetServiceEntryList(k8sClient, istioClient, namespace, dsQuery)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -898,6 +957,70 @@ func (apiHandler *APIHandler) handleGetServiceEntryDetail(request *restful.Reque
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandler) handleCreateServiceEntry(request *restful.Request, response *restful.Response) {
+	spec := new(serviceentry.ServiceEntrySpec)
+	if err := request.ReadEntity(spec); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := apiHandler.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := serviceentry.CreateServiceEntry(istioClient, namespace, spec)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+func (apiHandler *APIHandler) handleUpdateServiceEntry(request *restful.Request, response *restful.Response) {
+	spec := new(serviceentry.ServiceEntrySpec)
+	if err := request.ReadEntity(spec); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := apiHandler.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	name := request.PathParameter("name")
+	namespace := request.PathParameter("namespace")
+	result, err := serviceentry.UpdateServiceEntry(istioClient, namespace, name, spec)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandler) handleDeleteServiceEntry(request *restful.Request, response *restful.Response) {
+	istioClient, err := apiHandler.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	name := request.PathParameter("name")
+	namespace := request.PathParameter("namespace")
+	if err := serviceentry.DeleteServiceEntry(istioClient, namespace, name); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusOK)
+}
+
 func (apiHandler *APIHandler) handleGetGatewayList(request *restful.Request, response *restful.Response) {
 	k8sClient, err := apiHandler.cManager.Client(request)
 	if err != nil {
@@ -912,7 +1035,11 @@ func (apiHandler *APIHandler) handleGetGatewayList(request *restful.Request, res
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := gateway.GetGatewayList(k8sClient, istioClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -960,7 +1087,11 @@ func (apiHandler *APIHandler) handleGetDestinationRuleList(request *restful.Requ
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dsQuery := parseDataSelectPathParameter(request)
+	dsQuery, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := destinationrule.GetDestinationRuleList(client, istioClient, namespace, dsQuery)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1004,8 +1135,12 @@ func (apiHandler *APIHandler) handleGetServiceEvent(request *restful.Request, re
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("service")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := resourceService.GetServiceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1038,7 +1173,11 @@ func (apiHandler *APIHandler) handleGetIngressList(request *restful.Request, res
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	namespace := parseNamespacePathParameter(request)
 	result, err := ingress.GetIngressList(k8sClient, namespace, dataSelect)
 	if err != nil {
@@ -1057,8 +1196,12 @@ func (apiHandler *APIHandler) handleGetServicePods(request *restful.Request, res
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("service")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := resourceService.GetServicePods(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1074,8 +1217,12 @@ func (apiHandler *APIHandler) handleGetNodeList(request *restful.Request, respon
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := node.GetNodeList(k8sClient, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1092,8 +1239,12 @@ func (apiHandler *APIHandler) handleGetNodeDetail(request *restful.Request, resp
 	}
 
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1110,8 +1261,12 @@ func (apiHandler *APIHandler) handleGetNodeEvents(request *restful.Request, resp
 	}
 
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := event.GetNodeEvents(k8sClient, dataSelect, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1128,8 +1283,12 @@ func (apiHandler *APIHandler) handleGetNodePods(request *restful.Request, respon
 	}
 
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := node.GetNodePods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1283,8 +1442,12 @@ func (apiHandler *APIHandler) handleGetReplicationControllerList(request *restfu
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := replicationcontroller.GetReplicationControllerList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1301,8 +1464,12 @@ func (apiHandler *APIHandler) handleGetReplicaSets(request *restful.Request, res
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := replicaset.GetReplicaSetList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1320,7 +1487,13 @@ func (apiHandler *APIHandler) handleGetReplicaSetDetail(request *restful.Request
 
 	namespace := request.PathParameter("namespace")
 	replicaSet := request.PathParameter("replicaSet")
-	result, err := replicaset.GetReplicaSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, replicaSet)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := replicaset.GetReplicaSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, replicaSet,
+		dataselect.NewStandardMetricQuery(metricQuery))
 
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1339,8 +1512,12 @@ func (apiHandler *APIHandler) handleGetReplicaSetPods(request *restful.Request,
 
 	namespace := request.PathParameter("namespace")
 	replicaSet := request.PathParameter("replicaSet")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := replicaset.GetReplicaSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, replicaSet, namespace)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1359,8 +1536,12 @@ func (apiHandler *APIHandler) handleGetReplicaSetServices(request *restful.Reque
 
 	namespace := request.PathParameter("namespace")
 	replicaSet := request.PathParameter("replicaSet")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := replicaset.GetReplicaSetServices(k8sClient, dataSelect, namespace, replicaSet)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1379,8 +1560,12 @@ func (apiHandler *APIHandler) handleGetReplicaSetEvents(request *restful.Request
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("replicaSet")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := event.GetResourceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1400,8 +1585,12 @@ func (apiHandler *APIHandler) handleGetPodEvents(request *restful.Request, respo
 	log.Println("Getting events related to a pod in namespace")
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("pod")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := pod.GetEventsForPod(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1447,8 +1636,12 @@ func (apiHandler *APIHandler) handleGetDeployments(request *restful.Request, res
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := deployment.GetDeploymentList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1466,7 +1659,13 @@ func (apiHandler *APIHandler) handleGetDeploymentDetail(request *restful.Request
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("deployment")
-	result, err := deployment.GetDeploymentDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := deployment.GetDeploymentDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -1484,7 +1683,11 @@ func (apiHandler *APIHandler) handleGetDeploymentEvents(request *restful.Request
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("deployment")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := event.GetResourceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1502,8 +1705,12 @@ func (apiHandler *APIHandler) handleGetDeploymentOldReplicaSets(request *restful
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("deployment")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := deployment.GetDeploymentOldReplicaSets(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1536,8 +1743,12 @@ func (apiHandler *APIHandler) handleGetPods(request *restful.Request, response *
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics // download standard metrics - cpu, and memory - by default
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery) // download standard metrics - cpu, and memory - by default
 	result, err := pod.GetPodList(k8sClient, apiHandler.iManager.Metric().Client(), namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1555,7 +1766,13 @@ func (apiHandler *APIHandler) handleGetPodDetail(request *restful.Request, respo
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("pod")
-	result, err := pod.GetPodDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := pod.GetPodDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -1572,7 +1789,13 @@ func (apiHandler *APIHandler) handleGetReplicationControllerDetail(request *rest
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("replicationController")
-	result, err := replicationcontroller.GetReplicationControllerDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := replicationcontroller.GetReplicationControllerDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -1676,8 +1899,12 @@ func (apiHandler *APIHandler) handleGetReplicationControllerPods(request *restfu
 
 	namespace := request.PathParameter("namespace")
 	rc := request.PathParameter("replicationController")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := replicationcontroller.GetReplicationControllerPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, rc, namespace)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1712,7 +1939,11 @@ func (apiHandler *APIHandler) handleGetNamespaces(request *restful.Request, resp
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := ns.GetNamespaceList(k8sClient, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1745,7 +1976,11 @@ func (apiHandler *APIHandler) handleGetNamespaceEvents(request *restful.Request,
 	}
 
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := event.GetNamespaceEvents(k8sClient, dataSelect, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1798,7 +2033,11 @@ func (apiHandler *APIHandler) handleGetSecretList(request *restful.Request, resp
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	namespace := parseNamespacePathParameter(request)
 	result, err := secret.GetSecretList(k8sClient, namespace, dataSelect)
 	if err != nil {
@@ -1816,7 +2055,11 @@ func (apiHandler *APIHandler) handleGetConfigMapList(request *restful.Request, r
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := configmap.GetConfigMapList(k8sClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1849,7 +2092,11 @@ func (apiHandler *APIHandler) handleGetPersistentVolumeList(request *restful.Req
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := persistentvolume.GetPersistentVolumeList(k8sClient, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1882,7 +2129,11 @@ func (apiHandler *APIHandler) handleGetPersistentVolumeClaimList(request *restfu
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := persistentvolumeclaim.GetPersistentVolumeClaimList(k8sClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1934,7 +2185,11 @@ func (apiHandler *APIHandler) handleGetReplicationControllerEvents(request *rest
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("replicationController")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := event.GetResourceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1953,7 +2208,11 @@ func (apiHandler *APIHandler) handleGetReplicationControllerServices(request *re
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("replicationController")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := replicationcontroller.GetReplicationControllerServices(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1970,8 +2229,12 @@ func (apiHandler *APIHandler) handleGetDaemonSetList(request *restful.Request, r
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := daemonset.GetDaemonSetList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -1990,7 +2253,13 @@ func (apiHandler *APIHandler) handleGetDaemonSetDetail(
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("daemonSet")
-	result, err := daemonset.GetDaemonSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := daemonset.GetDaemonSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -2007,8 +2276,12 @@ func (apiHandler *APIHandler) handleGetDaemonSetPods(request *restful.Request, r
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("daemonSet")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := daemonset.GetDaemonSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name, namespace)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2026,7 +2299,11 @@ func (apiHandler *APIHandler) handleGetDaemonSetServices(request *restful.Reques
 
 	namespace := request.PathParameter("namespace")
 	daemonSet := request.PathParameter("daemonSet")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := daemonset.GetDaemonSetServices(k8sClient, dataSelect, namespace, daemonSet)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2044,7 +2321,11 @@ func (apiHandler *APIHandler) handleGetDaemonSetEvents(request *restful.Request,
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("daemonSet")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := event.GetResourceEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2062,7 +2343,11 @@ func (apiHandler *APIHandler) handleGetHorizontalPodAutoscalerList(request *rest
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := horizontalpodautoscaler.GetHorizontalPodAutoscalerList(k8sClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2096,8 +2381,12 @@ func (apiHandler *APIHandler) handleGetJobList(request *restful.Request, respons
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := job.GetJobList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2115,9 +2404,13 @@ func (apiHandler *APIHandler) handleGetJobDetail(request *restful.Request, respo
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := job.GetJobDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := job.GetJobDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name,
+		dataselect.NewStandardMetricQuery(metricQuery))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -2134,8 +2427,12 @@ func (apiHandler *APIHandler) handleGetJobPods(request *restful.Request, respons
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := job.GetJobPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2153,7 +2450,11 @@ func (apiHandler *APIHandler) handleGetJobEvents(request *restful.Request, respo
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := job.GetJobEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2170,8 +2471,12 @@ func (apiHandler *APIHandler) handleGetCronJobList(request *restful.Request, res
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := cronjob.GetCronJobList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2189,8 +2494,12 @@ func (apiHandler *APIHandler) handleGetCronJobDetail(request *restful.Request, r
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.MetricQuery = dataselect.NewStandardMetricQuery(dataSelect.MetricQuery)
 	result, err := cronjob.GetCronJobDetail(k8sClient, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2213,7 +2522,11 @@ func (apiHandler *APIHandler) handleGetCronJobJobs(request *restful.Request, res
 		active = false
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := cronjob.GetCronJobJobs(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, namespace, name, active)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2231,7 +2544,11 @@ func (apiHandler *APIHandler) handleGetCronJobEvents(request *restful.Request, r
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("name")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := cronjob.GetCronJobEvents(k8sClient, dataSelect, namespace, name)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2264,7 +2581,11 @@ func (apiHandler *APIHandler) handleGetStorageClassList(request *restful.Request
 		return
 	}
 
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := storageclass.GetStorageClassList(k8sClient, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -2298,7 +2619,11 @@ func (apiHandler *APIHandler) handleGetStorageClassPersistentVolumes(request *re
 	}
 
 	name := request.PathParameter("storageclass")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := persistentvolume.GetStorageClassPersistentVolumes(k8sClient,
 		name, dataSelect)
 	if err != nil {
@@ -2318,7 +2643,11 @@ func (apiHandler *APIHandler) handleGetPodPersistentVolumeClaims(request *restfu
 
 	name := request.PathParameter("pod")
 	namespace := request.PathParameter("namespace")
-	dataSelect := parseDataSelectPathParameter(request)
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
 	result, err := persistentvolumeclaim.GetPodPersistentVolumeClaims(k8sClient,
 		namespace, name, dataSelect)
 	if err != nil {
@@ -2411,6 +2740,33 @@ func (apiHandler *APIHandler) handleLogFile(request *restful.Request, response *
 	handleDownload(response, logStream)
 }
 
+func (apiHandler *APIHandler) handleGetRightSizingReport(request *restful.Request, response *restful.Response) {
+	k8sClient, err := apiHandler.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	percentile, err := parsePercentileParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := rightsizing.GetRightSizingReport(k8sClient, apiHandler.iManager.Metric().Client(),
+		namespace, percentile, metricQuery.TimeRange)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 // parseNamespacePathParameter parses namespace selector for list pages in path parameter.
 // The namespace selector is a comma separated list of namespaces that are trimmed.
 // No namespaces means "view all user namespaces", i.e., everything except kube-system.
@@ -2452,7 +2808,7 @@ func parseSortPathParameter(request *restful.Request) *dataselect.SortQuery {
 }
 
 // Parses query parameters of the request and returns a MetricQuery object
-func parseMetricPathParameter(request *restful.Request) *dataselect.MetricQuery {
+func parseMetricPathParameter(request *restful.Request) (*dataselect.MetricQuery, error) {
 	metricNamesParam := request.QueryParameter("metricNames")
 	var metricNames []string
 	if metricNamesParam != "" {
@@ -2469,17 +2825,44 @@ func parseMetricPathParameter(request *restful.Request) *dataselect.MetricQuery
 	}
 	aggregationModes := metricapi.AggregationModes{}
 	for _, e := range rawAggregations {
-		aggregationModes = append(aggregationModes, metricapi.AggregationMode(e))
+		aggregationMode, err := metricapi.ParseAggregationMode(e)
+		if err != nil {
+			return nil, k8sErrors.NewBadRequest(err.Error())
+		}
+		aggregationModes = append(aggregationModes, aggregationMode)
+	}
+	timeRange, err := metricapi.ParseTimeRange(request.QueryParameter("start"),
+		request.QueryParameter("end"), request.QueryParameter("resolution"), time.Now())
+	if err != nil {
+		return nil, k8sErrors.NewBadRequest(err.Error())
 	}
-	return dataselect.NewMetricQuery(metricNames, aggregationModes)
+	metricQuery := dataselect.NewMetricQuery(metricNames, aggregationModes)
+	metricQuery.TimeRange = timeRange
+	return metricQuery, nil
+}
 
+// parsePercentileParameter parses the usage percentile of the right-sizing report, which defaults
+// to rightsizing.DefaultPercentile.
+func parsePercentileParameter(request *restful.Request) (int, error) {
+	percentileParam := request.QueryParameter("percentile")
+	if percentileParam == "" {
+		return rightsizing.DefaultPercentile, nil
+	}
+	percentile, err := strconv.Atoi(percentileParam)
+	if err != nil || percentile < 1 || percentile > 100 {
+		return 0, k8sErrors.NewBadRequest("Invalid percentile: " + percentileParam)
+	}
+	return percentile, nil
 }
 
 // Parses query parameters of the request and returns a DataSelectQuery object
-func parseDataSelectPathParameter(request *restful.Request) *dataselect.DataSelectQuery {
+func parseDataSelectPathParameter(request *restful.Request) (*dataselect.DataSelectQuery, error) {
 	paginationQuery := parsePaginationPathParameter(request)
 	sortQuery := parseSortPathParameter(request)
 	filterQuery := parseFilterPathParameter(request)
-	metricQuery := parseMetricPathParameter(request)
-	return dataselect.NewDataSelectQuery(paginationQuery, sortQuery, filterQuery, metricQuery)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		return nil, err
+	}
+	return dataselect.NewDataSelectQuery(paginationQuery, sortQuery, filterQuery, metricQuery), nil
 }
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"bytes"
 	"reflect"
@@ -29,9 +30,11 @@ import (
 	authApi "github.com/kubernetes/dashboard/src/app/backend/auth/api"
 	"github.com/kubernetes/dashboard/src/app/backend/auth/jwe"
 	"github.com/kubernetes/dashboard/src/app/backend/client"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
 	"github.com/kubernetes/dashboard/src/app/backend/settings"
 	"github.com/kubernetes/dashboard/src/app/backend/sync"
 	"github.com/kubernetes/dashboard/src/app/backend/systembanner"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -171,3 +174,71 @@ func TestFormatRequestLog(t *testing.T) {
 		}
 	}
 }
+
+func TestParseMetricPathParameter(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2018-06-01T00:00:00Z")
+	cases := []struct {
+		uri          string
+		aggregations metricapi.AggregationModes
+		timeRange    metricapi.TimeRange
+		badRequest   bool
+	}{
+		{"/api/v1/pod?aggregations=sum,avg,p90", metricapi.AggregationModes{"sum", "avg", "p90"},
+			metricapi.DefaultTimeRange, false},
+		{"/api/v1/pod", metricapi.AggregationModes{}, metricapi.DefaultTimeRange, false},
+		{"/api/v1/pod?aggregations=p95", nil, metricapi.DefaultTimeRange, true},
+		{"/api/v1/pod?start=2018-06-01T00:00:00Z&end=2018-06-02T00:00:00Z&resolution=5m",
+			metricapi.AggregationModes{},
+			metricapi.TimeRange{Start: start, End: start.Add(24 * time.Hour), Resolution: 5 * time.Minute}, false},
+		{"/api/v1/pod?start=2018-06-02T00:00:00Z&end=2018-06-01T00:00:00Z", nil, metricapi.DefaultTimeRange, true},
+		{"/api/v1/pod?start=yesterday", nil, metricapi.DefaultTimeRange, true},
+		{"/api/v1/pod?resolution=0s", nil, metricapi.DefaultTimeRange, true},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.uri, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, err := parseMetricPathParameter(restful.NewRequest(req))
+		if c.badRequest {
+			if !k8sErrors.IsBadRequest(err) {
+				t.Errorf("parseMetricPathParameter(%s) expected bad request, got %v", c.uri, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(query.Aggregations, c.aggregations) ||
+			!reflect.DeepEqual(query.TimeRange, c.timeRange) {
+			t.Errorf("parseMetricPathParameter(%s) == %v, %v, expected %v, %v", c.uri, query, err,
+				c.aggregations, c.timeRange)
+		}
+	}
+}
+
+func TestParsePercentileParameter(t *testing.T) {
+	cases := []struct {
+		uri        string
+		percentile int
+		badRequest bool
+	}{
+		{"/api/v1/rightsizing/default", 90, false},
+		{"/api/v1/rightsizing/default?percentile=99", 99, false},
+		{"/api/v1/rightsizing/default?percentile=0", 0, true},
+		{"/api/v1/rightsizing/default?percentile=high", 0, true},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.uri, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		percentile, err := parsePercentileParameter(restful.NewRequest(req))
+		if c.badRequest {
+			if !k8sErrors.IsBadRequest(err) {
+				t.Errorf("parsePercentileParameter(%s) expected bad request, got %v", c.uri, err)
+			}
+			continue
+		}
+		if err != nil || percentile != c.percentile {
+			t.Errorf("parsePercentileParameter(%s) == %d, %v, expected %d", c.uri, percentile, err, c.percentile)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package api
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/kubernetes/dashboard/src/app/backend/api"
@@ -28,13 +29,15 @@ import (
 type MetricClient interface {
 	// DownloadMetric returns MetricPromises for specified list of selector, for single type
 	// of metric, i.e. cpu usage. Cached resources is usually list of pods as other high level
-	// resources do not directly provide metrics. Only pods targeted by them.
-	DownloadMetric(selectors []ResourceSelector, metricName string,
-		cachedResources *CachedResources) MetricPromises
+	// resources do not directly provide metrics. Only pods targeted by them. Time range limits
+	// the window and resolution of the downloaded data points. Aggregation combines the data
+	// points of the native resources of a selector, e.g. the pods of a deployment, sum if empty.
+	DownloadMetric(selectors []ResourceSelector, metricName string, timeRange TimeRange,
+		aggregation AggregationMode, cachedResources *CachedResources) MetricPromises
 	// DownloadMetrics is similar to DownloadMetric method. It returns MetricPromises for
 	// given list of metrics, i.e. cpu/memory usage instead of single metric type.
-	DownloadMetrics(selectors []ResourceSelector, metricNames []string,
-		cachedResources *CachedResources) MetricPromises
+	DownloadMetrics(selectors []ResourceSelector, metricNames []string, timeRange TimeRange,
+		aggregation AggregationMode, cachedResources *CachedResources) MetricPromises
 	// AggregateMetrics is used to aggregate previously downloaded metrics based on
 	// aggregation mode (sum, min, avg). It is used to show cumulative metric graphs on
 	// resource list pages.
@@ -56,7 +59,69 @@ type CachedResources struct {
 
 var NoResourceCache = &CachedResources{}
 
-// AggregationMode informs how data should be aggregated (sum, min, max)
+// TimeRange limits downloaded metrics to the samples between Start and End, one sample every
+// Resolution. Zero values leave the default window and resolution of the metric client in place.
+type TimeRange struct {
+	// Start of the range. If zero, the default window of the metric client is used.
+	Start time.Time
+	// End of the range. If zero, it is the current time.
+	End time.Time
+	// Resolution is the interval between two samples. If zero, the samples are not downsampled.
+	Resolution time.Duration
+}
+
+var DefaultTimeRange = TimeRange{}
+
+// Contains returns true if given timestamp is within the range.
+func (self TimeRange) Contains(timestamp time.Time) bool {
+	return (self.Start.IsZero() || !timestamp.Before(self.Start)) &&
+		(self.End.IsZero() || !timestamp.After(self.End))
+}
+
+// ParseTimeRange returns the time range of given raw start, end and resolution. Start and end are
+// either RFC3339 timestamps or durations before now, i.e. start=6h selects the last 6 hours.
+// Resolution is a duration of at least one second. Empty values are left unset.
+func ParseTimeRange(start, end, resolution string, now time.Time) (TimeRange, error) {
+	var err error
+	result := TimeRange{}
+	if result.Start, err = parseTime("start", start, now); err != nil {
+		return result, err
+	}
+	if result.End, err = parseTime("end", end, now); err != nil {
+		return result, err
+	}
+	if !result.Start.IsZero() {
+		effectiveEnd := result.End
+		if effectiveEnd.IsZero() {
+			effectiveEnd = now
+		}
+		if !result.Start.Before(effectiveEnd) {
+			return result, fmt.Errorf("Start of the time range must be before its end")
+		}
+	}
+	if resolution != "" {
+		result.Resolution, err = time.ParseDuration(resolution)
+		if err != nil || result.Resolution < time.Second {
+			return result, fmt.Errorf("Invalid resolution: %s", resolution)
+		}
+	}
+	return result, nil
+}
+
+func parseTime(name, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid %s: %s", name, value)
+}
+
+// AggregationMode informs how data should be aggregated (sum, min, max, avg, p50, p90, p99)
 type AggregationMode string
 
 // Aggregation modes which should be used for data aggregation. Eg. [sum, min, max].
@@ -64,6 +129,10 @@ const (
 	SumAggregation     = "sum"
 	MaxAggregation     = "max"
 	MinAggregation     = "min"
+	AvgAggregation     = "avg"
+	P50Aggregation     = "p50"
+	P90Aggregation     = "p90"
+	P99Aggregation     = "p99"
 	DefaultAggregation = SumAggregation
 )
 
@@ -76,6 +145,20 @@ var AggregatingFunctions = map[AggregationMode]func([]int64) int64{
 	SumAggregation: SumAggregate,
 	MaxAggregation: MaxAggregate,
 	MinAggregation: MinAggregate,
+	AvgAggregation: AvgAggregate,
+	P50Aggregation: PercentileAggregate(50),
+	P90Aggregation: PercentileAggregate(90),
+	P99Aggregation: PercentileAggregate(99),
+}
+
+// ParseAggregationMode returns the aggregation mode of given name, or an error when there is no
+// aggregating function for it.
+func ParseAggregationMode(name string) (AggregationMode, error) {
+	mode := AggregationMode(name)
+	if _, exists := AggregatingFunctions[mode]; !exists {
+		return "", fmt.Errorf("Unknown aggregation mode: %s", name)
+	}
+	return mode, nil
 }
 
 // DerivedResources is a map from a derived resource(a resource that is not supported by heapster)
@@ -294,3 +377,23 @@ func MinAggregate(values []int64) int64 {
 	}
 	return result
 }
+
+func AvgAggregate(values []int64) int64 {
+	return SumAggregate(values) / int64(len(values))
+}
+
+// PercentileAggregate returns a function aggregating values to their given percentile, using the
+// nearest-rank method, e.g. p90 of 10 pods is the usage of the 9th least consuming pod.
+func PercentileAggregate(percentile int) func([]int64) int64 {
+	return func(values []int64) int64 {
+		sorted := make([]int64, len(values))
+		copy(sorted, values)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		rank := (percentile*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+}
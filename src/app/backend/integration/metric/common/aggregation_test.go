@@ -276,3 +276,31 @@ func TestAggregateMetricPromises(t *testing.T) {
 		}
 	}
 }
+
+func TestAggregateDataModes(t *testing.T) {
+	metrics := make([]metricapi.Metric, 0)
+	for i, y := range []int64{40, 10, 30, 20, 100} {
+		metrics = append(metrics, metricapi.Metric{
+			DataPoints: []metricapi.DataPoint{{X: 0, Y: y}, {X: 60, Y: y * 2}},
+			MetricName: metricapi.CpuUsage,
+			Label:      metricapi.Label{api.ResourceKindPod: []types.UID{types.UID(rune('a' + i))}},
+		})
+	}
+
+	cases := []struct {
+		aggregation metricapi.AggregationMode
+		expected    metricapi.DataPoints
+	}{
+		{metricapi.SumAggregation, metricapi.DataPoints{{X: 0, Y: 200}, {X: 60, Y: 400}}},
+		{metricapi.AvgAggregation, metricapi.DataPoints{{X: 0, Y: 40}, {X: 60, Y: 80}}},
+		{metricapi.P50Aggregation, metricapi.DataPoints{{X: 0, Y: 30}, {X: 60, Y: 60}}},
+		{metricapi.P90Aggregation, metricapi.DataPoints{{X: 0, Y: 100}, {X: 60, Y: 200}}},
+		{metricapi.P99Aggregation, metricapi.DataPoints{{X: 0, Y: 100}, {X: 60, Y: 200}}},
+	}
+	for _, c := range cases {
+		actual := AggregateData(metrics, metricapi.CpuUsage, c.aggregation)
+		if !reflect.DeepEqual(actual.DataPoints, c.expected) || actual.Aggregate != c.aggregation {
+			t.Errorf("AggregateData() with %s == %v, expected %v", c.aggregation, actual.DataPoints, c.expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NativeResources are the native resources, i.e. pods or nodes, whose metrics are summed up for a
+// resource.
+type NativeResources struct {
+	ResourceType api.ResourceKind
+	Namespace    string
+	Names        []string
+	UIDs         []types.UID
+}
+
+// GetNativeResources converts the selector of a resource to its native resources. Derived
+// resources are converted to the pods they own, other resources are returned as they are.
+func GetNativeResources(selector metricapi.ResourceSelector,
+	cachedResources *metricapi.CachedResources) (NativeResources, error) {
+	summingResource, isDerivedResource := metricapi.DerivedResources[selector.ResourceType]
+	if !isDerivedResource {
+		return NativeResources{
+			ResourceType: selector.ResourceType,
+			Namespace:    selector.Namespace,
+			Names:        []string{selector.ResourceName},
+			UIDs:         []types.UID{selector.UID},
+		}, nil
+	}
+	if summingResource != api.ResourceKindPod {
+		return NativeResources{}, fmt.Errorf(`Internal Error: Requested summing resources not supported. Requested "%s"`,
+			summingResource)
+	}
+
+	pods, err := GetMyPodsFromCache(selector, cachedResources.Pods)
+	if err != nil {
+		return NativeResources{}, err
+	}
+	native := NativeResources{ResourceType: api.ResourceKindPod, Namespace: selector.Namespace}
+	for _, pod := range pods {
+		native.Names = append(native.Names, pod.Name)
+		native.UIDs = append(native.UIDs, pod.UID)
+	}
+	return native, nil
+}
+
+// GetMyPodsFromCache returns a full list of pods that belong to this resource.
+// It is important that cachedPods include ALL pods from the namespace of this resource (but they
+// can also include pods from other namespaces).
+func GetMyPodsFromCache(selector metricapi.ResourceSelector, cachedPods []v1.Pod) (matchingPods []v1.Pod, err error) {
+	switch {
+	case cachedPods == nil:
+		err = fmt.Errorf(`Pods were not available in cache. Required for resource type: "%s"`,
+			selector.ResourceType)
+	case selector.ResourceType == api.ResourceKindDeployment:
+		for _, pod := range cachedPods {
+			if pod.ObjectMeta.Namespace == selector.Namespace && api.IsSelectorMatching(selector.Selector, pod.Labels) {
+				matchingPods = append(matchingPods, pod)
+			}
+		}
+	default:
+		for _, pod := range cachedPods {
+			if pod.Namespace == selector.Namespace {
+				for _, ownerRef := range pod.OwnerReferences {
+					if ownerRef.Controller != nil && *ownerRef.Controller == true &&
+						ownerRef.UID == selector.UID {
+						matchingPods = append(matchingPods, pod)
+					}
+				}
+			}
+		}
+	}
+	return
+}
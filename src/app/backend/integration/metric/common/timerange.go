@@ -0,0 +1,84 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+)
+
+// SelectTimeRange returns the metric without the points outside of the time range. If the range
+// has a resolution, the remaining points are averaged into buckets of that size. Points are
+// expected to be sorted by time.
+func SelectTimeRange(metric metricapi.Metric, timeRange metricapi.TimeRange) metricapi.Metric {
+	if timeRange == metricapi.DefaultTimeRange {
+		return metric
+	}
+
+	dataPoints := metricapi.DataPoints{}
+	for _, point := range metric.DataPoints {
+		if timeRange.Contains(time.Unix(point.X, 0)) {
+			dataPoints = append(dataPoints, point)
+		}
+	}
+	metricPoints := []metricapi.MetricPoint{}
+	for _, point := range metric.MetricPoints {
+		if timeRange.Contains(point.Timestamp) {
+			metricPoints = append(metricPoints, point)
+		}
+	}
+
+	if resolution := int64(timeRange.Resolution / time.Second); resolution > 0 {
+		dataPoints = downsampleDataPoints(dataPoints, resolution)
+		metricPoints = downsampleMetricPoints(metricPoints, resolution)
+	}
+
+	metric.DataPoints = dataPoints
+	metric.MetricPoints = metricPoints
+	return metric
+}
+
+// downsampleDataPoints averages the data points into buckets of given number of seconds.
+func downsampleDataPoints(points metricapi.DataPoints, resolution int64) metricapi.DataPoints {
+	result := metricapi.DataPoints{}
+	var sum, count int64
+	for i, point := range points {
+		sum += point.Y
+		count++
+		bucket := point.X - point.X%resolution
+		if i+1 == len(points) || points[i+1].X-points[i+1].X%resolution != bucket {
+			result = append(result, metricapi.DataPoint{X: bucket, Y: sum / count})
+			sum, count = 0, 0
+		}
+	}
+	return result
+}
+
+// downsampleMetricPoints averages the metric points into buckets of given number of seconds.
+func downsampleMetricPoints(points []metricapi.MetricPoint, resolution int64) []metricapi.MetricPoint {
+	result := []metricapi.MetricPoint{}
+	var sum, count uint64
+	for i, point := range points {
+		sum += point.Value
+		count++
+		bucket := point.Timestamp.Unix() - point.Timestamp.Unix()%resolution
+		if i+1 == len(points) || points[i+1].Timestamp.Unix()-points[i+1].Timestamp.Unix()%resolution != bucket {
+			result = append(result, metricapi.MetricPoint{Timestamp: time.Unix(bucket, 0).UTC(), Value: sum / count})
+			sum, count = 0, 0
+		}
+	}
+	return result
+}
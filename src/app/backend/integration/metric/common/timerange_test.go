@@ -0,0 +1,75 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+)
+
+func TestSelectTimeRange(t *testing.T) {
+	start := time.Unix(1500000000, 0).UTC()
+	metric := metricapi.Metric{MetricName: metricapi.CpuUsage}
+	for i, value := range []int64{10, 20, 30, 40, 50, 60} {
+		timestamp := start.Add(time.Duration(i) * time.Minute)
+		metric.DataPoints = append(metric.DataPoints, metricapi.DataPoint{X: timestamp.Unix(), Y: value})
+		metric.MetricPoints = append(metric.MetricPoints, metricapi.MetricPoint{Timestamp: timestamp, Value: uint64(value)})
+	}
+
+	cases := []struct {
+		info      string
+		timeRange metricapi.TimeRange
+		expected  []int64
+	}{
+		{"default range keeps all points", metricapi.DefaultTimeRange, []int64{10, 20, 30, 40, 50, 60}},
+		{"window drops outer points",
+			metricapi.TimeRange{Start: start.Add(time.Minute), End: start.Add(3 * time.Minute)}, []int64{20, 30, 40}},
+		{"resolution averages buckets",
+			metricapi.TimeRange{End: start.Add(4 * time.Minute), Resolution: 2 * time.Minute}, []int64{15, 35, 50}},
+	}
+	for _, c := range cases {
+		selected := SelectTimeRange(metric, c.timeRange)
+		dataValues := []int64{}
+		for _, point := range selected.DataPoints {
+			dataValues = append(dataValues, point.Y)
+		}
+		metricValues := []int64{}
+		for _, point := range selected.MetricPoints {
+			metricValues = append(metricValues, int64(point.Value))
+		}
+		if !reflect.DeepEqual(dataValues, c.expected) || !reflect.DeepEqual(metricValues, c.expected) {
+			t.Errorf("Test Case: %s. SelectTimeRange() == %v, %v, expected %v", c.info, dataValues,
+				metricValues, c.expected)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	timeRange, err := metricapi.ParseTimeRange("6h", "", "10m", now)
+	expected := metricapi.TimeRange{Start: now.Add(-6 * time.Hour), Resolution: 10 * time.Minute}
+	if err != nil || !reflect.DeepEqual(timeRange, expected) {
+		t.Errorf("ParseTimeRange() == %v, %v, expected %v", timeRange, err, expected)
+	}
+
+	for _, raw := range [][]string{{"1h", "2h", ""}, {"-1h", "", ""}, {"", "", "500ms"}, {"", "noon", ""}} {
+		if _, err := metricapi.ParseTimeRange(raw[0], raw[1], raw[2], now); err == nil {
+			t.Errorf("ParseTimeRange(%v) expected an error", raw)
+		}
+	}
+}
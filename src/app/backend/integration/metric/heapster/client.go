@@ -56,10 +56,11 @@ func (self heapsterClient) ID() integrationapi.IntegrationID {
 
 // DownloadMetrics implements metric client interface. See MetricClient for more information.
 func (self heapsterClient) DownloadMetrics(selectors []metricapi.ResourceSelector,
-	metricNames []string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	metricNames []string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
 	result := metricapi.MetricPromises{}
 	for _, metricName := range metricNames {
-		collectedMetrics := self.DownloadMetric(selectors, metricName, cachedResources)
+		collectedMetrics := self.DownloadMetric(selectors, metricName, timeRange, aggregation, cachedResources)
 		result = append(result, collectedMetrics...)
 	}
 	return result
@@ -67,12 +68,14 @@ func (self heapsterClient) DownloadMetrics(selectors []metricapi.ResourceSelecto
 
 // DownloadMetric implements metric client interface. See MetricClient for more information.
 func (self heapsterClient) DownloadMetric(selectors []metricapi.ResourceSelector,
-	metricName string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	metricName string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
 	heapsterSelectors := getHeapsterSelectors(selectors, cachedResources)
 
 	// Downloads metric in the fastest possible way by first compressing HeapsterSelectors and later unpacking the result to separate boxes.
 	compressedSelectors, reverseMapping := compress(heapsterSelectors)
-	return self.downloadMetric(heapsterSelectors, compressedSelectors, reverseMapping, metricName)
+	return self.downloadMetric(heapsterSelectors, compressedSelectors, reverseMapping, metricName, timeRange,
+		aggregation)
 }
 
 // AggregateMetrics implements metric client interface. See MetricClient for more information.
@@ -83,12 +86,12 @@ func (self heapsterClient) AggregateMetrics(metrics metricapi.MetricPromises, me
 
 func (self heapsterClient) downloadMetric(heapsterSelectors []heapsterSelector,
 	compressedSelectors []heapsterSelector, reverseMapping map[string][]int,
-	metricName string) metricapi.MetricPromises {
+	metricName string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode) metricapi.MetricPromises {
 	// collect all the required data (as promises)
 	unassignedResourcePromisesList := make([]metricapi.MetricPromises, len(compressedSelectors))
 	for selectorId, compressedSelector := range compressedSelectors {
 		unassignedResourcePromisesList[selectorId] =
-			self.downloadMetricForEachTargetResource(compressedSelector, metricName)
+			self.downloadMetricForEachTargetResource(compressedSelector, metricName, timeRange)
 	}
 	// prepare final result
 	result := metricapi.NewMetricPromises(len(heapsterSelectors))
@@ -106,10 +109,11 @@ func (self heapsterClient) downloadMetric(heapsterSelectors []heapsterSelector,
 			}
 			continue
 		}
+		// heapster serves the data points at its own resolution, downsample them if requested.
 		unassignedResourceMap := map[types.UID]metricapi.Metric{}
 		for _, unassignedMetric := range unassignedResources {
 			unassignedResourceMap[unassignedMetric.
-				Label[selector.TargetResourceType][0]] = unassignedMetric
+				Label[selector.TargetResourceType][0]] = common.SelectTimeRange(unassignedMetric, timeRange)
 		}
 
 		// now, if everything went ok, unpack the metrics into original selectors
@@ -123,7 +127,7 @@ func (self heapsterClient) downloadMetric(heapsterSelectors []heapsterSelector,
 			}
 
 			// aggregate the data for this resource
-			aggregatedMetric := common.AggregateData(requestedResources, metricName, metricapi.SumAggregation)
+			aggregatedMetric := common.AggregateData(requestedResources, metricName, aggregation)
 			result[originalMappingIndex].Metric <- &aggregatedMetric
 			result[originalMappingIndex].Error <- nil
 		}
@@ -134,14 +138,15 @@ func (self heapsterClient) downloadMetric(heapsterSelectors []heapsterSelector,
 
 // downloadMetricForEachTargetResource downloads requested metric for each resource present in HeapsterSelector
 // and returns the result as a list of promises - one promise for each resource. Order of promises returned is the same as order in self.Resources.
-func (self heapsterClient) downloadMetricForEachTargetResource(selector heapsterSelector, metricName string) metricapi.MetricPromises {
+func (self heapsterClient) downloadMetricForEachTargetResource(selector heapsterSelector, metricName string,
+	timeRange metricapi.TimeRange) metricapi.MetricPromises {
 	var notAggregatedMetrics metricapi.MetricPromises
 	if HeapsterAllInOneDownloadConfig[selector.TargetResourceType] {
-		notAggregatedMetrics = self.allInOneDownload(selector, metricName)
+		notAggregatedMetrics = self.allInOneDownload(selector, metricName, timeRange)
 	} else {
 		notAggregatedMetrics = metricapi.MetricPromises{}
 		for i := range selector.Resources {
-			notAggregatedMetrics = append(notAggregatedMetrics, self.ithResourceDownload(selector, metricName, i))
+			notAggregatedMetrics = append(notAggregatedMetrics, self.ithResourceDownload(selector, metricName, timeRange, i))
 		}
 	}
 	return notAggregatedMetrics
@@ -150,11 +155,12 @@ func (self heapsterClient) downloadMetricForEachTargetResource(selector heapster
 // ithResourceDownload downloads metric for ith resource in self.Resources. Use only in case all in 1 download is not supported
 // for this resource type.
 func (self heapsterClient) ithResourceDownload(selector heapsterSelector, metricName string,
-	i int) metricapi.MetricPromise {
+	timeRange metricapi.TimeRange, i int) metricapi.MetricPromise {
 	result := metricapi.NewMetricPromise()
 	go func() {
 		rawResult := heapster.MetricResult{}
-		err := self.unmarshalType(selector.Path+selector.Resources[i]+"/metrics/"+metricName, &rawResult)
+		err := self.unmarshalType(selector.Path+selector.Resources[i]+"/metrics/"+metricName+
+			timeRangeQuery(timeRange), &rawResult)
 		if err != nil {
 			result.Metric <- nil
 			result.Error <- err
@@ -180,14 +186,16 @@ func (self heapsterClient) ithResourceDownload(selector heapsterSelector, metric
 
 // allInOneDownload downloads metrics for all resources present in self.Resources in one request.
 // returns a list of metric promises - one promise for each resource. Order of self.Resources is preserved.
-func (self heapsterClient) allInOneDownload(selector heapsterSelector, metricName string) metricapi.MetricPromises {
+func (self heapsterClient) allInOneDownload(selector heapsterSelector, metricName string,
+	timeRange metricapi.TimeRange) metricapi.MetricPromises {
 	result := metricapi.NewMetricPromises(len(selector.Resources))
 	go func() {
 		if len(selector.Resources) == 0 {
 			return
 		}
 		rawResults := heapster.MetricResultList{}
-		err := self.unmarshalType(selector.Path+strings.Join(selector.Resources, ",")+"/metrics/"+metricName, &rawResults)
+		err := self.unmarshalType(selector.Path+strings.Join(selector.Resources, ",")+"/metrics/"+metricName+
+			timeRangeQuery(timeRange), &rawResults)
 		if err != nil {
 			result.PutMetrics(nil, err)
 			return
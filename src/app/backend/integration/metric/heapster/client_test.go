@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -30,8 +31,16 @@ import (
 	"github.com/kubernetes/dashboard/src/app/backend/client"
 	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
 	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/deployment"
+	apps "k8s.io/api/apps/v1beta2"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
 	heapster "k8s.io/heapster/metrics/api/v1/types"
 )
@@ -288,8 +297,8 @@ func TestDownloadMetric(t *testing.T) {
 	for _, testCase := range testCases {
 		log.Println("-----------\n\n\n", testCase.Info, int(_NumRequests.get()))
 		hClient := heapsterClient{fakeHeapsterClient}
-		promises := hClient.DownloadMetric(testCase.Selectors, "",
-			&metricapi.CachedResources{})
+		promises := hClient.DownloadMetric(testCase.Selectors, "", metricapi.DefaultTimeRange,
+			metricapi.SumAggregation, &metricapi.CachedResources{})
 		metrics, err := hClient.AggregateMetrics(promises, "", nil).GetMetrics()
 		if err != nil {
 			t.Errorf("Test Case: %s. Failed to get metrics - %s", testCase.Info, err)
@@ -412,8 +421,8 @@ func TestDownloadMetrics(t *testing.T) {
 
 		metricPromises := make(metricapi.MetricPromises, 0)
 		for _, metricName := range testCase.MetricNames {
-			promises := hClient.DownloadMetric(selectors, metricName,
-				&metricapi.CachedResources{})
+			promises := hClient.DownloadMetric(selectors, metricName, metricapi.DefaultTimeRange,
+				metricapi.SumAggregation, &metricapi.CachedResources{})
 			promises = hClient.AggregateMetrics(promises, metricName,
 				testCase.AggregationNames)
 			metricPromises = append(metricPromises, promises...)
@@ -488,3 +497,91 @@ func TestCreateHeapsterClient(t *testing.T) {
 		}
 	}
 }
+
+type recordingHeapster struct {
+	FakeHeapster
+	mu    *sync.Mutex
+	paths *[]string
+}
+
+func (self recordingHeapster) Get(path string) RequestInterface {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	*self.paths = append(*self.paths, path)
+	return self.FakeHeapster.Get(path)
+}
+
+func TestDownloadMetricTimeRange(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, fmt.Sprintf(TimeTemplate, 0))
+	timeRange := metricapi.TimeRange{Start: start, End: start.Add(9 * time.Minute), Resolution: 2 * time.Minute}
+	paths := []string{}
+	hClient := heapsterClient{recordingHeapster{fakeHeapsterClient, &sync.Mutex{}, &paths}}
+
+	selectors := []metricapi.ResourceSelector{
+		getResourceSelector("NO_NAMESPACE", api.ResourceKindNode, "N1", "U11"),
+	}
+	metrics, err := hClient.DownloadMetric(selectors, "cpu/usage_rate", timeRange,
+		metricapi.SumAggregation, &metricapi.CachedResources{}).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetric() returned error: %v", err)
+	}
+
+	expectedPath := "/model/nodes/N1/metrics/cpu/usage_rate?end=2016-08-12T11%3A09%3A00Z&start=2016-08-12T11%3A00%3A00Z"
+	if len(paths) != 1 || paths[0] != expectedPath {
+		t.Errorf("DownloadMetric() requested %v, expected %s", paths, expectedPath)
+	}
+	// Points 0 and 5 fall into the first 2 minute bucket, point 10 into the second one.
+	expectedDataPoints := metricapi.DataPoints{
+		{X: TimeTemplateValue, Y: 2},
+		{X: TimeTemplateValue + 120, Y: 10},
+	}
+	if !reflect.DeepEqual(metrics[0].DataPoints, expectedDataPoints) {
+		t.Errorf("DownloadMetric() == %v, expected %v", metrics[0].DataPoints, expectedDataPoints)
+	}
+}
+
+func TestDeploymentListAggregation(t *testing.T) {
+	labels := map[string]string{"app": "test"}
+	objects := []runtime.Object{
+		&apps.Deployment{
+			ObjectMeta: metaV1.ObjectMeta{Name: "test", Namespace: "a", Labels: labels},
+			Spec:       apps.DeploymentSpec{Selector: &metaV1.LabelSelector{MatchLabels: labels}},
+		},
+	}
+	for _, name := range []string{"P1", "P2", "P3"} {
+		objects = append(objects, &v1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "a", Labels: labels, UID: types.UID("U" + name)},
+		})
+	}
+	hClient := heapsterClient{fakeHeapsterClient}
+
+	cases := []struct {
+		aggregations metricapi.AggregationModes
+		expected     []int64
+	}{
+		{nil, []int64{45, 60, 75}},
+		{metricapi.AggregationModes{metricapi.P90Aggregation}, []int64{30, 35, 40}},
+		{metricapi.AggregationModes{metricapi.MinAggregation}, []int64{0, 5, 10}},
+	}
+	for _, c := range cases {
+		dsQuery := dataselect.NewDataSelectQuery(dataselect.NoPagination, dataselect.NoSort,
+			dataselect.NoFilter, dataselect.NewMetricQuery([]string{metricapi.CpuUsage}, c.aggregations))
+		list, err := deployment.GetDeploymentList(fake.NewSimpleClientset(objects...),
+			common.NewNamespaceQuery(nil), dsQuery, hClient)
+		if err != nil {
+			t.Fatalf("GetDeploymentList() returned error: %v", err)
+		}
+
+		if len(list.CumulativeMetrics) != 1 {
+			t.Fatalf("GetDeploymentList(%v) == %v, expected one cumulative metric", c.aggregations,
+				list.CumulativeMetrics)
+		}
+		actual := []int64{}
+		for _, point := range list.CumulativeMetrics[0].DataPoints {
+			actual = append(actual, point.Y)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("GetDeploymentList(%v) == %v, expected %v", c.aggregations, actual, c.expected)
+		}
+	}
+}
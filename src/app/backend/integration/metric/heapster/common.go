@@ -15,6 +15,9 @@
 package heapster
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
 	heapster "k8s.io/heapster/metrics/api/v1/types"
@@ -87,3 +90,19 @@ func toMetricPoints(heapsterMetricPoint []heapster.MetricPoint) []metricapi.Metr
 
 	return metricPoints
 }
+
+// timeRangeQuery returns the query selecting the window of the time range in the heapster model
+// API, or an empty string when the default window is requested.
+func timeRangeQuery(timeRange metricapi.TimeRange) string {
+	query := url.Values{}
+	if !timeRange.Start.IsZero() {
+		query.Set("start", timeRange.Start.UTC().Format(time.RFC3339))
+	}
+	if !timeRange.End.IsZero() {
+		query.Set("end", timeRange.End.UTC().Format(time.RFC3339))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
@@ -15,6 +15,8 @@
 package heapster
 
 import (
+	"net/url"
+
 	"k8s.io/client-go/rest"
 )
 
@@ -42,12 +44,11 @@ type inClusterHeapsterClient struct {
 
 // Get creates request to given path.
 func (c inClusterHeapsterClient) Get(path string) RequestInterface {
-	return c.client.Get().
+	return withSuffix(c.client.Get().
 		Namespace("kube-system").
 		Resource("services").
 		Name("heapster").
-		SubResource("proxy").
-		Suffix("/api/v1/" + path)
+		SubResource("proxy"), "/api/v1/"+path)
 }
 
 // HealthCheck does a health check of the application.
@@ -71,7 +72,7 @@ type remoteHeapsterClient struct {
 
 // Get creates request to given path.
 func (c remoteHeapsterClient) Get(path string) RequestInterface {
-	return c.client.Get().Suffix(path)
+	return withSuffix(c.client.Get(), path)
 }
 
 // HealthCheck does a health check of the application.
@@ -80,3 +81,19 @@ func (self remoteHeapsterClient) HealthCheck() error {
 	_, err := self.Get("healthz").AbsPath("/").DoRaw()
 	return err
 }
+
+// withSuffix appends the path to the request. The query of the path, e.g. the time range of the
+// metrics, is set as request parameters, since the suffix is escaped.
+func withSuffix(request *rest.Request, path string) *rest.Request {
+	u, err := url.Parse(path)
+	if err != nil {
+		return request.Suffix(path)
+	}
+	request = request.Suffix(u.Path)
+	for name, values := range u.Query() {
+		for _, value := range values {
+			request = request.Param(name, value)
+		}
+	}
+	return request
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/emicklei/go-restful/log"
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/common"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -57,7 +58,7 @@ func getHeapsterSelector(selector metricapi.ResourceSelector,
 	// We are dealing with derived resource. Convert derived resource to its native resources.
 	// For example, convert deployment to the list of pod names that belong to this deployment
 	if summingResource == api.ResourceKindPod {
-		myPods, err := getMyPodsFromCache(selector, cachedResources.Pods)
+		myPods, err := common.GetMyPodsFromCache(selector, cachedResources.Pods)
 		if err != nil {
 			return heapsterSelector{}, err
 		}
@@ -69,35 +70,6 @@ func getHeapsterSelector(selector metricapi.ResourceSelector,
 	}
 }
 
-// getMyPodsFromCache returns a full list of pods that belong to this resource.
-// It is important that cachedPods include ALL pods from the namespace of this resource (but they
-// can also include pods from other namespaces).
-func getMyPodsFromCache(selector metricapi.ResourceSelector, cachedPods []v1.Pod) (matchingPods []v1.Pod, err error) {
-	switch {
-	case cachedPods == nil:
-		err = fmt.Errorf(`Pods were not available in cache. Required for resource type: "%s"`,
-			selector.ResourceType)
-	case selector.ResourceType == api.ResourceKindDeployment:
-		for _, pod := range cachedPods {
-			if pod.ObjectMeta.Namespace == selector.Namespace && api.IsSelectorMatching(selector.Selector, pod.Labels) {
-				matchingPods = append(matchingPods, pod)
-			}
-		}
-	default:
-		for _, pod := range cachedPods {
-			if pod.Namespace == selector.Namespace {
-				for _, ownerRef := range pod.OwnerReferences {
-					if ownerRef.Controller != nil && *ownerRef.Controller == true &&
-						ownerRef.UID == selector.UID {
-						matchingPods = append(matchingPods, pod)
-					}
-				}
-			}
-		}
-	}
-	return
-}
-
 // NewHeapsterSelectorFromNativeResource returns new heapster selector for native resources specified in arguments.
 // returns error if requested resource is not native or is not supported.
 func newHeapsterSelectorFromNativeResource(resourceType api.ResourceKind, namespace string,
@@ -23,6 +23,8 @@ import (
 	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
 	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
 	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/heapster"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/metricsserver"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/prometheus"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -35,13 +37,18 @@ type MetricManager interface {
 	// Enable is responsible for switching active client if given integration application id
 	// is found and related application is healthy (we can connect to it).
 	Enable(integrationapi.IntegrationID) error
-	// EnableWithRetry works similar to enable. It runs in a separate thread and tries to enable integration with given
-	// id every 'period' seconds.
-	EnableWithRetry(id integrationapi.IntegrationID, period time.Duration)
+	// EnableWithRetry works similar to enable. It runs in a separate thread and every 'period' seconds enables the
+	// first healthy integration of given ids, which are ordered by priority.
+	EnableWithRetry(ids []integrationapi.IntegrationID, period time.Duration)
 	// List returns list of available metric related integrations.
 	List() []integrationapi.Integration
 	// ConfigureHeapster configures and adds heapster to clients list.
 	ConfigureHeapster(host string) MetricManager
+	// ConfigurePrometheus configures and adds prometheus to clients list.
+	ConfigurePrometheus(host string) MetricManager
+	// ConfigureMetricsServer configures and adds metrics server to clients list, and starts
+	// collecting the recent samples in the background.
+	ConfigureMetricsServer() MetricManager
 }
 
 // Implements MetricManager interface.
@@ -82,26 +89,38 @@ func (self *metricManager) Enable(id integrationapi.IntegrationID) error {
 }
 
 // EnableWithRetry implements metric manager interface. See MetricManager for more information.
-func (self *metricManager) EnableWithRetry(id integrationapi.IntegrationID, period time.Duration) {
+func (self *metricManager) EnableWithRetry(ids []integrationapi.IntegrationID, period time.Duration) {
 	go wait.Forever(func() {
+		if !self.enableFirstHealthy(ids) {
+			log.Printf("No healthy metric client found. Retrying in %d seconds.", period)
+		}
+	}, period*time.Second)
+}
+
+// enableFirstHealthy switches the active client to the first healthy client of given ids and
+// reports whether one was found. Without any healthy client no client is active.
+func (self *metricManager) enableFirstHealthy(ids []integrationapi.IntegrationID) bool {
+	for _, id := range ids {
 		metricClient, exists := self.clients[id]
 		if !exists {
-			log.Printf("Metric client with given id %s does not exist.", id)
-			return
+			continue
 		}
 
 		err := metricClient.HealthCheck()
 		if err != nil {
-			self.active = nil
-			log.Printf("Metric client health check failed: %s. Retrying in %d seconds.", err, period)
-			return
+			log.Printf("Metric client %s health check failed: %s.", id, err)
+			continue
 		}
 
-		if self.active == nil {
+		if self.active == nil || self.active.ID() != id {
 			log.Printf("Successful request to %s", id)
 			self.active = metricClient
 		}
-	}, period*time.Second)
+		return true
+	}
+
+	self.active = nil
+	return false
 }
 
 // List implements metric manager interface. See MetricManager for more information.
@@ -127,6 +146,34 @@ func (self *metricManager) ConfigureHeapster(host string) MetricManager {
 	return self
 }
 
+// ConfigurePrometheus implements metric manager interface. See MetricManager for more information.
+func (self *metricManager) ConfigurePrometheus(host string) MetricManager {
+	kubeClient := self.manager.InsecureClient()
+	metricClient, err := prometheus.CreatePrometheusClient(host, kubeClient)
+	if err != nil {
+		log.Printf("There was an error during prometheus client creation: %s", err.Error())
+		return self
+	}
+
+	self.clients[metricClient.ID()] = metricClient
+	return self
+}
+
+// ConfigureMetricsServer implements metric manager interface. See MetricManager for more information.
+func (self *metricManager) ConfigureMetricsServer() MetricManager {
+	kubeClient := self.manager.InsecureClient()
+	metricClient, err := metricsserver.CreateMetricsServerClient(kubeClient, metricsserver.DefaultWindow,
+		metricsserver.DefaultPeriod)
+	if err != nil {
+		log.Printf("There was an error during metrics server client creation: %s", err.Error())
+		return self
+	}
+
+	go metricClient.Run(metricsserver.DefaultPeriod, wait.NeverStop)
+	self.clients[metricClient.ID()] = metricClient
+	return self
+}
+
 // NewMetricManager creates metric manager.
 func NewMetricManager(manager clientapi.ClientManager) MetricManager {
 	return &metricManager{
@@ -28,9 +28,13 @@ const fakeMetricClientID integrationapi.IntegrationID = "test-id"
 
 type FakeMetricClient struct {
 	healthOk bool
+	id       integrationapi.IntegrationID
 }
 
-func (FakeMetricClient) ID() integrationapi.IntegrationID {
+func (self FakeMetricClient) ID() integrationapi.IntegrationID {
+	if self.id != "" {
+		return self.id
+	}
 	return fakeMetricClientID
 }
 
@@ -43,11 +47,13 @@ func (self FakeMetricClient) HealthCheck() error {
 }
 
 func (self FakeMetricClient) DownloadMetric(selectors []api.ResourceSelector, metricName string,
+	timeRange api.TimeRange, aggregation api.AggregationMode,
 	cachedResources *api.CachedResources) api.MetricPromises {
 	return nil
 }
 
 func (self FakeMetricClient) DownloadMetrics(selectors []api.ResourceSelector, metricNames []string,
+	timeRange api.TimeRange, aggregation api.AggregationMode,
 	cachedResources *api.CachedResources) api.MetricPromises {
 	return nil
 }
@@ -150,3 +156,54 @@ func TestMetricManager_ConfigureHeapster(t *testing.T) {
 		}
 	}
 }
+
+func TestMetricManager_ConfigurePrometheus(t *testing.T) {
+	manager := NewMetricManager(client.NewClientManager("", "http://localhost:8080"))
+	manager.ConfigurePrometheus("")
+
+	if len(manager.List()) != 1 {
+		t.Errorf("Failed to configure prometheus. Expected number of clients to be "+
+			"%d, but got %d.", 1, len(manager.List()))
+	}
+}
+
+func TestMetricManager_EnableFirstHealthy(t *testing.T) {
+	cases := []struct {
+		clients  []api.MetricClient
+		ids      []integrationapi.IntegrationID
+		expected integrationapi.IntegrationID
+	}{
+		{
+			[]api.MetricClient{&FakeMetricClient{healthOk: true, id: "a"}, &FakeMetricClient{healthOk: true, id: "b"}},
+			[]integrationapi.IntegrationID{"a", "b"}, "a",
+		},
+		{
+			[]api.MetricClient{&FakeMetricClient{healthOk: false, id: "a"}, &FakeMetricClient{healthOk: true, id: "b"}},
+			[]integrationapi.IntegrationID{"a", "b"}, "b",
+		},
+		{
+			[]api.MetricClient{&FakeMetricClient{healthOk: true, id: "b"}},
+			[]integrationapi.IntegrationID{"a", "b"}, "b",
+		},
+		{
+			[]api.MetricClient{&FakeMetricClient{healthOk: false, id: "a"}, &FakeMetricClient{healthOk: false, id: "b"}},
+			[]integrationapi.IntegrationID{"a", "b"}, "",
+		},
+	}
+
+	for _, c := range cases {
+		manager := NewMetricManager(nil).(*metricManager)
+		for _, metricClient := range c.clients {
+			manager.AddClient(metricClient)
+		}
+		found := manager.enableFirstHealthy(c.ids)
+
+		var active integrationapi.IntegrationID
+		if manager.Client() != nil {
+			active = manager.Client().ID()
+		}
+		if active != c.expected || found != (c.expected != "") {
+			t.Errorf("Expected %q to be enabled, but got %q.", c.expected, active)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/common"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultWindow is how long the samples are kept, as heapster model keeps them.
+	DefaultWindow = 15 * time.Minute
+	// DefaultPeriod is how often the samples are collected, metrics-server refreshes the usage
+	// every minute by default.
+	DefaultPeriod = time.Minute
+)
+
+// MetricsServerClient implements MetricClient and Integration interfaces. As metrics-server only
+// serves the current usage, the usage is collected periodically and the recent samples are kept
+// in memory.
+type MetricsServerClient struct {
+	client MetricsAPIClient
+	store  *store
+	now    func() time.Time
+}
+
+// Implement Integration interface.
+
+// HealthCheck implements integration app interface. See Integration interface for more information.
+func (self *MetricsServerClient) HealthCheck() error {
+	if self.client == nil {
+		return errors.New("Metrics server not configured")
+	}
+
+	return self.client.HealthCheck()
+}
+
+// ID implements integration app interface. See Integration interface for more information.
+func (self *MetricsServerClient) ID() integrationapi.IntegrationID {
+	return integrationapi.MetricsServerIntegrationID
+}
+
+// Implement MetricClient interface
+
+// DownloadMetrics implements metric client interface. See MetricClient for more information.
+func (self *MetricsServerClient) DownloadMetrics(selectors []metricapi.ResourceSelector,
+	metricNames []string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		collectedMetrics := self.DownloadMetric(selectors, metricName, timeRange, aggregation, cachedResources)
+		result = append(result, collectedMetrics...)
+	}
+	return result
+}
+
+// DownloadMetric implements metric client interface. See MetricClient for more information.
+// The samples are served from memory, so the promises are fulfilled right away.
+func (self *MetricsServerClient) DownloadMetric(selectors []metricapi.ResourceSelector,
+	metricName string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.NewMetricPromises(len(selectors))
+	for i, selector := range selectors {
+		metric, err := self.getMetric(selector, metricName, timeRange, aggregation, cachedResources)
+		result[i].Metric <- metric
+		result[i].Error <- err
+	}
+	return result
+}
+
+// AggregateMetrics implements metric client interface. See MetricClient for more information.
+func (self *MetricsServerClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return common.AggregateMetricPromises(metrics, metricName, aggregations, nil)
+}
+
+// getMetric aggregates the samples of the native resources of the selector within the time range.
+// Only the samples kept in memory can be returned, the range does not extend the window.
+func (self *MetricsServerClient) getMetric(selector metricapi.ResourceSelector, metricName string,
+	timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) (*metricapi.Metric, error) {
+	value, ok := sampleValues[metricName]
+	if !ok {
+		return nil, fmt.Errorf(`Metric "%s" is not supported by metrics server client`, metricName)
+	}
+	native, err := common.GetNativeResources(selector, cachedResources)
+	if err != nil {
+		return nil, err
+	}
+	if native.ResourceType != api.ResourceKindPod && native.ResourceType != api.ResourceKindNode {
+		return nil, fmt.Errorf(`Resource "%s" is not supported by metrics server client`, native.ResourceType)
+	}
+
+	metrics := make([]metricapi.Metric, 0, len(native.Names))
+	for i, name := range native.Names {
+		samples := self.store.get(storeKey(native.ResourceType, native.Namespace, name))
+		if len(samples) == 0 {
+			continue
+		}
+		metric := metricapi.Metric{
+			DataPoints:   metricapi.DataPoints{},
+			MetricPoints: []metricapi.MetricPoint{},
+			MetricName:   metricName,
+			Label:        metricapi.Label{native.ResourceType: []types.UID{native.UIDs[i]}},
+		}
+		for _, s := range samples {
+			metric.DataPoints = append(metric.DataPoints, metricapi.DataPoint{X: s.timestamp.Unix(), Y: value(s)})
+			metric.MetricPoints = append(metric.MetricPoints, metricapi.MetricPoint{
+				Timestamp: s.timestamp,
+				Value:     uint64(value(s)),
+			})
+		}
+		metrics = append(metrics, common.SelectTimeRange(metric, timeRange))
+	}
+
+	aggregatedMetric := common.AggregateData(metrics, metricName, aggregation)
+	return &aggregatedMetric, nil
+}
+
+// sampleValues are the supported metrics, in the units of heapster.
+var sampleValues = map[string]func(sample) int64{
+	metricapi.CpuUsage:    func(s sample) int64 { return s.cpu },
+	metricapi.MemoryUsage: func(s sample) int64 { return s.memory },
+}
+
+func storeKey(resourceType api.ResourceKind, namespace, name string) string {
+	if resourceType == api.ResourceKindNode {
+		return string(resourceType) + "/" + name
+	}
+	return string(resourceType) + "/" + namespace + "/" + name
+}
+
+// Run collects the usage of the pods and nodes every period until the stop channel is closed.
+func (self *MetricsServerClient) Run(period time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := self.collect(); err != nil {
+			log.Printf("Failed to collect metrics from metrics server: %s", err.Error())
+		}
+	}, period, stopCh)
+}
+
+// collect stores the current usage of the pods and nodes. All the samples of a collection have
+// the same timestamp, so that the usage of several resources can be summed up.
+func (self *MetricsServerClient) collect() error {
+	now := self.now().Truncate(time.Second)
+
+	pods, err := self.client.PodMetrics()
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		s := sample{timestamp: now}
+		for _, container := range pod.Containers {
+			cpu, memory := usage(container.Usage)
+			s.cpu += cpu
+			s.memory += memory
+		}
+		self.store.add(storeKey(api.ResourceKindPod, pod.Namespace, pod.Name), s)
+	}
+
+	nodes, err := self.client.NodeMetrics()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes.Items {
+		cpu, memory := usage(node.Usage)
+		self.store.add(storeKey(api.ResourceKindNode, "", node.Name), sample{timestamp: now, cpu: cpu, memory: memory})
+	}
+
+	self.store.prune(now)
+	return nil
+}
+
+func usage(resources v1.ResourceList) (cpu int64, memory int64) {
+	if quantity, ok := resources[v1.ResourceCPU]; ok {
+		cpu = quantity.MilliValue()
+	}
+	if quantity, ok := resources[v1.ResourceMemory]; ok {
+		memory = quantity.Value()
+	}
+	return
+}
+
+// CreateMetricsServerClient creates new metrics server client, which keeps the samples collected
+// every period during the window. Run has to be started for the samples to be collected.
+func CreateMetricsServerClient(k8sClient kubernetes.Interface, window, period time.Duration) (
+	*MetricsServerClient, error) {
+	if k8sClient == nil {
+		return nil, errors.New("Kubernetes client is required by metrics server client")
+	}
+
+	log.Print("Creating metrics server client")
+	return newMetricsServerClient(apiServerMetricsClient{client: k8sClient.CoreV1().RESTClient()}, window, period), nil
+}
+
+func newMetricsServerClient(client MetricsAPIClient, window, period time.Duration) *MetricsServerClient {
+	return &MetricsServerClient{client: client, store: newStore(window, period), now: time.Now}
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeMetricsAPIClient struct {
+	pods  []PodMetrics
+	nodes []NodeMetrics
+	err   error
+}
+
+func (self *fakeMetricsAPIClient) PodMetrics() (*PodMetricsList, error) {
+	return &PodMetricsList{Items: self.pods}, self.err
+}
+
+func (self *fakeMetricsAPIClient) NodeMetrics() (*NodeMetricsList, error) {
+	return &NodeMetricsList{Items: self.nodes}, self.err
+}
+
+func (self *fakeMetricsAPIClient) HealthCheck() error {
+	return self.err
+}
+
+func usageList(cpu, memory string) v1.ResourceList {
+	return v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+}
+
+func podMetrics(name string, usages ...v1.ResourceList) PodMetrics {
+	metrics := PodMetrics{ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"}}
+	for i, usage := range usages {
+		metrics.Containers = append(metrics.Containers, ContainerMetrics{Name: fmt.Sprintf("container-%d", i), Usage: usage})
+	}
+	return metrics
+}
+
+func TestDownloadMetric(t *testing.T) {
+	fake := &fakeMetricsAPIClient{
+		pods: []PodMetrics{
+			podMetrics("reviews-1", usageList("100m", "1Ki"), usageList("50m", "1Ki")),
+			podMetrics("reviews-2", usageList("200m", "4Ki")),
+		},
+		nodes: []NodeMetrics{{ObjectMeta: metaV1.ObjectMeta{Name: "node-1"}, Usage: usageList("2", "1Mi")}},
+	}
+	client := newMetricsServerClient(fake, 3*time.Minute, time.Minute)
+	now := time.Unix(1530000000, 0)
+	client.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if err := client.collect(); err != nil {
+			t.Fatalf("collect() returned error: %v", err)
+		}
+		now = now.Add(time.Minute)
+		fake.pods[1].Containers[0].Usage = usageList("300m", "4Ki")
+	}
+
+	controller := true
+	pods := []v1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-1", Namespace: "default", UID: "pod-1",
+			OwnerReferences: []metaV1.OwnerReference{{UID: "rs-1", Controller: &controller}}}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-2", Namespace: "default", UID: "pod-2",
+			OwnerReferences: []metaV1.OwnerReference{{UID: "rs-1", Controller: &controller}}}},
+	}
+	selectors := []metricapi.ResourceSelector{
+		{Namespace: "default", ResourceType: api.ResourceKindReplicaSet, ResourceName: "reviews", UID: "rs-1"},
+		{ResourceType: api.ResourceKindNode, ResourceName: "node-1", UID: "node-1"},
+	}
+
+	metrics, err := client.DownloadMetric(selectors, metricapi.CpuUsage, metricapi.DefaultTimeRange,
+		metricapi.SumAggregation, &metricapi.CachedResources{Pods: pods}).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetric() returned error: %v", err)
+	}
+	// the window keeps the last 3 samples
+	expected := metricapi.DataPoints{{X: 1530000060, Y: 450}, {X: 1530000120, Y: 450}, {X: 1530000180, Y: 450}}
+	if !reflect.DeepEqual(metrics[0].DataPoints, expected) {
+		t.Errorf("DownloadMetric() pods == %v, expected %v", metrics[0].DataPoints, expected)
+	}
+	expectedLabel := metricapi.Label{api.ResourceKindPod: []types.UID{"pod-1", "pod-2"}}
+	if !reflect.DeepEqual(metrics[0].Label, expectedLabel) {
+		t.Errorf("DownloadMetric() label == %v, expected %v", metrics[0].Label, expectedLabel)
+	}
+	if len(metrics[1].DataPoints) != 3 || metrics[1].DataPoints[0].Y != 2000 {
+		t.Errorf("DownloadMetric() node == %v, expected 3 points of 2000", metrics[1].DataPoints)
+	}
+
+	metrics, err = client.DownloadMetrics(selectors[1:], []string{metricapi.MemoryUsage}, metricapi.DefaultTimeRange,
+		metricapi.SumAggregation, metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetrics() returned error: %v", err)
+	}
+	if len(metrics[0].MetricPoints) != 3 || metrics[0].MetricPoints[2].Value != 1024*1024 {
+		t.Errorf("DownloadMetrics() == %v, expected 3 metric points of 1Mi", metrics[0].MetricPoints)
+	}
+}
+
+func TestCollectPrunesDeletedResources(t *testing.T) {
+	fake := &fakeMetricsAPIClient{pods: []PodMetrics{podMetrics("reviews-1", usageList("100m", "1Ki"))}}
+	client := newMetricsServerClient(fake, 2*time.Minute, time.Minute)
+	now := time.Unix(1530000000, 0)
+	client.now = func() time.Time { return now }
+
+	client.collect()
+	fake.pods = nil
+	now = now.Add(3 * time.Minute)
+	client.collect()
+
+	if samples := client.store.get(storeKey(api.ResourceKindPod, "default", "reviews-1")); samples != nil {
+		t.Errorf("Expected samples of deleted pod to be pruned, got %v", samples)
+	}
+}
+
+func TestDownloadMetricErrors(t *testing.T) {
+	client := newMetricsServerClient(&fakeMetricsAPIClient{err: errors.New("unavailable")}, time.Minute, time.Minute)
+	if err := client.HealthCheck(); err == nil {
+		t.Error("Expected health check to fail")
+	}
+	if err := client.collect(); err == nil {
+		t.Error("Expected collect to fail")
+	}
+
+	cases := []struct {
+		selector   metricapi.ResourceSelector
+		metricName string
+	}{
+		{metricapi.ResourceSelector{ResourceType: api.ResourceKindService, ResourceName: "reviews"}, metricapi.CpuUsage},
+		{metricapi.ResourceSelector{ResourceType: api.ResourceKindPod, ResourceName: "reviews-1"}, "network/tx_rate"},
+	}
+	for _, c := range cases {
+		_, err := client.DownloadMetric([]metricapi.ResourceSelector{c.selector}, c.metricName,
+			metricapi.DefaultTimeRange, metricapi.SumAggregation, metricapi.NoResourceCache).GetMetrics()
+		if err == nil {
+			t.Errorf("DownloadMetric(%v, %s) expected an error", c.selector, c.metricName)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"encoding/json"
+
+	"k8s.io/client-go/rest"
+)
+
+// metricsAPIPath is the path of the resource metrics API served by metrics-server.
+const metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+// MetricsAPIClient reads the current resource usage of the pods and nodes of all namespaces.
+type MetricsAPIClient interface {
+	PodMetrics() (*PodMetricsList, error)
+	NodeMetrics() (*NodeMetricsList, error)
+	HealthCheck() error
+}
+
+// apiServerMetricsClient talks with metrics-server through the aggregated API of the apiserver.
+type apiServerMetricsClient struct {
+	client rest.Interface
+}
+
+// PodMetrics implements MetricsAPIClient interface.
+func (self apiServerMetricsClient) PodMetrics() (*PodMetricsList, error) {
+	list := &PodMetricsList{}
+	return list, self.get("pods", list)
+}
+
+// NodeMetrics implements MetricsAPIClient interface.
+func (self apiServerMetricsClient) NodeMetrics() (*NodeMetricsList, error) {
+	list := &NodeMetricsList{}
+	return list, self.get("nodes", list)
+}
+
+// HealthCheck does a health check of the application.
+// Returns nil if connection to application can be established, error object otherwise.
+func (self apiServerMetricsClient) HealthCheck() error {
+	_, err := self.client.Get().AbsPath(metricsAPIPath).DoRaw()
+	return err
+}
+
+func (self apiServerMetricsClient) get(resource string, v interface{}) error {
+	rawData, err := self.client.Get().AbsPath(metricsAPIPath, resource).DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(rawData, v)
+}
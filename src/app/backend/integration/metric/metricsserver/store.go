@@ -0,0 +1,110 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"sync"
+	"time"
+)
+
+// sample is the resource usage of a pod or a node at a point in time, cpu in millicores and
+// memory in bytes.
+type sample struct {
+	timestamp time.Time
+	cpu       int64
+	memory    int64
+}
+
+// ring keeps the latest samples of a resource, overwriting the oldest ones when full.
+type ring struct {
+	samples []sample
+	next    int
+	full    bool
+}
+
+func newRing(capacity int) *ring {
+	return &ring{samples: make([]sample, capacity)}
+}
+
+func (self *ring) add(s sample) {
+	self.samples[self.next] = s
+	self.next = (self.next + 1) % len(self.samples)
+	if self.next == 0 {
+		self.full = true
+	}
+}
+
+// list returns the samples from the oldest to the latest.
+func (self *ring) list() []sample {
+	if !self.full {
+		return append([]sample(nil), self.samples[:self.next]...)
+	}
+	return append(append([]sample(nil), self.samples[self.next:]...), self.samples[:self.next]...)
+}
+
+// latest returns the latest sample, the ring is never empty.
+func (self *ring) latest() sample {
+	return self.samples[(self.next-1+len(self.samples))%len(self.samples)]
+}
+
+// store keeps the recent samples of every pod and node, for the given window.
+type store struct {
+	mu       sync.RWMutex
+	window   time.Duration
+	capacity int
+	series   map[string]*ring
+}
+
+// newStore creates a store keeping the samples collected every period during the window.
+func newStore(window, period time.Duration) *store {
+	capacity := int(window / period)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &store{window: window, capacity: capacity, series: make(map[string]*ring)}
+}
+
+func (self *store) add(key string, s sample) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	series, exists := self.series[key]
+	if !exists {
+		series = newRing(self.capacity)
+		self.series[key] = series
+	}
+	series.add(s)
+}
+
+// get returns the samples of the resource from the oldest to the latest.
+func (self *store) get(key string) []sample {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	series, exists := self.series[key]
+	if !exists {
+		return nil
+	}
+	return series.list()
+}
+
+// prune forgets the resources which have no sample in the window anymore, e.g. deleted pods.
+func (self *store) prune(now time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for key, series := range self.series {
+		if now.Sub(series.latest().timestamp) > self.window {
+			delete(self.series, key)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing(3)
+	var expected []sample
+	for i := int64(0); i < 5; i++ {
+		s := sample{timestamp: time.Unix(i, 0), cpu: i}
+		r.add(s)
+		expected = append(expected, s)
+		if len(expected) > 3 {
+			expected = expected[1:]
+		}
+
+		if actual := r.list(); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("list() after %d samples == %v, expected %v", i+1, actual, expected)
+		}
+		if r.latest() != s {
+			t.Errorf("latest() after %d samples == %v, expected %v", i+1, r.latest(), s)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/client"
+	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Sample is a single value of a time series.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Series is a time series returned by a query, identified by its labels.
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// PrometheusClient runs PromQL queries against Prometheus and implements Integration interface.
+type PrometheusClient struct {
+	client PrometheusRESTClient
+}
+
+// response is the envelope of all the Prometheus HTTP API responses.
+type response struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+}
+
+// Implement Integration interface.
+
+// HealthCheck implements integration app interface. See Integration interface for more information.
+func (self PrometheusClient) HealthCheck() error {
+	if self.client == nil {
+		return errors.New("Prometheus not configured")
+	}
+
+	return self.client.HealthCheck()
+}
+
+// ID implements integration app interface. See Integration interface for more information.
+func (self PrometheusClient) ID() integrationapi.IntegrationID {
+	return integrationapi.PrometheusIntegrationID
+}
+
+// Query evaluates the instant query at the given time. Every returned series has one sample.
+func (self PrometheusClient) Query(query string, at time.Time) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatTime(at))
+	return self.get("query", params)
+}
+
+// QueryRange evaluates the query over the time range with the given resolution step.
+func (self PrometheusClient) QueryRange(query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	return self.get("query_range", params)
+}
+
+func (self PrometheusClient) get(path string, params url.Values) ([]Series, error) {
+	if self.client == nil {
+		return nil, errors.New("Prometheus not configured")
+	}
+
+	body, err := self.client.Get(path, params)
+	result := &response{}
+	// failed queries are reported with an error status code and the reason in the body
+	if jsonErr := json.Unmarshal(body, result); jsonErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, jsonErr
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed: %s: %s", result.ErrorType, result.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(result.Data.ResultType, result.Data.Result)
+}
+
+func parseResult(resultType string, data json.RawMessage) ([]Series, error) {
+	var result []struct {
+		Metric map[string]string `json:"metric"`
+		Value  []interface{}     `json:"value"`
+		Values [][]interface{}   `json:"values"`
+	}
+
+	switch resultType {
+	case "matrix", "vector":
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+	case "scalar":
+		var value []interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		sample, err := parseSample(value)
+		if err != nil {
+			return nil, err
+		}
+		return []Series{{Labels: map[string]string{}, Samples: []Sample{sample}}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Prometheus result type %s", resultType)
+	}
+
+	series := make([]Series, 0, len(result))
+	for _, r := range result {
+		s := Series{Labels: r.Metric, Samples: make([]Sample, 0, len(r.Values))}
+		if r.Value != nil {
+			r.Values = append(r.Values, r.Value)
+		}
+		for _, value := range r.Values {
+			sample, err := parseSample(value)
+			if err != nil {
+				return nil, err
+			}
+			s.Samples = append(s.Samples, sample)
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// parseSample parses a [<unix time>, "<value>"] pair.
+func parseSample(value []interface{}) (Sample, error) {
+	if len(value) != 2 {
+		return Sample{}, fmt.Errorf("invalid sample %v", value)
+	}
+	timestamp, ok := value[0].(float64)
+	if !ok {
+		return Sample{}, fmt.Errorf("invalid sample timestamp %v", value[0])
+	}
+	text, ok := value[1].(string)
+	if !ok {
+		return Sample{}, fmt.Errorf("invalid sample value %v", value[1])
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Sample{}, err
+	}
+	sec, frac := math.Modf(timestamp)
+	return Sample{Timestamp: time.Unix(int64(sec), int64(frac*1e9)).UTC(), Value: v}, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
+
+// CreatePrometheusClient creates new Prometheus client. When host is empty the client talks
+// with the Prometheus of Istio through service proxy.
+func CreatePrometheusClient(host string, k8sClient kubernetes.Interface) (*PrometheusClient, error) {
+	if host == "" && k8sClient != nil {
+		log.Print("Creating in-cluster Prometheus client")
+		c := inClusterPrometheusClient{client: k8sClient.CoreV1().RESTClient()}
+		return &PrometheusClient{client: c}, nil
+	}
+
+	cfg := &rest.Config{Host: host, QPS: client.DefaultQPS, Burst: client.DefaultBurst}
+	restClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Creating remote Prometheus client for %s", host)
+	c := remotePrometheusClient{client: restClient.CoreV1().RESTClient()}
+	return &PrometheusClient{client: c}, nil
+}
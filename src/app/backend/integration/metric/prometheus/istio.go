@@ -0,0 +1,215 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+)
+
+// Names of the Istio RED metrics. Metric values are integers, so the rates are scaled: the request
+// rate is given in requests per 1000 seconds, the error rate in per mille of the requests and the
+// durations in milliseconds.
+const (
+	RequestRate        = "istio/request_rate"
+	ErrorRate          = "istio/error_rate"
+	RequestDurationP50 = "istio/request_duration_p50"
+	RequestDurationP90 = "istio/request_duration_p90"
+	RequestDurationP99 = "istio/request_duration_p99"
+)
+
+const (
+	// versionLabel is the label of the Istio metrics holding the version of the destination app.
+	versionLabel = "destination_version"
+	// minRateInterval is the smallest range the rates are computed over, it has to contain at
+	// least two scrapes of the Istio telemetry.
+	minRateInterval = time.Minute
+)
+
+// redQuery is a PromQL template of one of the RED metrics. The template is given the series
+// selector, the rate interval and the grouping labels.
+type redQuery struct {
+	name     string
+	template string
+	scale    float64
+}
+
+var redQueries = []redQuery{
+	{
+		name:     RequestRate,
+		template: `sum by (%[3]s) (rate(istio_requests_total{%[1]s}[%[2]s]))`,
+		scale:    1000,
+	},
+	{
+		// versions without any failed request have no 5xx series, they get an error rate of 0.
+		name: ErrorRate,
+		template: `(sum by (%[3]s) (rate(istio_requests_total{%[1]s,response_code=~"5.."}[%[2]s]))` +
+			` or sum by (%[3]s) (rate(istio_requests_total{%[1]s}[%[2]s])) * 0)` +
+			` / sum by (%[3]s) (rate(istio_requests_total{%[1]s}[%[2]s]))`,
+		scale: 1000,
+	},
+	durationQuery(RequestDurationP50, 0.5),
+	durationQuery(RequestDurationP90, 0.9),
+	durationQuery(RequestDurationP99, 0.99),
+}
+
+func durationQuery(name string, quantile float64) redQuery {
+	return redQuery{
+		name: name,
+		template: fmt.Sprintf(`histogram_quantile(%v, sum by (le%%[4]s) `+
+			`(rate(istio_request_duration_seconds_bucket{%%[1]s}[%%[2]s])))`, quantile),
+		scale: 1000,
+	}
+}
+
+func (self redQuery) build(selector string, interval time.Duration, groupBy string) string {
+	bucketGroupBy := ""
+	if groupBy != "" {
+		bucketGroupBy = ", " + groupBy
+	}
+	return fmt.Sprintf(self.template, selector, formatDuration(interval), groupBy, bucketGroupBy)
+}
+
+// AppMetrics holds the RED metrics of an application and of each of its versions.
+type AppMetrics struct {
+	// Metrics of all the requests served by the application.
+	Metrics []metricapi.Metric `json:"metrics"`
+	// Versions holds the metrics of the requests served by each version, ordered by version.
+	Versions []VersionMetrics `json:"versions"`
+}
+
+// VersionMetrics holds the RED metrics of one version of an application.
+type VersionMetrics struct {
+	Version string             `json:"version"`
+	Metrics []metricapi.Metric `json:"metrics"`
+}
+
+// GetAppMetrics returns the request rate, error rate and request duration time series of the
+// application and of its versions, as reported by the sidecars of the application's pods.
+func (self PrometheusClient) GetAppMetrics(namespace, appName string, start, end time.Time,
+	step time.Duration) (*AppMetrics, error) {
+	selector := requestSelector(namespace, appName, "")
+	interval := step
+	if interval < minRateInterval {
+		interval = minRateInterval
+	}
+
+	result := &AppMetrics{Metrics: make([]metricapi.Metric, 0), Versions: make([]VersionMetrics, 0)}
+	versions := make(map[string]*VersionMetrics)
+	for _, q := range redQueries {
+		series, err := self.QueryRange(q.build(selector, interval, ""), start, end, step)
+		if err != nil {
+			return nil, err
+		}
+		metric := metricapi.Metric{MetricName: q.name, DataPoints: metricapi.DataPoints{},
+			MetricPoints: []metricapi.MetricPoint{}}
+		if len(series) > 0 {
+			metric = toMetric(q.name, series[0], q.scale)
+		}
+		result.Metrics = append(result.Metrics, metric)
+
+		series, err = self.QueryRange(q.build(selector, interval, versionLabel), start, end, step)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			version := s.Labels[versionLabel]
+			if version == "" {
+				version = "unknown"
+			}
+			if _, ok := versions[version]; !ok {
+				versions[version] = &VersionMetrics{Version: version}
+			}
+			versions[version].Metrics = append(versions[version].Metrics, toMetric(q.name, s, q.scale))
+		}
+	}
+
+	for _, version := range versions {
+		result.Versions = append(result.Versions, *version)
+	}
+	sort.Slice(result.Versions, func(i, j int) bool { return result.Versions[i].Version < result.Versions[j].Version })
+	return result, nil
+}
+
+// RequestRate returns the number of requests per second served by the version of the application
+// over the last window. Implements the request rate source of the version drainer.
+func (self PrometheusClient) RequestRate(namespace, appName, version string, window time.Duration) (float64, error) {
+	return self.instant(redQueries[0].build(requestSelector(namespace, appName, version), window, ""))
+}
+
+// ErrorRate returns the ratio (0-1) of requests failed with a server error served by the version
+// of the application over the last window. Implements the error rate source of the rollouts.
+func (self PrometheusClient) ErrorRate(namespace, appName, version string, window time.Duration) (float64, error) {
+	return self.instant(redQueries[1].build(requestSelector(namespace, appName, version), window, ""))
+}
+
+// instant evaluates the query returning a single value now, no traffic yields 0.
+func (self PrometheusClient) instant(query string) (float64, error) {
+	series, err := self.Query(query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if len(series) == 0 || len(series[0].Samples) == 0 {
+		return 0, nil
+	}
+	value := series[0].Samples[0].Value
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, nil
+	}
+	return value, nil
+}
+
+// requestSelector selects the requests to the application reported by the destination sidecars.
+func requestSelector(namespace, appName, version string) string {
+	matchers := []string{
+		`reporter="destination"`,
+		"destination_workload_namespace=" + strconv.Quote(namespace),
+		"destination_app=" + strconv.Quote(appName),
+	}
+	if version != "" {
+		matchers = append(matchers, versionLabel+"="+strconv.Quote(version))
+	}
+	return strings.Join(matchers, ",")
+}
+
+// toMetric converts the series to a metric, dropping the samples without a value such as the
+// error rate of a period without requests.
+func toMetric(name string, series Series, scale float64) metricapi.Metric {
+	metric := metricapi.Metric{
+		MetricName:   name,
+		DataPoints:   make(metricapi.DataPoints, 0, len(series.Samples)),
+		MetricPoints: make([]metricapi.MetricPoint, 0, len(series.Samples)),
+	}
+	for _, sample := range series.Samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		value := int64(math.Round(sample.Value * scale))
+		metric.DataPoints = append(metric.DataPoints, metricapi.DataPoint{X: sample.Timestamp.Unix(), Y: value})
+		metric.AddMetricPoint(metricapi.MetricPoint{Timestamp: sample.Timestamp, Value: uint64(value)})
+	}
+	return metric
+}
+
+// formatDuration formats the duration as a PromQL range in seconds.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(math.Ceil(d.Seconds())))
+}
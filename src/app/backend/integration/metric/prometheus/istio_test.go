@@ -0,0 +1,201 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+)
+
+const (
+	vectorResponse = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1530000000,"%s"]}]}}`
+	matrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[%s]}}`
+)
+
+func matrixSeries(version string, values ...string) string {
+	labels := ""
+	if version != "" {
+		labels = fmt.Sprintf(`"destination_version":%q`, version)
+	}
+	var samples []string
+	for i, value := range values {
+		samples = append(samples, fmt.Sprintf(`[%d.5,"%s"]`, 1530000000+60*i, value))
+	}
+	return fmt.Sprintf(`{"metric":{%s},"values":[%s]}`, labels, strings.Join(samples, ","))
+}
+
+// fakeResponse is returned by the test server for the queries containing the key.
+type fakeResponse struct {
+	key  string
+	body string
+}
+
+// newServer serves the response of the first key the query contains, and records the requests.
+func newServer(t *testing.T, responses []fakeResponse, requests *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		query := r.URL.Query().Get("query")
+		for _, response := range responses {
+			if strings.Contains(query, response.key) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, response.body)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected query"}`)
+	}))
+}
+
+func newClient(t *testing.T, server *httptest.Server) *PrometheusClient {
+	client, err := CreatePrometheusClient(server.URL, nil)
+	if err != nil {
+		t.Fatalf("CreatePrometheusClient() returned error: %v", err)
+	}
+	return client
+}
+
+func TestRequestAndErrorRate(t *testing.T) {
+	var requests []*http.Request
+	server := newServer(t, []fakeResponse{
+		{`response_code=~"5.."`, fmt.Sprintf(vectorResponse, "0.25")},
+		{"istio_requests_total", fmt.Sprintf(vectorResponse, "12.5")},
+	}, &requests)
+	defer server.Close()
+	client := newClient(t, server)
+
+	rate, err := client.RequestRate("default", "reviews", "v2", 30*time.Second)
+	if err != nil || rate != 12.5 {
+		t.Errorf("RequestRate() == %v, %v, expected 12.5", rate, err)
+	}
+	expected := `sum by () (rate(istio_requests_total{reporter="destination",destination_workload_namespace="default",` +
+		`destination_app="reviews",destination_version="v2"}[30s]))`
+	if query := requests[0].URL.Query().Get("query"); query != expected {
+		t.Errorf("RequestRate() query == %s, expected %s", query, expected)
+	}
+	if requests[0].URL.Path != "/api/v1/query" {
+		t.Errorf("RequestRate() path == %s, expected /api/v1/query", requests[0].URL.Path)
+	}
+
+	rate, err = client.ErrorRate("default", "reviews", "v2", time.Minute)
+	if err != nil || rate != 0.25 {
+		t.Errorf("ErrorRate() == %v, %v, expected 0.25", rate, err)
+	}
+}
+
+func TestInstantWithoutTraffic(t *testing.T) {
+	server := newServer(t, []fakeResponse{
+		{`response_code=~"5.."`, fmt.Sprintf(vectorResponse, "NaN")},
+		{"istio_requests_total", `{"status":"success","data":{"resultType":"vector","result":[]}}`},
+	}, nil)
+	defer server.Close()
+	client := newClient(t, server)
+
+	if rate, err := client.RequestRate("default", "reviews", "v1", time.Minute); err != nil || rate != 0 {
+		t.Errorf("RequestRate() == %v, %v, expected 0", rate, err)
+	}
+	if rate, err := client.ErrorRate("default", "reviews", "v1", time.Minute); err != nil || rate != 0 {
+		t.Errorf("ErrorRate() == %v, %v, expected 0", rate, err)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	server := newServer(t, nil, nil)
+	defer server.Close()
+
+	_, err := newClient(t, server).Query("up", time.Now())
+	if err == nil || err.Error() != "Prometheus query failed: bad_data: unexpected query" {
+		t.Errorf("Query() error == %v, expected the Prometheus error", err)
+	}
+
+	if _, err := (PrometheusClient{}).Query("up", time.Now()); err == nil {
+		t.Error("Query() of not configured client expected to fail")
+	}
+}
+
+func TestGetAppMetrics(t *testing.T) {
+	var requests []*http.Request
+	server := newServer(t, []fakeResponse{
+		{"0.99, sum by (le, destination_version)", fmt.Sprintf(matrixResponse, matrixSeries("v1", "0.120", "0.150"))},
+		{"0.99, sum by (le)", fmt.Sprintf(matrixResponse, matrixSeries("", "0.120", "0.150"))},
+		{"histogram_quantile", fmt.Sprintf(matrixResponse, "")},
+		{`sum by (destination_version) (rate(istio_requests_total{reporter="destination",` +
+			`destination_workload_namespace="default",destination_app="reviews",response_code`, fmt.Sprintf(matrixResponse, "")},
+		{`response_code=~"5.."`, fmt.Sprintf(matrixResponse, matrixSeries("", "NaN", "0.01"))},
+		{"sum by (destination_version)", fmt.Sprintf(matrixResponse,
+			matrixSeries("v2", "0.5")+","+matrixSeries("v1", "1.5", "2"))},
+		{"sum by ()", fmt.Sprintf(matrixResponse, matrixSeries("", "2", "2.5"))},
+	}, &requests)
+	defer server.Close()
+
+	end := time.Unix(1530000600, 0)
+	metrics, err := newClient(t, server).GetAppMetrics("default", "reviews", end.Add(-10*time.Minute), end, 30*time.Second)
+	if err != nil {
+		t.Fatalf("GetAppMetrics() returned error: %v", err)
+	}
+
+	var names []string
+	for _, metric := range metrics.Metrics {
+		names = append(names, metric.MetricName)
+	}
+	expectedNames := []string{RequestRate, ErrorRate, RequestDurationP50, RequestDurationP90, RequestDurationP99}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("GetAppMetrics() metric names == %v, expected %v", names, expectedNames)
+	}
+
+	expectedRate := metricapi.DataPoints{{X: 1530000000, Y: 2000}, {X: 1530000060, Y: 2500}}
+	if !reflect.DeepEqual(metrics.Metrics[0].DataPoints, expectedRate) {
+		t.Errorf("GetAppMetrics() request rate == %v, expected %v", metrics.Metrics[0].DataPoints, expectedRate)
+	}
+	if len(metrics.Metrics[0].MetricPoints) != 2 || metrics.Metrics[0].MetricPoints[1].Value != 2500 ||
+		!metrics.Metrics[0].MetricPoints[1].Timestamp.Equal(time.Unix(1530000060, 5e8)) {
+		t.Errorf("GetAppMetrics() request rate metric points == %v", metrics.Metrics[0].MetricPoints)
+	}
+	expectedErrors := metricapi.DataPoints{{X: 1530000060, Y: 10}}
+	if !reflect.DeepEqual(metrics.Metrics[1].DataPoints, expectedErrors) {
+		t.Errorf("GetAppMetrics() error rate == %v, expected %v", metrics.Metrics[1].DataPoints, expectedErrors)
+	}
+	if len(metrics.Metrics[2].DataPoints) != 0 {
+		t.Errorf("GetAppMetrics() p50 duration == %v, expected no data points", metrics.Metrics[2].DataPoints)
+	}
+	expectedDuration := metricapi.DataPoints{{X: 1530000000, Y: 120}, {X: 1530000060, Y: 150}}
+	if !reflect.DeepEqual(metrics.Metrics[4].DataPoints, expectedDuration) {
+		t.Errorf("GetAppMetrics() p99 duration == %v, expected %v", metrics.Metrics[4].DataPoints, expectedDuration)
+	}
+
+	if len(metrics.Versions) != 2 || metrics.Versions[0].Version != "v1" || metrics.Versions[1].Version != "v2" {
+		t.Fatalf("GetAppMetrics() versions == %#v, expected v1 and v2", metrics.Versions)
+	}
+	v1 := metrics.Versions[0].Metrics
+	if len(v1) != 2 || v1[0].MetricName != RequestRate || v1[1].MetricName != RequestDurationP99 ||
+		!reflect.DeepEqual(v1[0].DataPoints, metricapi.DataPoints{{X: 1530000000, Y: 1500}, {X: 1530000060, Y: 2000}}) {
+		t.Errorf("GetAppMetrics() v1 metrics == %v", v1)
+	}
+
+	params := requests[0].URL.Query()
+	if requests[0].URL.Path != "/api/v1/query_range" || params.Get("start") != "1530000000" ||
+		params.Get("end") != "1530000600" || params.Get("step") != "30" || !strings.Contains(params.Get("query"), "[60s]") {
+		t.Errorf("GetAppMetrics() request == %s", requests[0].URL)
+	}
+}
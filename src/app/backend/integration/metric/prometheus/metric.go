@@ -0,0 +1,212 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/common"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// metricWindow is how far back the metrics are downloaded, as heapster model keeps them.
+	metricWindow = 15 * time.Minute
+	// metricStep is the resolution of the downloaded metrics.
+	metricStep = time.Minute
+	// maxPoints is the maximum number of points of a series Prometheus returns for a range query.
+	maxPoints = 11000
+)
+
+// nameLabels are the labels of the cAdvisor metrics holding the names of the native resources,
+// i.e. the resources the metrics are collected for.
+var nameLabels = map[api.ResourceKind]string{
+	api.ResourceKindPod:  "pod_name",
+	api.ResourceKindNode: "kubernetes_io_hostname",
+}
+
+// metricQueries are the queries of the supported metrics for each native resource, in the units
+// of heapster: millicores for cpu and bytes for memory. %[1]s is the selector of the resources and
+// %[2]s the label holding their names.
+var metricQueries = map[api.ResourceKind]map[string]string{
+	api.ResourceKindPod: {
+		metricapi.CpuUsage:    `sum by (%[2]s) (rate(container_cpu_usage_seconds_total{%[1]s,container_name!="",container_name!="POD"}[1m])) * 1000`,
+		metricapi.MemoryUsage: `sum by (%[2]s) (container_memory_usage_bytes{%[1]s,container_name!="",container_name!="POD"})`,
+	},
+	api.ResourceKindNode: {
+		metricapi.CpuUsage:    `sum by (%[2]s) (rate(container_cpu_usage_seconds_total{%[1]s,id="/"}[1m])) * 1000`,
+		metricapi.MemoryUsage: `sum by (%[2]s) (container_memory_usage_bytes{%[1]s,id="/"})`,
+	},
+}
+
+// Implement MetricClient interface
+
+// DownloadMetrics implements metric client interface. See MetricClient for more information.
+func (self PrometheusClient) DownloadMetrics(selectors []metricapi.ResourceSelector,
+	metricNames []string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		collectedMetrics := self.DownloadMetric(selectors, metricName, timeRange, aggregation, cachedResources)
+		result = append(result, collectedMetrics...)
+	}
+	return result
+}
+
+// DownloadMetric implements metric client interface. See MetricClient for more information.
+func (self PrometheusClient) DownloadMetric(selectors []metricapi.ResourceSelector,
+	metricName string, timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.NewMetricPromises(len(selectors))
+	start, end, step := queryWindow(timeRange, time.Now())
+	for i, selector := range selectors {
+		go func(selector metricapi.ResourceSelector, promise metricapi.MetricPromise) {
+			metric, err := self.downloadMetric(selector, metricName, aggregation, cachedResources, start, end, step)
+			promise.Metric <- metric
+			promise.Error <- err
+		}(selector, result[i])
+	}
+	return result
+}
+
+// AggregateMetrics implements metric client interface. See MetricClient for more information.
+func (self PrometheusClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return common.AggregateMetricPromises(metrics, metricName, aggregations, nil)
+}
+
+// downloadMetric downloads the metric of every native resource of the selector in one query and
+// combines them with the given aggregation.
+func (self PrometheusClient) downloadMetric(selector metricapi.ResourceSelector, metricName string,
+	aggregation metricapi.AggregationMode, cachedResources *metricapi.CachedResources, start, end time.Time,
+	step time.Duration) (*metricapi.Metric, error) {
+	native, err := getNativeSelector(selector, cachedResources)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]metricapi.Metric, 0, len(native.Names))
+	if len(native.Names) > 0 {
+		query, err := native.query(metricName)
+		if err != nil {
+			return nil, err
+		}
+		series, err := self.QueryRange(query, start, end, step)
+		if err != nil {
+			return nil, err
+		}
+
+		byName := make(map[string]Series, len(series))
+		for _, s := range series {
+			byName[s.Labels[nameLabels[native.ResourceType]]] = s
+		}
+		for i, name := range native.Names {
+			if s, ok := byName[name]; ok {
+				metrics = append(metrics, toResourceMetric(s, metricName,
+					metricapi.Label{native.ResourceType: []types.UID{native.UIDs[i]}}))
+			}
+		}
+	}
+
+	aggregatedMetric := common.AggregateData(metrics, metricName, aggregation)
+	return &aggregatedMetric, nil
+}
+
+// queryWindow returns the start, end and step of the range queries for the time range. The step
+// is increased when the range would have more points than Prometheus returns.
+func queryWindow(timeRange metricapi.TimeRange, now time.Time) (time.Time, time.Time, time.Duration) {
+	end := timeRange.End
+	if end.IsZero() {
+		end = now
+	}
+	start := timeRange.Start
+	if start.IsZero() {
+		start = end.Add(-metricWindow)
+	}
+	step := timeRange.Resolution
+	if step == 0 {
+		step = metricStep
+	}
+	if minStep := end.Sub(start) / maxPoints; step < minStep {
+		step = minStep.Truncate(time.Second) + time.Second
+	}
+	return start, end, step
+}
+
+// getNativeSelector converts the selector of a resource to its native resources, which must be
+// supported by the queries.
+func getNativeSelector(selector metricapi.ResourceSelector,
+	cachedResources *metricapi.CachedResources) (nativeSelector, error) {
+	native, err := common.GetNativeResources(selector, cachedResources)
+	if err != nil {
+		return nativeSelector{}, err
+	}
+	if _, ok := metricQueries[native.ResourceType]; !ok {
+		return nativeSelector{}, fmt.Errorf(`Resource "%s" is not supported by Prometheus metric client`,
+			native.ResourceType)
+	}
+	return nativeSelector(native), nil
+}
+
+// nativeSelector identifies the native resources whose metrics are summed up for a resource.
+type nativeSelector common.NativeResources
+
+// query returns the query of the metric for the resources of the selector.
+func (self nativeSelector) query(metricName string) (string, error) {
+	template, ok := metricQueries[self.ResourceType][metricName]
+	if !ok {
+		return "", fmt.Errorf(`Metric "%s" is not supported by Prometheus metric client`, metricName)
+	}
+
+	nameLabel := nameLabels[self.ResourceType]
+	names := make([]string, len(self.Names))
+	for i, name := range self.Names {
+		names[i] = regexp.QuoteMeta(name)
+	}
+	selector := nameLabel + "=~" + strconv.Quote(strings.Join(names, "|"))
+	if self.ResourceType == api.ResourceKindPod {
+		selector = "namespace=" + strconv.Quote(self.Namespace) + "," + selector
+	}
+	return fmt.Sprintf(template, selector, nameLabel), nil
+}
+
+// toResourceMetric converts a series to a metric of the resource identified by the label.
+func toResourceMetric(series Series, metricName string, label metricapi.Label) metricapi.Metric {
+	metric := metricapi.Metric{
+		DataPoints:   metricapi.DataPoints{},
+		MetricPoints: []metricapi.MetricPoint{},
+		MetricName:   metricName,
+		Label:        label,
+	}
+	for _, sample := range series.Samples {
+		if math.IsNaN(sample.Value) || sample.Value < 0 {
+			continue
+		}
+		value := int64(math.Round(sample.Value))
+		metric.DataPoints = append(metric.DataPoints, metricapi.DataPoint{X: sample.Timestamp.Unix(), Y: value})
+		metric.MetricPoints = append(metric.MetricPoints, metricapi.MetricPoint{
+			Timestamp: sample.Timestamp,
+			Value:     uint64(value),
+		})
+	}
+	return metric
+}
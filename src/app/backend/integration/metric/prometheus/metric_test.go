@@ -0,0 +1,141 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func podSeries(label, name string, values ...string) string {
+	var samples []string
+	for i, value := range values {
+		samples = append(samples, fmt.Sprintf(`[%d,"%s"]`, 1530000000+60*i, value))
+	}
+	return fmt.Sprintf(`{"metric":{%q:%q},"values":[%s]}`, label, name, strings.Join(samples, ","))
+}
+
+func TestDownloadMetric(t *testing.T) {
+	var requests []*http.Request
+	server := newServer(t, []fakeResponse{
+		{"container_cpu_usage_seconds_total", fmt.Sprintf(matrixResponse,
+			podSeries("pod_name", "reviews-1", "10.4", "20")+","+podSeries("pod_name", "reviews-2", "5", "NaN"))},
+		{"container_memory_usage_bytes", fmt.Sprintf(matrixResponse, podSeries("kubernetes_io_hostname", "node.1", "1024"))},
+	}, &requests)
+	defer server.Close()
+	client := newClient(t, server)
+
+	controller := true
+	pods := []v1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-1", Namespace: "default", UID: "pod-1",
+			OwnerReferences: []metaV1.OwnerReference{{UID: "rs-1", Controller: &controller}}}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-2", Namespace: "default", UID: "pod-2",
+			OwnerReferences: []metaV1.OwnerReference{{UID: "rs-1", Controller: &controller}}}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "ratings-1", Namespace: "default", UID: "pod-3"}},
+	}
+	selectors := []metricapi.ResourceSelector{
+		{Namespace: "default", ResourceType: api.ResourceKindReplicaSet, ResourceName: "reviews", UID: "rs-1"},
+	}
+
+	metrics, err := client.DownloadMetric(selectors, metricapi.CpuUsage, metricapi.DefaultTimeRange,
+		metricapi.SumAggregation, &metricapi.CachedResources{Pods: pods}).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetric() returned error: %v", err)
+	}
+	expectedQuery := `sum by (pod_name) (rate(container_cpu_usage_seconds_total{namespace="default",` +
+		`pod_name=~"reviews-1|reviews-2",container_name!="",container_name!="POD"}[1m])) * 1000`
+	if query := requests[0].URL.Query().Get("query"); query != expectedQuery {
+		t.Errorf("DownloadMetric() query == %s, expected %s", query, expectedQuery)
+	}
+	if requests[0].URL.Path != "/api/v1/query_range" {
+		t.Errorf("DownloadMetric() path == %s, expected /api/v1/query_range", requests[0].URL.Path)
+	}
+	expectedPoints := metricapi.DataPoints{{X: 1530000000, Y: 15}, {X: 1530000060, Y: 20}}
+	if len(metrics) != 1 || !reflect.DeepEqual(metrics[0].DataPoints, expectedPoints) {
+		t.Errorf("DownloadMetric() == %v, expected data points %v", metrics, expectedPoints)
+	}
+	expectedLabel := metricapi.Label{api.ResourceKindPod: []types.UID{"pod-1", "pod-2"}}
+	if !reflect.DeepEqual(metrics[0].Label, expectedLabel) {
+		t.Errorf("DownloadMetric() label == %v, expected %v", metrics[0].Label, expectedLabel)
+	}
+
+	nodes := []metricapi.ResourceSelector{{ResourceType: api.ResourceKindNode, ResourceName: "node.1", UID: "node-1"}}
+	metrics, err = client.DownloadMetrics(nodes, []string{metricapi.MemoryUsage}, metricapi.DefaultTimeRange,
+		metricapi.SumAggregation, metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetrics() returned error: %v", err)
+	}
+	expectedQuery = `sum by (kubernetes_io_hostname) (container_memory_usage_bytes{kubernetes_io_hostname=~"node\\.1",id="/"})`
+	if query := requests[1].URL.Query().Get("query"); query != expectedQuery {
+		t.Errorf("DownloadMetrics() query == %s, expected %s", query, expectedQuery)
+	}
+	if len(metrics) != 1 || !reflect.DeepEqual(metrics[0].DataPoints, metricapi.DataPoints{{X: 1530000000, Y: 1024}}) {
+		t.Errorf("DownloadMetrics() == %v, expected one data point of 1024", metrics)
+	}
+}
+
+func TestDownloadMetricUnsupported(t *testing.T) {
+	client := &PrometheusClient{}
+	cases := []struct {
+		selector   metricapi.ResourceSelector
+		metricName string
+	}{
+		{metricapi.ResourceSelector{ResourceType: api.ResourceKindService, ResourceName: "reviews"}, metricapi.CpuUsage},
+		{metricapi.ResourceSelector{ResourceType: api.ResourceKindPod, ResourceName: "reviews-1"}, "network/tx_rate"},
+		{metricapi.ResourceSelector{ResourceType: api.ResourceKindDeployment, ResourceName: "reviews"}, metricapi.CpuUsage},
+	}
+	for _, c := range cases {
+		_, err := client.downloadMetric(c.selector, c.metricName, metricapi.SumAggregation,
+			metricapi.NoResourceCache,
+			time.Now().Add(-time.Hour), time.Now(), time.Minute)
+		if err == nil {
+			t.Errorf("downloadMetric(%v, %s) expected an error", c.selector, c.metricName)
+		}
+	}
+}
+
+func TestQueryWindow(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	cases := []struct {
+		info      string
+		timeRange metricapi.TimeRange
+		start     time.Time
+		end       time.Time
+		step      time.Duration
+	}{
+		{"default window", metricapi.DefaultTimeRange, now.Add(-metricWindow), now, metricStep},
+		{"last 24 hours at 5 minutes", metricapi.TimeRange{Start: now.Add(-24 * time.Hour), Resolution: 5 * time.Minute},
+			now.Add(-24 * time.Hour), now, 5 * time.Minute},
+		{"too many points", metricapi.TimeRange{Start: now.Add(-30 * 24 * time.Hour), End: now},
+			now.Add(-30 * 24 * time.Hour), now, 236 * time.Second},
+	}
+	for _, c := range cases {
+		start, end, step := queryWindow(c.timeRange, now)
+		if !start.Equal(c.start) || !end.Equal(c.end) || step != c.step {
+			t.Errorf("Test Case: %s. queryWindow() == %v, %v, %v, expected %v, %v, %v", c.info,
+				start, end, step, c.start, c.end, c.step)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"net/url"
+
+	"k8s.io/client-go/rest"
+)
+
+// PrometheusRESTClient is used to make raw requests to the Prometheus HTTP API.
+type PrometheusRESTClient interface {
+	// Get sends a GET request to the API endpoint given by path, e.g. query_range, with the
+	// given query parameters and returns the raw response body.
+	Get(path string, params url.Values) ([]byte, error)
+	HealthCheck() error
+}
+
+// inClusterPrometheusClient talks with the Prometheus deployed by Istio through service proxy.
+type inClusterPrometheusClient struct {
+	client rest.Interface
+}
+
+// Get implements PrometheusRESTClient interface.
+func (self inClusterPrometheusClient) Get(path string, params url.Values) ([]byte, error) {
+	return withParams(self.proxy().Suffix("/api/v1/"+path), params).DoRaw()
+}
+
+// HealthCheck does a health check of the application.
+// Returns nil if connection to application can be established, error object otherwise.
+func (self inClusterPrometheusClient) HealthCheck() error {
+	_, err := self.proxy().Suffix("/-/healthy").DoRaw()
+	return err
+}
+
+func (self inClusterPrometheusClient) proxy() *rest.Request {
+	return self.client.Get().
+		Namespace("istio-system").
+		Resource("services").
+		Name("prometheus:9090").
+		SubResource("proxy")
+}
+
+// remotePrometheusClient talks with Prometheus through raw RESTClient.
+type remotePrometheusClient struct {
+	client rest.Interface
+}
+
+// Get implements PrometheusRESTClient interface.
+func (self remotePrometheusClient) Get(path string, params url.Values) ([]byte, error) {
+	return withParams(self.client.Get().AbsPath("/api/v1", path), params).DoRaw()
+}
+
+// HealthCheck does a health check of the application.
+// Returns nil if connection to application can be established, error object otherwise.
+func (self remotePrometheusClient) HealthCheck() error {
+	_, err := self.client.Get().AbsPath("/-/healthy").DoRaw()
+	return err
+}
+
+func withParams(request *rest.Request, params url.Values) *rest.Request {
+	for name, values := range params {
+		for _, value := range values {
+			request = request.Param(name, value)
+		}
+	}
+	return request
+}
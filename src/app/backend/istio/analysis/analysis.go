@@ -0,0 +1,370 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	commonApi "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+)
+
+// Codes of the findings.
+const (
+	// SubsetNotFound means a route refers to a subset no destination rule of the host defines.
+	SubsetNotFound = "SubsetNotFound"
+	// GatewayNotFound means a virtual service is bound to a gateway which doesn't exist.
+	GatewayNotFound = "GatewayNotFound"
+	// WeightSumInvalid means the weights of a route's destinations don't sum to 100.
+	WeightSumInvalid = "WeightSumInvalid"
+	// DuplicateHost means several virtual services define the same host for the same gateway.
+	DuplicateHost = "DuplicateHost"
+	// DuplicateDestinationRule means several destination rules are defined for the same host.
+	DuplicateDestinationRule = "DuplicateDestinationRule"
+	// ExternalHostNotFound means a route refers to an external host no service entry declares,
+	// its traffic is dropped.
+	ExternalHostNotFound = "ExternalHostNotFound"
+)
+
+// meshGateway is the reserved gateway name of the sidecars inside the mesh.
+const meshGateway = "mesh"
+
+// Input is the istio configuration to analyze.
+type Input struct {
+	// Namespaces are the analyzed namespaces, references to objects outside of them are not checked.
+	Namespaces *common.NamespaceQuery
+
+	VirtualServices  []v1alpha3.VirtualService
+	DestinationRules []v1alpha3.DestinationRule
+	Gateways         []v1alpha3.Gateway
+	// ServiceEntries may come from any namespace, the ones exported to the namespace of a virtual
+	// service declare its external hosts.
+	ServiceEntries []ServiceEntry
+}
+
+// ServiceEntry is a service entry with the namespaces it's exported to, which the typed client
+// does not know.
+type ServiceEntry struct {
+	v1alpha3.ServiceEntry
+	ExportTo []string
+}
+
+// Analyze checks the configuration and returns its findings, ordered by object and code.
+func Analyze(input *Input) []api.Finding {
+	findings := make([]api.Finding, 0)
+	findings = append(findings, checkSubsets(input)...)
+	findings = append(findings, checkGateways(input)...)
+	findings = append(findings, checkWeights(input)...)
+	findings = append(findings, checkDuplicateHosts(input)...)
+	findings = append(findings, checkDuplicateDestinationRules(input)...)
+	findings = append(findings, checkExternalHosts(input)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i].Object, findings[j].Object
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return findings[i].Code < findings[j].Code
+	})
+	return findings
+}
+
+func checkSubsets(input *Input) []api.Finding {
+	subsets := make(map[string]map[string]bool)
+	for _, dr := range input.DestinationRules {
+		host, _ := resolveHost(dr.Spec.Host, dr.Namespace)
+		if subsets[host] == nil {
+			subsets[host] = make(map[string]bool)
+		}
+		for _, subset := range dr.Spec.Subsets {
+			subsets[host][subset.Name] = true
+		}
+	}
+
+	var findings []api.Finding
+	for _, vs := range input.VirtualServices {
+		for _, dest := range destinations(&vs) {
+			if dest.Subset == "" {
+				continue
+			}
+			host, inCluster := resolveHost(dest.Host, vs.Namespace)
+			// destination rules of the other namespaces are not known
+			if inCluster && !input.Namespaces.Matches(virtualservice.HostNamespace(host)) {
+				continue
+			}
+			if !definesSubset(subsets, host, dest.Subset) {
+				findings = append(findings, newFinding(api.FindingError, SubsetNotFound,
+					fmt.Sprintf("subset %s of host %s is not defined by any destination rule", dest.Subset, dest.Host),
+					commonApi.ResourceKindVirtualService, vs.Namespace, vs.Name))
+			}
+		}
+	}
+	return findings
+}
+
+// definesSubset checks whether a destination rule of the host, or one of a wildcard host matching
+// it, i.e. *.default.svc.cluster.local, defines the subset.
+func definesSubset(subsets map[string]map[string]bool, host, subset string) bool {
+	if subsets[host][subset] {
+		return true
+	}
+	for ruleHost, names := range subsets {
+		if names[subset] && matchesWildcard(ruleHost, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWildcard checks whether the pattern is a wildcard host, i.e. *.googleapis.com, matching
+// the host.
+func matchesWildcard(pattern, host string) bool {
+	return strings.HasPrefix(pattern, "*") && strings.HasSuffix(host, pattern[1:])
+}
+
+func checkGateways(input *Input) []api.Finding {
+	gateways := make(map[string]bool)
+	for _, gateway := range input.Gateways {
+		gateways[gateway.Namespace+"/"+gateway.Name] = true
+	}
+
+	var findings []api.Finding
+	for _, vs := range input.VirtualServices {
+		for _, ref := range vs.Spec.Gateways {
+			if ref == meshGateway {
+				continue
+			}
+			key := gatewayKey(ref, vs.Namespace)
+			if !input.Namespaces.Matches(strings.SplitN(key, "/", 2)[0]) {
+				continue
+			}
+			if !gateways[key] {
+				findings = append(findings, newFinding(api.FindingError, GatewayNotFound,
+					fmt.Sprintf("gateway %s does not exist", ref),
+					commonApi.ResourceKindVirtualService, vs.Namespace, vs.Name))
+			}
+		}
+	}
+	return findings
+}
+
+func checkWeights(input *Input) []api.Finding {
+	var findings []api.Finding
+	for _, vs := range input.VirtualServices {
+		for i, routes := range routeGroups(&vs) {
+			if len(routes) == 0 {
+				continue
+			}
+			var sum int32
+			for _, dest := range routes {
+				sum += dest.Weight
+			}
+			// a single destination without weight receives all the traffic
+			if len(routes) == 1 && (sum == 0 || sum == 100) {
+				continue
+			}
+			if sum != 100 {
+				findings = append(findings, newFinding(api.FindingError, WeightSumInvalid,
+					fmt.Sprintf("weights of route %d sum to %d instead of 100", i, sum),
+					commonApi.ResourceKindVirtualService, vs.Namespace, vs.Name))
+			}
+		}
+	}
+	return findings
+}
+
+func checkDuplicateHosts(input *Input) []api.Finding {
+	owners := make(map[string][]v1alpha3.VirtualService)
+	var keys []string
+	for _, vs := range input.VirtualServices {
+		gateways := vs.Spec.Gateways
+		if len(gateways) == 0 {
+			gateways = []string{meshGateway}
+		}
+		for _, ref := range gateways {
+			if ref != meshGateway {
+				ref = gatewayKey(ref, vs.Namespace)
+			}
+			for _, h := range vs.Spec.Hosts {
+				host, _ := resolveHost(h, vs.Namespace)
+				key := ref + " " + host
+				if _, ok := owners[key]; !ok {
+					keys = append(keys, key)
+				}
+				owners[key] = append(owners[key], vs)
+			}
+		}
+	}
+
+	var findings []api.Finding
+	for _, key := range keys {
+		if len(owners[key]) < 2 {
+			continue
+		}
+		parts := strings.SplitN(key, " ", 2)
+		names := make([]string, 0, len(owners[key]))
+		for _, vs := range owners[key] {
+			names = append(names, vs.Namespace+"/"+vs.Name)
+		}
+		for _, vs := range owners[key] {
+			findings = append(findings, newFinding(api.FindingWarning, DuplicateHost,
+				fmt.Sprintf("host %s of gateway %s is defined by virtual services %s", parts[1], parts[0],
+					strings.Join(names, ", ")),
+				commonApi.ResourceKindVirtualService, vs.Namespace, vs.Name))
+		}
+	}
+	return findings
+}
+
+func checkDuplicateDestinationRules(input *Input) []api.Finding {
+	owners := make(map[string][]v1alpha3.DestinationRule)
+	var hosts []string
+	for _, dr := range input.DestinationRules {
+		host, _ := resolveHost(dr.Spec.Host, dr.Namespace)
+		if _, ok := owners[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		owners[host] = append(owners[host], dr)
+	}
+
+	var findings []api.Finding
+	for _, host := range hosts {
+		if len(owners[host]) < 2 {
+			continue
+		}
+		for _, dr := range owners[host] {
+			findings = append(findings, newFinding(api.FindingWarning, DuplicateDestinationRule,
+				fmt.Sprintf("host %s has %d destination rules, only one of them applies", host, len(owners[host])),
+				commonApi.ResourceKindDestinationRule, dr.Namespace, dr.Name))
+		}
+	}
+	return findings
+}
+
+func checkExternalHosts(input *Input) []api.Finding {
+	var findings []api.Finding
+	for _, vs := range input.VirtualServices {
+		reported := make(map[string]bool)
+		for _, dest := range destinations(&vs) {
+			host, inCluster := resolveHost(dest.Host, vs.Namespace)
+			if inCluster || reported[host] || declared(host, vs.Namespace, input.ServiceEntries) {
+				continue
+			}
+			reported[host] = true
+			findings = append(findings, newFinding(api.FindingError, ExternalHostNotFound,
+				fmt.Sprintf("external host %s is not declared by any service entry", dest.Host),
+				commonApi.ResourceKindVirtualService, vs.Namespace, vs.Name))
+		}
+	}
+	return findings
+}
+
+// declared returns true if a service entry exported to the namespace declares the host, exactly or
+// by a wildcard.
+func declared(host, namespace string, serviceEntries []ServiceEntry) bool {
+	for _, se := range serviceEntries {
+		if !virtualservice.ExportedTo(se.ExportTo, se.Namespace, []string{namespace}) {
+			continue
+		}
+		for _, h := range se.Spec.Hosts {
+			if h == host || matchesWildcard(h, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveHost interprets the host referred to in the namespace and reports whether it is a
+// service of the cluster. As istio does, only short names, i.e. reviews, are interpreted as the
+// services of the namespace, the other names are used as they are.
+func resolveHost(host, namespace string) (string, bool) {
+	if strings.HasSuffix(host, virtualservice.ClusterDomainSuffix()) {
+		return host, true
+	}
+	if host != "*" && !strings.Contains(host, ".") {
+		return virtualservice.FQDN(host, namespace), true
+	}
+	return host, false
+}
+
+// gatewayKey resolves the gateway reference of a virtual service to namespace/name.
+func gatewayKey(ref, namespace string) string {
+	if strings.Contains(ref, "/") {
+		return ref
+	}
+	// <gateway>.<namespace>.svc.cluster.local is accepted by istio as well
+	if parts := strings.Split(ref, "."); len(parts) > 1 {
+		return parts[1] + "/" + parts[0]
+	}
+	return namespace + "/" + ref
+}
+
+// destinations returns the destinations of all the routes of the virtual service.
+func destinations(vs *v1alpha3.VirtualService) []*v1alpha3.Destination {
+	var result []*v1alpha3.Destination
+	for _, routes := range routeGroups(vs) {
+		for _, dest := range routes {
+			if dest.Destination != nil {
+				result = append(result, dest.Destination)
+			}
+		}
+	}
+	for _, http := range vs.Spec.Http {
+		if http.Mirror != nil {
+			result = append(result, http.Mirror)
+		}
+	}
+	return result
+}
+
+// routeGroups returns the weighted destinations of each HTTP, TCP and TLS route.
+func routeGroups(vs *v1alpha3.VirtualService) [][]*v1alpha3.DestinationWeight {
+	var groups [][]*v1alpha3.DestinationWeight
+	for _, http := range vs.Spec.Http {
+		groups = append(groups, http.Route)
+	}
+	for _, tcp := range vs.Spec.Tcp {
+		groups = append(groups, tcp.Route)
+	}
+	for _, tls := range vs.Spec.Tls {
+		groups = append(groups, tls.Route)
+	}
+	return groups
+}
+
+func newFinding(severity api.FindingSeverity, code, message, kind, namespace, name string) api.Finding {
+	return api.Finding{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Object: api.FindingObject{
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func newVirtualService(name string, hosts, gateways []string, routes ...[]*v1alpha3.DestinationWeight) v1alpha3.VirtualService {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1alpha3.VirtualServiceSpec{Hosts: hosts, Gateways: gateways},
+	}
+	for _, route := range routes {
+		vs.Spec.Http = append(vs.Spec.Http, &v1alpha3.HTTPRoute{Route: route})
+	}
+	return vs
+}
+
+func destination(host, subset string, weight int32) *v1alpha3.DestinationWeight {
+	return &v1alpha3.DestinationWeight{
+		Destination: &v1alpha3.Destination{Host: host, Subset: subset},
+		Weight:      weight,
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	reviews := v1alpha3.DestinationRule{
+		ObjectMeta: metaV1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.DestinationRuleSpec{
+			Host:    "reviews",
+			Subsets: []*v1alpha3.Subset{{Name: "v1"}, {Name: "v2"}},
+		},
+	}
+	input := &Input{
+		Namespaces:       common.NewSameNamespaceQuery("default"),
+		DestinationRules: []v1alpha3.DestinationRule{reviews},
+		Gateways: []v1alpha3.Gateway{
+			{ObjectMeta: metaV1.ObjectMeta{Name: "bookinfo", Namespace: "default"}},
+		},
+		ServiceEntries: []ServiceEntry{
+			{ServiceEntry: v1alpha3.ServiceEntry{Spec: v1alpha3.ServiceEntrySpec{Hosts: []string{"*.googleapis.com"}}}},
+		},
+		VirtualServices: []v1alpha3.VirtualService{
+			// valid
+			newVirtualService("reviews", []string{"reviews"}, nil,
+				[]*v1alpha3.DestinationWeight{destination("reviews", "v1", 90), destination("reviews", "v2", 10)}),
+			newVirtualService("bookinfo", []string{"bookinfo.com"}, []string{"bookinfo", "other/gateway"},
+				[]*v1alpha3.DestinationWeight{destination("reviews.default.svc.cluster.local", "v1", 0)},
+				[]*v1alpha3.DestinationWeight{destination("storage.googleapis.com", "", 0)}),
+			// broken
+			newVirtualService("reviews-canary", []string{"reviews"}, []string{"missing"},
+				[]*v1alpha3.DestinationWeight{destination("reviews", "v3", 50), destination("reviews", "v1", 30)},
+				[]*v1alpha3.DestinationWeight{destination("ratings.prod.svc.cluster.local", "v9", 0)},
+				[]*v1alpha3.DestinationWeight{destination("httpbin.org", "", 0)}),
+			newVirtualService("reviews-copy", []string{"reviews"}, []string{"mesh"},
+				[]*v1alpha3.DestinationWeight{destination("reviews", "v1", 0)}),
+		},
+	}
+
+	vs := func(name string) api.FindingObject {
+		return api.FindingObject{Kind: "virtualservice", Namespace: "default", Name: name}
+	}
+	type result struct {
+		code   string
+		object api.FindingObject
+	}
+	expected := []result{
+		{DuplicateHost, vs("reviews")},
+		{ExternalHostNotFound, vs("reviews-canary")},
+		{GatewayNotFound, vs("reviews-canary")},
+		{SubsetNotFound, vs("reviews-canary")},
+		{WeightSumInvalid, vs("reviews-canary")},
+		{DuplicateHost, vs("reviews-copy")},
+	}
+
+	findings := Analyze(input)
+	actual := make([]result, 0, len(findings))
+	for _, finding := range findings {
+		actual = append(actual, result{finding.Code, finding.Object})
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Analyze() == %+v, expected %+v", actual, expected)
+	}
+}
+
+func TestCheckSubsetsWildcardHost(t *testing.T) {
+	input := &Input{
+		Namespaces: common.NewSameNamespaceQuery("default"),
+		DestinationRules: []v1alpha3.DestinationRule{{
+			ObjectMeta: metaV1.ObjectMeta{Name: "default", Namespace: "default"},
+			Spec: v1alpha3.DestinationRuleSpec{
+				Host:    "*.default.svc.cluster.local",
+				Subsets: []*v1alpha3.Subset{{Name: "v1"}},
+			},
+		}},
+		VirtualServices: []v1alpha3.VirtualService{
+			newVirtualService("reviews", []string{"reviews"}, nil,
+				[]*v1alpha3.DestinationWeight{destination("reviews", "v1", 0)}),
+			newVirtualService("ratings", []string{"ratings"}, nil,
+				[]*v1alpha3.DestinationWeight{destination("ratings", "v2", 0)}),
+		},
+	}
+
+	findings := checkSubsets(input)
+	if len(findings) != 1 || findings[0].Object.Name != "ratings" {
+		t.Errorf("checkSubsets() == %+v, expected only the undefined subset of ratings", findings)
+	}
+}
+
+func TestDeclared(t *testing.T) {
+	entry := func(namespace string, exportTo []string, hosts ...string) ServiceEntry {
+		return ServiceEntry{
+			ServiceEntry: v1alpha3.ServiceEntry{
+				ObjectMeta: metaV1.ObjectMeta{Name: "external", Namespace: namespace},
+				Spec:       v1alpha3.ServiceEntrySpec{Hosts: hosts},
+			},
+			ExportTo: exportTo,
+		}
+	}
+	cases := []struct {
+		entry    ServiceEntry
+		host     string
+		expected bool
+	}{
+		{entry("istio-system", nil, "httpbin.org"), "httpbin.org", true},
+		{entry("istio-system", []string{"*"}, "*.googleapis.com"), "storage.googleapis.com", true},
+		{entry("istio-system", []string{"."}, "httpbin.org"), "httpbin.org", false},
+		{entry("default", []string{"."}, "httpbin.org"), "httpbin.org", true},
+		{entry("istio-system", nil, "httpbin.org"), "example.com", false},
+	}
+
+	for _, c := range cases {
+		if actual := declared(c.host, "default", []ServiceEntry{c.entry}); actual != c.expected {
+			t.Errorf("declared(%s, default, %+v) == %t, expected %t", c.host, c.entry, actual, c.expected)
+		}
+	}
+}
+
+func TestListServiceEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apis/networking.istio.io/v1alpha3/serviceentries":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`))
+		case "/apis/networking.istio.io/v1alpha3/namespaces/default/serviceentries":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"httpbin","namespace":"default"},` +
+				`"spec":{"hosts":["httpbin.org"],"exportTo":["."]}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+
+	entries, err := listServiceEntries(istioClient, common.NewSameNamespaceQuery("default"))
+	if err != nil {
+		t.Fatalf("listServiceEntries() returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "httpbin" || !reflect.DeepEqual(entries[0].ExportTo, []string{"."}) {
+		t.Errorf("listServiceEntries() == %+v, expected httpbin exported to its namespace", entries)
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	cases := []struct {
+		host      string
+		expected  string
+		inCluster bool
+	}{
+		{"reviews", "reviews.default.svc.cluster.local", true},
+		{"reviews.prod", "reviews.prod", false},
+		{"reviews.prod.svc.cluster.local", "reviews.prod.svc.cluster.local", true},
+		{"api.example.com", "api.example.com", false},
+		{"*", "*", false},
+	}
+
+	for _, c := range cases {
+		host, inCluster := resolveHost(c.host, "default")
+		if host != c.expected || inCluster != c.inCluster {
+			t.Errorf("resolveHost(%s) == %s, %t, expected %s, %t", c.host, host, inCluster, c.expected, c.inCluster)
+		}
+	}
+}
+
+func TestFilterFindings(t *testing.T) {
+	findings := []api.Finding{
+		{Code: GatewayNotFound, Object: api.FindingObject{Kind: "virtualservice", Namespace: "default", Name: "a"}},
+		{Code: GatewayNotFound, Object: api.FindingObject{Kind: "virtualservice", Namespace: "default", Name: "b"}},
+	}
+
+	actual := FilterFindings(findings, []api.FindingObject{{Kind: "virtualservice", Namespace: "default", Name: "b"}})
+	if !reflect.DeepEqual(actual, findings[1:]) {
+		t.Errorf("FilterFindings() == %+v, expected %+v", actual, findings[1:])
+	}
+}
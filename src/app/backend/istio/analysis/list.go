@@ -0,0 +1,147 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"encoding/json"
+	"log"
+
+	commonApi "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// GetFindings analyzes the istio configuration of the namespaces. The service entries of all the
+// namespaces are considered, as they may be exported to the analyzed ones.
+func GetFindings(istioClient istio.Interface, nsQuery *common.NamespaceQuery) (*api.FindingList, error) {
+	log.Printf("Analyzing istio configuration in %v namespaces", nsQuery.ToRequestParam())
+
+	channels := &common.ResourceChannels{
+		VirtualServiceList:  common.GetVirtualServiceListChannel(istioClient, nsQuery, 1),
+		DestinationRuleList: common.GetDestinationRuleListChannel(istioClient, nsQuery, 1),
+		GatewayList:         common.GetGatewayListChannel(istioClient, nsQuery, 1),
+	}
+
+	serviceEntries, err := listServiceEntries(istioClient, nsQuery)
+	nonCriticalErrors, criticalError := errors.HandleError(err)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	return GetFindingsFromChannels(channels, serviceEntries, nonCriticalErrors, nsQuery)
+}
+
+// GetFindingsFromChannels analyzes the istio configuration read from the channels along with the
+// given service entries. The errors of listing the service entries are passed on.
+func GetFindingsFromChannels(channels *common.ResourceChannels, serviceEntries []ServiceEntry,
+	nonCriticalErrors []error, nsQuery *common.NamespaceQuery) (*api.FindingList, error) {
+	virtualServices := <-channels.VirtualServiceList.List
+	err := <-channels.VirtualServiceList.Error
+	nonCriticalErrors, criticalError := errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	destinationRules := <-channels.DestinationRuleList.List
+	err = <-channels.DestinationRuleList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	gateways := <-channels.GatewayList.List
+	err = <-channels.GatewayList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	findings := Analyze(&Input{
+		Namespaces:       nsQuery,
+		VirtualServices:  virtualServices.Items,
+		DestinationRules: destinationRules.Items,
+		Gateways:         gateways.Items,
+		ServiceEntries:   serviceEntries,
+	})
+
+	return &api.FindingList{
+		ListMeta: commonApi.ListMeta{TotalItems: len(findings)},
+		Findings: findings,
+		Errors:   nonCriticalErrors,
+	}, nil
+}
+
+// listServiceEntries lists the service entries of all namespaces. When the caller may not list them
+// across all namespaces, only the analyzed namespace is looked up.
+func listServiceEntries(istioClient istio.Interface, nsQuery *common.NamespaceQuery) ([]ServiceEntry, error) {
+	items, err := listNamespaceServiceEntries(istioClient, "")
+	namespace := nsQuery.ToRequestParam()
+	if !k8sErrors.IsForbidden(err) || namespace == "" {
+		return items, err
+	}
+
+	log.Printf("Not allowed to list service entries of all namespaces, listing them in %s", namespace)
+	return listNamespaceServiceEntries(istioClient, namespace)
+}
+
+func listNamespaceServiceEntries(istioClient istio.Interface, namespace string) ([]ServiceEntry, error) {
+	path := []string{"/apis/networking.istio.io/v1alpha3"}
+	if namespace != "" {
+		path = append(path, "namespaces", namespace)
+	}
+	raw, err := istioClient.NetworkingV1alpha3().RESTClient().Get().AbsPath(append(path, "serviceentries")...).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := v1alpha3.ServiceEntryList{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	exports := struct {
+		Items []struct {
+			Spec struct {
+				ExportTo []string `json:"exportTo"`
+			} `json:"spec"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw, &exports); err != nil {
+		return nil, err
+	}
+
+	items := make([]ServiceEntry, len(list.Items))
+	for i := range list.Items {
+		items[i] = ServiceEntry{ServiceEntry: list.Items[i], ExportTo: exports.Items[i].Spec.ExportTo}
+	}
+	return items, nil
+}
+
+// FilterFindings returns the findings about the given objects.
+func FilterFindings(findings []api.Finding, objects []api.FindingObject) []api.Finding {
+	result := make([]api.Finding, 0)
+	for _, finding := range findings {
+		for _, object := range objects {
+			if finding.Object == object {
+				result = append(result, finding)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -17,8 +17,11 @@ package api
 import (
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/gateway"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	settingsApi "github.com/kubernetes/dashboard/src/app/backend/settings/api"
 	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type AppList struct {
@@ -37,7 +40,13 @@ type App struct {
 	Status          Status                          `json:"status"`
 	VirtualServices []virtualservice.VirtualService `json:"virtualServices,omitempty"`
 	Destinations    []Destination                   `json:"destinations"`
-	Metrics         Metrics                         `json:"metrics,omitempty"`
+	// Mirrors are the versions receiving a copy of the app's traffic.
+	Mirrors []TrafficMirror `json:"mirrors,omitempty"`
+	Metrics Metrics         `json:"metrics,omitempty"`
+	// Findings are the configuration problems of the app's virtual services and destination rule.
+	Findings []Finding `json:"findings,omitempty"`
+	// Security is the mutual TLS and authorization state of the app, only filled in detail view.
+	Security *Security `json:"security,omitempty"`
 }
 
 // Metrics is Istio application metrics collected by Prometheus which is is the default
@@ -53,8 +62,14 @@ type Metrics struct {
 }
 
 type Status struct {
-	// Istio indicates if this application is istio-enabled
+	// Istio indicates if this application is istio-enabled, i.e. its pods run the sidecar. An
+	// application without pods is istio-enabled when its namespace has injection enabled.
 	Istio bool `json:"istio"`
+	// SidecarVersions are the versions of the sidecars the application's pods run.
+	SidecarVersions []string `json:"sidecarVersions,omitempty"`
+	// Workloads is the sidecar injection state of the application's deployments, only filled
+	// in detail view.
+	Workloads []WorkloadInjection `json:"workloads,omitempty"`
 }
 
 type Destination struct {
@@ -70,13 +85,368 @@ type NewApplication struct {
 	PodTemplate v1.PodTemplateSpec `json:"podTemplate"`
 }
 
+// AppDeletion reports the result of deleting each object of an application.
+type AppDeletion struct {
+	Results []ObjectDeletion `json:"results"`
+}
+
+// ObjectDeletion is the result of deleting one object of an application.
+type ObjectDeletion struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Deleted indicates the object existed and has been deleted.
+	Deleted bool `json:"deleted"`
+	// Detached indicates the object is shared with other hosts, only the app has been removed from it.
+	Detached bool `json:"detached,omitempty"`
+	// Error is the reason the deletion failed, empty when deleted or when the object did not exist.
+	Error string `json:"error,omitempty"`
+}
+
+// VersionDiff is the difference between the pod templates and the subset traffic policies of two
+// versions of an application.
+type VersionDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is a field which differs between two versions. Old or New is missing when the field
+// is only set in one of the versions.
+type FieldChange struct {
+	// Path of the field, e.g. containers[reviews].env[LOG_LEVEL].value.
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Actions taken when importing an object of an application bundle.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportFailed  = "failed"
+)
+
+// AppImport reports the result of applying each object of an application bundle.
+type AppImport struct {
+	Results []ObjectImport `json:"results"`
+}
+
+// ObjectImport is the result of applying one object of an application bundle.
+type ObjectImport struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Action is one of created, updated or failed.
+	Action string `json:"action"`
+	// Error is the reason the object could not be applied.
+	Error string `json:"error,omitempty"`
+}
+
 type CanaryDeployment struct {
 	Version  string `json:"version"`
 	Replicas int32  `json:"replicas"`
+	// BaseVersion is the version of the deployment or stateful set the canary inherits its
+	// configuration from. It can be omitted when the app runs a single version.
+	BaseVersion string `json:"baseVersion,omitempty"`
 
 	PodTemplate v1.PodTemplateSpec `json:"podTemplate"`
 }
 
+// LabelKeys are the keys of the labels identifying the application and the version of the
+// workloads and their pods.
+type LabelKeys struct {
+	App     string
+	Version string
+	// Extra label keys set to the application name on the created workloads.
+	Extra []string
+}
+
+// NewLabelKeys returns the keys of the app & version labels configured in the settings, the
+// default keys are used for the ones not configured.
+func NewLabelKeys(s settingsApi.Settings) LabelKeys {
+	defaults := settingsApi.GetDefaultSettings()
+	keys := LabelKeys{App: s.AppLabel, Version: s.VersionLabel, Extra: s.ExtraAppLabels}
+	if keys.App == "" {
+		keys.App = defaults.AppLabel
+	}
+	if keys.Version == "" {
+		keys.Version = defaults.VersionLabel
+	}
+	return keys
+}
+
+// TrafficShifting describes how the application's traffic is split between its versions.
+type TrafficShifting struct {
+	// Weights maps the version (DestinationRule subset) to the percentage of traffic it receives.
+	// The weights must sum to 100.
+	Weights map[string]int32 `json:"weights"`
+}
+
+// TrafficMirror shadows the application's traffic to one of its versions, the responses of the
+// mirrored requests are discarded.
+type TrafficMirror struct {
+	// Version is the DestinationRule subset receiving the copy of the traffic, empty to stop mirroring.
+	Version string `json:"version"`
+	// VirtualServices are the names of the virtual services mirroring to the version.
+	VirtualServices []string `json:"virtualServices,omitempty"`
+}
+
+// ResiliencePolicy is the resilience settings of an application, the timeouts, retries and faults
+// of the routes to the application and the traffic policy of its destination rule.
+type ResiliencePolicy struct {
+	// Routes are the HTTP routes to the application in its virtual services.
+	Routes []RoutePolicy `json:"routes"`
+	// TrafficPolicy is the traffic policy of the application's destination rule.
+	TrafficPolicy *TrafficPolicy `json:"trafficPolicy,omitempty"`
+}
+
+// RoutePolicy is the resilience settings of one HTTP route of a virtual service.
+type RoutePolicy struct {
+	VirtualService string `json:"virtualService"`
+	Namespace      string `json:"namespace"`
+	// Index is the position of the route in the HTTP routes of the virtual service.
+	Index int `json:"index"`
+	// Destinations are the versions the route sends the traffic to, for display only.
+	Destinations []string `json:"destinations,omitempty"`
+
+	// Timeout of the requests, i.e. 3s, no timeout when empty.
+	Timeout string          `json:"timeout,omitempty"`
+	Retries *RetryPolicy    `json:"retries,omitempty"`
+	Fault   *FaultInjection `json:"fault,omitempty"`
+}
+
+// RetryPolicy retries the failed requests.
+type RetryPolicy struct {
+	Attempts int32 `json:"attempts"`
+	// PerTryTimeout is the timeout of each attempt, i.e. 1s.
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
+}
+
+// FaultInjection delays or aborts a percentage of the requests.
+type FaultInjection struct {
+	Delay *DelayFault `json:"delay,omitempty"`
+	Abort *AbortFault `json:"abort,omitempty"`
+}
+
+// DelayFault delays the percentage (0-100) of the requests by a fixed delay, i.e. 5s.
+type DelayFault struct {
+	Percent    int32  `json:"percent"`
+	FixedDelay string `json:"fixedDelay"`
+}
+
+// AbortFault aborts the percentage (0-100) of the requests with the HTTP status.
+type AbortFault struct {
+	Percent    int32 `json:"percent"`
+	HttpStatus int   `json:"httpStatus"`
+}
+
+// TrafficPolicy is the load balancing, connection pool and outlier detection settings of an
+// application. When updated, the omitted settings are kept and an empty connection pool or
+// outlier detection removes it.
+type TrafficPolicy struct {
+	// LoadBalancer is one of ROUND_ROBIN|LEAST_CONN|RANDOM|PASSTHROUGH, ROUND_ROBIN when empty.
+	LoadBalancer     string            `json:"loadBalancer,omitempty"`
+	ConnectionPool   *ConnectionPool   `json:"connectionPool,omitempty"`
+	OutlierDetection *OutlierDetection `json:"outlierDetection,omitempty"`
+}
+
+// ConnectionPool limits the connections and requests to each host of the application, zero
+// values fall back to the istio defaults.
+type ConnectionPool struct {
+	MaxConnections           int32  `json:"maxConnections,omitempty"`
+	ConnectTimeout           string `json:"connectTimeout,omitempty"`
+	Http1MaxPendingRequests  int32  `json:"http1MaxPendingRequests,omitempty"`
+	Http2MaxRequests         int32  `json:"http2MaxRequests,omitempty"`
+	MaxRequestsPerConnection int32  `json:"maxRequestsPerConnection,omitempty"`
+	MaxRetries               int32  `json:"maxRetries,omitempty"`
+}
+
+// OutlierDetection ejects the hosts of the application failing consecutively, which is the
+// circuit breaker of istio.
+type OutlierDetection struct {
+	ConsecutiveErrors  int32  `json:"consecutiveErrors,omitempty"`
+	Interval           string `json:"interval,omitempty"`
+	BaseEjectionTime   string `json:"baseEjectionTime,omitempty"`
+	MaxEjectionPercent int32  `json:"maxEjectionPercent,omitempty"`
+}
+
+// RoutingRuleList is the list of the application's routing rules.
+type RoutingRuleList struct {
+	ListMeta api.ListMeta `json:"listMeta"`
+
+	Rules []RoutingRule `json:"rules"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors"`
+}
+
+// RoutingRule routes the requests matching all of its conditions to one version of the
+// application, ahead of the default weighted route.
+type RoutingRule struct {
+	// ID identifies the rule, it is computed from the version and the match conditions.
+	ID string `json:"id,omitempty"`
+	// Version is the DestinationRule subset receiving the matched requests.
+	Version string `json:"version"`
+
+	// Header matches requests carrying the header with the exact value, i.e. x-canary: true.
+	Header *KeyValueMatch `json:"header,omitempty"`
+	// Cookie matches requests carrying the cookie with the exact value.
+	Cookie *KeyValueMatch `json:"cookie,omitempty"`
+	// UserAgent matches requests whose user agent matches the regular expression.
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// VirtualServices are the names of the virtual services containing the rule.
+	VirtualServices []string `json:"virtualServices,omitempty"`
+}
+
+// KeyValueMatch matches a named request attribute by its exact value.
+type KeyValueMatch struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// AppVersionList is the list of the application's versions with their traffic split.
+type AppVersionList struct {
+	ListMeta api.ListMeta `json:"listMeta"`
+
+	Versions []AppVersion `json:"versions"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors"`
+}
+
+// AppVersion represents one version of the application, which is a DestinationRule subset together
+// with the Deployment selected by it.
+type AppVersion struct {
+	Version string `json:"version"`
+
+	// Subset is the DestinationRule subset of the version, nil if the version has no subset.
+	Subset *Destination `json:"subset,omitempty"`
+	// Deployment is the deployment of the version, nil if the version has no deployment.
+	Deployment *VersionDeployment `json:"deployment,omitempty"`
+	// Weights are the effective weights the virtual services give to the version.
+	Weights []VersionWeight `json:"weights"`
+
+	// Drift indicates the version exists only in the DestinationRule or only as a Deployment.
+	Drift bool `json:"drift"`
+}
+
+// VersionDeployment is the deployment or stateful set running one version of the application.
+type VersionDeployment struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Number of pods that are ready.
+	Ready int32 `json:"ready"`
+	// Number of pods that are desired.
+	Desired int32 `json:"desired"`
+}
+
+// VersionWeight is the percentage of traffic a virtual service routes to one version.
+type VersionWeight struct {
+	VirtualService string   `json:"virtualService"`
+	Namespace      string   `json:"namespace"`
+	Gateways       []string `json:"gateways,omitempty"`
+	// Mesh indicates the virtual service applies to the sidecars inside the mesh.
+	Mesh   bool  `json:"mesh"`
+	Weight int32 `json:"weight"`
+}
+
+// DrainPhase is the phase of draining an application version.
+type DrainPhase string
+
+const (
+	// DrainDraining means the version is detached and the sidecars are stopping to route to it.
+	DrainDraining DrainPhase = "Draining"
+	// DrainScalingDown means the version's deployment is being scaled to zero.
+	DrainScalingDown DrainPhase = "ScalingDown"
+	// DrainCompleted means the version has been offlined.
+	DrainCompleted DrainPhase = "Completed"
+	// DrainFailed means the version could not be offlined, see the message.
+	DrainFailed DrainPhase = "Failed"
+)
+
+// DrainSpec configures how a version is drained before it is offlined.
+type DrainSpec struct {
+	// Timeout is the maximum time in seconds to wait for the traffic to the version to stop.
+	Timeout int64 `json:"timeout"`
+}
+
+// DrainStatus is the progress of draining and offlining an application version.
+type DrainStatus struct {
+	Namespace string     `json:"namespace"`
+	App       string     `json:"app"`
+	Version   string     `json:"version"`
+	Phase     DrainPhase `json:"phase"`
+	// RequestRate is the last observed number of requests per second to the version, -1 if unknown.
+	RequestRate    float64      `json:"requestRate"`
+	StartTime      metaV1.Time  `json:"startTime"`
+	CompletionTime *metaV1.Time `json:"completionTime,omitempty"`
+	Message        string       `json:"message,omitempty"`
+}
+
+// RolloutPhase is the phase of a progressive delivery.
+type RolloutPhase string
+
+const (
+	// RolloutProgressing means the canary weight is being advanced through the steps.
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutSucceeded means the canary has taken over all the traffic.
+	RolloutSucceeded RolloutPhase = "Succeeded"
+	// RolloutRolledBack means a health gate failed and the stable version has taken back all the traffic.
+	RolloutRolledBack RolloutPhase = "RolledBack"
+)
+
+// RolloutSpec describes a progressive delivery of the canary version of an application.
+type RolloutSpec struct {
+	// Stable is the version currently serving the traffic.
+	Stable string `json:"stable"`
+	// Canary is the version being rolled out.
+	Canary string `json:"canary"`
+	// Deployment creates the canary version before the rollout starts when given.
+	Deployment *CanaryDeployment `json:"deployment,omitempty"`
+	// Steps are the ascending percentages of traffic the canary receives, i.e. [5, 25, 50, 100].
+	Steps []int32 `json:"steps"`
+	// Interval is the time in seconds each step is held before advancing to the next one.
+	Interval int64 `json:"interval"`
+	// Gates are the health conditions the canary has to meet during the rollout.
+	Gates RolloutGates `json:"gates"`
+	// Cleanup offlines the version losing the traffic when the rollout finishes.
+	Cleanup bool `json:"cleanup"`
+}
+
+// RolloutGates are the thresholds that roll the canary back when exceeded. A step is only advanced
+// once at least one canary pod is ready.
+type RolloutGates struct {
+	// MaxFailingPods is the number of failing canary pods tolerated.
+	MaxFailingPods int32 `json:"maxFailingPods"`
+	// MaxRestarts is the number of canary container restarts tolerated since the rollout started.
+	MaxRestarts int32 `json:"maxRestarts"`
+	// MaxErrorRate is the ratio (0-1) of failed requests tolerated, 0 disables the gate.
+	MaxErrorRate float64 `json:"maxErrorRate,omitempty"`
+}
+
+// Rollout is the persisted state of a progressive delivery.
+type Rollout struct {
+	ObjectMeta api.ObjectMeta `json:"objectMeta"`
+	TypeMeta   api.TypeMeta   `json:"typeMeta"`
+
+	Spec   RolloutSpec   `json:"spec"`
+	Status RolloutStatus `json:"status"`
+}
+
+// RolloutStatus is the observed state of a progressive delivery.
+type RolloutStatus struct {
+	Phase RolloutPhase `json:"phase"`
+	// Step is the index of the current step in the spec's steps.
+	Step int `json:"step"`
+	// Weight is the percentage of traffic the canary currently receives.
+	Weight int32 `json:"weight"`
+	// BaselineRestarts is the restart count of the canary pods when the rollout started.
+	BaselineRestarts int32 `json:"baselineRestarts"`
+	// LastTransitionTime is the time the current step was applied.
+	LastTransitionTime metaV1.Time `json:"lastTransitionTime"`
+	Message            string      `json:"message,omitempty"`
+}
+
 // IngressList indicates a list of istio ingresses.
 type IngressList struct {
 	api.ListMeta `json:"listMeta"`
@@ -94,6 +464,245 @@ type Ingress struct {
 	Hosts             []common.Endpoint               `json:"hosts,omitempty"`
 	ExternalEndpoints []common.Endpoint               `json:"externalEndpoints"`
 	VirtualServices   []virtualservice.VirtualService `json:"virtualServices,omitempty"`
+	// Findings are the configuration problems of the gateway and its virtual services.
+	Findings []Finding `json:"findings,omitempty"`
+	// Certificates are the certificates served by the TLS servers of the gateway.
+	Certificates []gateway.ServerCertificate `json:"certificates,omitempty"`
 
 	Errors []error `json:"errors"`
 }
+
+// IngressSpec describes the istio ingress to create, which is a `Gateway` together with the
+// `VirtualService` bound to it.
+type IngressSpec struct {
+	// Hosts are the hosts exposed by the gateway, i.e. bookinfo.example.com.
+	Hosts []string `json:"hosts"`
+	// Selector selects the gateway workload, defaults to the istio ingress gateway.
+	Selector map[string]string `json:"selector,omitempty"`
+	Ports    []IngressPort     `json:"ports"`
+	// Routes forward the requests to the backend applications, they are matched in order.
+	Routes []IngressRoute `json:"routes"`
+}
+
+// IngressPort is a port the gateway listens on.
+type IngressPort struct {
+	Number uint32 `json:"number"`
+	// Protocol is one of HTTP|HTTPS|GRPC|HTTP2|MONGO|TCP|TLS.
+	Protocol string `json:"protocol"`
+	Name     string `json:"name,omitempty"`
+	// TLS is required for the HTTPS and TLS protocols.
+	TLS *IngressTLS `json:"tls,omitempty"`
+}
+
+// IngressTLS configures the TLS termination of a gateway port.
+type IngressTLS struct {
+	// Mode is one of PASSTHROUGH|SIMPLE|MUTUAL.
+	Mode string `json:"mode"`
+	// CredentialSecret is the secret holding tls.crt and tls.key, it has to be mounted by the
+	// gateway workload. Required for SIMPLE and MUTUAL modes.
+	CredentialSecret string `json:"credentialSecret,omitempty"`
+	// CaSecret is the secret holding the CA certificates, it has to be mounted by the gateway
+	// workload. Required for MUTUAL mode.
+	CaSecret string `json:"caSecret,omitempty"`
+	// HttpsRedirect makes the HTTP port answer with a redirect to HTTPS.
+	HttpsRedirect bool `json:"httpsRedirect,omitempty"`
+}
+
+// IngressRoute forwards the requests matching the prefix to a backend application.
+type IngressRoute struct {
+	// Prefix is the uri prefix to match, all requests are matched when empty.
+	Prefix string `json:"prefix,omitempty"`
+	App    string `json:"app"`
+	// Namespace of the application, defaults to the namespace of the ingress.
+	Namespace string `json:"namespace,omitempty"`
+	Port      uint32 `json:"port,omitempty"`
+	// Version is the DestinationRule subset receiving the requests, all versions when empty.
+	Version string `json:"version,omitempty"`
+}
+
+// FindingSeverity is the severity of a configuration finding.
+type FindingSeverity string
+
+const (
+	// FindingError means the configuration is broken, i.e. requests fail or are dropped.
+	FindingError FindingSeverity = "Error"
+	// FindingWarning means the configuration works but likely not as intended.
+	FindingWarning FindingSeverity = "Warning"
+)
+
+// FindingList is the list of the configuration findings of the analyzed namespaces.
+type FindingList struct {
+	ListMeta api.ListMeta `json:"listMeta"`
+
+	Findings []Finding `json:"findings"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors"`
+}
+
+// Finding is a problem found in the istio configuration.
+type Finding struct {
+	Severity FindingSeverity `json:"severity"`
+	// Code identifies the kind of problem, i.e. SubsetNotFound.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Object is the offending object.
+	Object FindingObject `json:"object"`
+}
+
+// FindingObject references the object a finding is about.
+type FindingObject struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// GraphNodeKind is the kind of a node of the mesh topology.
+type GraphNodeKind string
+
+const (
+	GraphNodeNamespace    GraphNodeKind = "namespace"
+	GraphNodeApp          GraphNodeKind = "app"
+	GraphNodeVersion      GraphNodeKind = "version"
+	GraphNodeGateway      GraphNodeKind = "gateway"
+	GraphNodeServiceEntry GraphNodeKind = "serviceentry"
+	// GraphNodeExternal is a host routed to which is neither a service nor declared by a service entry.
+	GraphNodeExternal GraphNodeKind = "external"
+)
+
+// GraphEdgeKind is the kind of an edge of the mesh topology.
+type GraphEdgeKind string
+
+const (
+	// GraphEdgeRoute is the traffic routed to a destination by a virtual service.
+	GraphEdgeRoute GraphEdgeKind = "route"
+	// GraphEdgeMirror is the traffic mirrored to a destination by a virtual service.
+	GraphEdgeMirror GraphEdgeKind = "mirror"
+	// GraphEdgeGateway binds a gateway to the hosts of a virtual service.
+	GraphEdgeGateway GraphEdgeKind = "gateway"
+)
+
+// Graph is the directed graph of the mesh topology. Namespaces and apps are compound nodes,
+// the nodes they contain refer to them as their parent.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors"`
+}
+
+// GraphNode is a node of the mesh topology.
+type GraphNode struct {
+	// ID is unique in the graph, i.e. app/default/reviews.
+	ID        string        `json:"id"`
+	Kind      GraphNodeKind `json:"kind"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	// Parent is the ID of the namespace or app containing the node.
+	Parent string `json:"parent,omitempty"`
+}
+
+// GraphEdge is an edge of the mesh topology.
+type GraphEdge struct {
+	// ID is unique in the graph, made of the kind and the IDs of the source and the target.
+	ID     string        `json:"id"`
+	Kind   GraphEdgeKind `json:"kind"`
+	Source string        `json:"source"`
+	Target string        `json:"target"`
+	// Weight is the percentage of the traffic of the route going to the target, the highest
+	// one is kept when several routes lead to the same target.
+	Weight int32 `json:"weight,omitempty"`
+	// VirtualServices are the namespace/name of the virtual services defining the edge.
+	VirtualServices []string `json:"virtualServices"`
+}
+
+// NamespaceInjection is the sidecar injection state of a namespace and its workloads.
+type NamespaceInjection struct {
+	Namespace string `json:"namespace"`
+	// Enabled is true when the namespace is labeled with istio-injection=enabled.
+	Enabled   bool                `json:"enabled"`
+	Workloads []WorkloadInjection `json:"workloads"`
+}
+
+// WorkloadInjection is the sidecar injection state of a workload, computed from its pods.
+type WorkloadInjection struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Annotation is the sidecar.istio.io/inject annotation of the pod template, if any.
+	Annotation string `json:"annotation,omitempty"`
+	// Expected is true when the new pods of the workload get the sidecar injected.
+	Expected bool `json:"expected"`
+	// Pods is the number of pods of the workload, InjectedPods the number of them running the sidecar.
+	Pods         int `json:"pods"`
+	InjectedPods int `json:"injectedPods"`
+	// SidecarImages are the images of the sidecars the pods run.
+	SidecarImages []string `json:"sidecarImages,omitempty"`
+	// SidecarVersions are the tags of the sidecar images.
+	SidecarVersions []string `json:"sidecarVersions,omitempty"`
+	// RestartRequired is true when the pods don't match the expected injection, they have to be
+	// restarted for it to apply.
+	RestartRequired bool `json:"restartRequired"`
+}
+
+// InjectionSpec enables or disables sidecar injection on a namespace or a workload.
+type InjectionSpec struct {
+	Enabled bool `json:"enabled"`
+	// Restart restarts the pods which don't match the new injection state.
+	Restart bool `json:"restart,omitempty"`
+}
+
+// Mutual TLS modes the sidecars of an app accept the requests with.
+const (
+	// MTLSStrict accepts mutual TLS requests only.
+	MTLSStrict = "STRICT"
+	// MTLSPermissive accepts both mutual TLS and plaintext requests.
+	MTLSPermissive = "PERMISSIVE"
+	// MTLSDisabled accepts plaintext requests only.
+	MTLSDisabled = "DISABLE"
+)
+
+// Security is the mutual TLS and authorization state of an app.
+type Security struct {
+	// ServerMTLS is the mutual TLS mode the app's sidecars accept the requests with.
+	ServerMTLS string `json:"serverMtls"`
+	// AuthenticationPolicy is the namespace/name of the policy the server mode comes from, or
+	// MeshPolicy/default. It is empty when no policy applies.
+	AuthenticationPolicy string `json:"authenticationPolicy,omitempty"`
+	// ClientTLS is the TLS mode the clients originate the requests to the app with: DISABLE,
+	// SIMPLE, MUTUAL or ISTIO_MUTUAL.
+	ClientTLS string `json:"clientTls"`
+	// DestinationRule is the namespace/name of the destination rule the client mode comes from.
+	DestinationRule string `json:"destinationRule,omitempty"`
+	// MTLSEnforced is true when the app rejects the plaintext requests.
+	MTLSEnforced bool `json:"mtlsEnforced"`
+	// Authorization lists the callers allowed by the istio RBAC.
+	Authorization Authorization `json:"authorization"`
+	// Warnings are the mismatches between the client and server settings.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Authorization is the istio RBAC state of an app.
+type Authorization struct {
+	// Enabled is true when the RBAC applies to the app, otherwise any caller is allowed.
+	Enabled bool `json:"enabled"`
+	// Principals are the peers allowed to call the app, * for any.
+	Principals []string `json:"principals"`
+	// Roles are the namespace/name of the service roles granting access to the app.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// NamespaceSecurity summarizes the security of the services of a namespace.
+type NamespaceSecurity struct {
+	Namespace string            `json:"namespace"`
+	Services  []ServiceSecurity `json:"services"`
+	// PlaintextServices are the names of the services still accepting plaintext requests.
+	PlaintextServices []string `json:"plaintextServices"`
+}
+
+// ServiceSecurity is the security of one service.
+type ServiceSecurity struct {
+	Name     string   `json:"name"`
+	Security Security `json:"security"`
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// OfflineAccess returns the accesses needed to offline a version of an app in the namespace: its
+// subset is removed from the destination rule, then its workloads are scaled down and deleted.
+func OfflineAccess(namespace string) []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{
+		{Namespace: namespace, Verb: "update", Group: "networking.istio.io", Resource: "destinationrules"},
+		{Namespace: namespace, Verb: "update", Group: "extensions", Resource: "deployments"},
+		{Namespace: namespace, Verb: "delete", Group: "extensions", Resource: "deployments"},
+		{Namespace: namespace, Verb: "update", Group: "apps", Resource: "statefulsets"},
+		{Namespace: namespace, Verb: "delete", Group: "apps", Resource: "statefulsets"},
+	}
+}
+
+// ShiftAccess returns the accesses needed to shift the traffic of an app in the namespace, its
+// virtual service is created when none exists.
+func ShiftAccess(namespace string) []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{
+		{Namespace: namespace, Verb: "create", Group: "networking.istio.io", Resource: "virtualservices"},
+		{Namespace: namespace, Verb: "update", Group: "networking.istio.io", Resource: "virtualservices"},
+	}
+}
+
+// ReadAccess returns the access needed to observe the versions of the apps in the namespace.
+func ReadAccess(namespace string) []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{
+		{Namespace: namespace, Verb: "get", Group: "extensions", Resource: "deployments"},
+	}
+}
+
+// CheckSelfAccess reviews the accesses of the user the client authenticates as. Work done later
+// with the dashboard's own privileges must be checked first, so users can't exceed their rights.
+// A forbidden error names the first denied access.
+func CheckSelfAccess(client kubernetes.Interface, accesses []authorizationv1.ResourceAttributes) error {
+	for i := range accesses {
+		review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &accesses[i]},
+		})
+		if err != nil {
+			return err
+		}
+		if !review.Status.Allowed {
+			return forbidden(&accesses[i])
+		}
+	}
+	return nil
+}
+
+// CheckUserAccess reviews the accesses of the given user, the client has to be allowed to create
+// subject access reviews. A forbidden error names the first denied access.
+func CheckUserAccess(client kubernetes.Interface, user *authenticationv1.UserInfo,
+	accesses []authorizationv1.ResourceAttributes) error {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	for i := range accesses {
+		review, err := client.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &accesses[i],
+				User:               user.Username,
+				Groups:             user.Groups,
+				UID:                user.UID,
+				Extra:              extra,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if !review.Status.Allowed {
+			return forbidden(&accesses[i])
+		}
+	}
+	return nil
+}
+
+func forbidden(access *authorizationv1.ResourceAttributes) error {
+	return k8sErrors.NewForbidden(schema.GroupResource{Group: access.Group, Resource: access.Resource}, "",
+		fmt.Errorf("%s access to %s namespace is required", access.Verb, access.Namespace))
+}
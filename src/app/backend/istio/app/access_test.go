@@ -0,0 +1,79 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newAccessClient creates a fake client whose user is allowed every access but the denied
+// verbs on the denied resources.
+func newAccessClient(allowed bool, denied ...string) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = allowed
+			for _, access := range denied {
+				if access == attributes.Verb+" "+attributes.Resource {
+					review.Status.Allowed = false
+				}
+			}
+			return true, review, nil
+		})
+	return client
+}
+
+func TestCheckSelfAccess(t *testing.T) {
+	cases := []struct {
+		client    *fake.Clientset
+		forbidden bool
+	}{
+		{newAccessClient(true), false},
+		{newAccessClient(true, "delete statefulsets"), true},
+		{newAccessClient(false), true},
+	}
+
+	for _, c := range cases {
+		err := CheckSelfAccess(c.client, OfflineAccess("default"))
+		assert.Equal(t, k8sErrors.IsForbidden(err), c.forbidden)
+		if !c.forbidden {
+			assert.Equal(t, err, nil)
+		}
+	}
+}
+
+func TestDrainRequiresOfflineAccess(t *testing.T) {
+	drainer := NewDrainer(nil, nil, nil)
+
+	// the access is checked before the version is detached with the nil istio client
+	_, err := drainer.Drain(newAccessClient(true, "delete deployments"), nil,
+		common.NewSameNamespaceQuery("default"), "reviews", "v1", "", nil, recommendedKeys)
+	assert.Equal(t, k8sErrors.IsForbidden(err), true)
+	assert.Equal(t, len(drainer.drains), 0)
+
+	_, err = drainer.DrainStatus(newAccessClient(false), "default", "reviews", "v1")
+	assert.Equal(t, k8sErrors.IsForbidden(err), true)
+}
@@ -15,16 +15,13 @@
 package app
 
 import (
-	"fmt"
-	"log"
-
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
 	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
 	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
-	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/client-go/kubernetes"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func getAppVirtualServices(app *api.App, vServices []istioApi.VirtualService) []virtualservice.VirtualService {
@@ -86,38 +83,41 @@ func getAppDestinations(app *api.App, dRules []istioApi.DestinationRule) []api.D
 	return destinations
 }
 
-// IsApp checks if the specified app, namespace is an app.
-func IsApp(app string, namespace *common.NamespaceQuery) bool {
-	return true
-}
+// getAppDestinationsByName queries the destination rules of the namespace and returns the
+// subsets of the one whose host is the specified app.
+func getAppDestinationsByName(istioClient istio.Interface, namespace, appName string) ([]api.Destination, error) {
+	dRules, err := istioClient.NetworkingV1alpha3().DestinationRules(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
 
-// fixDeployment fixes the deployment's labels & matchLabels according to the podTemplate.
-// It checks whether the app & version labels exist for Istio running correctly.
-func fixDeployment(client kubernetes.Interface, parent *v1beta1.Deployment) error {
-	if parent.Spec.Template.Labels["app"] == "" || parent.Spec.Template.Labels["version"] == "" {
-		return fmt.Errorf("parent deployment's pod template need to have app & version labels")
+	app := &api.App{
+		ObjectMeta: api2.ObjectMeta{
+			Name:      appName,
+			Namespace: namespace,
+		},
 	}
+	return getAppDestinations(app, dRules.Items), nil
+}
 
-	appName := parent.Spec.Template.Labels["app"]
-	version := parent.Spec.Template.Labels["version"]
-
-	if parent.Spec.Selector.MatchLabels["app"] == "" || parent.Spec.Selector.MatchLabels["version"] == "" ||
-		parent.Labels["app"] == "" || parent.Labels["version"] == "" {
-		parent.Labels["app"] = appName
-		parent.Labels["version"] = version
-		parent.Spec.Selector.MatchLabels["app"] = appName
-		parent.Spec.Selector.MatchLabels["version"] = version
-		_, err := client.ExtensionsV1beta1().Deployments(parent.Namespace).Update(parent)
-		if err != nil {
-			log.Println("fail to fix parent deployment", err)
-			return err
+func hasDestination(destinations []api.Destination, version string) bool {
+	for _, d := range destinations {
+		if d.Version == version {
+			return true
 		}
 	}
-	return nil
+	return false
+}
+
+// IsApp checks if the specified app, namespace is an app.
+func IsApp(app string, namespace *common.NamespaceQuery) bool {
+	return true
 }
 
-// addToDestinationRule adds the specified version from destination rule.
-func addToDestinationRule(client istio.Interface, rule *istioApi.DestinationRule, version string, namespace string) error {
+// addToDestinationRule adds the specified version from destination rule, its pods are selected
+// by the version label key.
+func addToDestinationRule(client istio.Interface, rule *istioApi.DestinationRule, version, versionLabel string,
+	namespace string) error {
 	// TODO the same reason as destination rule
 	subsets := []*istioApi.Subset{}
 	for _, subset := range rule.Spec.Subsets {
@@ -131,7 +131,7 @@ func addToDestinationRule(client istio.Interface, rule *istioApi.DestinationRule
 	subsets = append(subsets, &istioApi.Subset{
 		Name: version,
 		Labels: map[string]string{
-			"version": version,
+			versionLabel: version,
 		},
 	})
 	rule.Spec.Subsets = subsets
@@ -15,10 +15,10 @@
 package app
 
 import (
-	"errors"
 	"fmt"
 	"log"
-	"time"
+	"sort"
+	"strings"
 
 	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
 	kdErrors "github.com/kubernetes/dashboard/src/app/backend/errors"
@@ -31,21 +31,25 @@ import (
 	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 )
 
 // CanaryApp creates a canary version for the specified namespace
 // version is a logic canary meaning, doesn't need to bind to image version.
+// The canary inherits the configuration of the app's deployment or stateful set running the
+// base version, the labels are identified by the given keys.
 func CanaryApp(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
-	appName string, canaryDep *api.CanaryDeployment) error {
+	appName string, canaryDep *api.CanaryDeployment, keys api.LabelKeys) error {
 	version := canaryDep.Version
 
 	// check if the specified app exist
 	dataSelector := dataselect.NoDataSelect
 	dataSelector.FilterQuery = dataselect.NewFilterQuery([]string{dataselect.NameProperty, appName})
 
-	_, err := GetAppDetail(client, istioClient, namespace, appName, dataSelector)
+	_, err := GetAppDetail(client, istioClient, namespace, appName, dataSelector, keys)
 	if err != nil {
 		return err
 	}
@@ -61,64 +65,18 @@ func CanaryApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 		return fmt.Errorf("app %s is in canary", appName)
 	}
 
-	// 3. create a deployment with specified version & canary plan name
-	// find the existed deployment first, and inherent from its deployment configuration
-	var parent v1beta1.Deployment
-	if parentDeps, err := getDeploymentByLabels(client, namespace, map[string]string{"app": appName}); err != nil || len(parentDeps) != 1 {
-		return fmt.Errorf("support only one parent deployment, %d given", len(parentDeps))
-	} else {
-		parent = parentDeps[0]
-	}
-
-	// fix parent deployment when some label is not set correctly.
-	if err := fixDeployment(client, &parent); err != nil {
+	// 3. create a workload with specified version & canary plan name
+	// find the existed workload first, and inherent from its configuration
+	parents, err := getParentWorkloads(client, namespace.ToRequestParam(), appName, keys)
+	if err != nil {
 		return err
 	}
-
-	// create new deployment
-	newPodSpec := canaryDep.PodTemplate
-	newPodSpec.Labels["app"] = appName
-	newPodSpec.Labels["qcloud-app"] = appName
-	newPodSpec.Labels["version"] = version
-
-	var replica int32
-	if canaryDep.Replicas > 0 {
-		replica = canaryDep.Replicas
-	} else {
-		replica = *parent.Spec.Replicas
-	}
-	newDep := &v1beta1.Deployment{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       api2.ResourceKindDeployment,
-			APIVersion: parent.APIVersion,
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-%s", appName, version),
-			Namespace: parent.Namespace,
-			Labels: map[string]string{
-				"app":        appName,
-				"qcloud-app": appName,
-				"version":    version,
-			},
-		},
-		Spec: v1beta1.DeploymentSpec{
-			Replicas: &replica,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app":     appName,
-					"version": version,
-				},
-			},
-			Template:                newPodSpec,
-			Strategy:                parent.Spec.Strategy,
-			MinReadySeconds:         parent.Spec.MinReadySeconds,
-			RevisionHistoryLimit:    parent.Spec.RevisionHistoryLimit,
-			ProgressDeadlineSeconds: parent.Spec.ProgressDeadlineSeconds,
-		},
+	parent, err := selectParent(parents, appName, version, canaryDep.BaseVersion, keys)
+	if err != nil {
+		return err
 	}
 
-	newDep, err = client.ExtensionsV1beta1().Deployments(namespace.ToRequestParam()).Create(newDep)
-	if err != nil {
+	if err := newCanaryWorkload(client, parent, appName, canaryDep, keys); err != nil {
 		return err
 	}
 
@@ -136,15 +94,15 @@ func CanaryApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 					Host: appName,
 					Subsets: []*istioApi.Subset{
 						{
-							Name: parent.Labels["version"],
+							Name: parent.version,
 							Labels: map[string]string{
-								"version": parent.Labels["version"],
+								keys.Version: parent.version,
 							},
 						},
 						{
-							Name: newDep.Labels["version"],
+							Name: version,
 							Labels: map[string]string{
-								"version": newDep.Labels["version"],
+								keys.Version: version,
 							},
 						},
 					},
@@ -156,21 +114,38 @@ func CanaryApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 		return err
 	}
 
-	return addToDestinationRule(istioClient, destinationRule, version, namespace.ToRequestParam())
+	return addToDestinationRule(istioClient, destinationRule, version, keys.Version, namespace.ToRequestParam())
 }
 
 // CreateApp creates application
 // 1. create service
 // 2. create deployment, app, labels, podTemplate and so on.
 // 3. create destination rule
-func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery, appName string, newApp *api.NewApplication) error {
-	// TODO validation
-	var err error
+// The creation is a unit, the objects already created are deleted again when a later step fails.
+// The labels of the objects are identified by the given keys.
+func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	newApp *api.NewApplication, keys api.LabelKeys) (err error) {
+	if err = validateNewApplication(appName, newApp); err != nil {
+		return err
+	}
 	version := newApp.Version
-	if version == "" {
-		return errors.New("Application creation without app version")
+	appLabels := map[string]string{keys.App: appName}
+	for _, key := range keys.Extra {
+		appLabels[key] = appName
+	}
+	versionLabels := map[string]string{keys.Version: version}
+	for key, value := range appLabels {
+		versionLabels[key] = value
 	}
 
+	var rollbacks rollback
+	defer func() {
+		if err != nil {
+			log.Printf("Rolling back creation of %s app in %s namespace: %v", appName, namespace.ToRequestParam(), err)
+			rollbacks.run()
+		}
+	}()
+
 	// 1. Create service
 	svc := &v1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -180,15 +155,12 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      appName,
 			Namespace: namespace.ToRequestParam(),
-			Labels: map[string]string{
-				"app":        appName,
-				"qcloud-app": appName,
-			},
+			Labels:    appLabels,
 		},
 		Spec: v1.ServiceSpec{
 			Ports: newApp.Ports,
 			Selector: map[string]string{
-				"app": appName,
+				keys.App: appName,
 			},
 			Type: v1.ServiceTypeClusterIP,
 		},
@@ -197,6 +169,9 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 	if err != nil {
 		return err
 	}
+	rollbacks.add(api2.ResourceKindService, svc.Name, func() error {
+		return client.CoreV1().Services(namespace.ToRequestParam()).Delete(svc.Name, &metav1.DeleteOptions{})
+	})
 
 	var replica int32
 	if newApp.Replicas > 0 {
@@ -207,9 +182,12 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 	}
 
 	newPodSpec := newApp.PodTemplate
-	newPodSpec.Labels["app"] = appName
-	newPodSpec.Labels["qcloud-app"] = appName
-	newPodSpec.Labels["version"] = version
+	if newPodSpec.Labels == nil {
+		newPodSpec.Labels = make(map[string]string)
+	}
+	for key, value := range versionLabels {
+		newPodSpec.Labels[key] = value
+	}
 
 	var limit int32 = 5
 	var deadlineSeconds int32 = 600
@@ -221,18 +199,14 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-%s", appName, version),
 			Namespace: namespace.ToRequestParam(),
-			Labels: map[string]string{
-				"app":        appName,
-				"qcloud-app": appName,
-				"version":    version,
-			},
+			Labels:    versionLabels,
 		},
 		Spec: v1beta1.DeploymentSpec{
 			Replicas: &replica,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
-					"app":     appName,
-					"version": version,
+					keys.App:     appName,
+					keys.Version: version,
 				},
 			},
 			Template: newPodSpec,
@@ -249,6 +223,11 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 	if err != nil {
 		return err
 	}
+	rollbacks.add(api2.ResourceKindDeployment, newDep.Name, func() error {
+		replicaDeletion := metav1.DeletePropagationBackground
+		return client.ExtensionsV1beta1().Deployments(namespace.ToRequestParam()).Delete(newDep.Name,
+			&metav1.DeleteOptions{GracePeriodSeconds: new(int64), PropagationPolicy: &replicaDeletion})
+	})
 
 	// 3. Create destination rule
 	rule := &istioApi.DestinationRule{
@@ -262,28 +241,112 @@ func CreateApp(client kubernetes.Interface, istioClient istio.Interface, namespa
 				{
 					Name: version,
 					Labels: map[string]string{
-						"version": version,
+						keys.Version: version,
 					},
 				},
 			},
 		},
 	}
 	_, err = istioClient.NetworkingV1alpha3().DestinationRules(namespace.ToRequestParam()).Create(rule)
-	if err != nil {
-		return err
+	return err
+}
+
+// validateNewApplication validates the application before any of its objects is created.
+func validateNewApplication(appName string, newApp *api.NewApplication) error {
+	var messages []string
+	for _, msg := range validation.IsDNS1035Label(appName) {
+		messages = append(messages, fmt.Sprintf("invalid app name %q: %s", appName, msg))
+	}
+
+	if newApp.Version == "" {
+		messages = append(messages, "app version is required")
+	} else {
+		for _, msg := range validation.IsDNS1123Label(newApp.Version) {
+			messages = append(messages, fmt.Sprintf("invalid app version %q: %s", newApp.Version, msg))
+		}
+		// the deployment is named after the app and the version
+		for _, msg := range validation.IsDNS1123Subdomain(fmt.Sprintf("%s-%s", appName, newApp.Version)) {
+			messages = append(messages, fmt.Sprintf("invalid deployment name: %s", msg))
+		}
+	}
+
+	if newApp.Replicas < 0 {
+		messages = append(messages, fmt.Sprintf("replicas must not be negative, %d given", newApp.Replicas))
+	}
+	if len(newApp.PodTemplate.Spec.Containers) == 0 {
+		messages = append(messages, "pod template requires at least one container")
+	}
+
+	if len(newApp.Ports) == 0 {
+		messages = append(messages, "at least one port is required")
+	}
+	names := make(map[string]bool)
+	for _, port := range newApp.Ports {
+		for _, msg := range validation.IsValidPortNum(int(port.Port)) {
+			messages = append(messages, fmt.Sprintf("invalid port %d: %s", port.Port, msg))
+		}
+		if port.TargetPort.IntValue() != 0 {
+			for _, msg := range validation.IsValidPortNum(port.TargetPort.IntValue()) {
+				messages = append(messages, fmt.Sprintf("invalid target port %d: %s", port.TargetPort.IntValue(), msg))
+			}
+		}
+		if port.Protocol != "" && port.Protocol != v1.ProtocolTCP && port.Protocol != v1.ProtocolUDP {
+			messages = append(messages, fmt.Sprintf("unsupported protocol %s of port %d", port.Protocol, port.Port))
+		}
+		// port names are required when the service has multiple ports
+		if port.Name == "" {
+			if len(newApp.Ports) > 1 {
+				messages = append(messages, fmt.Sprintf("name of port %d is required", port.Port))
+			}
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Label(port.Name) {
+			messages = append(messages, fmt.Sprintf("invalid name of port %d: %s", port.Port, msg))
+		}
+		if names[port.Name] {
+			messages = append(messages, fmt.Sprintf("duplicate port name %s", port.Name))
+		}
+		names[port.Name] = true
+	}
+
+	if len(messages) > 0 {
+		return k8sErrors.NewBadRequest(strings.Join(messages, "; "))
 	}
 	return nil
 }
 
+// rollback deletes the objects created so far, in the reverse order of their creation.
+type rollback []rollbackStep
+
+type rollbackStep struct {
+	kind   string
+	name   string
+	delete func() error
+}
+
+func (self *rollback) add(kind, name string, delete func() error) {
+	*self = append(*self, rollbackStep{kind: kind, name: name, delete: delete})
+}
+
+func (self rollback) run() {
+	for i := len(self) - 1; i >= 0; i-- {
+		if err := self[i].delete(); err != nil && !k8sErrors.IsNotFound(err) {
+			log.Printf("Failed to roll back %s %s: %v", self[i].kind, self[i].name, err)
+		}
+	}
+}
+
 // OfflineAppVersion offlines the specified app version from the virtualService with the same name.
+// The workload running the version is looked up with the label keys before the traffic is touched.
 func OfflineAppVersion(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
-	appName string, version string, offlineType string) error {
-	var (
-		virtualServices []istioApi.VirtualService
-		err             error
-	)
+	appName string, version string, offlineType string, keys api.LabelKeys) error {
+	workload, err := getVersionWorkload(client, namespace.ToRequestParam(), appName, version, keys)
+	if err != nil {
+		return err
+	}
 
-	virtualServices, err = virtualservice.GetVirtualServices(istioClient, []string{appName}, offlineType)
+	virtualServices, err := virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, offlineType)
 	if err != nil {
 		return err
 	}
@@ -295,74 +358,127 @@ func OfflineAppVersion(client kubernetes.Interface, istioClient istio.Interface,
 		}
 	}
 
+	// without a destination rule there is no subset to remove
 	dRule, err := istioClient.NetworkingV1alpha3().DestinationRules(namespace.ToRequestParam()).Get(appName, metav1.GetOptions{})
-	if err != nil {
-		log.Println("fail to get destination rule", err)
-	}
-
-	if err = removeFromDestinationRule(istioClient, dRule, version); err != nil {
-		log.Println("fail to remove from destination rule", err)
-	}
-
-	// Sleep 3 seconds for changing the flow
-	time.Sleep(3 * time.Second)
-
-	deps, err := getDeploymentByLabels(client, namespace, map[string]string{
-		"app":     appName,
-		"version": version,
-	})
-	if err != nil {
+	if err != nil && !kdErrors.IsNotFoundError(err) {
 		return err
 	}
-
-	if len(deps) != 1 {
-		return fmt.Errorf("deployment not found, %d given", len(deps))
+	if err == nil {
+		if err := removeFromDestinationRule(istioClient, dRule, version); err != nil {
+			log.Println("fail to remove from destination rule", err)
+		}
 	}
 
+	// the pods are given their grace period to finish the requests in flight
 	replicaDeletion := metav1.DeletePropagationBackground
-	err = client.ExtensionsV1beta1().Deployments(namespace.ToRequestParam()).Delete(
-		deps[0].Name, &metav1.DeleteOptions{GracePeriodSeconds: new(int64), PropagationPolicy: &replicaDeletion})
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return workload.delete(client, &metav1.DeleteOptions{PropagationPolicy: &replicaDeletion})
 }
 
 // DeleteApp deletes application
-// 1. delete deployment
+// 1. delete deployments and stateful sets labelled with the app
 // 2. delete destination rule
-// 3. delete virtual services
+// 3. delete virtual services of the app's host, the ones shared with other hosts are detached
 // 4. delete service
-func DeleteApp(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery, appName string) error {
-	// TODO wrap the error messages
-	// TODO delete virtual services by hosts
-
-	// 1. delete deployments
+// Every object is attempted, the result of each deletion is reported and an error is returned
+// when any of them failed.
+func DeleteApp(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	keys api.LabelKeys) (*api.AppDeletion, error) {
+	ns := namespace.ToRequestParam()
+	deletion := &api.AppDeletion{Results: make([]api.ObjectDeletion, 0)}
+
+	// 1. delete deployments and stateful sets
+	workloads, err := getParentWorkloads(client, ns, appName, keys)
+	if err != nil {
+		return nil, err
+	}
 	replicaDeletion := metav1.DeletePropagationBackground
-	client.ExtensionsV1beta1().Deployments(namespace.ToRequestParam()).Delete(appName, &metav1.DeleteOptions{
-		GracePeriodSeconds: new(int64), PropagationPolicy: &replicaDeletion,
-	})
+	for _, workload := range workloads {
+		err = workload.delete(client, &metav1.DeleteOptions{
+			GracePeriodSeconds: new(int64), PropagationPolicy: &replicaDeletion,
+		})
+		deletion.Results = append(deletion.Results, toObjectDeletion(workload.kind, workload.name, err))
+	}
 
 	// 2. delete destination rules
-	err := istioClient.NetworkingV1alpha3().DestinationRules(namespace.ToRequestParam()).Delete(appName, &metav1.DeleteOptions{})
-	if err != nil {
-		log.Println("fail to delete service: ", err)
-	}
+	err = istioClient.NetworkingV1alpha3().DestinationRules(ns).Delete(appName, &metav1.DeleteOptions{})
+	deletion.Results = append(deletion.Results, toObjectDeletion(api2.ResourceKindDestinationRule, appName, err))
 
 	// 3. delete virtual services
-	err = istioClient.NetworkingV1alpha3().VirtualServices(namespace.ToRequestParam()).Delete(appName, &metav1.DeleteOptions{})
+	host := virtualservice.FQDN(appName, ns)
+	virtualServices, err := virtualservice.GetVirtualServices(istioClient, []string{host}, virtualservice.OnlyHost)
 	if err != nil {
-		log.Println("fail to delete virtual service: ", err)
+		deletion.Results = append(deletion.Results, toObjectDeletion(api2.ResourceKindVirtualService, appName, err))
+	}
+	for i := range virtualServices {
+		deletion.Results = append(deletion.Results, deleteVirtualServiceHost(istioClient, &virtualServices[i], host))
 	}
 
 	// 4. delete service
-	err = client.CoreV1().Services(namespace.ToRequestParam()).Delete(appName, &metav1.DeleteOptions{})
-	if err != nil {
-		log.Println("fail to delete service: ", err)
+	err = client.CoreV1().Services(ns).Delete(appName, &metav1.DeleteOptions{})
+	deletion.Results = append(deletion.Results, toObjectDeletion(api2.ResourceKindService, appName, err))
+
+	var failed []string
+	for _, result := range deletion.Results {
+		if result.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s %s", result.Kind, result.Name))
+		}
+	}
+	if len(failed) > 0 {
+		return deletion, fmt.Errorf("failed to delete %s of app %s", strings.Join(failed, ", "), appName)
 	}
+	return deletion, nil
+}
 
-	return nil
+// deleteVirtualServiceHost deletes the virtual service of the host. A virtual service shared with
+// other hosts is kept, the host and the destinations routing to it are removed from it. It's only
+// deleted when the other hosts were all routed to the removed host.
+func deleteVirtualServiceHost(istioClient istio.Interface, vs *istioApi.VirtualService, host string) api.ObjectDeletion {
+	hosts := make([]string, 0, len(vs.Spec.Hosts))
+	for _, h := range vs.Spec.Hosts {
+		if virtualservice.FQDN(h, vs.Namespace) != host {
+			hosts = append(hosts, h)
+		}
+	}
+
+	routes := make([]*istioApi.HTTPRoute, 0, len(vs.Spec.Http))
+	for _, http := range vs.Spec.Http {
+		destinations := make([]*istioApi.DestinationWeight, 0, len(http.Route))
+		for _, dest := range http.Route {
+			if dest.Destination == nil || virtualservice.FQDN(dest.Destination.Host, vs.Namespace) != host {
+				destinations = append(destinations, dest)
+			}
+		}
+		if len(destinations) == 0 {
+			continue
+		}
+		if len(destinations) != len(http.Route) {
+			http.Route = reweight(destinations)
+		}
+		routes = append(routes, http)
+	}
+
+	if len(hosts) == 0 || len(routes) == 0 {
+		err := istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Delete(vs.Name, &metav1.DeleteOptions{})
+		return toObjectDeletion(api2.ResourceKindVirtualService, vs.Name, err)
+	}
+
+	vs.Spec.Hosts = hosts
+	vs.Spec.Http = routes
+	_, err := istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Update(vs)
+	result := toObjectDeletion(api2.ResourceKindVirtualService, vs.Name, err)
+	result.Deleted = false
+	result.Detached = err == nil
+	return result
+}
+
+// toObjectDeletion converts the result of a deletion, a missing object is not a failure.
+func toObjectDeletion(kind, name string, err error) api.ObjectDeletion {
+	result := api.ObjectDeletion{Kind: kind, Name: name, Deleted: err == nil}
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		log.Printf("Failed to delete %s %s: %v", kind, name, err)
+		result.Error = err.Error()
+	}
+	return result
 }
 
 // removeFromDestinationRule removes the specified version from destination rule.
@@ -396,14 +512,11 @@ func removeFromVirtualService(istioClient istio.Interface, service *istioApi.Vir
 }
 
 // TakeOverAllTraffic makes only one application's version is online.
-// 1. make sure if this app's versioned destination rule exist
+// 1. make sure a workload labelled with the keys runs the version
 // 2. change virtual service to this version
 func TakeOverAllTraffic(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
-	appName string, version string, offlineType string) error {
-	_, err := getDeploymentByLabels(client, namespace, map[string]string{
-		"app":     appName,
-		"version": version,
-	})
+	appName string, version string, offlineType string, keys api.LabelKeys) error {
+	_, err := getVersionWorkload(client, namespace.ToRequestParam(), appName, version, keys)
 	if err != nil {
 		return err
 	}
@@ -455,6 +568,133 @@ func TakeOverAllTraffic(client kubernetes.Interface, istioClient istio.Interface
 	return nil
 }
 
+// ShiftTraffic splits the application's traffic between its versions by the given weights.
+// 1. make sure the weights sum to 100 and every version is a subset of the app's destination rule
+// 2. rewrite the weighted routes of the virtual services, create one if none exists
+func ShiftTraffic(istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	shifting *api.TrafficShifting, targetType string) error {
+	destinations, err := getAppDestinationsByName(istioClient, namespace.ToRequestParam(), appName)
+	if err != nil {
+		return err
+	}
+
+	if err := validateWeights(shifting.Weights, destinations); err != nil {
+		return err
+	}
+
+	var virtualServices []istioApi.VirtualService
+	if virtualServices, err = virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, targetType,
+	); err != nil {
+		return err
+	}
+
+	if len(virtualServices) == 0 { // no any virtualServices exists, create one
+		vs := &istioApi.VirtualService{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "networking.istio.io/v1alpha3",
+				Kind:       "VirtualService",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      appName,
+				Namespace: namespace.ToRequestParam(),
+			},
+			Spec: istioApi.VirtualServiceSpec{
+				Hosts: []string{appName},
+				Http: []*istioApi.HTTPRoute{
+					{
+						Route: toDestinationWeights(&istioApi.Destination{Host: appName}, shifting.Weights),
+					},
+				},
+			},
+		}
+		_, err := istioClient.NetworkingV1alpha3().VirtualServices(namespace.ToRequestParam()).Create(vs)
+		return err
+	}
+
+	for _, vs := range virtualServices {
+		overrideWeights(&vs, appName, namespace.ToRequestParam(), shifting.Weights)
+		_, err = istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Update(&vs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWeights checks that the weights sum to 100 and that every weighted version is one of
+// the given destinations.
+func validateWeights(weights map[string]int32, destinations []api.Destination) error {
+	if len(weights) == 0 {
+		return k8sErrors.NewBadRequest("no version weights given")
+	}
+
+	var sum int32
+	for version, weight := range weights {
+		if weight < 0 || weight > 100 {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("weight of version %s must be between 0 and 100, %d given", version, weight))
+		}
+		if !hasDestination(destinations, version) {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("version %s is not a subset of the destination rule", version))
+		}
+		sum += weight
+	}
+
+	if sum != 100 {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("weights must sum to 100, %d given", sum))
+	}
+	return nil
+}
+
+// overrideWeights replaces all routes to the specified host with weighted routes to its subsets.
+func overrideWeights(vs *istioApi.VirtualService, host, namespace string, weights map[string]int32) {
+	host = virtualservice.FQDN(host, namespace)
+	for i := range vs.Spec.Http {
+		var template *istioApi.Destination
+		newRoute := []*istioApi.DestinationWeight{}
+		for _, dest := range vs.Spec.Http[i].Route {
+			if host == virtualservice.FQDN(dest.Destination.Host, vs.Namespace) {
+				if template == nil {
+					template = dest.Destination
+				}
+				continue
+			}
+			newRoute = append(newRoute, dest)
+		}
+
+		// the route doesn't point to the host, leave it as it is
+		if template == nil {
+			continue
+		}
+		vs.Spec.Http[i].Route = append(newRoute, toDestinationWeights(template, weights)...)
+	}
+}
+
+// toDestinationWeights creates weighted destinations from the template destination, one for each
+// version with positive weight, ordered by version name.
+func toDestinationWeights(template *istioApi.Destination, weights map[string]int32) []*istioApi.DestinationWeight {
+	versions := make([]string, 0, len(weights))
+	for version, weight := range weights {
+		if weight > 0 {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+
+	route := make([]*istioApi.DestinationWeight, 0, len(versions))
+	for _, version := range versions {
+		route = append(route, &istioApi.DestinationWeight{
+			Destination: &istioApi.Destination{
+				Host:   template.Host,
+				Subset: version,
+				Port:   template.Port,
+			},
+			Weight: weights[version],
+		})
+	}
+	return route
+}
+
 func overrideSubset(vs *istioApi.VirtualService, host, namespace, subset string) {
 	host = virtualservice.FQDN(host, namespace)
 	for i := range vs.Spec.Http {
@@ -15,13 +15,23 @@
 package app
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/magiconair/properties/assert"
 
 	"github.com/kubernetes/dashboard/src/app/backend/api"
+	istioapi "github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
 	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
 func TestTakeOver(t *testing.T) {
@@ -84,3 +94,219 @@ func TestTakeOver(t *testing.T) {
 	assert.Equal(t, vs.Spec.Http[0].Route[2].Destination.Host, "something_else")
 	assert.Equal(t, vs.Spec.Http[0].Route[2].Destination.Subset, "v3")
 }
+
+func TestOverrideWeights(t *testing.T) {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "wall",
+		},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"test"},
+			Http: []*v1alpha3.HTTPRoute{
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{
+							Destination: &v1alpha3.Destination{
+								Host:   "test",
+								Subset: "v1",
+								Port: &v1alpha3.PortSelector{
+									Number: 8080,
+								},
+							},
+							Weight: 100,
+						},
+					},
+				},
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{
+							Destination: &v1alpha3.Destination{
+								Host:   "something_else",
+								Subset: "v1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	overrideWeights(&vs, "test", "wall", map[string]int32{"v2": 25, "v1": 75, "v3": 0})
+
+	assert.Equal(t, len(vs.Spec.Http[0].Route), 2)
+	assert.Equal(t, vs.Spec.Http[0].Route[0].Destination.Subset, "v1")
+	assert.Equal(t, vs.Spec.Http[0].Route[0].Destination.Port.Number, uint32(8080))
+	assert.Equal(t, vs.Spec.Http[0].Route[0].Weight, int32(75))
+	assert.Equal(t, vs.Spec.Http[0].Route[1].Destination.Subset, "v2")
+	assert.Equal(t, vs.Spec.Http[0].Route[1].Weight, int32(25))
+
+	assert.Equal(t, len(vs.Spec.Http[1].Route), 1)
+	assert.Equal(t, vs.Spec.Http[1].Route[0].Destination.Host, "something_else")
+}
+
+func TestValidateWeights(t *testing.T) {
+	destinations := []istioapi.Destination{{Version: "v1"}, {Version: "v2"}}
+	cases := []struct {
+		weights  map[string]int32
+		expected bool
+	}{
+		{map[string]int32{"v1": 90, "v2": 10}, true},
+		{map[string]int32{"v1": 100}, true},
+		{map[string]int32{"v1": 90, "v2": 20}, false},
+		{map[string]int32{"v1": 90, "v3": 10}, false},
+		{map[string]int32{"v1": 110, "v2": -10}, false},
+		{map[string]int32{}, false},
+	}
+
+	for _, c := range cases {
+		err := validateWeights(c.weights, destinations)
+		assert.Equal(t, err == nil, c.expected)
+	}
+}
+
+func TestValidateNewApplication(t *testing.T) {
+	valid := func() *istioapi.NewApplication {
+		return &istioapi.NewApplication{
+			Version: "v1",
+			Ports:   []v1.ServicePort{{Name: "http", Port: 80}},
+			PodTemplate: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "nginx"}}},
+			},
+		}
+	}
+
+	cases := []struct {
+		appName  string
+		mutate   func(app *istioapi.NewApplication)
+		expected bool
+	}{
+		{"reviews", func(app *istioapi.NewApplication) {}, true},
+		{"Reviews", func(app *istioapi.NewApplication) {}, false},
+		{"1reviews", func(app *istioapi.NewApplication) {}, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.Version = "" }, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.Version = "v1.0" }, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.Replicas = -1 }, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.PodTemplate.Spec.Containers = nil }, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.Ports = nil }, false},
+		{"reviews", func(app *istioapi.NewApplication) { app.Ports[0].Port = 70000 }, false},
+		{"reviews", func(app *istioapi.NewApplication) {
+			app.Ports = append(app.Ports, v1.ServicePort{Name: "http", Port: 8080})
+		}, false},
+		{"reviews", func(app *istioapi.NewApplication) {
+			app.Ports = append(app.Ports, v1.ServicePort{Port: 8080})
+		}, false},
+		{"reviews", func(app *istioapi.NewApplication) {
+			app.Ports = append(app.Ports, v1.ServicePort{Name: "grpc", Port: 8080, Protocol: v1.ProtocolTCP})
+		}, true},
+	}
+
+	for _, c := range cases {
+		app := valid()
+		c.mutate(app)
+		err := validateNewApplication(c.appName, app)
+		assert.Equal(t, err == nil, c.expected)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	var deleted []string
+	var rollbacks rollback
+	for _, name := range []string{"service", "deployment", "destinationrule"} {
+		name := name
+		rollbacks.add(name, "reviews", func() error {
+			deleted = append(deleted, name)
+			if name == "deployment" {
+				return errors.New("deployment can not be deleted")
+			}
+			return nil
+		})
+	}
+
+	rollbacks.run()
+	assert.Equal(t, deleted, []string{"destinationrule", "deployment", "service"})
+}
+
+func TestToObjectDeletion(t *testing.T) {
+	result := toObjectDeletion(api.ResourceKindService, "reviews", nil)
+	assert.Equal(t, result, istioapi.ObjectDeletion{Kind: api.ResourceKindService, Name: "reviews", Deleted: true})
+
+	notFound := k8sErrors.NewNotFound(schema.GroupResource{Resource: "services"}, "reviews")
+	result = toObjectDeletion(api.ResourceKindService, "reviews", notFound)
+	assert.Equal(t, result, istioapi.ObjectDeletion{Kind: api.ResourceKindService, Name: "reviews"})
+
+	result = toObjectDeletion(api.ResourceKindService, "reviews", errors.New("forbidden"))
+	assert.Equal(t, result, istioapi.ObjectDeletion{Kind: api.ResourceKindService, Name: "reviews", Error: "forbidden"})
+}
+
+func TestOfflineAppVersionWithoutDestinationRule(t *testing.T) {
+	updates := []string{}
+	server := newResilienceServer(&updates)
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+	versioned := map[string]string{recommendedKeys.App: "ratings", recommendedKeys.Version: "v1"}
+	deployment := newParentDeployment("ratings-v1", "v1", versioned)
+	deployment.Spec.Template.Labels = versioned
+	client := fake.NewSimpleClientset(deployment)
+
+	// the server has no destination rule of ratings, there is no subset to remove
+	err = OfflineAppVersion(client, istioClient, common.NewSameNamespaceQuery("default"), "ratings", "v1",
+		virtualservice.All, recommendedKeys)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(updates), 0)
+
+	_, err = client.ExtensionsV1beta1().Deployments("default").Get("ratings-v1", metav1.GetOptions{})
+	assert.Equal(t, k8sErrors.IsNotFound(err), true)
+}
+
+func TestDeleteVirtualServiceHost(t *testing.T) {
+	updates := []string{}
+	server := newResilienceServer(&updates)
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+	host := virtualservice.FQDN("reviews", "default")
+
+	own := &v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"reviews"},
+			Http: []*v1alpha3.HTTPRoute{{Route: []*v1alpha3.DestinationWeight{
+				{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}},
+			}}},
+		},
+	}
+	result := deleteVirtualServiceHost(istioClient, own, host)
+	assert.Equal(t, result, istioapi.ObjectDeletion{Kind: api.ResourceKindVirtualService, Name: "reviews", Deleted: true})
+
+	shared := &v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "bookinfo", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"api.test.com", "reviews.default.svc.cluster.local"},
+			Http: []*v1alpha3.HTTPRoute{
+				{Route: []*v1alpha3.DestinationWeight{
+					{Destination: &v1alpha3.Destination{Host: "reviews"}},
+				}},
+				{Route: []*v1alpha3.DestinationWeight{
+					{Destination: &v1alpha3.Destination{Host: "reviews"}, Weight: 20},
+					{Destination: &v1alpha3.Destination{Host: "ratings"}, Weight: 80},
+				}},
+			},
+		},
+	}
+	result = deleteVirtualServiceHost(istioClient, shared, host)
+	assert.Equal(t, result, istioapi.ObjectDeletion{Kind: api.ResourceKindVirtualService, Name: "bookinfo", Detached: true})
+	assert.Equal(t, shared.Spec.Hosts, []string{"api.test.com"})
+	assert.Equal(t, len(shared.Spec.Http), 1)
+	assert.Equal(t, shared.Spec.Http[0].Route[0].Destination.Host, "ratings")
+
+	assert.Equal(t, updates, []string{
+		"/apis/networking.istio.io/v1alpha3/namespaces/default/virtualservices/reviews",
+		"/apis/networking.istio.io/v1alpha3/namespaces/default/virtualservices/bookinfo",
+	})
+}
@@ -15,8 +15,13 @@
 package app
 
 import (
+	"log"
+
 	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/analysis"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/injection"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/security"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/namespace"
@@ -26,18 +31,21 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
 // GetAppDeploySpec queries the specified application's k8s deployment.
-func GetAppDeploySpec(client kubernetes.Interface, namespace *common.NamespaceQuery, appName string) ([]v1beta1.Deployment, error) {
+func GetAppDeploySpec(client kubernetes.Interface, namespace *common.NamespaceQuery, appName string,
+	keys api.LabelKeys) ([]v1beta1.Deployment, error) {
 	return getDeploymentByLabels(client, namespace, map[string]string{
-		"app": appName,
+		keys.App: appName,
 	})
 }
 
 // GetAppDetail queries the specified application's deploy.
-func GetAppDetail(client kubernetes.Interface, istioClient istio.Interface, nsQuery *common.NamespaceQuery, appName string, dataSelect *dataselect.DataSelectQuery) (*api.App, error) {
+func GetAppDetail(client kubernetes.Interface, istioClient istio.Interface, nsQuery *common.NamespaceQuery, appName string,
+	dataSelect *dataselect.DataSelectQuery, keys api.LabelKeys) (*api.App, error) {
 	dRules, err := istioClient.Networking().DestinationRules(nsQuery.ToRequestParam()).List(metaV1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -58,14 +66,67 @@ func GetAppDetail(client kubernetes.Interface, istioClient istio.Interface, nsQu
 		return nil, err
 	}
 
+	pods, err := client.CoreV1().Pods(nsQuery.ToRequestParam()).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := client.AppsV1().Deployments(nsQuery.ToRequestParam()).List(metaV1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{keys.App: appName}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// merge destinationRules & services
-	var app = getAppDetail(svc, vServices, dRules.Items, ns)
+	var app = getAppDetail(svc, vServices, dRules.Items, ns, pods.Items)
+	app.Status.Workloads = injection.GetWorkloadInjections(toNamespace(ns), deployments.Items, pods.Items)
 	app.Metrics = *GetAppMetrics(client, app)
+
+	// the findings are informative, the detail is returned without them when the analysis fails
+	findings, err := analysis.GetFindings(istioClient, common.NewSameNamespaceQuery(nsQuery.ToRequestParam()))
+	if err != nil {
+		log.Printf("Failed to analyze istio configuration of %s app: %v", appName, err)
+	} else {
+		app.Findings = analysis.FilterFindings(findings.Findings, getAppObjects(app, dRules.Items))
+	}
+
+	// so is the security, it is computed from the objects the typed istio client doesn't handle
+	input, err := security.GetInput(istioClient, nsQuery.ToRequestParam())
+	if err != nil {
+		log.Printf("Failed to get security of %s app: %v", appName, err)
+	} else {
+		app.Security = security.GetServiceSecurity(input, nsQuery.ToRequestParam(), appName)
+	}
 	return app, nil
 }
 
+// getAppObjects returns the virtual services and destination rules of the app.
+func getAppObjects(app *api.App, dRules []istioApi.DestinationRule) []api.FindingObject {
+	var objects []api.FindingObject
+	for _, vs := range app.VirtualServices {
+		objects = append(objects, api.FindingObject{
+			Kind:      api2.ResourceKindVirtualService,
+			Namespace: vs.ObjectMeta.Namespace,
+			Name:      vs.ObjectMeta.Name,
+		})
+	}
+
+	appAddr := virtualservice.FQDN(app.ObjectMeta.Name, app.ObjectMeta.Namespace)
+	for _, dRule := range dRules {
+		if appAddr == virtualservice.FQDN(dRule.Spec.Host, dRule.ObjectMeta.Namespace) {
+			objects = append(objects, api.FindingObject{
+				Kind:      api2.ResourceKindDestinationRule,
+				Namespace: dRule.ObjectMeta.Namespace,
+				Name:      dRule.ObjectMeta.Name,
+			})
+		}
+	}
+	return objects
+}
+
 func getAppDetail(svc *v1.Service, vServices []istioApi.VirtualService,
-	dRules []istioApi.DestinationRule, namespace *namespace.NamespaceDetail) *api.App {
+	dRules []istioApi.DestinationRule, namespace *namespace.NamespaceDetail, pods []v1.Pod) *api.App {
 	app := &api.App{
 		ObjectMeta: api2.NewObjectMeta(svc.ObjectMeta),
 		TypeMeta: api2.TypeMeta{
@@ -85,15 +146,42 @@ func getAppDetail(svc *v1.Service, vServices []istioApi.VirtualService,
 
 	if vServices != nil && len(vServices) > 0 {
 		app.VirtualServices = getAppVirtualServices(app, vServices)
+		app.Mirrors = getAppMirrors(svc.Name, svc.Namespace, vServices)
 	}
 
 	// add these applications' istio statuses
-	if namespace != nil {
-		if namespace.ObjectMeta.Labels["istio-injection"] == "enabled" {
-			app.Status.Istio = true
+	setSidecarStatus(app, toNamespace(namespace), svc.Spec.Selector, pods)
+	return app
+}
+
+// setSidecarStatus sets the istio status of the app from the pods its service selects. An app
+// without pods is istio-enabled when the injection is enabled in its namespace.
+func setSidecarStatus(app *api.App, namespace *v1.Namespace, selector map[string]string, pods []v1.Pod) {
+	var selected []v1.Pod
+	if len(selector) > 0 {
+		for _, pod := range pods {
+			if pod.Namespace == app.ObjectMeta.Namespace && labels.SelectorFromSet(selector).Matches(labels.Set(pod.Labels)) {
+				selected = append(selected, pod)
+			}
 		}
 	}
-	return app
+
+	running, injected, images := injection.Sidecars(selected)
+	if running == 0 {
+		app.Status.Istio = injection.NamespaceEnabled(namespace)
+		return
+	}
+	app.Status.Istio = injected > 0
+	app.Status.SidecarVersions = injection.ImageVersions(images)
+}
+
+func toNamespace(detail *namespace.NamespaceDetail) *v1.Namespace {
+	if detail == nil {
+		return nil
+	}
+	return &v1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{Name: detail.ObjectMeta.Name, Labels: detail.ObjectMeta.Labels},
+	}
 }
 
 // getDeploymentByLabels filters deployments by the labels.
@@ -0,0 +1,196 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// containerFields are the container fields compared between versions.
+var containerFields = []string{
+	"image", "command", "args", "env", "envFrom", "resources", "livenessProbe", "readinessProbe", "volumeMounts",
+}
+
+// DiffVersions compares the pod templates of the deployments or stateful sets of two versions of
+// an application, and the traffic policies of their destination rule subsets.
+func DiffVersions(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName, from, to string, keys api.LabelKeys) (*api.VersionDiff, error) {
+	fromWorkload, err := getVersionWorkload(client, namespace.ToRequestParam(), appName, from, keys)
+	if err != nil {
+		return nil, err
+	}
+	toWorkload, err := getVersionWorkload(client, namespace.ToRequestParam(), appName, to, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &api.VersionDiff{From: from, To: to, Changes: make([]api.FieldChange, 0)}
+	if err := diffPodSpecs(&fromWorkload.template.Spec, &toWorkload.template.Spec, &diff.Changes); err != nil {
+		return nil, err
+	}
+
+	// the rule is read raw, the typed client does not decode the traffic policies of istio
+	rule, err := getIstioObject(istioClient, namespace.ToRequestParam(), "destinationrules", appName)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		diffValues("trafficPolicy", subsetTrafficPolicy(rule, from), subsetTrafficPolicy(rule, to), &diff.Changes)
+	}
+	return diff, nil
+}
+
+// diffPodSpecs compares the selected fields of the containers, matched by name, and the volumes.
+func diffPodSpecs(from, to *v1.PodSpec, changes *[]api.FieldChange) error {
+	fromContainers, err := toContainerMaps(from.Containers)
+	if err != nil {
+		return err
+	}
+	toContainers, err := toContainerMaps(to.Containers)
+	if err != nil {
+		return err
+	}
+	diffValues("containers", fromContainers, toContainers, changes)
+
+	fromVolumes, err := toUnstructuredList(from.Volumes)
+	if err != nil {
+		return err
+	}
+	toVolumes, err := toUnstructuredList(to.Volumes)
+	if err != nil {
+		return err
+	}
+	diffValues("volumes", fromVolumes, toVolumes, changes)
+	return nil
+}
+
+func toContainerMaps(containers []v1.Container) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(containers))
+	for i := range containers {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&containers[i])
+		if err != nil {
+			return nil, err
+		}
+		container := map[string]interface{}{"name": containers[i].Name}
+		for _, field := range containerFields {
+			if value, ok := obj[field]; ok {
+				container[field] = value
+			}
+		}
+		result = append(result, container)
+	}
+	return result, nil
+}
+
+func toUnstructuredList(volumes []v1.Volume) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(volumes))
+	for i := range volumes {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&volumes[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+// subsetTrafficPolicy returns the traffic policy of the subset of the version in a raw destination
+// rule, whichever of the camel and snake case fields is set.
+func subsetTrafficPolicy(rule map[string]interface{}, version string) interface{} {
+	spec, _ := rule["spec"].(map[string]interface{})
+	subsets, _ := spec["subsets"].([]interface{})
+	for _, s := range subsets {
+		subset, _ := s.(map[string]interface{})
+		if subset["name"] != version {
+			continue
+		}
+		if policy, ok := subset["trafficPolicy"]; ok {
+			return policy
+		}
+		return subset["traffic_policy"]
+	}
+	return nil
+}
+
+// diffValues appends the changes between two unstructured values. Maps are compared key by key and
+// lists of named items, like containers, env or volumes, item by item, other values as a whole.
+func diffValues(path string, old, new interface{}, changes *[]api.FieldChange) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		for _, key := range unionKeys(oldMap, newMap) {
+			diffValues(path+"."+key, oldMap[key], newMap[key], changes)
+		}
+		return
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		oldNamed, oldOk := byName(oldList)
+		newNamed, newOk := byName(newList)
+		if oldOk && newOk {
+			for _, name := range unionKeys(oldNamed, newNamed) {
+				diffValues(fmt.Sprintf("%s[%s]", path, name), oldNamed[name], newNamed[name], changes)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, api.FieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+// byName indexes a list whose items are all maps with a name.
+func byName(list []interface{}) (map[string]interface{}, bool) {
+	named := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := obj["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		named[name] = obj
+	}
+	return named, true
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestDiffPodSpecs(t *testing.T) {
+	from := &v1.PodSpec{
+		Containers: []v1.Container{{
+			Name:  "reviews",
+			Image: "reviews:v1",
+			Env:   []v1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}, {Name: "STAR_COLOR", Value: "black"}},
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+			},
+		}},
+		Volumes: []v1.Volume{{Name: "config", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}},
+	}
+	to := &v1.PodSpec{
+		Containers: []v1.Container{
+			{
+				Name:  "reviews",
+				Image: "reviews:v2",
+				Env:   []v1.EnvVar{{Name: "STAR_COLOR", Value: "black"}, {Name: "LOG_LEVEL", Value: "debug"}},
+				Resources: v1.ResourceRequirements{
+					Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m")},
+				},
+				ReadinessProbe: &v1.Probe{InitialDelaySeconds: 5},
+			},
+			{Name: "proxy", Image: "proxy:1.0"},
+		},
+		Volumes: []v1.Volume{{Name: "config", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}},
+	}
+
+	var changes []api.FieldChange
+	if err := diffPodSpecs(from, to, &changes); err != nil {
+		t.Fatalf("diffPodSpecs() error: %v", err)
+	}
+
+	expected := []api.FieldChange{
+		{Path: "containers[proxy]", New: map[string]interface{}{"name": "proxy", "image": "proxy:1.0", "resources": map[string]interface{}{}}},
+		{Path: "containers[reviews].env[LOG_LEVEL].value", Old: "info", New: "debug"},
+		{Path: "containers[reviews].image", Old: "reviews:v1", New: "reviews:v2"},
+		{Path: "containers[reviews].readinessProbe", New: map[string]interface{}{"initialDelaySeconds": int64(5)}},
+		{Path: "containers[reviews].resources.limits.cpu", Old: "100m", New: "200m"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diffPodSpecs() got %#v, expected %#v", changes, expected)
+	}
+}
+
+func TestSubsetTrafficPolicy(t *testing.T) {
+	rule := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"host": "reviews",
+			"subsets": []interface{}{
+				map[string]interface{}{
+					"name":          "v1",
+					"trafficPolicy": map[string]interface{}{"loadBalancer": map[string]interface{}{"simple": "ROUND_ROBIN"}},
+				},
+				map[string]interface{}{
+					"name":           "v2",
+					"traffic_policy": map[string]interface{}{"loadBalancer": map[string]interface{}{"simple": "RANDOM"}},
+				},
+				map[string]interface{}{"name": "v3"},
+			},
+		},
+	}
+
+	var changes []api.FieldChange
+	diffValues("trafficPolicy", subsetTrafficPolicy(rule, "v1"), subsetTrafficPolicy(rule, "v2"), &changes)
+	diffValues("trafficPolicy", subsetTrafficPolicy(rule, "v3"), subsetTrafficPolicy(rule, "v4"), &changes)
+
+	expected := []api.FieldChange{
+		{Path: "trafficPolicy.loadBalancer.simple", Old: "ROUND_ROBIN", New: "RANDOM"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diffValues() got %#v, expected %#v", changes, expected)
+	}
+}
+
+func TestDiffVersions(t *testing.T) {
+	updates := []string{}
+	server := newResilienceServer(&updates)
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+	dep := newParentDeployment("reviews-v1", "v1",
+		map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v1"})
+	dep.Spec.Template.Spec.Containers = []v1.Container{{Name: "reviews", Image: "reviews:v1"}}
+	set := newParentStatefulSet("reviews-v2", "v2")
+	set.Spec.Template.Spec.Containers = []v1.Container{{Name: "reviews", Image: "reviews:v2"}}
+	client := fake.NewSimpleClientset(dep, set)
+	namespace := common.NewSameNamespaceQuery("default")
+
+	diff, err := DiffVersions(client, istioClient, namespace, "reviews", "v1", "v2", recommendedKeys)
+	if err != nil {
+		t.Fatalf("DiffVersions() returned error: %v", err)
+	}
+	expected := []api.FieldChange{{Path: "containers[reviews].image", Old: "reviews:v1", New: "reviews:v2"}}
+	if !reflect.DeepEqual(diff.Changes, expected) {
+		t.Errorf("DiffVersions() == %+v, expected %+v", diff.Changes, expected)
+	}
+
+	if _, err := DiffVersions(client, istioClient, namespace, "reviews", "v1", "v3", recommendedKeys); !k8sErrors.IsNotFound(err) {
+		t.Errorf("DiffVersions() of unknown version == %v, expected not found", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	kdErrors "github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultDrainTimeout is used when the drain spec doesn't give a timeout.
+	DefaultDrainTimeout = 60 * time.Second
+	// maxDrainTimeout bounds the time a version is kept alive after it has been detached.
+	maxDrainTimeout = time.Hour
+	// drainPeriod is the interval the request rate and the scale down are checked at.
+	drainPeriod = 5 * time.Second
+	// drainWindow is the time range the request rate is computed over.
+	drainWindow = 30 * time.Second
+	// scaleDownTimeout is the maximum time to wait for the pods to terminate.
+	scaleDownTimeout = 5 * time.Minute
+	// drainStatusTTL is the time the status of a finished drain is kept for.
+	drainStatusTTL = time.Hour
+)
+
+// RequestRateSource reports the number of requests per second an application version receives.
+type RequestRateSource interface {
+	RequestRate(namespace, appName, version string, window time.Duration) (float64, error)
+}
+
+// Drainer offlines application versions gracefully in the background. The version is detached
+// from the virtual services first, it keeps running until the sidecars have stopped routing to
+// it, then its deployment is scaled to zero and deleted.
+type Drainer struct {
+	// client and istioClient use the privileges of the dashboard service account, the drains
+	// outlive the requests and the tokens of the users who started them.
+	client      kubernetes.Interface
+	istioClient istio.Interface
+	// requestRate observes the traffic to the drained version. Without it, the version is kept
+	// until the drain timeout expires.
+	requestRate RequestRateSource
+	period      time.Duration
+
+	mu     sync.Mutex
+	drains map[string]*api.DrainStatus
+}
+
+// NewDrainer creates a Drainer offlining the versions with the given clients and observing the
+// traffic with the given source, which may be nil.
+func NewDrainer(client kubernetes.Interface, istioClient istio.Interface, requestRate RequestRateSource) *Drainer {
+	return &Drainer{
+		client:      client,
+		istioClient: istioClient,
+		requestRate: requestRate,
+		period:      drainPeriod,
+		drains:      make(map[string]*api.DrainStatus),
+	}
+}
+
+// Drain detaches the version from the virtual services selected by the target type and starts
+// offlining it in the background. The version is detached with the clients of the request, the
+// background work uses the clients of the drainer once the user of the request has been checked
+// to be allowed to do it. The returned status can be followed with DrainStatus.
+func (self *Drainer) Drain(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName, version, targetType string, spec *api.DrainSpec, keys api.LabelKeys) (*api.DrainStatus, error) {
+	ns := namespace.ToRequestParam()
+
+	timeout := DefaultDrainTimeout
+	if spec != nil && spec.Timeout != 0 {
+		timeout = time.Duration(spec.Timeout) * time.Second
+	}
+	if timeout < 0 || timeout > maxDrainTimeout {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("drain timeout must be between 0 and %d seconds",
+			int64(maxDrainTimeout/time.Second)))
+	}
+
+	if err := CheckSelfAccess(client, OfflineAccess(ns)); err != nil {
+		return nil, err
+	}
+
+	status, err := self.reserve(ns, appName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := detachVersion(istioClient, ns, appName, version, targetType); err != nil {
+		self.release(ns, appName, version)
+		return nil, err
+	}
+
+	log.Printf("Draining version %s of %s app in %s namespace", version, appName, ns)
+	go self.run(namespace, appName, version, timeout, keys)
+	return status, nil
+}
+
+// DrainStatus returns the status of the last drain of the version to the users allowed to read
+// the workloads of the namespace. Finished drains are forgotten after drainStatusTTL.
+func (self *Drainer) DrainStatus(client kubernetes.Interface, namespace, appName, version string) (*api.DrainStatus,
+	error) {
+	if err := CheckSelfAccess(client, ReadAccess(namespace)); err != nil {
+		return nil, err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.prune(time.Now())
+
+	status, ok := self.drains[drainKey(namespace, appName, version)]
+	if !ok {
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "drains"}, fmt.Sprintf("%s-%s", appName, version))
+	}
+	copied := *status
+	return &copied, nil
+}
+
+// reserve registers a new drain of the version, a conflict is returned when one is in progress.
+func (self *Drainer) reserve(namespace, appName, version string) (*api.DrainStatus, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.prune(time.Now())
+
+	key := drainKey(namespace, appName, version)
+	if status, ok := self.drains[key]; ok && status.CompletionTime == nil {
+		return nil, k8sErrors.NewAlreadyExists(schema.GroupResource{Resource: "drains"}, fmt.Sprintf("%s-%s", appName, version))
+	}
+
+	status := &api.DrainStatus{
+		Namespace:   namespace,
+		App:         appName,
+		Version:     version,
+		Phase:       api.DrainDraining,
+		RequestRate: -1,
+		StartTime:   metav1.Now(),
+	}
+	self.drains[key] = status
+	copied := *status
+	return &copied, nil
+}
+
+func (self *Drainer) release(namespace, appName, version string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.drains, drainKey(namespace, appName, version))
+}
+
+// prune removes the drains which finished more than drainStatusTTL before now. It must be called
+// with the lock held.
+func (self *Drainer) prune(now time.Time) {
+	for key, status := range self.drains {
+		if status.CompletionTime != nil && now.Sub(status.CompletionTime.Time) > drainStatusTTL {
+			delete(self.drains, key)
+		}
+	}
+}
+
+// update applies the change to the status of the drain under the lock.
+func (self *Drainer) update(namespace, appName, version string, change func(status *api.DrainStatus)) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if status, ok := self.drains[drainKey(namespace, appName, version)]; ok {
+		change(status)
+	}
+}
+
+func (self *Drainer) run(namespace *common.NamespaceQuery, appName, version string, timeout time.Duration,
+	keys api.LabelKeys) {
+	ns := namespace.ToRequestParam()
+
+	message := self.waitForDrain(ns, appName, version, timeout)
+
+	err := offlineDrainedVersion(self.client, self.istioClient, namespace, appName, version, keys, self.period, func() {
+		self.update(ns, appName, version, func(status *api.DrainStatus) {
+			status.Phase = api.DrainScalingDown
+		})
+	})
+
+	self.update(ns, appName, version, func(status *api.DrainStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Phase = api.DrainCompleted
+		status.Message = message
+		if err != nil {
+			log.Printf("Failed to offline version %s of %s app in %s namespace: %v", version, appName, ns, err)
+			status.Phase = api.DrainFailed
+			status.Message = err.Error()
+		}
+	})
+}
+
+// waitForDrain waits until the version receives no more requests or the timeout expires and
+// returns a message when the version was not observed drained.
+func (self *Drainer) waitForDrain(namespace, appName, version string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		if self.requestRate != nil {
+			rate, err := self.requestRate.RequestRate(namespace, appName, version, drainWindow)
+			if err != nil {
+				log.Printf("Failed to get request rate of version %s of %s app: %v", version, appName, err)
+			} else {
+				self.update(namespace, appName, version, func(status *api.DrainStatus) {
+					status.RequestRate = rate
+				})
+				if rate == 0 {
+					return ""
+				}
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			if self.requestRate == nil {
+				return "request rate is not available, drained for the whole timeout"
+			}
+			return "timed out waiting for the traffic to stop"
+		}
+		time.Sleep(self.period)
+	}
+}
+
+// detachVersion removes the version from the routes of the virtual services, the remaining
+// destinations of each route are reweighted to receive all of its traffic.
+func detachVersion(istioClient istio.Interface, namespace, appName, version, targetType string) error {
+	destinations, err := getAppDestinationsByName(istioClient, namespace, appName)
+	if err != nil {
+		return err
+	}
+	if !hasDestination(destinations, version) {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("version %s is not a subset of the destination rule", version))
+	}
+
+	virtualServices, err := virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, namespace)}, targetType)
+	if err != nil {
+		return err
+	}
+
+	// check all the virtual services before changing any of them
+	for i := range virtualServices {
+		if err := detachSubset(&virtualServices[i], appName, namespace, version); err != nil {
+			return err
+		}
+	}
+
+	for i := range virtualServices {
+		if _, err := istioClient.NetworkingV1alpha3().VirtualServices(virtualServices[i].Namespace).
+			Update(&virtualServices[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachSubset removes the host's subset from the routes of the virtual service. A route left
+// without destinations would fail the requests, so detaching the only destination is refused.
+func detachSubset(vs *istioApi.VirtualService, host, namespace, subset string) error {
+	host = virtualservice.FQDN(host, namespace)
+	for _, http := range vs.Spec.Http {
+		newRoute := make([]*istioApi.DestinationWeight, 0, len(http.Route))
+		for _, dest := range http.Route {
+			if dest.Destination.Subset == subset && host == virtualservice.FQDN(dest.Destination.Host, vs.Namespace) {
+				continue
+			}
+			newRoute = append(newRoute, dest)
+		}
+
+		if len(newRoute) == len(http.Route) {
+			continue
+		}
+		if len(newRoute) == 0 {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("version %s is the only destination of a route of virtual service %s",
+				subset, vs.Name))
+		}
+		http.Route = reweight(newRoute)
+	}
+	return nil
+}
+
+// reweight scales the weights of the destinations up to sum to 100, keeping their proportions.
+func reweight(route []*istioApi.DestinationWeight) []*istioApi.DestinationWeight {
+	if len(route) == 1 {
+		// a single destination receives all the traffic without weight
+		route[0].Weight = 0
+		return route
+	}
+
+	var total int32
+	for _, dest := range route {
+		total += dest.Weight
+	}
+	if total == 0 {
+		return route
+	}
+
+	var sum int32
+	for _, dest := range route {
+		dest.Weight = dest.Weight * 100 / total
+		sum += dest.Weight
+	}
+	// the rounding remainder goes to the first destination
+	route[0].Weight += 100 - sum
+	return route
+}
+
+// offlineDrainedVersion removes the version from the destination rule, scales its workloads to
+// zero so their pods terminate gracefully and deletes the workloads once they are gone.
+func offlineDrainedVersion(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName, version string, keys api.LabelKeys, period time.Duration, scalingDown func()) error {
+	ns := namespace.ToRequestParam()
+
+	dRule, err := istioClient.NetworkingV1alpha3().DestinationRules(ns).Get(appName, metav1.GetOptions{})
+	if err != nil && !kdErrors.IsNotFoundError(err) {
+		return err
+	}
+	if err == nil {
+		if err := removeFromDestinationRule(istioClient, dRule, version); err != nil {
+			return err
+		}
+	}
+
+	workloads, err := getVersionWorkloads(client, ns, appName, version, keys)
+	if err != nil {
+		return err
+	}
+
+	scalingDown()
+	for i := range workloads {
+		if err := workloads[i].scale(client, 0); err != nil {
+			return err
+		}
+	}
+
+	for i := range workloads {
+		workload := &workloads[i]
+		err := wait.PollImmediate(period, scaleDownTimeout, func() (bool, error) {
+			replicas, err := workload.currentReplicas(client)
+			if err != nil {
+				return false, err
+			}
+			return replicas == 0, nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s %s did not scale down: %v", workload.kind, workload.name, err)
+		}
+
+		replicaDeletion := metav1.DeletePropagationBackground
+		if err := workload.delete(client, &metav1.DeleteOptions{PropagationPolicy: &replicaDeletion}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func drainKey(namespace, appName, version string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, appName, version)
+}
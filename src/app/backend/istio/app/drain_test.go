@@ -0,0 +1,188 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+
+	istioapi "github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestDetachSubset(t *testing.T) {
+	vs := &v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"reviews"},
+			Http: []*v1alpha3.HTTPRoute{
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}, Weight: 50},
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v2"}, Weight: 30},
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v3"}, Weight: 20},
+					},
+				},
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}, Weight: 90},
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v3"}, Weight: 10},
+					},
+				},
+			},
+		},
+	}
+
+	err := detachSubset(vs, "reviews", "default", "v3")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(vs.Spec.Http[0].Route), 2)
+	assert.Equal(t, vs.Spec.Http[0].Route[0].Weight, int32(63))
+	assert.Equal(t, vs.Spec.Http[0].Route[1].Weight, int32(37))
+	assert.Equal(t, len(vs.Spec.Http[1].Route), 1)
+	assert.Equal(t, vs.Spec.Http[1].Route[0].Destination.Subset, "v1")
+	assert.Equal(t, vs.Spec.Http[1].Route[0].Weight, int32(0))
+
+	err = detachSubset(vs, "reviews", "default", "v1")
+	assert.Equal(t, err != nil, true)
+}
+
+type fakeRequestRate struct {
+	rates []float64
+	err   error
+}
+
+func (self *fakeRequestRate) RequestRate(namespace, appName, version string, window time.Duration) (float64, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+	rate := self.rates[0]
+	if len(self.rates) > 1 {
+		self.rates = self.rates[1:]
+	}
+	return rate, nil
+}
+
+func TestWaitForDrain(t *testing.T) {
+	cases := []struct {
+		source  RequestRateSource
+		timeout time.Duration
+		message string
+		rate    float64
+	}{
+		{&fakeRequestRate{rates: []float64{2.5, 0.5, 0}}, time.Minute, "", 0},
+		{&fakeRequestRate{rates: []float64{2.5}}, 0, "timed out waiting for the traffic to stop", 2.5},
+		{&fakeRequestRate{err: errors.New("no prometheus")}, 0, "timed out waiting for the traffic to stop", -1},
+		{nil, 0, "request rate is not available, drained for the whole timeout", -1},
+	}
+
+	for _, c := range cases {
+		drainer := NewDrainer(nil, nil, c.source)
+		drainer.period = time.Millisecond
+		_, err := drainer.reserve("default", "reviews", "v1")
+		assert.Equal(t, err, nil)
+
+		message := drainer.waitForDrain("default", "reviews", "v1", c.timeout)
+		assert.Equal(t, message, c.message)
+
+		status, err := drainer.DrainStatus(newAccessClient(true), "default", "reviews", "v1")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, status.RequestRate, c.rate)
+	}
+}
+
+func TestDrainReservation(t *testing.T) {
+	drainer := NewDrainer(nil, nil, nil)
+
+	_, err := drainer.DrainStatus(newAccessClient(true), "default", "reviews", "v1")
+	assert.Equal(t, err != nil, true)
+
+	status, err := drainer.reserve("default", "reviews", "v1")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.Phase, istioapi.DrainDraining)
+
+	// a drain in progress can not be started again
+	_, err = drainer.reserve("default", "reviews", "v1")
+	assert.Equal(t, err != nil, true)
+
+	drainer.update("default", "reviews", "v1", func(status *istioapi.DrainStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Phase = istioapi.DrainCompleted
+	})
+	_, err = drainer.reserve("default", "reviews", "v1")
+	assert.Equal(t, err, nil)
+}
+
+func TestDrainStatusPruning(t *testing.T) {
+	drainer := NewDrainer(nil, nil, nil)
+	for _, version := range []string{"v1", "v2", "v3"} {
+		_, err := drainer.reserve("default", "reviews", version)
+		assert.Equal(t, err, nil)
+	}
+
+	// v1 finished long ago, v2 recently and v3 is still draining
+	drainer.update("default", "reviews", "v1", func(status *istioapi.DrainStatus) {
+		finished := metav1.NewTime(time.Now().Add(-drainStatusTTL - time.Minute))
+		status.CompletionTime = &finished
+	})
+	drainer.update("default", "reviews", "v2", func(status *istioapi.DrainStatus) {
+		finished := metav1.Now()
+		status.CompletionTime = &finished
+	})
+
+	_, err := drainer.DrainStatus(newAccessClient(true), "default", "reviews", "v1")
+	assert.Equal(t, k8sErrors.IsNotFound(err), true)
+	_, err = drainer.DrainStatus(newAccessClient(true), "default", "reviews", "v2")
+	assert.Equal(t, err, nil)
+	_, err = drainer.DrainStatus(newAccessClient(true), "default", "reviews", "v3")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(drainer.drains), 2)
+}
+
+func TestOfflineDrainedVersion(t *testing.T) {
+	updates := []string{}
+	server := newResilienceServer(&updates)
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+	versioned := map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v1"}
+	client := fake.NewSimpleClientset(
+		newParentDeployment("reviews-v1", "v1", versioned),
+		newParentStatefulSet("reviews-v2", "v2"),
+	)
+
+	scaledDown := false
+	err = offlineDrainedVersion(client, istioClient, common.NewSameNamespaceQuery("default"), "reviews", "v2",
+		recommendedKeys, time.Millisecond, func() { scaledDown = true })
+	assert.Equal(t, err, nil)
+	assert.Equal(t, scaledDown, true)
+	assert.Equal(t, updates, []string{"/apis/networking.istio.io/v1alpha3/namespaces/default/destinationrules/reviews"})
+
+	_, err = client.AppsV1().StatefulSets("default").Get("reviews-v2", metav1.GetOptions{})
+	assert.Equal(t, k8sErrors.IsNotFound(err), true)
+	_, err = client.ExtensionsV1beta1().Deployments("default").Get("reviews-v1", metav1.GetOptions{})
+	assert.Equal(t, err, nil)
+}
@@ -0,0 +1,378 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/deployment"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"gopkg.in/yaml.v2"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// bundleSeparator separates the documents of an application bundle.
+const bundleSeparator = "---\n"
+
+// strippedMetadata are the cluster specific metadata fields removed from exported objects.
+var strippedMetadata = []string{"namespace", "uid", "resourceVersion", "selfLink", "creationTimestamp", "generation"}
+
+// strippedAnnotations are the annotations maintained by the cluster, removed from exported objects.
+var strippedAnnotations = []string{
+	"deployment.kubernetes.io/revision",
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// ExportApp exports the service, the version workloads, the destination rule and the virtual
+// services of an application as a multi-document yaml bundle, which can be imported into
+// another cluster with ImportApp. Cluster specific fields are stripped.
+func ExportApp(client kubernetes.Interface, istioClient istio.Interface, namespace, appName string, keys api.LabelKeys) (string, error) {
+	objects, err := getExportObjects(client, istioClient, namespace, appName, keys)
+	if err != nil {
+		return "", err
+	}
+	return toBundle(objects)
+}
+
+func getExportObjects(client kubernetes.Interface, istioClient istio.Interface, namespace, appName string, keys api.LabelKeys) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+
+	service, err := client.CoreV1().Services(namespace).Get(appName, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	obj, err := toExportObject("v1", "Service", service)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, obj)
+
+	deployments, err := client.AppsV1().Deployments(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if dep.Labels[keys.App] != appName && dep.Spec.Template.Labels[keys.App] != appName {
+			continue
+		}
+		obj, err := toExportObject("apps/v1", "Deployment", dep)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		set := &statefulSets.Items[i]
+		if set.Labels[keys.App] != appName && set.Spec.Template.Labels[keys.App] != appName {
+			continue
+		}
+		obj, err := toExportObject("apps/v1", "StatefulSet", set)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+
+	// istio objects are read raw, the typed client does not round trip every field
+	rule, err := getIstioObject(istioClient, namespace, "destinationrules", appName)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		cleanObject(rule)
+		objects = append(objects, rule)
+	}
+
+	vsList, err := istioClient.NetworkingV1alpha3().VirtualServices(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	app := &api.App{ObjectMeta: api2.ObjectMeta{Name: appName, Namespace: namespace}}
+	for _, vs := range getAppVirtualServices(app, vsList.Items) {
+		obj, err := getIstioObject(istioClient, namespace, "virtualservices", vs.ObjectMeta.Name)
+		if err != nil {
+			return nil, err
+		}
+		cleanObject(obj)
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func getIstioObject(istioClient istio.Interface, namespace, resource, name string) (map[string]interface{}, error) {
+	raw, err := istioClient.NetworkingV1alpha3().RESTClient().Get().
+		AbsPath("/apis/networking.istio.io/v1alpha3", "namespaces", namespace, resource, name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// toExportObject converts a typed object, whose type meta is not filled in by the client, to
+// its cleaned unstructured representation.
+func toExportObject(apiVersion, kind string, typed runtime.Object) (map[string]interface{}, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return nil, err
+	}
+	obj["apiVersion"] = apiVersion
+	obj["kind"] = kind
+	cleanObject(obj)
+	return obj, nil
+}
+
+// cleanObject strips the fields which are specific to the cluster the object was read from.
+func cleanObject(obj map[string]interface{}) {
+	delete(obj, "status")
+	for _, field := range strippedMetadata {
+		unstructured.RemoveNestedField(obj, "metadata", field)
+	}
+	for _, annotation := range strippedAnnotations {
+		unstructured.RemoveNestedField(obj, "metadata", "annotations", annotation)
+	}
+	if annotations, _, _ := unstructured.NestedMap(obj, "metadata", "annotations"); annotations != nil && len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj, "metadata", "annotations")
+	}
+	unstructured.RemoveNestedField(obj, "spec", "template", "metadata", "creationTimestamp")
+
+	if obj["kind"] == "Service" {
+		// headless services keep their cluster ip, the others are allocated by the target cluster
+		if clusterIP, _, _ := unstructured.NestedString(obj, "spec", "clusterIP"); clusterIP != "None" {
+			unstructured.RemoveNestedField(obj, "spec", "clusterIP")
+		}
+	}
+}
+
+func toBundle(objects []map[string]interface{}) (string, error) {
+	documents := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		document, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, string(document))
+	}
+	return strings.Join(documents, bundleSeparator), nil
+}
+
+// objectClient reads and writes objects of any kind.
+type objectClient interface {
+	Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+}
+
+// dynamicObjectClient implements objectClient with the dynamic client, the resource of every
+// object is discovered from its kind.
+type dynamicObjectClient struct {
+	cfg    *rest.Config
+	client dynamic.Interface
+}
+
+func (self *dynamicObjectClient) resource(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvr, err := deployment.ResourceFor(self.cfg, obj)
+	if err != nil {
+		return nil, err
+	}
+	return self.client.Resource(gvr).Namespace(obj.GetNamespace()), nil
+}
+
+func (self *dynamicObjectClient) Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource, err := self.resource(obj)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Get(obj.GetName(), metaV1.GetOptions{})
+}
+
+func (self *dynamicObjectClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource, err := self.resource(obj)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Create(obj, metaV1.CreateOptions{})
+}
+
+func (self *dynamicObjectClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource, err := self.resource(obj)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Update(obj, metaV1.UpdateOptions{})
+}
+
+// ImportApp applies a bundle exported by ExportApp to the namespace. Every object is created, or
+// updated when it already exists, so importing the same bundle again is harmless. Every object is
+// attempted, the result of each one is reported and an error is returned when any of them failed.
+// The bundle is rejected before anything is applied when it holds objects not belonging to the app.
+func ImportApp(cfg *rest.Config, namespace, appName, content string, keys api.LabelKeys) (*api.AppImport, error) {
+	objects, err := decodeBundle(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateBundle(objects, namespace, appName, keys); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return importObjects(&dynamicObjectClient{cfg: cfg, client: dynamicClient}, namespace, objects)
+}
+
+// decodeBundle decodes every object of the bundle, so that a malformed bundle is rejected before
+// anything is applied.
+func decodeBundle(content string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	err := deployment.DecodeObjects(content, func(obj *unstructured.Unstructured) error {
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return fmt.Errorf("every object of the bundle must have a kind and a name")
+		}
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("invalid bundle: %v", err))
+	}
+	if len(objects) == 0 {
+		return nil, k8sErrors.NewBadRequest("bundle is empty")
+	}
+	return objects, nil
+}
+
+// validateBundle checks every object of the bundle is one of the kinds exported by ExportApp and
+// belongs to the app, the same way the exported objects are selected.
+func validateBundle(objects []*unstructured.Unstructured, namespace, appName string, keys api.LabelKeys) error {
+	var rejected []string
+	for _, obj := range objects {
+		if !belongsToApp(obj, namespace, appName, keys) {
+			rejected = append(rejected, fmt.Sprintf("%s %s", strings.ToLower(obj.GetKind()), obj.GetName()))
+		}
+	}
+	if len(rejected) > 0 {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("objects %s don't belong to app %s",
+			strings.Join(rejected, ", "), appName))
+	}
+	return nil
+}
+
+func belongsToApp(obj *unstructured.Unstructured, namespace, appName string, keys api.LabelKeys) bool {
+	switch obj.GetKind() {
+	case "Service", "DestinationRule":
+		return obj.GetName() == appName
+	case "Deployment", "StatefulSet":
+		templateLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		return obj.GetLabels()[keys.App] == appName || templateLabels[keys.App] == appName
+	case "VirtualService":
+		return routesToApp(obj, namespace, appName)
+	}
+	return false
+}
+
+// routesToApp checks whether one of the hosts or route destinations of the raw virtual service is
+// the app.
+func routesToApp(obj *unstructured.Unstructured, namespace, appName string) bool {
+	appAddr := virtualservice.FQDN(appName, namespace)
+	hosts, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "hosts")
+	for _, host := range hosts {
+		if virtualservice.FQDN(host, namespace) == appAddr {
+			return true
+		}
+	}
+
+	routes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "http")
+	for _, r := range routes {
+		route, _ := r.(map[string]interface{})
+		destinations, _, _ := unstructured.NestedSlice(route, "route")
+		for _, d := range destinations {
+			destination, _ := d.(map[string]interface{})
+			host, _, _ := unstructured.NestedString(destination, "destination", "host")
+			if virtualservice.FQDN(host, namespace) == appAddr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func importObjects(client objectClient, namespace string, objects []*unstructured.Unstructured) (*api.AppImport, error) {
+	result := &api.AppImport{Results: make([]api.ObjectImport, 0)}
+	var failed []string
+	for _, obj := range objects {
+		obj.SetNamespace(namespace)
+		kind := strings.ToLower(obj.GetKind())
+		action, err := applyObject(client, obj)
+		objectImport := api.ObjectImport{Kind: kind, Name: obj.GetName(), Action: action}
+		if err != nil {
+			log.Printf("Failed to import %s %s: %v", kind, obj.GetName(), err)
+			objectImport.Action = api.ImportFailed
+			objectImport.Error = err.Error()
+			failed = append(failed, fmt.Sprintf("%s %s", kind, obj.GetName()))
+		}
+		result.Results = append(result.Results, objectImport)
+	}
+
+	if len(failed) > 0 {
+		return result, fmt.Errorf("failed to import %s", strings.Join(failed, ", "))
+	}
+	return result, nil
+}
+
+// applyObject creates the object, or updates it on top of the existing one.
+func applyObject(client objectClient, obj *unstructured.Unstructured) (string, error) {
+	existing, err := client.Get(obj)
+	if k8sErrors.IsNotFound(err) {
+		_, err = client.Create(obj)
+		return api.ImportCreated, err
+	}
+	if err != nil {
+		return api.ImportFailed, err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if obj.GetKind() == "Service" {
+		// the cluster ip of a service is immutable
+		if _, found, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); !found {
+			if clusterIP, found, _ := unstructured.NestedString(existing.Object, "spec", "clusterIP"); found {
+				unstructured.SetNestedField(obj.Object, clusterIP, "spec", "clusterIP")
+			}
+		}
+	}
+	_, err = client.Update(obj)
+	return api.ImportUpdated, err
+}
@@ -0,0 +1,251 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	istioapi "github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestExportBundle(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "reviews", Namespace: "default", UID: "1234", ResourceVersion: "42",
+			Labels:      map[string]string{"app": "reviews"},
+			Annotations: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Selector:  map[string]string{"app": "reviews"},
+			Ports:     []v1.ServicePort{{Name: "http", Port: 9080}},
+		},
+		Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}}},
+	}
+	obj, err := toExportObject("v1", "Service", service)
+	if err != nil {
+		t.Fatalf("toExportObject() error: %v", err)
+	}
+	rule := map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1alpha3",
+		"kind":       "DestinationRule",
+		"metadata":   map[string]interface{}{"name": "reviews", "namespace": "default", "uid": "5678"},
+		"spec":       map[string]interface{}{"host": "reviews"},
+	}
+	cleanObject(rule)
+
+	bundle, err := toBundle([]map[string]interface{}{obj, rule})
+	if err != nil {
+		t.Fatalf("toBundle() error: %v", err)
+	}
+	objects, err := decodeBundle(bundle)
+	if err != nil {
+		t.Fatalf("decodeBundle() error: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("decodeBundle() got %d objects, expected 2:\n%s", len(objects), bundle)
+	}
+
+	exported := objects[0]
+	if exported.GetKind() != "Service" || exported.GetName() != "reviews" {
+		t.Errorf("expected service reviews, got %s %s", exported.GetKind(), exported.GetName())
+	}
+	if exported.GetNamespace() != "" || exported.GetUID() != "" || exported.GetResourceVersion() != "" {
+		t.Errorf("cluster specific metadata not stripped: %v", exported.Object["metadata"])
+	}
+	if exported.GetAnnotations() != nil {
+		t.Errorf("expected no annotations, got %v", exported.GetAnnotations())
+	}
+	if _, found := exported.Object["status"]; found {
+		t.Errorf("status not stripped: %v", exported.Object["status"])
+	}
+	if _, found, _ := unstructured.NestedString(exported.Object, "spec", "clusterIP"); found {
+		t.Errorf("cluster ip not stripped")
+	}
+	if !reflect.DeepEqual(exported.GetLabels(), map[string]string{"app": "reviews"}) {
+		t.Errorf("expected labels to be kept, got %v", exported.GetLabels())
+	}
+	if host, _, _ := unstructured.NestedString(objects[1].Object, "spec", "host"); host != "reviews" {
+		t.Errorf("expected destination rule host reviews, got %q", host)
+	}
+}
+
+func TestCleanObjectKeepsHeadlessClusterIP(t *testing.T) {
+	obj := map[string]interface{}{
+		"kind": "Service",
+		"spec": map[string]interface{}{"clusterIP": "None"},
+	}
+	cleanObject(obj)
+	if clusterIP, _, _ := unstructured.NestedString(obj, "spec", "clusterIP"); clusterIP != "None" {
+		t.Errorf("expected headless cluster ip to be kept, got %q", clusterIP)
+	}
+}
+
+func TestDecodeBundle(t *testing.T) {
+	cases := []struct {
+		content string
+		valid   bool
+	}{
+		{"kind: Service\nmetadata:\n  name: reviews\n", true},
+		{"", false},
+		{"kind: Service\nmetadata:\n  name: reviews\n---\nkind: Deployment\n", false},
+		{"kind: [", false},
+	}
+	for _, c := range cases {
+		_, err := decodeBundle(c.content)
+		if (err == nil) != c.valid {
+			t.Errorf("decodeBundle(%q) error = %v, expected valid %v", c.content, err, c.valid)
+		}
+		if err != nil && !k8sErrors.IsBadRequest(err) {
+			t.Errorf("decodeBundle(%q) expected bad request, got %v", c.content, err)
+		}
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	cases := []struct {
+		content string
+		valid   bool
+	}{
+		{"kind: Service\nmetadata:\n  name: reviews\n", true},
+		{"kind: Service\nmetadata:\n  name: ratings\n", false},
+		{"kind: DestinationRule\nmetadata:\n  name: reviews\n", true},
+		{"kind: Deployment\nmetadata:\n  name: reviews-v1\n  labels:\n    app.kubernetes.io/name: reviews\n", true},
+		{"kind: StatefulSet\nmetadata:\n  name: reviews-v2\nspec:\n  template:\n    metadata:\n" +
+			"      labels:\n        app.kubernetes.io/name: reviews\n", true},
+		{"kind: Deployment\nmetadata:\n  name: reviews-v1\n  labels:\n    app: reviews\n", false},
+		{"kind: VirtualService\nmetadata:\n  name: reviews\nspec:\n  hosts:\n  - reviews.staging.svc.cluster.local\n", true},
+		{"kind: VirtualService\nmetadata:\n  name: api-test-com\nspec:\n  hosts:\n  - api.test.com\n  http:\n" +
+			"  - route:\n    - destination:\n        host: reviews\n", true},
+		{"kind: VirtualService\nmetadata:\n  name: ratings\nspec:\n  hosts:\n  - ratings\n", false},
+		{"kind: ClusterRoleBinding\nmetadata:\n  name: reviews\n", false},
+		{"kind: Service\nmetadata:\n  name: reviews\n---\nkind: Secret\nmetadata:\n  name: reviews\n", false},
+	}
+	for _, c := range cases {
+		objects, err := decodeBundle(c.content)
+		if err != nil {
+			t.Fatalf("decodeBundle(%q) error: %v", c.content, err)
+		}
+		err = validateBundle(objects, "staging", "reviews", recommendedKeys)
+		if (err == nil) != c.valid {
+			t.Errorf("validateBundle(%q) error = %v, expected valid %v", c.content, err, c.valid)
+		}
+		if err != nil && !k8sErrors.IsBadRequest(err) {
+			t.Errorf("validateBundle(%q) expected bad request, got %v", c.content, err)
+		}
+	}
+}
+
+type fakeObjectClient struct {
+	objects map[string]*unstructured.Unstructured
+	failing map[string]bool
+	updated []*unstructured.Unstructured
+}
+
+func (self *fakeObjectClient) key(obj *unstructured.Unstructured) string {
+	return obj.GetKind() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+func (self *fakeObjectClient) Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existing, ok := self.objects[self.key(obj)]
+	if !ok {
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: obj.GetKind()}, obj.GetName())
+	}
+	return existing, nil
+}
+
+func (self *fakeObjectClient) Create(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if self.failing[self.key(obj)] {
+		return nil, errors.New("forbidden")
+	}
+	self.objects[self.key(obj)] = obj
+	return obj, nil
+}
+
+func (self *fakeObjectClient) Update(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	self.updated = append(self.updated, obj)
+	self.objects[self.key(obj)] = obj
+	return obj, nil
+}
+
+func TestImportObjects(t *testing.T) {
+	existing := &unstructured.Unstructured{}
+	existing.SetKind("Service")
+	existing.SetNamespace("staging")
+	existing.SetName("reviews")
+	existing.SetResourceVersion("42")
+	unstructured.SetNestedField(existing.Object, "10.0.0.1", "spec", "clusterIP")
+
+	client := &fakeObjectClient{
+		objects: map[string]*unstructured.Unstructured{"Service/staging/reviews": existing},
+		failing: map[string]bool{"VirtualService/staging/reviews": true},
+	}
+
+	objects, err := decodeBundle("apiVersion: v1\nkind: Service\nmetadata:\n  name: reviews\n  namespace: default\n" +
+		"---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: reviews-v1\n" +
+		"---\napiVersion: networking.istio.io/v1alpha3\nkind: VirtualService\nmetadata:\n  name: reviews\n")
+	if err != nil {
+		t.Fatalf("decodeBundle() error: %v", err)
+	}
+
+	result, err := importObjects(client, "staging", objects)
+	if err == nil {
+		t.Errorf("expected an error for the failed virtual service")
+	}
+	expected := []istioapi.ObjectImport{
+		{Kind: "service", Name: "reviews", Action: istioapi.ImportUpdated},
+		{Kind: "deployment", Name: "reviews-v1", Action: istioapi.ImportCreated},
+		{Kind: "virtualservice", Name: "reviews", Action: istioapi.ImportFailed, Error: "forbidden"},
+	}
+	if result == nil || !reflect.DeepEqual(result.Results, expected) {
+		t.Errorf("importObjects() got %v, expected %v", result, expected)
+	}
+
+	if len(client.updated) != 1 {
+		t.Fatalf("expected 1 update, got %d", len(client.updated))
+	}
+	updated := client.updated[0]
+	if updated.GetResourceVersion() != "42" {
+		t.Errorf("expected resource version 42, got %q", updated.GetResourceVersion())
+	}
+	if clusterIP, _, _ := unstructured.NestedString(updated.Object, "spec", "clusterIP"); clusterIP != "10.0.0.1" {
+		t.Errorf("expected cluster ip to be kept, got %q", clusterIP)
+	}
+	if _, ok := client.objects["Deployment/staging/reviews-v1"]; !ok {
+		t.Errorf("expected deployment to be created in the target namespace")
+	}
+
+	// importing again updates the applied objects and retries the failed one
+	client.failing = nil
+	result, err = importObjects(client, "staging", objects)
+	if err != nil {
+		t.Errorf("importObjects() unexpected error: %v", err)
+	}
+	expected = []istioapi.ObjectImport{
+		{Kind: "service", Name: "reviews", Action: istioapi.ImportUpdated},
+		{Kind: "deployment", Name: "reviews-v1", Action: istioapi.ImportUpdated},
+		{Kind: "virtualservice", Name: "reviews", Action: istioapi.ImportCreated},
+	}
+	if !reflect.DeepEqual(result.Results, expected) {
+		t.Errorf("importObjects() got %v, expected %v", result.Results, expected)
+	}
+}
@@ -36,6 +36,7 @@ func GetAppList(client kubernetes.Interface, istioClient istio.Interface, ns *co
 		ServiceList:         common.GetServiceListChannel(client, ns, 1),
 		NamespaceList:       common.GetNamespaceListChannel(client, 1),
 		DestinationRuleList: common.GetDestinationRuleListChannel(istioClient, ns, 1),
+		PodList:             common.GetPodListChannel(client, ns, 1),
 	}
 	return getAppsFromChannel(channels, dsQuery)
 }
@@ -62,12 +63,19 @@ func getAppsFromChannel(channels *common.ResourceChannels, dsQuery *dataselect.D
 		return nil, criticalError
 	}
 
-	return toAppList(services.Items, destinationRules.Items, namespaces.Items, nonCriticalErrors, dsQuery)
+	pods := <-channels.PodList.List
+	err = <-channels.PodList.Error
+	nonCriticalErrors, criticalError = kdErrors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	return toAppList(services.Items, destinationRules.Items, namespaces.Items, pods.Items, nonCriticalErrors, dsQuery)
 }
 
 // toAppList merges k8s services & istio destinationRules & virtualServices, return apps.
 func toAppList(services []v1.Service, dRules []istioApi.DestinationRule, namespaces []v1.Namespace,
-	nonCriticalErrors []error, dsQuery *dataselect.DataSelectQuery) (*api.AppList, error) {
+	pods []v1.Pod, nonCriticalErrors []error, dsQuery *dataselect.DataSelectQuery) (*api.AppList, error) {
 	var apps []*api.App
 
 	if services == nil {
@@ -98,18 +106,16 @@ func toAppList(services []v1.Service, dRules []istioApi.DestinationRule, namespa
 	}
 
 	// add these applications' istio statuses
-	if namespaces != nil {
-		for _, app := range apps {
-			ns := app.ObjectMeta.Namespace
-			for _, n := range namespaces {
-				if n.ObjectMeta.Name == ns {
-					if n.ObjectMeta.Labels["istio-injection"] == "enabled" {
-						app.Status.Istio = true
-					}
-					break
-				}
+	for i, app := range apps {
+		var ns *v1.Namespace
+		for j := range namespaces {
+			if namespaces[j].ObjectMeta.Name == app.ObjectMeta.Namespace {
+				ns = &namespaces[j]
+				break
 			}
 		}
+		// the apps are made of the services in the same order
+		setSidecarStatus(app, ns, serviceList.Services[i].Selector, pods)
 	}
 
 	list := &api.AppList{
@@ -0,0 +1,141 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MirrorTraffic mirrors the application's traffic to the specified version, an empty version
+// stops mirroring.
+// 1. make sure the version is a subset of the app's destination rule
+// 2. rewrite the mirror of the routes to the app, create a virtual service if none exists
+func MirrorTraffic(istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	mirror *api.TrafficMirror, targetType string) error {
+	ns := namespace.ToRequestParam()
+	log.Printf("Mirroring traffic of %s app in %s namespace to version %q", appName, ns, mirror.Version)
+
+	if mirror.Version != "" {
+		destinations, err := getAppDestinationsByName(istioClient, ns, appName)
+		if err != nil {
+			return err
+		}
+		if !hasDestination(destinations, mirror.Version) {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("version %s is not a subset of the destination rule", mirror.Version))
+		}
+	}
+
+	virtualServices, err := virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, ns)}, targetType)
+	if err != nil {
+		return err
+	}
+
+	if len(virtualServices) == 0 {
+		if mirror.Version == "" {
+			return nil
+		}
+		// no any virtualServices exists, create one routing to all versions
+		vs := &istioApi.VirtualService{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "networking.istio.io/v1alpha3",
+				Kind:       "VirtualService",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      appName,
+				Namespace: ns,
+			},
+			Spec: istioApi.VirtualServiceSpec{
+				Hosts: []string{appName},
+				Http: []*istioApi.HTTPRoute{
+					{
+						Route:  []*istioApi.DestinationWeight{{Destination: &istioApi.Destination{Host: appName}}},
+						Mirror: &istioApi.Destination{Host: appName, Subset: mirror.Version},
+					},
+				},
+			},
+		}
+		_, err := istioClient.NetworkingV1alpha3().VirtualServices(ns).Create(vs)
+		return err
+	}
+
+	for _, vs := range virtualServices {
+		overrideMirror(&vs, appName, ns, mirror.Version)
+		if _, err := istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Update(&vs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overrideMirror sets the mirror of all routes to the specified host to the subset of the host.
+// An empty subset removes the mirrors to the host.
+func overrideMirror(vs *istioApi.VirtualService, host, namespace, subset string) {
+	fqdn := virtualservice.FQDN(host, namespace)
+	for i := range vs.Spec.Http {
+		http := vs.Spec.Http[i]
+		if subset == "" {
+			if http.Mirror != nil && fqdn == virtualservice.FQDN(http.Mirror.Host, vs.Namespace) {
+				http.Mirror = nil
+			}
+			continue
+		}
+
+		// mirror the routes to the host, using the host and port the route refers to it with
+		for _, dest := range http.Route {
+			if fqdn == virtualservice.FQDN(dest.Destination.Host, vs.Namespace) {
+				http.Mirror = &istioApi.Destination{
+					Host:   dest.Destination.Host,
+					Subset: subset,
+					Port:   dest.Destination.Port,
+				}
+				break
+			}
+		}
+	}
+}
+
+// getAppMirrors returns the versions of the app its traffic is mirrored to, ordered by version.
+func getAppMirrors(appName, namespace string, vServices []istioApi.VirtualService) []api.TrafficMirror {
+	fqdn := virtualservice.FQDN(appName, namespace)
+	mirrored := make(map[string][]string)
+	for _, vs := range vServices {
+		for _, http := range vs.Spec.Http {
+			if http.Mirror == nil || fqdn != virtualservice.FQDN(http.Mirror.Host, vs.Namespace) {
+				continue
+			}
+			if !contains(mirrored[http.Mirror.Subset], vs.Name) {
+				mirrored[http.Mirror.Subset] = append(mirrored[http.Mirror.Subset], vs.Name)
+			}
+		}
+	}
+
+	mirrors := make([]api.TrafficMirror, 0, len(mirrored))
+	for version, names := range mirrored {
+		mirrors = append(mirrors, api.TrafficMirror{Version: version, VirtualServices: names})
+	}
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].Version < mirrors[j].Version })
+	return mirrors
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	istioapi "github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOverrideMirror(t *testing.T) {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-test-com", Namespace: "wall"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts:    []string{"api.test.com"},
+			Gateways: []string{"api-test-com"},
+			Http: []*v1alpha3.HTTPRoute{
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "api-gateway", Subset: "v1",
+							Port: &v1alpha3.PortSelector{Number: 8080}}},
+					},
+				},
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "something_else"}},
+					},
+				},
+			},
+		},
+	}
+
+	overrideMirror(&vs, "api-gateway", "wall", "v2")
+	assert.Equal(t, vs.Spec.Http[0].Mirror, &v1alpha3.Destination{Host: "api-gateway", Subset: "v2",
+		Port: &v1alpha3.PortSelector{Number: 8080}})
+	assert.Equal(t, vs.Spec.Http[1].Mirror == nil, true)
+
+	assert.Equal(t, getAppMirrors("api-gateway", "wall", []v1alpha3.VirtualService{vs}),
+		[]istioapi.TrafficMirror{{Version: "v2", VirtualServices: []string{"api-test-com"}}})
+
+	overrideMirror(&vs, "api-gateway", "wall", "")
+	assert.Equal(t, vs.Spec.Http[0].Mirror == nil, true)
+	assert.Equal(t, len(getAppMirrors("api-gateway", "wall", []v1alpha3.VirtualService{vs})), 0)
+}
@@ -0,0 +1,306 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// parentWorkload is a deployment or stateful set running one version of the app, a canary
+// version inherits its configuration from one of them.
+type parentWorkload struct {
+	kind        string
+	name        string
+	version     string
+	template    *v1.PodTemplateSpec
+	selector    *metav1.LabelSelector
+	replicas    *int32
+	deployment  *v1beta1.Deployment
+	statefulSet *appsv1.StatefulSet
+}
+
+// getParentWorkloads returns the deployments and stateful sets whose pods are labelled with the app.
+func getParentWorkloads(client kubernetes.Interface, namespace, appName string, keys api.LabelKeys) ([]parentWorkload, error) {
+	deployments, err := client.ExtensionsV1beta1().Deployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []parentWorkload
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if dep.Labels[keys.App] == appName || dep.Spec.Template.Labels[keys.App] == appName {
+			parents = append(parents, newDeploymentWorkload(dep, keys))
+		}
+	}
+	for i := range statefulSets.Items {
+		set := &statefulSets.Items[i]
+		if set.Labels[keys.App] == appName || set.Spec.Template.Labels[keys.App] == appName {
+			parents = append(parents, newStatefulSetWorkload(set, keys))
+		}
+	}
+	return parents, nil
+}
+
+func newDeploymentWorkload(dep *v1beta1.Deployment, keys api.LabelKeys) parentWorkload {
+	return parentWorkload{
+		kind:       api2.ResourceKindDeployment,
+		name:       dep.Name,
+		version:    dep.Spec.Template.Labels[keys.Version],
+		template:   &dep.Spec.Template,
+		selector:   dep.Spec.Selector,
+		replicas:   dep.Spec.Replicas,
+		deployment: dep,
+	}
+}
+
+func newStatefulSetWorkload(set *appsv1.StatefulSet, keys api.LabelKeys) parentWorkload {
+	return parentWorkload{
+		kind:        api2.ResourceKindStatefulSet,
+		name:        set.Name,
+		version:     set.Spec.Template.Labels[keys.Version],
+		template:    &set.Spec.Template,
+		selector:    set.Spec.Selector,
+		replicas:    set.Spec.Replicas,
+		statefulSet: set,
+	}
+}
+
+// getVersionWorkloads returns the deployments and stateful sets running the version of the app.
+func getVersionWorkloads(client kubernetes.Interface, namespace, appName, version string,
+	keys api.LabelKeys) ([]parentWorkload, error) {
+	workloads, err := getParentWorkloads(client, namespace, appName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []parentWorkload
+	for _, workload := range workloads {
+		if workload.version == version {
+			result = append(result, workload)
+		}
+	}
+	return result, nil
+}
+
+// getVersionWorkload returns the only deployment or stateful set running the version of the app.
+func getVersionWorkload(client kubernetes.Interface, namespace, appName, version string,
+	keys api.LabelKeys) (*parentWorkload, error) {
+	workloads, err := getVersionWorkloads(client, namespace, appName, version, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(workloads) {
+	case 0:
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "deployments"},
+			fmt.Sprintf("%s=%s,%s=%s", keys.App, appName, keys.Version, version))
+	case 1:
+		return &workloads[0], nil
+	default:
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("%d workloads of app %s run version %s",
+			len(workloads), appName, version))
+	}
+}
+
+// selectParent chooses the parent of the canary version among the app's workloads. The base
+// version selects the parent when the app runs several versions.
+func selectParent(parents []parentWorkload, appName, version, baseVersion string, keys api.LabelKeys) (*parentWorkload, error) {
+	if len(parents) == 0 {
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "deployments"},
+			fmt.Sprintf("%s=%s", keys.App, appName))
+	}
+
+	var versions []string
+	var candidates []parentWorkload
+	for _, parent := range parents {
+		if parent.version == version {
+			return nil, k8sErrors.NewBadRequest(fmt.Sprintf("version %s of app %s exists already in %s %s",
+				version, appName, parent.kind, parent.name))
+		}
+		versions = append(versions, parent.version)
+		if baseVersion == "" || parent.version == baseVersion {
+			candidates = append(candidates, parent)
+		}
+	}
+	sort.Strings(versions)
+
+	switch {
+	case len(candidates) == 0:
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "deployments"},
+			fmt.Sprintf("%s=%s,%s=%s", keys.App, appName, keys.Version, baseVersion))
+	case len(candidates) > 1 && baseVersion == "":
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("app %s runs versions %s, choose the base version of the canary",
+			appName, strings.Join(versions, ", ")))
+	case len(candidates) > 1:
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("%d workloads of app %s run version %s",
+			len(candidates), appName, baseVersion))
+	}
+
+	parent := &candidates[0]
+	if err := validateParent(parent, keys); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// validateParent checks the parent's pods can be told apart from the canary's. The parent is
+// never rewritten, changing its selector recreates its pods.
+func validateParent(parent *parentWorkload, keys api.LabelKeys) error {
+	if parent.version == "" {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("pod template of %s %s has no %s label, istio can't route to its version",
+			parent.kind, parent.name, keys.Version))
+	}
+	if parent.selector == nil || parent.selector.MatchLabels[keys.Version] != parent.version {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("selector of %s %s doesn't match the %s label, it would adopt the pods of the canary",
+			parent.kind, parent.name, keys.Version))
+	}
+	return nil
+}
+
+// newCanaryWorkload creates the canary version of the app as a workload of the parent's kind.
+func newCanaryWorkload(client kubernetes.Interface, parent *parentWorkload, appName string,
+	canaryDep *api.CanaryDeployment, keys api.LabelKeys) error {
+	version := canaryDep.Version
+	labels := map[string]string{keys.App: appName, keys.Version: version}
+	for _, key := range keys.Extra {
+		labels[key] = appName
+	}
+
+	template := canaryDep.PodTemplate
+	if template.Labels == nil {
+		template.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		template.Labels[key] = value
+	}
+
+	replicas := parent.replicas
+	if canaryDep.Replicas > 0 {
+		replicas = &canaryDep.Replicas
+	}
+	meta := metav1.ObjectMeta{
+		Name:      fmt.Sprintf("%s-%s", appName, version),
+		Namespace: parent.namespace(),
+		Labels:    labels,
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{keys.App: appName, keys.Version: version},
+	}
+
+	if parent.statefulSet != nil {
+		set := &appsv1.StatefulSet{
+			ObjectMeta: meta,
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:             replicas,
+				Selector:             selector,
+				Template:             template,
+				VolumeClaimTemplates: parent.statefulSet.Spec.VolumeClaimTemplates,
+				ServiceName:          parent.statefulSet.Spec.ServiceName,
+				PodManagementPolicy:  parent.statefulSet.Spec.PodManagementPolicy,
+				UpdateStrategy:       parent.statefulSet.Spec.UpdateStrategy,
+				RevisionHistoryLimit: parent.statefulSet.Spec.RevisionHistoryLimit,
+			},
+		}
+		_, err := client.AppsV1().StatefulSets(meta.Namespace).Create(set)
+		return err
+	}
+
+	dep := &v1beta1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       api2.ResourceKindDeployment,
+			APIVersion: parent.deployment.APIVersion,
+		},
+		ObjectMeta: meta,
+		Spec: v1beta1.DeploymentSpec{
+			Replicas:                replicas,
+			Selector:                selector,
+			Template:                template,
+			Strategy:                parent.deployment.Spec.Strategy,
+			MinReadySeconds:         parent.deployment.Spec.MinReadySeconds,
+			RevisionHistoryLimit:    parent.deployment.Spec.RevisionHistoryLimit,
+			ProgressDeadlineSeconds: parent.deployment.Spec.ProgressDeadlineSeconds,
+		},
+	}
+	_, err := client.ExtensionsV1beta1().Deployments(meta.Namespace).Create(dep)
+	return err
+}
+
+func (self *parentWorkload) namespace() string {
+	if self.statefulSet != nil {
+		return self.statefulSet.Namespace
+	}
+	return self.deployment.Namespace
+}
+
+func (self *parentWorkload) readyReplicas() int32 {
+	if self.statefulSet != nil {
+		return self.statefulSet.Status.ReadyReplicas
+	}
+	return self.deployment.Status.ReadyReplicas
+}
+
+// scale updates the number of replicas of the workload.
+func (self *parentWorkload) scale(client kubernetes.Interface, replicas int32) error {
+	if self.statefulSet != nil {
+		set := self.statefulSet.DeepCopy()
+		set.Spec.Replicas = &replicas
+		_, err := client.AppsV1().StatefulSets(set.Namespace).Update(set)
+		return err
+	}
+	dep := self.deployment.DeepCopy()
+	dep.Spec.Replicas = &replicas
+	_, err := client.ExtensionsV1beta1().Deployments(dep.Namespace).Update(dep)
+	return err
+}
+
+// currentReplicas returns the number of pods the workload runs at the moment.
+func (self *parentWorkload) currentReplicas(client kubernetes.Interface) (int32, error) {
+	if self.statefulSet != nil {
+		set, err := client.AppsV1().StatefulSets(self.namespace()).Get(self.name, metav1.GetOptions{})
+		if err != nil {
+			return 0, err
+		}
+		return set.Status.Replicas, nil
+	}
+	dep, err := client.ExtensionsV1beta1().Deployments(self.namespace()).Get(self.name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return dep.Status.Replicas, nil
+}
+
+func (self *parentWorkload) delete(client kubernetes.Interface, options *metav1.DeleteOptions) error {
+	if self.statefulSet != nil {
+		return client.AppsV1().StatefulSets(self.namespace()).Delete(self.name, options)
+	}
+	return client.ExtensionsV1beta1().Deployments(self.namespace()).Delete(self.name, options)
+}
@@ -0,0 +1,196 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var recommendedKeys = api.LabelKeys{App: "app.kubernetes.io/name", Version: "app.kubernetes.io/version"}
+
+func newParentDeployment(name, version string, selector map[string]string) *v1beta1.Deployment {
+	replicas := int32(2)
+	return &v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: version},
+			}},
+		},
+	}
+}
+
+func newParentStatefulSet(name, version string) *appsv1.StatefulSet {
+	replicas := int32(3)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: "reviews",
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{
+				recommendedKeys.App: "reviews", recommendedKeys.Version: version}},
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: version},
+			}},
+		},
+	}
+}
+
+func TestSelectParent(t *testing.T) {
+	versioned := map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v1"}
+	ratings := newParentDeployment("ratings", "v1", map[string]string{recommendedKeys.App: "ratings"})
+	ratings.Spec.Template.Labels[recommendedKeys.App] = "ratings"
+	client := fake.NewSimpleClientset(
+		newParentDeployment("reviews-v1", "v1", versioned),
+		newParentStatefulSet("reviews-v2", "v2"),
+		newParentDeployment("reviews-unversioned", "", map[string]string{recommendedKeys.App: "reviews"}),
+		newParentDeployment("reviews-shared", "v3", map[string]string{recommendedKeys.App: "reviews"}),
+		ratings,
+	)
+	parents, err := getParentWorkloads(client, "default", "reviews", recommendedKeys)
+	if err != nil {
+		t.Fatalf("getParentWorkloads() returned error: %v", err)
+	}
+	if len(parents) != 4 {
+		t.Fatalf("getParentWorkloads() returned %d workloads, expected 4", len(parents))
+	}
+
+	cases := []struct {
+		info        string
+		version     string
+		baseVersion string
+		expected    string
+		check       func(error) bool
+	}{
+		{"deployment of the base version", "v4", "v1", "reviews-v1", nil},
+		{"stateful set of the base version", "v4", "v2", "reviews-v2", nil},
+		{"several versions without base version", "v4", "", "", k8sErrors.IsBadRequest},
+		{"unknown base version", "v4", "v5", "", k8sErrors.IsNotFound},
+		{"existing version", "v2", "v1", "", k8sErrors.IsBadRequest},
+		{"base version without version label", "v4", "", "", k8sErrors.IsBadRequest},
+		{"selector overlapping the canary", "v4", "v3", "", k8sErrors.IsBadRequest},
+	}
+	for _, c := range cases {
+		candidates := parents
+		if c.info == "base version without version label" {
+			candidates = nil
+			for _, parent := range parents {
+				if parent.name == "reviews-unversioned" {
+					candidates = append(candidates, parent)
+				}
+			}
+		}
+		parent, err := selectParent(candidates, "reviews", c.version, c.baseVersion, recommendedKeys)
+		if c.check != nil {
+			if !c.check(err) {
+				t.Errorf("%s: selectParent() returned error %v", c.info, err)
+			}
+			continue
+		}
+		if err != nil || parent.name != c.expected {
+			t.Errorf("%s: selectParent() == %v, %v, expected %s", c.info, parent, err, c.expected)
+		}
+	}
+
+	if _, err := selectParent(nil, "details", "v2", "", recommendedKeys); !k8sErrors.IsNotFound(err) {
+		t.Errorf("selectParent() of app without workloads == %v, expected not found", err)
+	}
+}
+
+func TestNewCanaryWorkload(t *testing.T) {
+	keys := recommendedKeys
+	keys.Extra = []string{"qcloud-app"}
+	canary := &api.CanaryDeployment{Version: "v2", PodTemplate: v1.PodTemplateSpec{
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "reviews", Image: "reviews:v2"}}},
+	}}
+	expectedLabels := map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v2", "qcloud-app": "reviews"}
+	expectedSelector := map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v2"}
+
+	parentSet := newParentStatefulSet("reviews-v1", "v1")
+	client := fake.NewSimpleClientset(parentSet)
+	parent := &parentWorkload{kind: "statefulset", name: parentSet.Name, version: "v1",
+		replicas: parentSet.Spec.Replicas, statefulSet: parentSet}
+	if err := newCanaryWorkload(client, parent, "reviews", canary, keys); err != nil {
+		t.Fatalf("newCanaryWorkload() returned error: %v", err)
+	}
+	set, err := client.AppsV1().StatefulSets("default").Get("reviews-v2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("canary stateful set not created: %v", err)
+	}
+	if *set.Spec.Replicas != 3 || set.Spec.ServiceName != "reviews" ||
+		!reflect.DeepEqual(set.Labels, expectedLabels) ||
+		!reflect.DeepEqual(set.Spec.Template.Labels, expectedLabels) ||
+		!reflect.DeepEqual(set.Spec.Selector.MatchLabels, expectedSelector) {
+		t.Errorf("canary stateful set == %#v", set)
+	}
+
+	parentDep := newParentDeployment("reviews-v1", "v1",
+		map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v1"})
+	client = fake.NewSimpleClientset(parentDep)
+	parent = &parentWorkload{kind: "deployment", name: parentDep.Name, version: "v1",
+		replicas: parentDep.Spec.Replicas, deployment: parentDep}
+	canary.Replicas = 1
+	if err := newCanaryWorkload(client, parent, "reviews", canary, keys); err != nil {
+		t.Fatalf("newCanaryWorkload() returned error: %v", err)
+	}
+	dep, err := client.ExtensionsV1beta1().Deployments("default").Get("reviews-v2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("canary deployment not created: %v", err)
+	}
+	if *dep.Spec.Replicas != 1 || !reflect.DeepEqual(dep.Spec.Template.Labels, expectedLabels) ||
+		!reflect.DeepEqual(dep.Spec.Selector.MatchLabels, expectedSelector) {
+		t.Errorf("canary deployment == %#v", dep)
+	}
+
+	// the parent is left untouched
+	parentAfter, _ := client.ExtensionsV1beta1().Deployments("default").Get("reviews-v1", metav1.GetOptions{})
+	if !reflect.DeepEqual(parentAfter, parentDep) {
+		t.Errorf("parent deployment was modified: %#v", parentAfter)
+	}
+}
+
+func TestGetVersionWorkload(t *testing.T) {
+	versioned := map[string]string{recommendedKeys.App: "reviews", recommendedKeys.Version: "v1"}
+	client := fake.NewSimpleClientset(
+		newParentDeployment("reviews-v1", "v1", versioned),
+		newParentStatefulSet("reviews-v2", "v2"),
+	)
+
+	workload, err := getVersionWorkload(client, "default", "reviews", "v2", recommendedKeys)
+	if err != nil || workload.kind != api2.ResourceKindStatefulSet || workload.name != "reviews-v2" {
+		t.Errorf("getVersionWorkload() == %v, %v, expected stateful set reviews-v2", workload, err)
+	}
+	if _, err := getVersionWorkload(client, "default", "reviews", "v3", recommendedKeys); !k8sErrors.IsNotFound(err) {
+		t.Errorf("getVersionWorkload() of unknown version == %v, expected not found", err)
+	}
+
+	// the workloads aren't found with the default keys
+	defaultKeys := api.LabelKeys{App: "app", Version: "version"}
+	if _, err := getVersionWorkload(client, "default", "reviews", "v1", defaultKeys); !k8sErrors.IsNotFound(err) {
+		t.Errorf("getVersionWorkload() with default keys == %v, expected not found", err)
+	}
+}
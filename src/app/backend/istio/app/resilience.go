@@ -0,0 +1,399 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	kdErrors "github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// loadBalancers maps the names of the simple load balancers to
+// istio.networking.v1alpha3.LoadBalancerSettings_SimpleLB.
+var loadBalancers = map[string]int32{
+	"ROUND_ROBIN": 0,
+	"LEAST_CONN":  1,
+	"RANDOM":      2,
+	"PASSTHROUGH": 3,
+}
+
+// GetResiliencePolicy returns the resilience settings of the application's routes selected by
+// the target type and of its destination rule.
+func GetResiliencePolicy(istioClient istio.Interface, namespace *common.NamespaceQuery, appName,
+	targetType string) (*api.ResiliencePolicy, error) {
+	ns := namespace.ToRequestParam()
+
+	virtualServices, err := virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, ns)}, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &api.ResiliencePolicy{Routes: toRoutePolicies(appName, ns, virtualServices)}
+
+	dRule, err := istioClient.NetworkingV1alpha3().DestinationRules(ns).Get(appName, metav1.GetOptions{})
+	if err != nil && !kdErrors.IsNotFoundError(err) {
+		return nil, err
+	}
+	if err == nil {
+		policy.TrafficPolicy = toTrafficPolicy(dRule.Spec.TrafficPolicy)
+	}
+	return policy, nil
+}
+
+// UpdateResiliencePolicy updates the resilience settings of the given routes and the traffic
+// policy of the application's destination rule. Routes which are not given are left as they are,
+// so is the destination rule when no traffic policy is given. The traffic policy is merged into
+// the destination rule's, the settings it omits are kept.
+func UpdateResiliencePolicy(istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	policy *api.ResiliencePolicy, targetType string) error {
+	ns := namespace.ToRequestParam()
+	log.Printf("Updating resilience policy of %s app in %s namespace", appName, ns)
+
+	if err := validateResiliencePolicy(policy); err != nil {
+		return err
+	}
+
+	virtualServices, err := virtualservice.GetVirtualServices(
+		istioClient, []string{virtualservice.FQDN(appName, ns)}, targetType)
+	if err != nil {
+		return err
+	}
+
+	// apply all the route policies before updating any virtual service
+	changed := make(map[int]bool)
+	for _, route := range policy.Routes {
+		i := findVirtualService(virtualServices, route.Namespace, route.VirtualService)
+		if i < 0 {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("virtual service %s/%s does not route to app %s",
+				route.Namespace, route.VirtualService, appName))
+		}
+		if err := applyRoutePolicy(&virtualServices[i], appName, ns, route); err != nil {
+			return err
+		}
+		changed[i] = true
+	}
+
+	var dRule *istioApi.DestinationRule
+	if policy.TrafficPolicy != nil {
+		dRule, err = istioClient.NetworkingV1alpha3().DestinationRules(ns).Get(appName, metav1.GetOptions{})
+		if kdErrors.IsNotFoundError(err) {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("app %s has no destination rule", appName))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range virtualServices {
+		if !changed[i] {
+			continue
+		}
+		if _, err := istioClient.NetworkingV1alpha3().VirtualServices(virtualServices[i].Namespace).
+			Update(&virtualServices[i]); err != nil {
+			return err
+		}
+	}
+
+	if dRule == nil {
+		return nil
+	}
+	dRule.Spec.TrafficPolicy = fromTrafficPolicy(policy.TrafficPolicy, dRule.Spec.TrafficPolicy)
+	_, err = istioClient.NetworkingV1alpha3().DestinationRules(ns).Update(dRule)
+	return err
+}
+
+func toRoutePolicies(appName, namespace string, virtualServices []istioApi.VirtualService) []api.RoutePolicy {
+	host := virtualservice.FQDN(appName, namespace)
+	policies := make([]api.RoutePolicy, 0)
+	for _, vs := range virtualServices {
+		for i, http := range vs.Spec.Http {
+			if !routesTo(http, host, vs.Namespace) {
+				continue
+			}
+
+			policy := api.RoutePolicy{
+				VirtualService: vs.Name,
+				Namespace:      vs.Namespace,
+				Index:          i,
+				Timeout:        http.Timeout,
+			}
+			for _, dest := range http.Route {
+				if host == virtualservice.FQDN(dest.Destination.Host, vs.Namespace) && dest.Destination.Subset != "" {
+					policy.Destinations = append(policy.Destinations, dest.Destination.Subset)
+				}
+			}
+			if http.Retries != nil {
+				policy.Retries = &api.RetryPolicy{
+					Attempts:      http.Retries.Attempts,
+					PerTryTimeout: http.Retries.PerTryTimeout,
+				}
+			}
+			if http.Fault != nil {
+				policy.Fault = &api.FaultInjection{}
+				if http.Fault.Delay != nil {
+					policy.Fault.Delay = &api.DelayFault{
+						Percent:    http.Fault.Delay.Percent,
+						FixedDelay: http.Fault.Delay.FixedDelay,
+					}
+				}
+				if http.Fault.Abort != nil {
+					policy.Fault.Abort = &api.AbortFault{
+						Percent:    http.Fault.Abort.Percent,
+						HttpStatus: http.Fault.Abort.HttpStatus,
+					}
+				}
+			}
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// applyRoutePolicy replaces the timeout, retries and fault of the route, which has to route to
+// the application.
+func applyRoutePolicy(vs *istioApi.VirtualService, appName, namespace string, policy api.RoutePolicy) error {
+	if policy.Index < 0 || policy.Index >= len(vs.Spec.Http) ||
+		!routesTo(vs.Spec.Http[policy.Index], virtualservice.FQDN(appName, namespace), vs.Namespace) {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("route %d of virtual service %s does not route to app %s",
+			policy.Index, vs.Name, appName))
+	}
+
+	http := vs.Spec.Http[policy.Index]
+	http.Timeout = policy.Timeout
+	http.Retries = nil
+	if policy.Retries != nil && policy.Retries.Attempts > 0 {
+		http.Retries = &istioApi.HTTPRetry{
+			Attempts:      policy.Retries.Attempts,
+			PerTryTimeout: policy.Retries.PerTryTimeout,
+		}
+	}
+
+	http.Fault = nil
+	if policy.Fault != nil && (policy.Fault.Delay != nil || policy.Fault.Abort != nil) {
+		http.Fault = &istioApi.HTTPFaultInjection{}
+		if policy.Fault.Delay != nil {
+			http.Fault.Delay = &istioApi.HTTPFaultInjection_Delay{
+				Percent:    policy.Fault.Delay.Percent,
+				FixedDelay: policy.Fault.Delay.FixedDelay,
+			}
+		}
+		if policy.Fault.Abort != nil {
+			http.Fault.Abort = &istioApi.HTTPFaultInjection_Abort{
+				Percent:    policy.Fault.Abort.Percent,
+				HttpStatus: policy.Fault.Abort.HttpStatus,
+			}
+		}
+	}
+	return nil
+}
+
+func toTrafficPolicy(trafficPolicy *istioApi.TrafficPolicy) *api.TrafficPolicy {
+	if trafficPolicy == nil {
+		return nil
+	}
+
+	policy := &api.TrafficPolicy{}
+	if trafficPolicy.LoadBalancer != nil && trafficPolicy.LoadBalancer.ConsistentHash == nil {
+		for name, value := range loadBalancers {
+			if value == trafficPolicy.LoadBalancer.Simple {
+				policy.LoadBalancer = name
+			}
+		}
+	}
+	if pool := trafficPolicy.ConnectionPool; pool != nil {
+		policy.ConnectionPool = &api.ConnectionPool{}
+		if pool.Tcp != nil {
+			policy.ConnectionPool.MaxConnections = pool.Tcp.MaxConnections
+			policy.ConnectionPool.ConnectTimeout = pool.Tcp.ConnectTimeout
+		}
+		if pool.Http != nil {
+			policy.ConnectionPool.Http1MaxPendingRequests = pool.Http.Http1MaxPendingRequests
+			policy.ConnectionPool.Http2MaxRequests = pool.Http.Http2MaxRequests
+			policy.ConnectionPool.MaxRequestsPerConnection = pool.Http.MaxRequestsPerConnection
+			policy.ConnectionPool.MaxRetries = pool.Http.MaxRetries
+		}
+	}
+	if outlier := trafficPolicy.OutlierDetection; outlier != nil {
+		policy.OutlierDetection = &api.OutlierDetection{
+			ConsecutiveErrors:  outlier.ConsecutiveErrors,
+			Interval:           outlier.Interval,
+			BaseEjectionTime:   outlier.BaseEjectionTime,
+			MaxEjectionPercent: outlier.MaxEjectionPercent,
+		}
+	}
+	return policy
+}
+
+// fromTrafficPolicy merges the policy into the current traffic policy. The load balancer,
+// connection pool and outlier detection which are omitted are kept as they are, an empty
+// connection pool or outlier detection removes it. The TLS and port level settings are kept.
+func fromTrafficPolicy(policy *api.TrafficPolicy, current *istioApi.TrafficPolicy) *istioApi.TrafficPolicy {
+	trafficPolicy := &istioApi.TrafficPolicy{}
+	if current != nil {
+		*trafficPolicy = *current
+	}
+
+	if policy != nil {
+		// consistent hashing can't be edited here, it is kept unless another balancer is chosen
+		if policy.LoadBalancer != "" {
+			trafficPolicy.LoadBalancer = &istioApi.LoadBalancerSettings{Simple: loadBalancers[policy.LoadBalancer]}
+		}
+		if pool := policy.ConnectionPool; pool != nil {
+			trafficPolicy.ConnectionPool = nil
+			if *pool != (api.ConnectionPool{}) {
+				trafficPolicy.ConnectionPool = &istioApi.ConnectionPoolSettings{
+					Tcp: &istioApi.ConnectionPoolSettings_TCPSettings{
+						MaxConnections: pool.MaxConnections,
+						ConnectTimeout: pool.ConnectTimeout,
+					},
+					Http: &istioApi.ConnectionPoolSettings_HTTPSettings{
+						Http1MaxPendingRequests:  pool.Http1MaxPendingRequests,
+						Http2MaxRequests:         pool.Http2MaxRequests,
+						MaxRequestsPerConnection: pool.MaxRequestsPerConnection,
+						MaxRetries:               pool.MaxRetries,
+					},
+				}
+			}
+		}
+		if outlier := policy.OutlierDetection; outlier != nil {
+			trafficPolicy.OutlierDetection = nil
+			if *outlier != (api.OutlierDetection{}) {
+				trafficPolicy.OutlierDetection = &istioApi.OutlierDetection{
+					ConsecutiveErrors:  outlier.ConsecutiveErrors,
+					Interval:           outlier.Interval,
+					BaseEjectionTime:   outlier.BaseEjectionTime,
+					MaxEjectionPercent: outlier.MaxEjectionPercent,
+				}
+			}
+		}
+	}
+
+	if trafficPolicy.LoadBalancer == nil && trafficPolicy.ConnectionPool == nil &&
+		trafficPolicy.OutlierDetection == nil && trafficPolicy.Tls == nil && len(trafficPolicy.PortLevelSettings) == 0 {
+		return nil
+	}
+	return trafficPolicy
+}
+
+func validateResiliencePolicy(policy *api.ResiliencePolicy) error {
+	for _, route := range policy.Routes {
+		name := fmt.Sprintf("route %d of virtual service %s", route.Index, route.VirtualService)
+		if err := validateDuration(name+" timeout", route.Timeout); err != nil {
+			return err
+		}
+		if route.Retries != nil {
+			if route.Retries.Attempts < 0 {
+				return k8sErrors.NewBadRequest(fmt.Sprintf("%s retry attempts must not be negative", name))
+			}
+			if err := validateDuration(name+" per try timeout", route.Retries.PerTryTimeout); err != nil {
+				return err
+			}
+		}
+		if route.Fault != nil {
+			if delay := route.Fault.Delay; delay != nil {
+				if err := validatePercent(name+" delay", delay.Percent); err != nil {
+					return err
+				}
+				if delay.FixedDelay == "" {
+					return k8sErrors.NewBadRequest(fmt.Sprintf("%s fixed delay is required", name))
+				}
+				if err := validateDuration(name+" fixed delay", delay.FixedDelay); err != nil {
+					return err
+				}
+			}
+			if abort := route.Fault.Abort; abort != nil {
+				if err := validatePercent(name+" abort", abort.Percent); err != nil {
+					return err
+				}
+				if abort.HttpStatus < 200 || abort.HttpStatus > 599 {
+					return k8sErrors.NewBadRequest(fmt.Sprintf("%s abort http status must be between 200 and 599, %d given",
+						name, abort.HttpStatus))
+				}
+			}
+		}
+	}
+
+	if policy.TrafficPolicy == nil {
+		return nil
+	}
+	if lb := policy.TrafficPolicy.LoadBalancer; lb != "" {
+		if _, ok := loadBalancers[lb]; !ok {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("unsupported load balancer %s", lb))
+		}
+	}
+	if pool := policy.TrafficPolicy.ConnectionPool; pool != nil {
+		if pool.MaxConnections < 0 || pool.Http1MaxPendingRequests < 0 || pool.Http2MaxRequests < 0 ||
+			pool.MaxRequestsPerConnection < 0 || pool.MaxRetries < 0 {
+			return k8sErrors.NewBadRequest("connection pool limits must not be negative")
+		}
+		if err := validateDuration("connect timeout", pool.ConnectTimeout); err != nil {
+			return err
+		}
+	}
+	if outlier := policy.TrafficPolicy.OutlierDetection; outlier != nil {
+		if outlier.ConsecutiveErrors < 0 {
+			return k8sErrors.NewBadRequest("consecutive errors must not be negative")
+		}
+		if err := validatePercent("max ejection", outlier.MaxEjectionPercent); err != nil {
+			return err
+		}
+		if err := validateDuration("outlier detection interval", outlier.Interval); err != nil {
+			return err
+		}
+		if err := validateDuration("base ejection time", outlier.BaseEjectionTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDuration checks the duration is empty or at least 1ms, as required by istio.
+func validateDuration(name, duration string) error {
+	if duration == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d < time.Millisecond {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("%s must be a duration of at least 1ms, i.e. 3s, %s given",
+			name, duration))
+	}
+	return nil
+}
+
+func validatePercent(name string, percent int32) error {
+	if percent < 0 || percent > 100 {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("%s percent must be between 0 and 100, %d given", name, percent))
+	}
+	return nil
+}
+
+func findVirtualService(virtualServices []istioApi.VirtualService, namespace, name string) int {
+	for i, vs := range virtualServices {
+		if vs.Namespace == namespace && vs.Name == name {
+			return i
+		}
+	}
+	return -1
+}
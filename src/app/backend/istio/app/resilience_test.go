@@ -0,0 +1,224 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	istioapi "github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestRoutePolicy(t *testing.T) {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"reviews"},
+			Http: []*v1alpha3.HTTPRoute{
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "ratings"}},
+					},
+				},
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}, Weight: 80},
+						{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v2"}, Weight: 20},
+					},
+					Timeout: "10s",
+				},
+			},
+		},
+	}
+
+	policies := toRoutePolicies("reviews", "default", []v1alpha3.VirtualService{vs})
+	assert.Equal(t, policies, []istioapi.RoutePolicy{{
+		VirtualService: "reviews",
+		Namespace:      "default",
+		Index:          1,
+		Destinations:   []string{"v1", "v2"},
+		Timeout:        "10s",
+	}})
+
+	policy := policies[0]
+	policy.Timeout = "3s"
+	policy.Retries = &istioapi.RetryPolicy{Attempts: 3, PerTryTimeout: "1s"}
+	policy.Fault = &istioapi.FaultInjection{Abort: &istioapi.AbortFault{Percent: 10, HttpStatus: 503}}
+	assert.Equal(t, applyRoutePolicy(&vs, "reviews", "default", policy), nil)
+	assert.Equal(t, vs.Spec.Http[1].Timeout, "3s")
+	assert.Equal(t, vs.Spec.Http[1].Retries, &v1alpha3.HTTPRetry{Attempts: 3, PerTryTimeout: "1s"})
+	assert.Equal(t, vs.Spec.Http[1].Fault.Delay == nil, true)
+	assert.Equal(t, vs.Spec.Http[1].Fault.Abort.HttpStatus, 503)
+
+	policy.Retries = nil
+	policy.Fault = nil
+	assert.Equal(t, applyRoutePolicy(&vs, "reviews", "default", policy), nil)
+	assert.Equal(t, vs.Spec.Http[1].Retries == nil, true)
+	assert.Equal(t, vs.Spec.Http[1].Fault == nil, true)
+
+	// the first route doesn't route to the app
+	policy.Index = 0
+	assert.Equal(t, applyRoutePolicy(&vs, "reviews", "default", policy) != nil, true)
+}
+
+func TestTrafficPolicy(t *testing.T) {
+	current := &v1alpha3.TrafficPolicy{
+		Tls: &v1alpha3.TLSSettings{Mode: 2},
+		OutlierDetection: &v1alpha3.OutlierDetection{
+			ConsecutiveErrors: 5,
+			Interval:          "10s",
+		},
+	}
+
+	policy := toTrafficPolicy(current)
+	assert.Equal(t, policy, &istioapi.TrafficPolicy{
+		LoadBalancer:     "",
+		OutlierDetection: &istioapi.OutlierDetection{ConsecutiveErrors: 5, Interval: "10s"},
+	})
+
+	policy.LoadBalancer = "LEAST_CONN"
+	policy.ConnectionPool = &istioapi.ConnectionPool{MaxConnections: 100, Http1MaxPendingRequests: 10}
+	// an empty outlier detection removes it
+	policy.OutlierDetection = &istioapi.OutlierDetection{}
+	updated := fromTrafficPolicy(policy, current)
+	assert.Equal(t, updated.Tls, current.Tls)
+	assert.Equal(t, updated.LoadBalancer.Simple, int32(1))
+	assert.Equal(t, updated.ConnectionPool.Tcp.MaxConnections, int32(100))
+	assert.Equal(t, updated.ConnectionPool.Http.Http1MaxPendingRequests, int32(10))
+	assert.Equal(t, updated.OutlierDetection == nil, true)
+
+	// the omitted settings are kept
+	merged := fromTrafficPolicy(&istioapi.TrafficPolicy{LoadBalancer: "RANDOM"}, updated)
+	assert.Equal(t, merged.LoadBalancer.Simple, int32(2))
+	assert.Equal(t, merged.ConnectionPool, updated.ConnectionPool)
+	assert.Equal(t, merged.Tls, current.Tls)
+	merged = fromTrafficPolicy(&istioapi.TrafficPolicy{}, current)
+	assert.Equal(t, merged.OutlierDetection, current.OutlierDetection)
+	assert.Equal(t, merged.LoadBalancer == nil, true)
+
+	assert.Equal(t, fromTrafficPolicy(nil, nil) == nil, true)
+}
+
+// newResilienceServer serves a virtual service and a destination rule of the reviews app and
+// records the paths of the updates.
+func newResilienceServer(updates *[]string) *httptest.Server {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"reviews"},
+			Http: []*v1alpha3.HTTPRoute{{
+				Route: []*v1alpha3.DestinationWeight{
+					{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}},
+				},
+			}},
+		},
+	}
+	dRule := v1alpha3.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.DestinationRuleSpec{
+			Host: "reviews",
+			TrafficPolicy: &v1alpha3.TrafficPolicy{
+				LoadBalancer:     &v1alpha3.LoadBalancerSettings{Simple: 1},
+				OutlierDetection: &v1alpha3.OutlierDetection{ConsecutiveErrors: 5, Interval: "10s"},
+			},
+		},
+	}
+
+	mu := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			mu.Lock()
+			*updates = append(*updates, r.URL.Path)
+			mu.Unlock()
+			w.Write([]byte("{}"))
+			return
+		}
+		switch r.URL.Path {
+		case "/apis/networking.istio.io/v1alpha3/virtualservices":
+			json.NewEncoder(w).Encode(v1alpha3.VirtualServiceList{Items: []v1alpha3.VirtualService{vs}})
+		case "/apis/networking.istio.io/v1alpha3/namespaces/default/destinationrules/reviews":
+			json.NewEncoder(w).Encode(dRule)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestUpdateResiliencePolicy(t *testing.T) {
+	updates := []string{}
+	server := newResilienceServer(&updates)
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+	namespace := common.NewNamespaceQuery([]string{"default"})
+	route := istioapi.RoutePolicy{VirtualService: "reviews", Namespace: "default", Index: 0, Timeout: "3s"}
+
+	// without a traffic policy the destination rule is left as it is
+	err = UpdateResiliencePolicy(istioClient, namespace, "reviews",
+		&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{route}}, virtualservice.All)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, updates, []string{"/apis/networking.istio.io/v1alpha3/namespaces/default/virtualservices/reviews"})
+
+	updates = updates[:0]
+	err = UpdateResiliencePolicy(istioClient, namespace, "reviews",
+		&istioapi.ResiliencePolicy{TrafficPolicy: &istioapi.TrafficPolicy{LoadBalancer: "RANDOM"}}, virtualservice.All)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, updates, []string{"/apis/networking.istio.io/v1alpha3/namespaces/default/destinationrules/reviews"})
+}
+
+func TestValidateResiliencePolicy(t *testing.T) {
+	cases := []struct {
+		policy   *istioapi.ResiliencePolicy
+		expected bool
+	}{
+		{&istioapi.ResiliencePolicy{}, true},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Timeout: "3s",
+			Retries: &istioapi.RetryPolicy{Attempts: 3, PerTryTimeout: "500ms"}}}}, true},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Timeout: "3"}}}, false},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Timeout: "100us"}}}, false},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Retries: &istioapi.RetryPolicy{Attempts: -1}}}}, false},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Fault: &istioapi.FaultInjection{
+			Delay: &istioapi.DelayFault{Percent: 50, FixedDelay: "5s"}}}}}, true},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Fault: &istioapi.FaultInjection{
+			Delay: &istioapi.DelayFault{Percent: 150, FixedDelay: "5s"}}}}}, false},
+		{&istioapi.ResiliencePolicy{Routes: []istioapi.RoutePolicy{{Fault: &istioapi.FaultInjection{
+			Abort: &istioapi.AbortFault{Percent: 10, HttpStatus: 42}}}}}, false},
+		{&istioapi.ResiliencePolicy{TrafficPolicy: &istioapi.TrafficPolicy{LoadBalancer: "RANDOM"}}, true},
+		{&istioapi.ResiliencePolicy{TrafficPolicy: &istioapi.TrafficPolicy{LoadBalancer: "FASTEST"}}, false},
+		{&istioapi.ResiliencePolicy{TrafficPolicy: &istioapi.TrafficPolicy{
+			ConnectionPool: &istioapi.ConnectionPool{MaxConnections: -1}}}, false},
+		{&istioapi.ResiliencePolicy{TrafficPolicy: &istioapi.TrafficPolicy{
+			OutlierDetection: &istioapi.OutlierDetection{MaxEjectionPercent: 120}}}, false},
+	}
+
+	for _, c := range cases {
+		err := validateResiliencePolicy(c.policy)
+		assert.Equal(t, err == nil, c.expected)
+	}
+}
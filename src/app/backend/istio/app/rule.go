@@ -0,0 +1,385 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	cookieHeader    = "cookie"
+	userAgentHeader = "user-agent"
+
+	// cookiePrefix and cookieSuffix surround the quoted name=value pair of a cookie rule, so it
+	// matches the pair anywhere in the cookie header.
+	cookiePrefix = `^(.*?;\s*)?(`
+	cookieSuffix = `)(;.*)?$`
+
+	// RoutingRulesAnnotation lists the ids of the routing rules the dashboard added to a virtual
+	// service, separated by commas. Only these routes are listed and removed as routing rules, the
+	// hand-written routes are left alone. The vendored istio types don't know the name of an http
+	// route, so the routes can't be marked themselves.
+	RoutingRulesAnnotation = "dashboard.istio.io/routing-rules"
+)
+
+// GetRoutingRules lists the routing rules of the virtual services routing to the application.
+func GetRoutingRules(istioClient istio.Interface, namespace *common.NamespaceQuery, appName string) (*api.RoutingRuleList, error) {
+	vServices, err := virtualservice.GetVirtualServices(istioClient,
+		[]string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, virtualservice.All)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := toRoutingRules(appName, namespace.ToRequestParam(), vServices)
+	return &api.RoutingRuleList{
+		ListMeta: api2.ListMeta{TotalItems: len(rules)},
+		Rules:    rules,
+		Errors:   []error{},
+	}, nil
+}
+
+// AddRoutingRule adds the rule ahead of the default route of every virtual service routing to the
+// application. The rule's version has to be a subset of the app's destination rule. An already
+// exists error is returned when the virtual services already have the rule.
+func AddRoutingRule(istioClient istio.Interface, namespace *common.NamespaceQuery, appName string,
+	rule *api.RoutingRule, targetType string) (*api.RoutingRule, error) {
+	match, err := toMatchRequest(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations, err := getAppDestinationsByName(istioClient, namespace.ToRequestParam(), appName)
+	if err != nil {
+		return nil, err
+	}
+	if !hasDestination(destinations, rule.Version) {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("version %s is not a subset of the destination rule", rule.Version))
+	}
+
+	vServices, err := virtualservice.GetVirtualServices(istioClient,
+		[]string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	result := *rule
+	result.ID = ruleID(match, rule.Version)
+	result.VirtualServices = make([]string, 0)
+	exists := false
+	for _, vs := range vServices {
+		if managedRules(&vs)[result.ID] {
+			exists = true
+			continue
+		}
+		if !insertRoutingRule(&vs, appName, namespace.ToRequestParam(), match, rule.Version) {
+			continue
+		}
+		if _, err := istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Update(&vs); err != nil {
+			return nil, err
+		}
+		result.VirtualServices = append(result.VirtualServices, vs.Name)
+	}
+
+	if len(result.VirtualServices) == 0 && exists {
+		return nil, k8sErrors.NewAlreadyExists(schema.GroupResource{Resource: "routingrules"}, result.ID)
+	}
+	if len(result.VirtualServices) == 0 {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("no virtual service has a default route to app %s", appName))
+	}
+	return &result, nil
+}
+
+// RemoveRoutingRule removes the rule with the given id from every virtual service routing to the application.
+func RemoveRoutingRule(istioClient istio.Interface, namespace *common.NamespaceQuery, appName, id string,
+	targetType string) error {
+	vServices, err := virtualservice.GetVirtualServices(istioClient,
+		[]string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, targetType)
+	if err != nil {
+		return err
+	}
+
+	removed := false
+	for _, vs := range vServices {
+		if !deleteRoutingRule(&vs, appName, namespace.ToRequestParam(), id) {
+			continue
+		}
+		if _, err := istioClient.NetworkingV1alpha3().VirtualServices(vs.Namespace).Update(&vs); err != nil {
+			return err
+		}
+		removed = true
+	}
+
+	if !removed {
+		return k8sErrors.NewNotFound(schema.GroupResource{Resource: "routingrules"}, id)
+	}
+	return nil
+}
+
+// toRoutingRules collects the routing rules the dashboard added to the virtual services, rules
+// found in several virtual services are merged.
+func toRoutingRules(appName, namespace string, vServices []istioApi.VirtualService) []api.RoutingRule {
+	host := virtualservice.FQDN(appName, namespace)
+	rules := make([]api.RoutingRule, 0)
+	indexes := make(map[string]int)
+	for _, vs := range vServices {
+		managed := managedRules(&vs)
+		for _, http := range vs.Spec.Http {
+			rule := toRoutingRule(http, host, vs.Namespace)
+			if rule == nil || !managed[rule.ID] {
+				continue
+			}
+
+			if i, exists := indexes[rule.ID]; exists {
+				rules[i].VirtualServices = append(rules[i].VirtualServices, vs.Name)
+				continue
+			}
+			rule.VirtualServices = []string{vs.Name}
+			indexes[rule.ID] = len(rules)
+			rules = append(rules, *rule)
+		}
+	}
+	return rules
+}
+
+// toRoutingRule converts the http route to a routing rule, nil is returned when the route isn't a
+// header, cookie or user agent match to a single subset of the host.
+func toRoutingRule(http *istioApi.HTTPRoute, host, namespace string) *api.RoutingRule {
+	if len(http.Match) != 1 || len(http.Route) != 1 {
+		return nil
+	}
+
+	match := http.Match[0]
+	dest := http.Route[0].Destination
+	if dest == nil || dest.Subset == "" || host != virtualservice.FQDN(dest.Host, namespace) {
+		return nil
+	}
+	if match.Uri != nil || match.Scheme != nil || match.Method != nil || match.Authority != nil ||
+		match.Port != 0 || len(match.SourceLabels) > 0 || len(match.Gateways) > 0 || len(match.Headers) == 0 {
+		return nil
+	}
+
+	rule := &api.RoutingRule{
+		ID:      ruleID(match, dest.Subset),
+		Version: dest.Subset,
+	}
+	for name, value := range match.Headers {
+		switch {
+		case value == nil:
+			return nil
+		case name == cookieHeader && strings.HasPrefix(value.Regex, cookiePrefix) &&
+			strings.HasSuffix(value.Regex, cookieSuffix):
+			pair := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(value.Regex, cookiePrefix), cookieSuffix), "=", 2)
+			if len(pair) != 2 {
+				return nil
+			}
+			rule.Cookie = &api.KeyValueMatch{Name: unquoteMeta(pair[0]), Value: unquoteMeta(pair[1])}
+		case name == userAgentHeader && value.Regex != "":
+			rule.UserAgent = value.Regex
+		case value.Exact != "" && rule.Header == nil:
+			rule.Header = &api.KeyValueMatch{Name: name, Value: value.Exact}
+		default:
+			return nil
+		}
+	}
+	return rule
+}
+
+// toMatchRequest converts the routing rule's conditions to the http match request.
+func toMatchRequest(rule *api.RoutingRule) (*istioApi.HTTPMatchRequest, error) {
+	if rule.Version == "" {
+		return nil, k8sErrors.NewBadRequest("routing rule without version")
+	}
+
+	headers := make(map[string]*istioApi.StringMatch)
+	if rule.Header != nil {
+		name := strings.ToLower(rule.Header.Name)
+		if name == "" || rule.Header.Value == "" {
+			return nil, k8sErrors.NewBadRequest("header match requires both name and value")
+		}
+		if name == cookieHeader || name == userAgentHeader {
+			return nil, k8sErrors.NewBadRequest(fmt.Sprintf("use the %s match instead of the header match", name))
+		}
+		headers[name] = &istioApi.StringMatch{Exact: rule.Header.Value}
+	}
+
+	if rule.Cookie != nil {
+		if rule.Cookie.Name == "" || rule.Cookie.Value == "" {
+			return nil, k8sErrors.NewBadRequest("cookie match requires both name and value")
+		}
+		headers[cookieHeader] = &istioApi.StringMatch{
+			Regex: cookiePrefix + regexp.QuoteMeta(rule.Cookie.Name) + "=" + regexp.QuoteMeta(rule.Cookie.Value) + cookieSuffix,
+		}
+	}
+
+	if rule.UserAgent != "" {
+		if _, err := regexp.Compile(rule.UserAgent); err != nil {
+			return nil, k8sErrors.NewBadRequest(fmt.Sprintf("invalid user agent regex: %s", err))
+		}
+		headers[userAgentHeader] = &istioApi.StringMatch{Regex: rule.UserAgent}
+	}
+
+	if len(headers) == 0 {
+		return nil, k8sErrors.NewBadRequest("routing rule requires a header, cookie or user agent match")
+	}
+	return &istioApi.HTTPMatchRequest{Headers: headers}, nil
+}
+
+// insertRoutingRule inserts the rule right ahead of the default route to the host and marks it as
+// added by the dashboard. It returns false when the virtual service has no default route to the
+// host or already has the rule.
+func insertRoutingRule(vs *istioApi.VirtualService, appName, namespace string, match *istioApi.HTTPMatchRequest,
+	subset string) bool {
+	host := virtualservice.FQDN(appName, namespace)
+	id := ruleID(match, subset)
+	managed := managedRules(vs)
+	if managed[id] {
+		return false
+	}
+
+	position := -1
+	for i, http := range vs.Spec.Http {
+		if position < 0 && len(http.Match) == 0 && routesTo(http, host, vs.Namespace) {
+			position = i
+		}
+	}
+	if position < 0 {
+		return false
+	}
+
+	var template *istioApi.Destination
+	for _, dest := range vs.Spec.Http[position].Route {
+		if host == virtualservice.FQDN(dest.Destination.Host, vs.Namespace) {
+			template = dest.Destination
+			break
+		}
+	}
+
+	route := &istioApi.HTTPRoute{
+		Match: []*istioApi.HTTPMatchRequest{match},
+		Route: []*istioApi.DestinationWeight{
+			{
+				Destination: &istioApi.Destination{
+					Host:   template.Host,
+					Subset: subset,
+					Port:   template.Port,
+				},
+			},
+		},
+	}
+
+	https := make([]*istioApi.HTTPRoute, 0, len(vs.Spec.Http)+1)
+	https = append(https, vs.Spec.Http[:position]...)
+	https = append(https, route)
+	vs.Spec.Http = append(https, vs.Spec.Http[position:]...)
+
+	managed[id] = true
+	setManagedRules(vs, managed)
+	return true
+}
+
+// deleteRoutingRule removes the route of the rule with the given id the dashboard added, it returns
+// false when no rule was removed.
+func deleteRoutingRule(vs *istioApi.VirtualService, appName, namespace, id string) bool {
+	managed := managedRules(vs)
+	if !managed[id] {
+		return false
+	}
+
+	host := virtualservice.FQDN(appName, namespace)
+	removed := false
+	https := make([]*istioApi.HTTPRoute, 0, len(vs.Spec.Http))
+	for _, http := range vs.Spec.Http {
+		// the dashboard inserts a rule once, a hand-written copy of its route is kept
+		if rule := toRoutingRule(http, host, vs.Namespace); !removed && rule != nil && rule.ID == id {
+			removed = true
+			continue
+		}
+		https = append(https, http)
+	}
+	vs.Spec.Http = https
+
+	delete(managed, id)
+	setManagedRules(vs, managed)
+	return removed
+}
+
+// managedRules returns the ids of the routing rules the dashboard added to the virtual service.
+func managedRules(vs *istioApi.VirtualService) map[string]bool {
+	managed := make(map[string]bool)
+	for _, id := range strings.Split(vs.Annotations[RoutingRulesAnnotation], ",") {
+		if id != "" {
+			managed[id] = true
+		}
+	}
+	return managed
+}
+
+// setManagedRules records the ids of the routing rules the dashboard added to the virtual service.
+func setManagedRules(vs *istioApi.VirtualService, managed map[string]bool) {
+	ids := make([]string, 0, len(managed))
+	for id := range managed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if len(ids) == 0 {
+		delete(vs.Annotations, RoutingRulesAnnotation)
+		return
+	}
+	if vs.Annotations == nil {
+		vs.Annotations = make(map[string]string)
+	}
+	vs.Annotations[RoutingRulesAnnotation] = strings.Join(ids, ",")
+}
+
+// ruleID computes a short stable identifier of the match conditions routed to the subset.
+func ruleID(match *istioApi.HTTPMatchRequest, subset string) string {
+	// maps are marshalled with sorted keys, so the encoding is stable.
+	data, _ := json.Marshal(match)
+	hash := fnv.New32a()
+	hash.Write(data)
+	hash.Write([]byte(subset))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// unquoteMeta reverts regexp.QuoteMeta.
+func unquoteMeta(s string) string {
+	var b bytes.Buffer
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestRoutingRules(t *testing.T) {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "wall",
+		},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"test"},
+			Http: []*v1alpha3.HTTPRoute{
+				{
+					Route: []*v1alpha3.DestinationWeight{
+						{
+							Destination: &v1alpha3.Destination{
+								Host:   "test",
+								Subset: "v1",
+								Port:   &v1alpha3.PortSelector{Number: 8080},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rules := []*api.RoutingRule{
+		{Version: "v2", Header: &api.KeyValueMatch{Name: "X-Canary", Value: "true"}},
+		{Version: "v2", Cookie: &api.KeyValueMatch{Name: "user", Value: "qa.team"}},
+		{Version: "v3", UserAgent: ".*Mobile.*"},
+	}
+	for _, rule := range rules {
+		match, err := toMatchRequest(rule)
+		if err != nil {
+			t.Fatalf("toMatchRequest(%+v) returned error: %s", rule, err)
+		}
+		assert.Equal(t, insertRoutingRule(&vs, "test", "wall", match, rule.Version), true)
+		// the same rule is added once.
+		assert.Equal(t, insertRoutingRule(&vs, "test", "wall", match, rule.Version), false)
+	}
+
+	assert.Equal(t, len(vs.Spec.Http), 4)
+	// the default route stays the last one.
+	assert.Equal(t, len(vs.Spec.Http[3].Match), 0)
+	assert.Equal(t, vs.Spec.Http[0].Route[0].Destination.Port.Number, uint32(8080))
+
+	listed := toRoutingRules("test", "wall", []v1alpha3.VirtualService{vs})
+	assert.Equal(t, len(listed), 3)
+	assert.Equal(t, listed[0].Header.Name, "x-canary")
+	assert.Equal(t, listed[0].Header.Value, "true")
+	assert.Equal(t, listed[1].Cookie.Name, "user")
+	assert.Equal(t, listed[1].Cookie.Value, "qa.team")
+	assert.Equal(t, listed[2].UserAgent, ".*Mobile.*")
+	assert.Equal(t, listed[2].Version, "v3")
+	assert.Equal(t, listed[2].VirtualServices, []string{"test"})
+
+	assert.Equal(t, deleteRoutingRule(&vs, "test", "wall", listed[1].ID), true)
+	assert.Equal(t, deleteRoutingRule(&vs, "test", "wall", listed[1].ID), false)
+	assert.Equal(t, len(vs.Spec.Http), 3)
+}
+
+func TestRoutingRulesHandWritten(t *testing.T) {
+	rule := &api.RoutingRule{Version: "v2", Header: &api.KeyValueMatch{Name: "x-canary", Value: "true"}}
+	match, err := toMatchRequest(rule)
+	if err != nil {
+		t.Fatalf("toMatchRequest(%+v) returned error: %s", rule, err)
+	}
+	handWritten := &v1alpha3.HTTPRoute{
+		Match: []*v1alpha3.HTTPMatchRequest{match},
+		Route: []*v1alpha3.DestinationWeight{{Destination: &v1alpha3.Destination{Host: "test", Subset: "v2"}}},
+	}
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "wall"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"test"},
+			Http: []*v1alpha3.HTTPRoute{
+				handWritten,
+				{Route: []*v1alpha3.DestinationWeight{{Destination: &v1alpha3.Destination{Host: "test", Subset: "v1"}}}},
+			},
+		},
+	}
+	id := ruleID(match, rule.Version)
+
+	// the hand-written route is neither listed nor removed
+	assert.Equal(t, len(toRoutingRules("test", "wall", []v1alpha3.VirtualService{vs})), 0)
+	assert.Equal(t, deleteRoutingRule(&vs, "test", "wall", id), false)
+	assert.Equal(t, len(vs.Spec.Http), 2)
+
+	assert.Equal(t, insertRoutingRule(&vs, "test", "wall", match, rule.Version), true)
+	assert.Equal(t, vs.Annotations[RoutingRulesAnnotation], id)
+	assert.Equal(t, len(toRoutingRules("test", "wall", []v1alpha3.VirtualService{vs})), 1)
+
+	// only the route the dashboard added is removed
+	assert.Equal(t, deleteRoutingRule(&vs, "test", "wall", id), true)
+	assert.Equal(t, len(vs.Spec.Http), 2)
+	assert.Equal(t, vs.Spec.Http[0], handWritten)
+	_, marked := vs.Annotations[RoutingRulesAnnotation]
+	assert.Equal(t, marked, false)
+}
+
+func TestAddRoutingRuleExists(t *testing.T) {
+	rule := &api.RoutingRule{Version: "v2", Header: &api.KeyValueMatch{Name: "x-canary", Value: "true"}}
+	match, err := toMatchRequest(rule)
+	if err != nil {
+		t.Fatalf("toMatchRequest(%+v) returned error: %s", rule, err)
+	}
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts: []string{"reviews"},
+			Http: []*v1alpha3.HTTPRoute{
+				{Route: []*v1alpha3.DestinationWeight{{Destination: &v1alpha3.Destination{Host: "reviews", Subset: "v1"}}}},
+			},
+		},
+	}
+	insertRoutingRule(&vs, "reviews", "default", match, rule.Version)
+	dRule := v1alpha3.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: v1alpha3.DestinationRuleSpec{
+			Host:    "reviews",
+			Subsets: []*v1alpha3.Subset{{Name: "v1"}, {Name: "v2"}},
+		},
+	}
+
+	updates := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			updates++
+			w.Write([]byte("{}"))
+			return
+		}
+		switch r.URL.Path {
+		case "/apis/networking.istio.io/v1alpha3/virtualservices":
+			json.NewEncoder(w).Encode(v1alpha3.VirtualServiceList{Items: []v1alpha3.VirtualService{vs}})
+		case "/apis/networking.istio.io/v1alpha3/namespaces/default/destinationrules":
+			json.NewEncoder(w).Encode(v1alpha3.DestinationRuleList{Items: []v1alpha3.DestinationRule{dRule}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+
+	_, err = AddRoutingRule(istioClient, common.NewNamespaceQuery([]string{"default"}), "reviews", rule, virtualservice.All)
+	assert.Equal(t, k8sErrors.IsAlreadyExists(err), true)
+	assert.Equal(t, updates, 0)
+}
+
+func TestToMatchRequestValidation(t *testing.T) {
+	cases := []*api.RoutingRule{
+		{Version: "v2"},
+		{Header: &api.KeyValueMatch{Name: "x-canary", Value: "true"}},
+		{Version: "v2", Header: &api.KeyValueMatch{Name: "cookie", Value: "a=b"}},
+		{Version: "v2", Cookie: &api.KeyValueMatch{Name: "user"}},
+		{Version: "v2", UserAgent: "(unclosed"},
+	}
+
+	for _, c := range cases {
+		_, err := toMatchRequest(c)
+		assert.Equal(t, err != nil, true)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"sort"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+)
+
+// meshGateway is the reserved gateway name of the sidecars inside the mesh.
+const meshGateway = "mesh"
+
+// GetAppVersions queries the application's versions and the traffic each version receives.
+// 1. get the subsets of the app's destination rule
+// 2. get the deployments and stateful sets labelled with the app
+// 3. get the virtual services routing to the app
+func GetAppVersions(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName string, keys api.LabelKeys) (*api.AppVersionList, error) {
+	destinations, err := getAppDestinationsByName(istioClient, namespace.ToRequestParam(), appName)
+	if err != nil {
+		return nil, err
+	}
+
+	workloads, err := getParentWorkloads(client, namespace.ToRequestParam(), appName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	vServices, err := virtualservice.GetVirtualServices(istioClient,
+		[]string{virtualservice.FQDN(appName, namespace.ToRequestParam())}, virtualservice.All)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := toAppVersions(appName, namespace.ToRequestParam(), destinations, workloads, vServices)
+	return &api.AppVersionList{
+		ListMeta: api2.ListMeta{TotalItems: len(versions)},
+		Versions: versions,
+		Errors:   []error{},
+	}, nil
+}
+
+// toAppVersions merges destination rule subsets, workloads and virtual services into versions.
+func toAppVersions(appName, namespace string, destinations []api.Destination, workloads []parentWorkload,
+	vServices []istioApi.VirtualService) []api.AppVersion {
+	versions := make([]api.AppVersion, 0)
+	matched := make(map[string]bool)
+
+	for i := range destinations {
+		version := api.AppVersion{
+			Version: destinations[i].Version,
+			Subset:  &destinations[i],
+			Weights: getVersionWeights(appName, namespace, destinations[i].Version, vServices),
+		}
+
+		for j := range workloads {
+			if len(destinations[i].Selector) > 0 && subsetOf(destinations[i].Selector, workloads[j].template.Labels) {
+				version.Deployment = toVersionDeployment(&workloads[j])
+				matched[workloads[j].kind+"/"+workloads[j].name] = true
+				break
+			}
+		}
+
+		version.Drift = version.Deployment == nil
+		versions = append(versions, version)
+	}
+
+	for i := range workloads {
+		if matched[workloads[i].kind+"/"+workloads[i].name] {
+			continue
+		}
+
+		name := workloads[i].version
+		if name == "" {
+			name = workloads[i].name
+		}
+		versions = append(versions, api.AppVersion{
+			Version:    name,
+			Deployment: toVersionDeployment(&workloads[i]),
+			Weights:    make([]api.VersionWeight, 0),
+			Drift:      true,
+		})
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions
+}
+
+func toVersionDeployment(workload *parentWorkload) *api.VersionDeployment {
+	var desired int32 = 1
+	if workload.replicas != nil {
+		desired = *workload.replicas
+	}
+	return &api.VersionDeployment{
+		Kind:    workload.kind,
+		Name:    workload.name,
+		Ready:   workload.readyReplicas(),
+		Desired: desired,
+	}
+}
+
+// getVersionWeights computes the weight each virtual service gives to the subset of the app.
+func getVersionWeights(appName, namespace, subset string, vServices []istioApi.VirtualService) []api.VersionWeight {
+	weights := make([]api.VersionWeight, 0)
+	host := virtualservice.FQDN(appName, namespace)
+	for _, vs := range vServices {
+		route := getDefaultRoute(&vs, host)
+		if route == nil {
+			continue
+		}
+
+		weights = append(weights, api.VersionWeight{
+			VirtualService: vs.Name,
+			Namespace:      vs.Namespace,
+			Gateways:       vs.Spec.Gateways,
+			Mesh:           len(vs.Spec.Gateways) == 0 || contains(vs.Spec.Gateways, meshGateway),
+			Weight:         getSubsetWeight(route, host, vs.Namespace, subset),
+		})
+	}
+	return weights
+}
+
+// getDefaultRoute returns the http route which serves the requests to the host that aren't matched
+// by any other rule, i.e. the first route to the host without match conditions. When every route
+// has match conditions, the first route to the host is returned.
+func getDefaultRoute(vs *istioApi.VirtualService, host string) *istioApi.HTTPRoute {
+	var first *istioApi.HTTPRoute
+	for _, http := range vs.Spec.Http {
+		if !routesTo(http, host, vs.Namespace) {
+			continue
+		}
+		if len(http.Match) == 0 {
+			return http
+		}
+		if first == nil {
+			first = http
+		}
+	}
+	return first
+}
+
+// getSubsetWeight returns the percentage of the host's traffic the route sends to the subset.
+func getSubsetWeight(route *istioApi.HTTPRoute, host, namespace, subset string) int32 {
+	var weight int32
+	var destinations int
+	var matched bool
+	for _, dest := range route.Route {
+		if host != virtualservice.FQDN(dest.Destination.Host, namespace) {
+			continue
+		}
+		destinations++
+		if dest.Destination.Subset == subset {
+			weight += dest.Weight
+			matched = true
+		}
+	}
+
+	// a single destination without weight receives all the traffic.
+	if destinations == 1 && matched && weight == 0 {
+		return 100
+	}
+	return weight
+}
+
+func routesTo(http *istioApi.HTTPRoute, host, namespace string) bool {
+	for _, dest := range http.Route {
+		if host == virtualservice.FQDN(dest.Destination.Host, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+func subsetOf(given map[string]string, target map[string]string) bool {
+	for k, v := range given {
+		if target[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(collection []string, element string) bool {
+	for _, e := range collection {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
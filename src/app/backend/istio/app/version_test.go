@@ -0,0 +1,122 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newVersionDeployment(name, version string, ready, desired int32) parentWorkload {
+	labels := map[string]string{recommendedKeys.App: "test", recommendedKeys.Version: version}
+	return newDeploymentWorkload(&v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &desired,
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+		Status: v1beta1.DeploymentStatus{ReadyReplicas: ready},
+	}, recommendedKeys)
+}
+
+func TestToAppVersions(t *testing.T) {
+	destinations := []api.Destination{
+		{Version: "v1", Selector: map[string]string{recommendedKeys.Version: "v1"}},
+		{Version: "v2", Selector: map[string]string{recommendedKeys.Version: "v2"}},
+		{Version: "v3", Selector: map[string]string{recommendedKeys.Version: "v3"}},
+	}
+	set := newParentStatefulSet("test-v2", "v2")
+	set.Status.ReadyReplicas = 1
+	workloads := []parentWorkload{
+		newVersionDeployment("test-v1", "v1", 2, 3),
+		newStatefulSetWorkload(set, recommendedKeys),
+		newVersionDeployment("test-v4", "v4", 1, 1),
+	}
+	vServices := []v1alpha3.VirtualService{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "wall"},
+			Spec: v1alpha3.VirtualServiceSpec{
+				Hosts: []string{"test"},
+				Http: []*v1alpha3.HTTPRoute{
+					{
+						Match: []*v1alpha3.HTTPMatchRequest{
+							{Headers: map[string]*v1alpha3.StringMatch{"x-canary": {Exact: "true"}}},
+						},
+						Route: []*v1alpha3.DestinationWeight{
+							{Destination: &v1alpha3.Destination{Host: "test", Subset: "v2"}},
+						},
+					},
+					{
+						Route: []*v1alpha3.DestinationWeight{
+							{Destination: &v1alpha3.Destination{Host: "test", Subset: "v1"}, Weight: 80},
+							{Destination: &v1alpha3.Destination{Host: "test.wall.svc.cluster.local", Subset: "v2"}, Weight: 20},
+						},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-test-com", Namespace: "wall"},
+			Spec: v1alpha3.VirtualServiceSpec{
+				Hosts:    []string{"api.test.com"},
+				Gateways: []string{"api-test-com"},
+				Http: []*v1alpha3.HTTPRoute{
+					{
+						Route: []*v1alpha3.DestinationWeight{
+							{Destination: &v1alpha3.Destination{Host: "test", Subset: "v1"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	versions := toAppVersions("test", "wall", destinations, workloads, vServices)
+
+	assert.Equal(t, len(versions), 4)
+
+	assert.Equal(t, versions[0].Version, "v1")
+	assert.Equal(t, versions[0].Drift, false)
+	assert.Equal(t, versions[0].Deployment.Name, "test-v1")
+	assert.Equal(t, versions[0].Deployment.Ready, int32(2))
+	assert.Equal(t, versions[0].Deployment.Desired, int32(3))
+	assert.Equal(t, len(versions[0].Weights), 2)
+	assert.Equal(t, versions[0].Weights[0].Mesh, true)
+	assert.Equal(t, versions[0].Weights[0].Weight, int32(80))
+	assert.Equal(t, versions[0].Weights[1].Mesh, false)
+	assert.Equal(t, versions[0].Weights[1].Weight, int32(100))
+
+	assert.Equal(t, versions[1].Version, "v2")
+	assert.Equal(t, *versions[1].Deployment, api.VersionDeployment{Kind: "statefulset", Name: "test-v2", Ready: 1, Desired: 3})
+	assert.Equal(t, versions[1].Weights[0].Weight, int32(20))
+	assert.Equal(t, versions[1].Weights[1].Weight, int32(0))
+
+	// subset without deployment
+	assert.Equal(t, versions[2].Version, "v3")
+	assert.Equal(t, versions[2].Drift, true)
+	assert.Equal(t, versions[2].Deployment == nil, true)
+
+	// deployment without subset
+	assert.Equal(t, versions[3].Version, "v4")
+	assert.Equal(t, versions[3].Drift, true)
+	assert.Equal(t, versions[3].Subset == nil, true)
+}
@@ -15,26 +15,46 @@
 package istio
 
 import (
+	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	restful "github.com/emicklei/go-restful"
+	"github.com/kubernetes/dashboard/src/app/backend/args"
 	clientapi "github.com/kubernetes/dashboard/src/app/backend/client/api"
 	kdErrors "github.com/kubernetes/dashboard/src/app/backend/errors"
 	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/integration/metric/prometheus"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/analysis"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/app"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/ingress"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/injection"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/rollout"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/security"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/topology"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/kubernetes/dashboard/src/app/backend/settings"
 	"k8s.io/api/apps/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
 )
 
+// yamlMIME is the content type of the application bundles.
+const yamlMIME = "application/yaml"
+
 // IstioHandler manages all endpoints related to istio management.
 type IstioHandler struct {
-	cManager clientapi.ClientManager
+	cManager   clientapi.ClientManager
+	sManager   settings.SettingsManager
+	prometheus *prometheus.PrometheusClient
+	drainer    *app.Drainer
 }
 
 // Install creates new endpoints for istio management.
@@ -54,11 +74,22 @@ func (self *IstioHandler) Install(ws *restful.WebService) {
 	ws.Route(
 		ws.POST("/istio/app/{namespace}/{app}").
 			To(self.handleCreateApp).
+			Reads(api.NewApplication{}).
 			Writes(nil))
 	ws.Route(
 		ws.DELETE("/istio/app/{namespace}/{app}").
 			To(self.handleDeleteApp).
-			Writes(nil))
+			Writes(api.AppDeletion{}))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/export").
+			To(self.handleExportApp).
+			Produces(yamlMIME).
+			Writes(""))
+	ws.Route(
+		ws.POST("/istio/app/{namespace}/{app}/import").
+			To(self.handleImportApp).
+			Consumes(yamlMIME).
+			Writes(api.AppImport{}))
 	ws.Route(
 		ws.POST("/istio/app/{namespace}/{app}/canary").
 			To(self.handleCanaryApp).
@@ -67,20 +98,127 @@ func (self *IstioHandler) Install(ws *restful.WebService) {
 	ws.Route(
 		ws.DELETE("/istio/app/{namespace}/{app}/{version}").
 			To(self.handleOfflineVersion).
-			Writes(nil))
+			Writes(api.DrainStatus{}))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/{version}/drain").
+			To(self.handleGetDrainStatus).
+			Writes(api.DrainStatus{}))
 	ws.Route(
 		ws.POST("/istio/app/{namespace}/{app}/{version}/takeover").
 			To(self.handleAppTakeOverAllTraffic).
 			Writes(nil))
+	ws.Route(
+		ws.PUT("/istio/app/{namespace}/{app}/traffic").
+			To(self.handleShiftAppTraffic).
+			Reads(api.TrafficShifting{}).
+			Writes(nil))
+	ws.Route(
+		ws.PUT("/istio/app/{namespace}/{app}/mirror").
+			To(self.handleMirrorAppTraffic).
+			Reads(api.TrafficMirror{}).
+			Writes(nil))
+	ws.Route(
+		ws.DELETE("/istio/app/{namespace}/{app}/mirror").
+			To(self.handleStopAppMirror).
+			Writes(nil))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/resilience").
+			To(self.handleGetResiliencePolicy).
+			Writes(api.ResiliencePolicy{}))
+	ws.Route(
+		ws.PUT("/istio/app/{namespace}/{app}/resilience").
+			To(self.handleUpdateResiliencePolicy).
+			Reads(api.ResiliencePolicy{}).
+			Writes(api.ResiliencePolicy{}))
 	ws.Route(
 		ws.GET("/istio/app/{namespace}/{app}/versions").
 			To(self.handleGetAppVersions).
+			Writes(api.AppVersionList{}))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/diff").
+			To(self.handleDiffAppVersions).
+			Writes(api.VersionDiff{}))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/metrics").
+			To(self.handleGetAppMetrics).
+			Writes(prometheus.AppMetrics{}))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/rules").
+			To(self.handleGetRoutingRules).
+			Writes(api.RoutingRuleList{}))
+	ws.Route(
+		ws.POST("/istio/app/{namespace}/{app}/rules").
+			To(self.handleAddRoutingRule).
+			Reads(api.RoutingRule{}).
+			Writes(api.RoutingRule{}))
+	ws.Route(
+		ws.DELETE("/istio/app/{namespace}/{app}/rules/{rule}").
+			To(self.handleRemoveRoutingRule).
+			Writes(nil))
+	ws.Route(
+		ws.GET("/istio/app/{namespace}/{app}/rollout").
+			To(self.handleGetRollout).
+			Writes(api.Rollout{}))
+	ws.Route(
+		ws.POST("/istio/app/{namespace}/{app}/rollout").
+			To(self.handleStartRollout).
+			Reads(api.RolloutSpec{}).
+			Writes(api.Rollout{}))
+	ws.Route(
+		ws.DELETE("/istio/app/{namespace}/{app}/rollout").
+			To(self.handleAbortRollout).
 			Writes(nil))
 	ws.Route(
 		ws.GET("/istio/app/{namespace}/{app}/deployments").
 			To(self.handleGetAppDeploySpec).
 			Writes([]v1beta1.Deployment{}))
 
+	// Istio configuration analysis
+	ws.Route(
+		ws.GET("/istio/analysis").
+			To(self.handleGetFindings).
+			Writes(api.FindingList{}))
+	ws.Route(
+		ws.GET("/istio/analysis/{namespace}").
+			To(self.handleGetFindings).
+			Writes(api.FindingList{}))
+
+	// mTLS & authorization
+	ws.Route(
+		ws.GET("/istio/security/{namespace}").
+			To(self.handleGetNamespaceSecurity).
+			Writes(api.NamespaceSecurity{}))
+
+	// Sidecar injection
+	ws.Route(
+		ws.GET("/istio/injection/{namespace}").
+			To(self.handleGetNamespaceInjection).
+			Writes(api.NamespaceInjection{}))
+	ws.Route(
+		ws.PUT("/istio/injection/{namespace}").
+			To(self.handleSetNamespaceInjection).
+			Reads(api.InjectionSpec{}).
+			Writes(api.NamespaceInjection{}))
+	ws.Route(
+		ws.POST("/istio/injection/{namespace}/restart").
+			To(self.handleRestartWorkloads).
+			Writes(api.NamespaceInjection{}))
+	ws.Route(
+		ws.PUT("/istio/injection/{namespace}/deployment/{deployment}").
+			To(self.handleSetDeploymentInjection).
+			Reads(api.InjectionSpec{}).
+			Writes(api.WorkloadInjection{}))
+
+	// Mesh topology
+	ws.Route(
+		ws.GET("/istio/topology").
+			To(self.handleGetTopology).
+			Writes(api.Graph{}))
+	ws.Route(
+		ws.GET("/istio/topology/{namespace}").
+			To(self.handleGetTopology).
+			Writes(api.Graph{}))
+
 	// Istio Ingresses
 	ws.Route(
 		ws.GET("/istio/ingress").
@@ -93,6 +231,7 @@ func (self *IstioHandler) Install(ws *restful.WebService) {
 	ws.Route(
 		ws.POST("/istio/ingress/{namespace}/{name}").
 			To(self.handleCreateIngress).
+			Reads(api.IngressSpec{}).
 			Writes(api.Ingress{}))
 	ws.Route(
 		ws.GET("/istio/ingress/{namespace}/{name}").
@@ -104,72 +243,511 @@ func (self *IstioHandler) Install(ws *restful.WebService) {
 			Writes(nil))
 }
 
+// handleMirrorAppTraffic mirrors the app's traffic to one of its versions.
+func (self *IstioHandler) handleMirrorAppTraffic(request *restful.Request, response *restful.Response) {
+	mirror := new(api.TrafficMirror)
+	if err := request.ReadEntity(mirror); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	if err := app.MirrorTraffic(istioClient, namespace, appName, mirror, targetType); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
+
+// handleStopAppMirror stops mirroring the app's traffic.
+func (self *IstioHandler) handleStopAppMirror(request *restful.Request, response *restful.Response) {
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	if err := app.MirrorTraffic(istioClient, namespace, appName, &api.TrafficMirror{}, targetType); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
+
+// handleGetResiliencePolicy gets the timeouts, retries, faults and traffic policy of the app.
+func (self *IstioHandler) handleGetResiliencePolicy(request *restful.Request, response *restful.Response) {
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	policy, err := app.GetResiliencePolicy(istioClient, namespace, appName, targetType)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, policy)
+}
+
+// handleUpdateResiliencePolicy updates the timeouts, retries, faults and traffic policy of the app.
+func (self *IstioHandler) handleUpdateResiliencePolicy(request *restful.Request, response *restful.Response) {
+	policy := new(api.ResiliencePolicy)
+	if err := request.ReadEntity(policy); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	if err := app.UpdateResiliencePolicy(istioClient, namespace, appName, policy, targetType); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	updated, err := app.GetResiliencePolicy(istioClient, namespace, appName, targetType)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, updated)
+}
+
 // handleGetAppVersions gets the application's versions and their flow control.
 func (self *IstioHandler) handleGetAppVersions(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+
+	result, err := app.GetAppVersions(client, istioClient, namespace, appName, self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
-func (self *IstioHandler) handleGetApps(request *restful.Request, response *restful.Response) {
-	client, err := self.cManager.Client(request)
-	if err != nil {
+// handleDiffAppVersions compares the "from" and "to" versions of the application.
+func (self *IstioHandler) handleDiffAppVersions(request *restful.Request, response *restful.Response) {
+	from := request.QueryParameter("from")
+	to := request.QueryParameter("to")
+	if from == "" || to == "" {
+		kdErrors.HandleInternalError(response, k8sErrors.NewBadRequest("both from and to versions are required"))
+		return
+	}
+
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	appName := request.PathParameter("app")
+	namespace := parseNamespacePathParameter(request)
+
+	diff, err := app.DiffVersions(client, istioClient, namespace, appName, from, to, self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, diff)
+}
+
+// handleGetAppMetrics gets the request rate, error rate and request duration of the application
+// and its versions over the window (30m by default) ending now, with the given resolution step.
+func (self *IstioHandler) handleGetAppMetrics(request *restful.Request, response *restful.Response) {
+	window, err := parseDurationParameter(request, "window", 30*time.Minute)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	step, err := parseDurationParameter(request, "step", time.Minute)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	appName := request.PathParameter("app")
+	end := time.Now()
+
+	if self.prometheus == nil {
+		kdErrors.HandleInternalError(response, k8sErrors.NewServiceUnavailable("Prometheus client is not configured"))
+		return
+	}
+	result, err := self.prometheus.GetAppMetrics(namespace, appName, end.Add(-window), end, step)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (self *IstioHandler) handleGetApps(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	// 1. Get Services by the namespace
+	// 2. Assemble APPs
+	// if service has destination rules with the same host name with service, treat them as the
+	// gray release
+	// app_name, versions, label, created_at
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	result, err := app.GetAppList(client, istioClient, parseNamespacePathParameter(request), dataSelect)
+	if err != nil {
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (self *IstioHandler) handleGetAppDetail(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	// 1. Get Services by the namespace
+	// 2. Assemble APPs
+	// if service has destination rules with the same host name with service, treat them as the
+	// gray release
+	// app_name, versions, label, created_at
+	appName := request.PathParameter("app")
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	dataSelect.FilterQuery = dataselect.NewFilterQuery([]string{"name", appName})
+	result, err := app.GetAppDetail(client, istioClient, parseNamespacePathParameter(request), appName, dataSelect,
+		self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleOfflineVersion makes one version of app offline
+func (self *IstioHandler) handleOfflineVersion(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	version := request.PathParameter("version")
+	offlineType := request.QueryParameter("offlineType")
+	if offlineType == "" {
+		offlineType = virtualservice.OnlyHost
+	}
+
+	// drain mode offlines the version in the background once its traffic has stopped
+	if request.QueryParameter("drain") == "true" {
+		spec := new(api.DrainSpec)
+		if timeout := request.QueryParameter("timeout"); timeout != "" {
+			if spec.Timeout, err = strconv.ParseInt(timeout, 10, 64); err != nil {
+				kdErrors.HandleInternalError(response, k8sErrors.NewBadRequest(fmt.Sprintf("invalid timeout %s", timeout)))
+				return
+			}
+		}
+
+		status, err := self.drainer.Drain(client, istioClient, namespace, appName, version, offlineType, spec,
+			self.labelKeys(client))
+		if err != nil {
+			kdErrors.HandleInternalError(response, err)
+			return
+		}
+		response.WriteHeaderAndEntity(http.StatusAccepted, status)
+		return
+	}
+
+	err = app.OfflineAppVersion(client, istioClient, namespace, appName, version, offlineType, self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
+
+// handleGetDrainStatus returns the status of the last drain of an app version.
+func (self *IstioHandler) handleGetDrainStatus(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	version := request.PathParameter("version")
+
+	status, err := self.drainer.DrainStatus(client, namespace.ToRequestParam(), appName, version)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, status)
+}
+
+// handleAppTakeOverAllTraffic makes one version of app the only online version.
+func (self *IstioHandler) handleAppTakeOverAllTraffic(request *restful.Request, response *restful.Response) {
+	// change virtual service
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	version := request.PathParameter("version")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	err = app.TakeOverAllTraffic(client, istioClient, namespace, appName, version, targetType, self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
+
+// handleShiftAppTraffic splits the application's traffic between its versions by weights.
+func (self *IstioHandler) handleShiftAppTraffic(request *restful.Request, response *restful.Response) {
+	shifting := new(api.TrafficShifting)
+	if err := request.ReadEntity(shifting); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	if err := app.ShiftTraffic(istioClient, namespace, appName, shifting, targetType); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
+
+// handleGetRoutingRules lists the header, cookie and user agent routing rules of the application.
+func (self *IstioHandler) handleGetRoutingRules(request *restful.Request, response *restful.Response) {
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+
+	result, err := app.GetRoutingRules(istioClient, namespace, appName)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleAddRoutingRule routes the requests matching the rule to one version of the application.
+func (self *IstioHandler) handleAddRoutingRule(request *restful.Request, response *restful.Response) {
+	rule := new(api.RoutingRule)
+	if err := request.ReadEntity(rule); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	result, err := app.AddRoutingRule(istioClient, namespace, appName, rule, targetType)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+// handleRemoveRoutingRule removes the routing rule from the application.
+func (self *IstioHandler) handleRemoveRoutingRule(request *restful.Request, response *restful.Response) {
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+	id := request.PathParameter("rule")
+	targetType := request.QueryParameter("targetType")
+	if targetType == "" {
+		targetType = virtualservice.All
+	}
+
+	if err := app.RemoveRoutingRule(istioClient, namespace, appName, id, targetType); err != nil {
+		kdErrors.HandleInternalError(response, err)
 		return
 	}
+	response.WriteHeaderAndEntity(http.StatusOK, nil)
+}
 
-	istioClient, err := self.cManager.IstioClient(request)
+// handleGetRollout gets the progressive delivery of the application.
+func (self *IstioHandler) handleGetRollout(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
 
-	// 1. Get Services by the namespace
-	// 2. Assemble APPs
-	// if service has destination rules with the same host name with service, treat them as the
-	// gray release
-	// app_name, versions, label, created_at
-	result, err := app.GetAppList(client, istioClient, parseNamespacePathParameter(request), parseDataSelectPathParameter(request))
+	namespace := parseNamespacePathParameter(request)
+	appName := request.PathParameter("app")
+
+	result, err := rollout.GetRollout(client, namespace.ToRequestParam(), appName)
 	if err != nil {
+		kdErrors.HandleInternalError(response, err)
 		return
 	}
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
-func (self *IstioHandler) handleGetAppDetail(request *restful.Request, response *restful.Response) {
-	client, err := self.cManager.Client(request)
-	if err != nil {
+// handleStartRollout starts the progressive delivery of the application's canary version.
+func (self *IstioHandler) handleStartRollout(request *restful.Request, response *restful.Response) {
+	spec := new(api.RolloutSpec)
+	if err := request.ReadEntity(spec); err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
 
-	istioClient, err := self.cManager.IstioClient(request)
+	client, err := self.cManager.Client(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
 
-	// 1. Get Services by the namespace
-	// 2. Assemble APPs
-	// if service has destination rules with the same host name with service, treat them as the
-	// gray release
-	// app_name, versions, label, created_at
-	appName := request.PathParameter("app")
-	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.FilterQuery = dataselect.NewFilterQuery([]string{"name", appName})
-	result, err := app.GetAppDetail(client, istioClient, parseNamespacePathParameter(request), appName, dataSelect)
+	istioClient, err := self.cManager.IstioClient(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusOK, result)
-}
 
-// handleOfflineVersion makes one version of app offline
-func (self *IstioHandler) handleOfflineVersion(request *restful.Request, response *restful.Response) {
-	client, err := self.cManager.Client(request)
+	// the controller advances the rollout for the user, it needs to know who the user is
+	cfg, err := self.cManager.Config(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-
-	istioClient, err := self.cManager.IstioClient(request)
+	user, err := rollout.ReviewUser(self.cManager.InsecureClient(), cfg)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -177,22 +755,18 @@ func (self *IstioHandler) handleOfflineVersion(request *restful.Request, respons
 
 	namespace := parseNamespacePathParameter(request)
 	appName := request.PathParameter("app")
-	version := request.PathParameter("version")
-	offlineType := request.QueryParameter("offlineType")
-	if offlineType == "" {
-		offlineType = virtualservice.OnlyHost
-	}
 
-	if err := app.OfflineAppVersion(client, istioClient, namespace, appName, version, offlineType); err != nil {
+	result, err := rollout.StartRollout(client, istioClient, namespace, appName, spec, self.labelKeys(client), user,
+		self.cManager.CSRFKey())
+	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusOK, nil)
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
 }
 
-// handleAppTakeOverAllTraffic makes one version of app the only online version.
-func (self *IstioHandler) handleAppTakeOverAllTraffic(request *restful.Request, response *restful.Response) {
-	// change virtual service
+// handleAbortRollout stops the progressive delivery of the application.
+func (self *IstioHandler) handleAbortRollout(request *restful.Request, response *restful.Response) {
 	client, err := self.cManager.Client(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -207,18 +781,11 @@ func (self *IstioHandler) handleAppTakeOverAllTraffic(request *restful.Request,
 
 	namespace := parseNamespacePathParameter(request)
 	appName := request.PathParameter("app")
-	version := request.PathParameter("version")
-	targetType := request.QueryParameter("targetType")
-	if targetType == "" {
-		targetType = virtualservice.All
-	}
 
-	err = app.TakeOverAllTraffic(client, istioClient, namespace, appName, version, targetType)
-	if err != nil {
+	if err := rollout.AbortRollout(client, istioClient, namespace, appName); err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-
 	response.WriteHeaderAndEntity(http.StatusOK, nil)
 }
 
@@ -233,7 +800,7 @@ func (self *IstioHandler) handleGetAppFlowControl(request *restful.Request, resp
 	appName := request.PathParameter("app")
 	namespace := parseNamespacePathParameter(request)
 
-	deployments, err := app.GetAppDeploySpec(client, namespace, appName)
+	deployments, err := app.GetAppDeploySpec(client, namespace, appName, self.labelKeys(client))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -253,7 +820,7 @@ func (self *IstioHandler) handleGetAppDeploySpec(request *restful.Request, respo
 	appName := request.PathParameter("app")
 	namespace := parseNamespacePathParameter(request)
 
-	deployments, err := app.GetAppDeploySpec(client, namespace, appName)
+	deployments, err := app.GetAppDeploySpec(client, namespace, appName, self.labelKeys(client))
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -283,11 +850,82 @@ func (self *IstioHandler) handleDeleteApp(request *restful.Request, response *re
 	appName := request.PathParameter("app")
 	namespace := parseNamespacePathParameter(request)
 
-	if err := app.DeleteApp(client, istioClient, namespace, appName); err != nil {
+	deletion, err := app.DeleteApp(client, istioClient, namespace, appName, self.labelKeys(client))
+	if deletion == nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusOK, nil)
+	if err != nil {
+		// report which objects could not be deleted
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, deletion)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, deletion)
+}
+
+// handleExportApp exports the objects of an istio application as a yaml bundle.
+func (self *IstioHandler) handleExportApp(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	appName := request.PathParameter("app")
+	namespace := request.PathParameter("namespace")
+
+	bundle, err := app.ExportApp(client, istioClient, namespace, appName, self.labelKeys(client))
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	response.AddHeader("Content-Type", yamlMIME)
+	response.AddHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%s.yaml", appName))
+	response.WriteHeader(http.StatusOK)
+	response.Write([]byte(bundle))
+}
+
+// handleImportApp applies a yaml bundle exported by handleExportApp to the namespace.
+func (self *IstioHandler) handleImportApp(request *restful.Request, response *restful.Response) {
+	content, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := self.cManager.Config(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	appName := request.PathParameter("app")
+
+	result, err := app.ImportApp(cfg, namespace, appName, string(content), self.labelKeys(client))
+	if result == nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	if err != nil {
+		// report which objects could not be applied
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, result)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
 // handleCreateApp creates istio application.
@@ -313,7 +951,7 @@ func (self *IstioHandler) handleCreateApp(request *restful.Request, response *re
 	appName := request.PathParameter("app")
 	namespace := parseNamespacePathParameter(request)
 
-	if err := app.CreateApp(client, istioClient, namespace, appName, newApp); err != nil {
+	if err := app.CreateApp(client, istioClient, namespace, appName, newApp, self.labelKeys(client)); err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
@@ -343,13 +981,173 @@ func (self *IstioHandler) handleCanaryApp(request *restful.Request, response *re
 	appName := request.PathParameter("app")
 	namespace := parseNamespacePathParameter(request)
 
-	if err := app.CanaryApp(client, istioClient, namespace, appName, canaryDep); err != nil {
+	if err := app.CanaryApp(client, istioClient, namespace, appName, canaryDep, self.labelKeys(client)); err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
 	response.WriteHeaderAndEntity(http.StatusCreated, nil)
 }
 
+// handleGetFindings analyzes the istio configuration of the namespaces.
+func (self *IstioHandler) handleGetFindings(request *restful.Request, response *restful.Response) {
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	findings, err := analysis.GetFindings(istioClient, namespace)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, findings)
+}
+
+// handleGetNamespaceSecurity gets the mutual TLS and authorization state of the namespace's services.
+func (self *IstioHandler) handleGetNamespaceSecurity(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := security.GetNamespaceSecurity(client, istioClient, namespace)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleGetNamespaceInjection returns the sidecar injection state of the namespace's workloads.
+func (self *IstioHandler) handleGetNamespaceInjection(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := injection.GetNamespaceInjection(client, namespace)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleSetNamespaceInjection enables or disables the sidecar injection of the namespace.
+func (self *IstioHandler) handleSetNamespaceInjection(request *restful.Request, response *restful.Response) {
+	spec := new(api.InjectionSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := injection.SetNamespaceInjection(client, namespace, spec)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleRestartWorkloads restarts the namespace's workloads whose pods don't match the injection state.
+func (self *IstioHandler) handleRestartWorkloads(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := injection.RestartWorkloads(client, namespace)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleSetDeploymentInjection opts the deployment's pods in or out of the sidecar injection.
+func (self *IstioHandler) handleSetDeploymentInjection(request *restful.Request, response *restful.Response) {
+	spec := new(api.InjectionSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := injection.SetDeploymentInjection(client, namespace, name, spec)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// handleGetTopology returns the mesh topology, or the neighborhood of an app given with the app
+// and depth query parameters.
+func (self *IstioHandler) handleGetTopology(request *restful.Request, response *restful.Response) {
+	client, err := self.cManager.Client(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	istioClient, err := self.cManager.IstioClient(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	depth := 1
+	if param := request.QueryParameter("depth"); param != "" {
+		if depth, err = strconv.Atoi(param); err != nil {
+			kdErrors.HandleInternalError(response, k8sErrors.NewBadRequest(fmt.Sprintf("invalid depth %s", param)))
+			return
+		}
+	}
+
+	namespace := parseNamespacePathParameter(request)
+	graph, err := topology.GetTopology(client, istioClient, namespace)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
+	if appName := request.QueryParameter("app"); appName != "" {
+		if graph, err = topology.AppNeighborhood(graph, appName, depth); err != nil {
+			kdErrors.HandleInternalError(response, err)
+			return
+		}
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, graph)
+}
+
 // handleGetIngresses lists the istio ingresses.
 func (self *IstioHandler) handleGetIngresses(request *restful.Request, response *restful.Response) {
 	client, err := self.cManager.Client(request)
@@ -365,7 +1163,12 @@ func (self *IstioHandler) handleGetIngresses(request *restful.Request, response
 	}
 
 	namespace := parseNamespacePathParameter(request)
-	ingresses, err := ingress.GetIngresses(client, istioClient, namespace, parseDataSelectPathParameter(request))
+	dataSelect, err := parseDataSelectPathParameter(request)
+	if err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+	ingresses, err := ingress.GetIngresses(client, istioClient, namespace, dataSelect)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
@@ -399,6 +1202,12 @@ func (self *IstioHandler) handleGetIngress(request *restful.Request, response *r
 
 // handleCreateIngress create istio ingress.
 func (self *IstioHandler) handleCreateIngress(request *restful.Request, response *restful.Response) {
+	spec := new(api.IngressSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		kdErrors.HandleInternalError(response, err)
+		return
+	}
+
 	client, err := self.cManager.Client(request)
 	if err != nil {
 		kdErrors.HandleInternalError(response, err)
@@ -413,11 +1222,12 @@ func (self *IstioHandler) handleCreateIngress(request *restful.Request, response
 
 	namespace := parseNamespacePathParameter(request)
 	name := request.PathParameter("name")
-	if err := ingress.CreateIngress(client, istioClient, namespace, name); err != nil {
+	ing, err := ingress.CreateIngress(client, istioClient, namespace, name, spec)
+	if err != nil {
 		kdErrors.HandleInternalError(response, err)
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusCreated, nil)
+	response.WriteHeaderAndEntity(http.StatusCreated, ing)
 }
 
 // handleDeleteIngress deletes istio ingress.
@@ -444,12 +1254,15 @@ func (self *IstioHandler) handleDeleteIngress(request *restful.Request, response
 }
 
 // Parses query parameters of the request and returns a DataSelectQuery object
-func parseDataSelectPathParameter(request *restful.Request) *dataselect.DataSelectQuery {
+func parseDataSelectPathParameter(request *restful.Request) (*dataselect.DataSelectQuery, error) {
 	paginationQuery := parsePaginationPathParameter(request)
 	sortQuery := parseSortPathParameter(request)
 	filterQuery := parseFilterPathParameter(request)
-	metricQuery := parseMetricPathParameter(request)
-	return dataselect.NewDataSelectQuery(paginationQuery, sortQuery, filterQuery, metricQuery)
+	metricQuery, err := parseMetricPathParameter(request)
+	if err != nil {
+		return nil, err
+	}
+	return dataselect.NewDataSelectQuery(paginationQuery, sortQuery, filterQuery, metricQuery), nil
 }
 
 func parsePaginationPathParameter(request *restful.Request) *dataselect.PaginationQuery {
@@ -477,7 +1290,7 @@ func parseSortPathParameter(request *restful.Request) *dataselect.SortQuery {
 }
 
 // Parses query parameters of the request and returns a MetricQuery object
-func parseMetricPathParameter(request *restful.Request) *dataselect.MetricQuery {
+func parseMetricPathParameter(request *restful.Request) (*dataselect.MetricQuery, error) {
 	metricNamesParam := request.QueryParameter("metricNames")
 	var metricNames []string
 	if metricNamesParam != "" {
@@ -494,10 +1307,20 @@ func parseMetricPathParameter(request *restful.Request) *dataselect.MetricQuery
 	}
 	aggregationModes := metricapi.AggregationModes{}
 	for _, e := range rawAggregations {
-		aggregationModes = append(aggregationModes, metricapi.AggregationMode(e))
+		aggregationMode, err := metricapi.ParseAggregationMode(e)
+		if err != nil {
+			return nil, k8sErrors.NewBadRequest(err.Error())
+		}
+		aggregationModes = append(aggregationModes, aggregationMode)
 	}
-	return dataselect.NewMetricQuery(metricNames, aggregationModes)
-
+	timeRange, err := metricapi.ParseTimeRange(request.QueryParameter("start"),
+		request.QueryParameter("end"), request.QueryParameter("resolution"), time.Now())
+	if err != nil {
+		return nil, k8sErrors.NewBadRequest(err.Error())
+	}
+	metricQuery := dataselect.NewMetricQuery(metricNames, aggregationModes)
+	metricQuery.TimeRange = timeRange
+	return metricQuery, nil
 }
 
 // parseNamespacePathParameter parses namespace selector for list pages in path parameter.
@@ -516,7 +1339,36 @@ func parseNamespacePathParameter(request *restful.Request) *common.NamespaceQuer
 	return common.NewNamespaceQuery(nonEmptyNamespaces)
 }
 
-// NewIstioHandler creates IstioHandler.
-func NewIstioHandler(cManager clientapi.ClientManager) IstioHandler {
-	return IstioHandler{cManager: cManager}
+// Parses the duration query parameter, the default is returned when it is not given.
+func parseDurationParameter(request *restful.Request, name string, defaultValue time.Duration) (time.Duration, error) {
+	param := request.QueryParameter(name)
+	if param == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(param)
+	if err != nil || duration <= 0 {
+		return 0, k8sErrors.NewBadRequest(fmt.Sprintf("invalid %s %s", name, param))
+	}
+	return duration, nil
+}
+
+// labelKeys returns the keys of the app & version labels configured in the global settings.
+func (self *IstioHandler) labelKeys(client kubernetes.Interface) api.LabelKeys {
+	return api.NewLabelKeys(self.sManager.GetGlobalSettings(client))
+}
+
+// NewIstioHandler creates IstioHandler. The app metrics and the request rate of the drained
+// versions are queried from the Prometheus deployed by Istio.
+func NewIstioHandler(cManager clientapi.ClientManager, sManager settings.SettingsManager) IstioHandler {
+	handler := IstioHandler{cManager: cManager, sManager: sManager}
+	prometheusClient, err := prometheus.CreatePrometheusClient(args.Holder.GetPrometheusHost(), cManager.InsecureClient())
+	if err != nil {
+		// Pass an untyped nil, a nil client in the interface would not be detected by the drainer.
+		log.Printf("Failed to create Prometheus client, app metrics are unavailable: %s", err)
+		handler.drainer = app.NewDrainer(cManager.InsecureClient(), cManager.InsecureIstioClient(), nil)
+		return handler
+	}
+	handler.prometheus = prometheusClient
+	handler.drainer = app.NewDrainer(cManager.InsecureClient(), cManager.InsecureIstioClient(), prometheusClient)
+	return handler
 }
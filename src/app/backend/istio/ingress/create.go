@@ -0,0 +1,296 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	resourceGateway "github.com/kubernetes/dashboard/src/app/backend/resource/gateway"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tlsModes maps the names of the TLS modes to istio.networking.v1alpha3.Server_TLSOptions_TLSmode.
+var tlsModes = map[string]int32{
+	"PASSTHROUGH": 0,
+	"SIMPLE":      1,
+	"MUTUAL":      2,
+}
+
+var protocols = map[string]bool{
+	"HTTP":  true,
+	"HTTPS": true,
+	"GRPC":  true,
+	"HTTP2": true,
+	"MONGO": true,
+	"TCP":   true,
+	"TLS":   true,
+}
+
+// defaultSelector selects the istio ingress gateway installed by the istio chart.
+var defaultSelector = map[string]string{"istio": "ingressgateway"}
+
+const (
+	// certificateFile and privateKeyFile are the keys of a kubernetes.io/tls secret.
+	certificateFile = "tls.crt"
+	privateKeyFile  = "tls.key"
+	// caCertificatesFile is the key of the CA secret, as documented by istio for mutual TLS.
+	caCertificatesFile = "ca-chain.cert.pem"
+)
+
+// CreateIngress creates the `Gateway` exposing the hosts and ports of the spec, together with
+// the `VirtualService` forwarding its requests to the backend applications. Both are named after
+// the ingress, the gateway is removed again when the virtual service can not be created.
+func CreateIngress(k8sClient kubernetes.Interface, istioClient istio.Interface,
+	namespace *common.NamespaceQuery, name string, spec *api.IngressSpec) (*api.Ingress, error) {
+
+	ns := namespace.ToRequestParam()
+	log.Printf("Creating %s ingress in %s namespace", name, ns)
+
+	if err := validateIngressSpec(spec); err != nil {
+		return nil, err
+	}
+
+	selector := spec.Selector
+	if len(selector) == 0 {
+		selector = defaultSelector
+	}
+
+	mounts, err := getSecretMounts(k8sClient, selector, spec.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway, err := istioClient.NetworkingV1alpha3().Gateways(ns).Create(toGateway(ns, name, selector, spec, mounts))
+	if err != nil {
+		return nil, err
+	}
+
+	vs := toVirtualService(ns, name, spec)
+	if vs == nil {
+		return ToIngress(gateway, nil, nil, nil), nil
+	}
+
+	vs, err = istioClient.NetworkingV1alpha3().VirtualServices(ns).Create(vs)
+	if err != nil {
+		log.Printf("Rolling back %s gateway in %s namespace: %v", name, ns, err)
+		if rollbackErr := istioClient.NetworkingV1alpha3().Gateways(ns).Delete(name, &metaV1.DeleteOptions{}); rollbackErr != nil {
+			log.Printf("Failed to roll back %s gateway in %s namespace: %v", name, ns, rollbackErr)
+		}
+		return nil, err
+	}
+
+	return ToIngress(gateway, nil, []v1alpha3.VirtualService{*vs}, nil), nil
+}
+
+func validateIngressSpec(spec *api.IngressSpec) error {
+	if spec == nil || len(spec.Hosts) == 0 {
+		return k8sErrors.NewBadRequest("at least one host is required")
+	}
+	if len(spec.Ports) == 0 {
+		return k8sErrors.NewBadRequest("at least one port is required")
+	}
+
+	for _, port := range spec.Ports {
+		if port.Number == 0 || port.Number > 65535 {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("invalid port number %d", port.Number))
+		}
+		if !protocols[port.Protocol] {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("unsupported protocol %s of port %d", port.Protocol, port.Number))
+		}
+		if err := validateIngressTLS(port); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range spec.Routes {
+		if route.App == "" {
+			return k8sErrors.NewBadRequest("app of the route is required")
+		}
+		if route.Prefix != "" && !strings.HasPrefix(route.Prefix, "/") {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("prefix %s of the route must start with /", route.Prefix))
+		}
+	}
+	return nil
+}
+
+func validateIngressTLS(port api.IngressPort) error {
+	terminated := port.Protocol == "HTTPS" || port.Protocol == "TLS"
+	if port.TLS == nil {
+		if terminated {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("tls is required for %s port %d", port.Protocol, port.Number))
+		}
+		return nil
+	}
+	if !terminated {
+		if port.TLS.Mode != "" || port.TLS.CredentialSecret != "" || port.TLS.CaSecret != "" {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("%s port %d only supports https redirect", port.Protocol, port.Number))
+		}
+		return nil
+	}
+
+	if _, ok := tlsModes[port.TLS.Mode]; !ok {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("unsupported tls mode %s of port %d", port.TLS.Mode, port.Number))
+	}
+	if port.TLS.Mode != "PASSTHROUGH" && port.TLS.CredentialSecret == "" {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("credential secret is required for %s tls of port %d",
+			port.TLS.Mode, port.Number))
+	}
+	if port.TLS.Mode == "MUTUAL" && port.TLS.CaSecret == "" {
+		return k8sErrors.NewBadRequest(fmt.Sprintf("ca secret is required for MUTUAL tls of port %d", port.Number))
+	}
+	return nil
+}
+
+// getSecretMounts resolves where the gateway workload mounts the secrets referenced by the ports.
+// The istio gateway reads the certificates from files, so the secrets have to be mounted by
+// its pods, i.e. istio-ingressgateway-certs at /etc/istio/ingressgateway-certs.
+func getSecretMounts(k8sClient kubernetes.Interface, selector map[string]string,
+	ports []api.IngressPort) (map[string]string, error) {
+
+	var secrets []string
+	for _, port := range ports {
+		if port.TLS != nil {
+			for _, secret := range []string{port.TLS.CredentialSecret, port.TLS.CaSecret} {
+				if secret != "" {
+					secrets = append(secrets, secret)
+				}
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+
+	labelSelector, err := metaV1.LabelSelectorAsSelector(&metaV1.LabelSelector{MatchLabels: selector})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := k8sClient.CoreV1().Pods(v1.NamespaceAll).List(metaV1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("no gateway workload matches selector %s", labelSelector))
+	}
+
+	mounts := make(map[string]string)
+	for _, secret := range secrets {
+		mountPath := resourceGateway.FindSecretMount(&pods.Items[0], secret)
+		if mountPath == "" {
+			return nil, k8sErrors.NewBadRequest(fmt.Sprintf("secret %s is not mounted by gateway workload %s/%s",
+				secret, pods.Items[0].Namespace, pods.Items[0].Name))
+		}
+		mounts[secret] = mountPath
+	}
+	return mounts, nil
+}
+
+func toGateway(namespace, name string, selector map[string]string, spec *api.IngressSpec,
+	mounts map[string]string) *v1alpha3.Gateway {
+
+	servers := make([]*v1alpha3.Server, 0, len(spec.Ports))
+	for _, port := range spec.Ports {
+		portName := port.Name
+		if portName == "" {
+			portName = fmt.Sprintf("%s-%d", strings.ToLower(port.Protocol), port.Number)
+		}
+		server := &v1alpha3.Server{
+			Hosts: spec.Hosts,
+			Port: &v1alpha3.Port{
+				Number:   port.Number,
+				Protocol: port.Protocol,
+				Name:     portName,
+			},
+		}
+		if port.TLS != nil {
+			server.Tls = &v1alpha3.Server_TLSOptions{
+				HttpsRedirect: port.TLS.HttpsRedirect,
+				Mode:          tlsModes[port.TLS.Mode],
+			}
+			if port.TLS.CredentialSecret != "" {
+				server.Tls.ServerCertificate = path.Join(mounts[port.TLS.CredentialSecret], certificateFile)
+				server.Tls.PrivateKey = path.Join(mounts[port.TLS.CredentialSecret], privateKeyFile)
+			}
+			if port.TLS.CaSecret != "" {
+				server.Tls.CaCertificates = path.Join(mounts[port.TLS.CaSecret], caCertificatesFile)
+			}
+		}
+		servers = append(servers, server)
+	}
+
+	return &v1alpha3.Gateway{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1alpha3.GatewaySpec{
+			Servers:  servers,
+			Selector: selector,
+		},
+	}
+}
+
+// toVirtualService binds the routes to the gateway, nil is returned when there are no routes.
+func toVirtualService(namespace, name string, spec *api.IngressSpec) *v1alpha3.VirtualService {
+	if len(spec.Routes) == 0 {
+		return nil
+	}
+
+	routes := make([]*v1alpha3.HTTPRoute, 0, len(spec.Routes))
+	for _, route := range spec.Routes {
+		host := route.App
+		if route.Namespace != "" && route.Namespace != namespace {
+			host = virtualservice.FQDN(route.App, route.Namespace)
+		}
+		destination := &v1alpha3.Destination{
+			Host:   host,
+			Subset: route.Version,
+		}
+		if route.Port != 0 {
+			destination.Port = &v1alpha3.PortSelector{Number: route.Port}
+		}
+
+		httpRoute := &v1alpha3.HTTPRoute{
+			Route: []*v1alpha3.DestinationWeight{{Destination: destination}},
+		}
+		if route.Prefix != "" {
+			httpRoute.Match = []*v1alpha3.HTTPMatchRequest{{Uri: &v1alpha3.StringMatch{Prefix: route.Prefix}}}
+		}
+		routes = append(routes, httpRoute)
+	}
+
+	return &v1alpha3.VirtualService{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1alpha3.VirtualServiceSpec{
+			Hosts:    spec.Hosts,
+			Gateways: []string{name},
+			Http:     routes,
+		},
+	}
+}
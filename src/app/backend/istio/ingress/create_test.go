@@ -0,0 +1,151 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateIngressSpec(t *testing.T) {
+	https := api.IngressPort{Number: 443, Protocol: "HTTPS", TLS: &api.IngressTLS{Mode: "SIMPLE", CredentialSecret: "certs"}}
+	cases := []struct {
+		spec  *api.IngressSpec
+		valid bool
+	}{
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{{Number: 80, Protocol: "HTTP"}}}, true},
+		{&api.IngressSpec{Ports: []api.IngressPort{{Number: 80, Protocol: "HTTP"}}}, false},
+		{&api.IngressSpec{Hosts: []string{"*"}}, false},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{{Number: 80, Protocol: "FTP"}}}, false},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{https}}, true},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{{Number: 443, Protocol: "HTTPS"}}}, false},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{{Number: 443, Protocol: "HTTPS",
+			TLS: &api.IngressTLS{Mode: "MUTUAL", CredentialSecret: "certs"}}}}, false},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{{Number: 80, Protocol: "HTTP",
+			TLS: &api.IngressTLS{HttpsRedirect: true}}}}, true},
+		{&api.IngressSpec{Hosts: []string{"*"}, Ports: []api.IngressPort{https},
+			Routes: []api.IngressRoute{{Prefix: "api"}}}, false},
+	}
+
+	for _, c := range cases {
+		err := validateIngressSpec(c.spec)
+		if (err == nil) != c.valid {
+			t.Errorf("validateIngressSpec(%+v) == %v, expected valid: %t", c.spec, err, c.valid)
+		}
+	}
+}
+
+func TestGetSecretMounts(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "istio-ingressgateway-0", Namespace: "istio-system",
+			Labels: map[string]string{"istio": "ingressgateway"}},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{{Name: "ingressgateway-certs", VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: "istio-ingressgateway-certs"}}}},
+			Containers: []v1.Container{{VolumeMounts: []v1.VolumeMount{
+				{Name: "ingressgateway-certs", MountPath: "/etc/istio/ingressgateway-certs"}}}},
+		},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	port := api.IngressPort{Number: 443, Protocol: "HTTPS",
+		TLS: &api.IngressTLS{Mode: "SIMPLE", CredentialSecret: "istio-ingressgateway-certs"}}
+	mounts, err := getSecretMounts(client, defaultSelector, []api.IngressPort{port})
+	if err != nil {
+		t.Fatalf("getSecretMounts() returned error: %v", err)
+	}
+	expected := map[string]string{"istio-ingressgateway-certs": "/etc/istio/ingressgateway-certs"}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("getSecretMounts() == %v, expected %v", mounts, expected)
+	}
+
+	port.TLS.CredentialSecret = "unmounted"
+	if _, err := getSecretMounts(client, defaultSelector, []api.IngressPort{port}); err == nil {
+		t.Errorf("getSecretMounts() expected error for unmounted secret")
+	}
+}
+
+func TestToGatewayAndVirtualService(t *testing.T) {
+	spec := &api.IngressSpec{
+		Hosts: []string{"bookinfo.example.com"},
+		Ports: []api.IngressPort{{Number: 443, Protocol: "HTTPS",
+			TLS: &api.IngressTLS{Mode: "SIMPLE", CredentialSecret: "certs"}}},
+		Routes: []api.IngressRoute{
+			{Prefix: "/api", App: "reviews", Namespace: "backend", Version: "v2"},
+			{App: "productpage", Port: 9080},
+		},
+	}
+
+	gateway := toGateway("default", "bookinfo", defaultSelector, spec, map[string]string{"certs": "/etc/certs"})
+	expectedServer := &v1alpha3.Server{
+		Hosts: []string{"bookinfo.example.com"},
+		Port:  &v1alpha3.Port{Number: 443, Protocol: "HTTPS", Name: "https-443"},
+		Tls: &v1alpha3.Server_TLSOptions{Mode: 1, ServerCertificate: "/etc/certs/tls.crt",
+			PrivateKey: "/etc/certs/tls.key"},
+	}
+	if len(gateway.Spec.Servers) != 1 || !reflect.DeepEqual(gateway.Spec.Servers[0], expectedServer) {
+		t.Errorf("toGateway() servers == %+v, expected %+v", gateway.Spec.Servers, expectedServer)
+	}
+
+	vs := toVirtualService("default", "bookinfo", spec)
+	expectedRoutes := []*v1alpha3.HTTPRoute{
+		{
+			Match: []*v1alpha3.HTTPMatchRequest{{Uri: &v1alpha3.StringMatch{Prefix: "/api"}}},
+			Route: []*v1alpha3.DestinationWeight{{Destination: &v1alpha3.Destination{
+				Host: "reviews.backend.svc.cluster.local", Subset: "v2"}}},
+		},
+		{
+			Route: []*v1alpha3.DestinationWeight{{Destination: &v1alpha3.Destination{
+				Host: "productpage", Port: &v1alpha3.PortSelector{Number: 9080}}}},
+		},
+	}
+	if !reflect.DeepEqual(vs.Spec.Gateways, []string{"bookinfo"}) {
+		t.Errorf("toVirtualService() gateways == %v, expected [bookinfo]", vs.Spec.Gateways)
+	}
+	if !reflect.DeepEqual(vs.Spec.Http, expectedRoutes) {
+		t.Errorf("toVirtualService() routes == %+v, expected %+v", vs.Spec.Http, expectedRoutes)
+	}
+
+	if toVirtualService("default", "bookinfo", &api.IngressSpec{}) != nil {
+		t.Errorf("toVirtualService() expected nil without routes")
+	}
+}
+
+func TestDetachGateway(t *testing.T) {
+	cases := []struct {
+		gateways []string
+		expected []string
+		kept     bool
+	}{
+		{[]string{"bookinfo"}, []string{}, false},
+		{[]string{"bookinfo", "mesh"}, []string{"mesh"}, true},
+		{[]string{"other", "bookinfo"}, []string{"other"}, true},
+	}
+
+	for _, c := range cases {
+		vs := &v1alpha3.VirtualService{Spec: v1alpha3.VirtualServiceSpec{Gateways: c.gateways}}
+		kept := detachGateway(vs, "bookinfo")
+		if kept != c.kept || !reflect.DeepEqual(vs.Spec.Gateways, c.expected) {
+			t.Errorf("detachGateway(%v) == %t, %v, expected %t, %v", c.gateways, kept, vs.Spec.Gateways,
+				c.kept, c.expected)
+		}
+	}
+}
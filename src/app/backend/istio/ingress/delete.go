@@ -0,0 +1,76 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"log"
+
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DeleteIngress deletes the `Gateway` of the ingress. The `VirtualService`s bound only to the
+// gateway are deleted as well, the others are detached from it.
+func DeleteIngress(k8sClient kubernetes.Interface, istioClient istio.Interface,
+	namespace, name string) error {
+
+	log.Printf("Deleting %s ingress in %s namespace", name, namespace)
+
+	gateway, err := istioClient.NetworkingV1alpha3().Gateways(namespace).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	vsList, err := istioClient.NetworkingV1alpha3().VirtualServices(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for i := range vsList.Items {
+		vs := &vsList.Items[i]
+		if !contains(vs.Spec.Gateways, gateway.Name) {
+			continue
+		}
+
+		if detachGateway(vs, gateway.Name) {
+			log.Printf("Detaching %s virtual service from %s gateway", vs.Name, gateway.Name)
+			_, err = istioClient.NetworkingV1alpha3().VirtualServices(namespace).Update(vs)
+		} else {
+			log.Printf("Deleting %s virtual service bound to %s gateway", vs.Name, gateway.Name)
+			err = istioClient.NetworkingV1alpha3().VirtualServices(namespace).Delete(vs.Name, &metaV1.DeleteOptions{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return istioClient.NetworkingV1alpha3().Gateways(namespace).Delete(gateway.Name, &metaV1.DeleteOptions{})
+}
+
+// detachGateway removes the gateway from the virtual service and reports whether the virtual
+// service is still bound to other gateways. A virtual service without gateways would apply to
+// the sidecars inside the mesh, so it has to be deleted rather than kept.
+func detachGateway(vs *v1alpha3.VirtualService, gateway string) bool {
+	gateways := make([]string, 0, len(vs.Spec.Gateways))
+	for _, g := range vs.Spec.Gateways {
+		if g != gateway {
+			gateways = append(gateways, g)
+		}
+	}
+	vs.Spec.Gateways = gateways
+	return len(gateways) > 0
+}
@@ -19,8 +19,10 @@ import (
 
 	commonApi "github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/analysis"
 	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	resourceGateway "github.com/kubernetes/dashboard/src/app/backend/resource/gateway"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
 	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
 	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
@@ -65,7 +67,41 @@ func GetIngress(k8sClient kubernetes.Interface, istioClient istio.Interface,
 		return nil, criticalError
 	}
 
-	return ToIngress(gateway, rawServices.Items, rawVs.Items, nonCriticalErrors), nil
+	ingress := ToIngress(gateway, rawServices.Items, rawVs.Items, nonCriticalErrors)
+
+	// the findings are informative, the detail is returned without them when the analysis fails
+	findings, err := analysis.GetFindings(istioClient, common.NewSameNamespaceQuery(namespace))
+	if err != nil {
+		log.Printf("Failed to analyze istio configuration of %s ingress: %v", name, err)
+	} else {
+		ingress.Findings = analysis.FilterFindings(findings.Findings, getIngressObjects(ingress))
+	}
+
+	// so are the certificates, a gateway workload can't be inspected without the pods permission
+	certificates, err := resourceGateway.GetServerCertificates(k8sClient, gateway)
+	if err != nil {
+		log.Printf("Failed to inspect certificates of %s ingress: %v", name, err)
+	} else {
+		ingress.Certificates = certificates
+	}
+	return ingress, nil
+}
+
+// getIngressObjects returns the gateway and the virtual services of the ingress.
+func getIngressObjects(ingress *api.Ingress) []api.FindingObject {
+	objects := []api.FindingObject{{
+		Kind:      commonApi.ResourceKindGateway,
+		Namespace: ingress.ObjectMeta.Namespace,
+		Name:      ingress.ObjectMeta.Name,
+	}}
+	for _, vs := range ingress.VirtualServices {
+		objects = append(objects, api.FindingObject{
+			Kind:      commonApi.ResourceKindVirtualService,
+			Namespace: vs.ObjectMeta.Namespace,
+			Name:      vs.ObjectMeta.Name,
+		})
+	}
+	return objects
 }
 
 func ToIngress(gateway *v1alpha3.Gateway, services []v1.Service, virtualServices []v1alpha3.VirtualService, nonCriticalErrors []error) *api.Ingress {
@@ -89,15 +89,3 @@ func subsetOf(given map[string]string, target map[string]string) bool {
 	}
 	return true
 }
-
-func CreateIngress(k8sClient kubernetes.Interface, istioClient istio.Interface,
-	namespace *common.NamespaceQuery, name string) error {
-	// TODO
-	return nil
-}
-
-func DeleteIngress(k8sClient kubernetes.Interface, istioClient istio.Interface,
-	namespace, name string) error {
-	// TODO
-	return nil
-}
@@ -0,0 +1,259 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package injection
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/deployment"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NamespaceLabel enables the sidecar injector webhook on the namespace when set to enabled.
+	NamespaceLabel = "istio-injection"
+	// InjectAnnotation opts a pod in or out of the injection.
+	InjectAnnotation = "sidecar.istio.io/inject"
+	// SidecarContainer is the name of the injected container.
+	SidecarContainer = "istio-proxy"
+)
+
+// GetNamespaceInjection returns the sidecar injection state of the namespace and its deployments.
+func GetNamespaceInjection(client kubernetes.Interface, namespace string) (*api.NamespaceInjection, error) {
+	log.Printf("Getting sidecar injection state of %s namespace", namespace)
+
+	ns, err := client.CoreV1().Namespaces().Get(namespace, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := client.AppsV1().Deployments(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.NamespaceInjection{
+		Namespace: namespace,
+		Enabled:   NamespaceEnabled(ns),
+		Workloads: GetWorkloadInjections(ns, deployments.Items, pods.Items),
+	}, nil
+}
+
+// SetNamespaceInjection enables or disables the injection on the namespace. The deployments
+// whose pods don't match the new state are restarted if the spec asks for it.
+func SetNamespaceInjection(client kubernetes.Interface, namespace string, spec *api.InjectionSpec) (*api.NamespaceInjection, error) {
+	log.Printf("Setting sidecar injection of %s namespace to %v", namespace, spec.Enabled)
+
+	ns, err := client.CoreV1().Namespaces().Get(namespace, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string)
+	}
+	ns.Labels[NamespaceLabel] = "disabled"
+	if spec.Enabled {
+		ns.Labels[NamespaceLabel] = "enabled"
+	}
+	if _, err := client.CoreV1().Namespaces().Update(ns); err != nil {
+		return nil, err
+	}
+
+	if spec.Restart {
+		return RestartWorkloads(client, namespace)
+	}
+	return GetNamespaceInjection(client, namespace)
+}
+
+// SetDeploymentInjection opts the deployment's pods in or out of the injection. Changing the
+// pod template rolls the pods out again, restart forces it when the template is unchanged.
+func SetDeploymentInjection(client kubernetes.Interface, namespace, name string, spec *api.InjectionSpec) (*api.WorkloadInjection, error) {
+	log.Printf("Setting sidecar injection of %s deployment in %s namespace to %v", name, namespace, spec.Enabled)
+
+	ns, err := client.CoreV1().Namespaces().Get(namespace, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the injector webhook is only called for the namespaces with injection enabled
+	if spec.Enabled && !NamespaceEnabled(ns) {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("sidecar injection is disabled in namespace %s, enable it there first",
+			namespace))
+	}
+
+	dep, err := client.AppsV1().Deployments(namespace).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if dep.Spec.Template.Annotations == nil {
+		dep.Spec.Template.Annotations = make(map[string]string)
+	}
+	dep.Spec.Template.Annotations[InjectAnnotation] = fmt.Sprint(spec.Enabled)
+	if spec.Restart {
+		deployment.BumpRedeployCount(&dep.Spec.Template)
+	}
+	dep, err = client.AppsV1().Deployments(namespace).Update(dep)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	injection := ToWorkloadInjection(ns, dep, pods.Items)
+	return &injection, nil
+}
+
+// RestartWorkloads restarts the deployments of the namespace whose pods don't match the
+// injection state, using the same pod template bump as the deployment redeploy.
+func RestartWorkloads(client kubernetes.Interface, namespace string) (*api.NamespaceInjection, error) {
+	injection, err := GetNamespaceInjection(client, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, workload := range injection.Workloads {
+		if !workload.RestartRequired {
+			continue
+		}
+		log.Printf("Restarting %s deployment in %s namespace to apply sidecar injection", workload.Name, namespace)
+		if err := deployment.Redeploy(client, namespace, workload.Name); err != nil {
+			return nil, err
+		}
+		// the pods are replaced by the rolling update
+		injection.Workloads[i].RestartRequired = false
+	}
+	return injection, nil
+}
+
+// GetWorkloadInjections returns the injection state of the deployments, ordered by name.
+func GetWorkloadInjections(namespace *v1.Namespace, deployments []appsv1.Deployment, pods []v1.Pod) []api.WorkloadInjection {
+	workloads := make([]api.WorkloadInjection, 0, len(deployments))
+	for i := range deployments {
+		workloads = append(workloads, ToWorkloadInjection(namespace, &deployments[i], pods))
+	}
+	sort.Slice(workloads, func(i, j int) bool { return workloads[i].Name < workloads[j].Name })
+	return workloads
+}
+
+// ToWorkloadInjection computes the injection state of the deployment from its pods.
+func ToWorkloadInjection(namespace *v1.Namespace, dep *appsv1.Deployment, pods []v1.Pod) api.WorkloadInjection {
+	annotation := dep.Spec.Template.Annotations[InjectAnnotation]
+	injection := api.WorkloadInjection{
+		Kind:       "deployment",
+		Namespace:  dep.Namespace,
+		Name:       dep.Name,
+		Annotation: annotation,
+		Expected:   Expected(namespace, annotation),
+	}
+
+	selector, err := metaV1.LabelSelectorAsSelector(dep.Spec.Selector)
+	if err != nil {
+		log.Printf("Invalid selector of %s deployment: %v", dep.Name, err)
+		return injection
+	}
+	var selected []v1.Pod
+	for _, pod := range pods {
+		if pod.Namespace == dep.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+			selected = append(selected, pod)
+		}
+	}
+
+	injection.Pods, injection.InjectedPods, injection.SidecarImages = Sidecars(selected)
+	injection.SidecarVersions = ImageVersions(injection.SidecarImages)
+	injection.RestartRequired = injection.Pods > 0 &&
+		(injection.Expected && injection.InjectedPods < injection.Pods || !injection.Expected && injection.InjectedPods > 0)
+	return injection
+}
+
+// Sidecars returns the number of running pods, the number of them running the sidecar and the
+// distinct images of their sidecars.
+func Sidecars(pods []v1.Pod) (int, int, []string) {
+	var running, injected int
+	var images []string
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		running++
+		for _, container := range pod.Spec.Containers {
+			if container.Name != SidecarContainer {
+				continue
+			}
+			injected++
+			if !contains(images, container.Image) {
+				images = append(images, container.Image)
+			}
+			break
+		}
+	}
+	sort.Strings(images)
+	return running, injected, images
+}
+
+// ImageVersions returns the distinct tags of the images, latest if an image has no tag.
+func ImageVersions(images []string) []string {
+	var versions []string
+	for _, image := range images {
+		image = strings.SplitN(image, "@", 2)[0]
+		version := "latest"
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			version = image[i+1:]
+		}
+		if !contains(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// NamespaceEnabled returns true if the namespace has the injection enabled.
+func NamespaceEnabled(namespace *v1.Namespace) bool {
+	return namespace != nil && namespace.Labels[NamespaceLabel] == "enabled"
+}
+
+// Expected returns true if the new pods with the inject annotation get the sidecar injected.
+// It follows the default injection policy of istio, the pods are injected unless they opt out.
+func Expected(namespace *v1.Namespace, annotation string) bool {
+	if !NamespaceEnabled(namespace) {
+		return false
+	}
+	switch strings.ToLower(annotation) {
+	case "n", "no", "false", "off":
+		return false
+	}
+	return true
+}
+
+func contains(collection []string, element string) bool {
+	for _, e := range collection {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package injection
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/deployment"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newNamespace(injection string) *v1.Namespace {
+	ns := &v1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "default"}}
+	if injection != "" {
+		ns.Labels = map[string]string{NamespaceLabel: injection}
+	}
+	return ns
+}
+
+func newDeployment(name, annotation string) *appsv1.Deployment {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+		},
+	}
+	if annotation != "" {
+		dep.Spec.Template.Annotations = map[string]string{InjectAnnotation: annotation}
+	}
+	return dep
+}
+
+func newPod(name, app, sidecarImage string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": app}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: app, Image: app}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if sidecarImage != "" {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: SidecarContainer, Image: sidecarImage})
+	}
+	return pod
+}
+
+func TestToWorkloadInjection(t *testing.T) {
+	pods := []v1.Pod{
+		*newPod("reviews-1", "reviews", "docker.io/istio/proxyv2:1.0.2"),
+		*newPod("reviews-2", "reviews", "docker.io/istio/proxyv2:1.0.3"),
+		*newPod("ratings-1", "ratings", ""),
+		*newPod("details-1", "details", "localhost:5000/istio/proxyv2"),
+	}
+	completed := newPod("ratings-job", "ratings", "")
+	completed.Status.Phase = v1.PodSucceeded
+	pods = append(pods, *completed)
+
+	cases := []struct {
+		info       string
+		namespace  *v1.Namespace
+		deployment *appsv1.Deployment
+		expected   api.WorkloadInjection
+	}{
+		{
+			"injected pods of various sidecar versions",
+			newNamespace("enabled"),
+			newDeployment("reviews", ""),
+			api.WorkloadInjection{
+				Kind: "deployment", Namespace: "default", Name: "reviews", Expected: true,
+				Pods: 2, InjectedPods: 2,
+				SidecarImages:   []string{"docker.io/istio/proxyv2:1.0.2", "docker.io/istio/proxyv2:1.0.3"},
+				SidecarVersions: []string{"1.0.2", "1.0.3"},
+			},
+		},
+		{
+			"pods started before injection was enabled",
+			newNamespace("enabled"),
+			newDeployment("ratings", "true"),
+			api.WorkloadInjection{
+				Kind: "deployment", Namespace: "default", Name: "ratings", Annotation: "true", Expected: true,
+				Pods: 1, RestartRequired: true,
+			},
+		},
+		{
+			"pods opted out of injection",
+			newNamespace("enabled"),
+			newDeployment("details", "false"),
+			api.WorkloadInjection{
+				Kind: "deployment", Namespace: "default", Name: "details", Annotation: "false",
+				Pods: 1, InjectedPods: 1, RestartRequired: true,
+				SidecarImages:   []string{"localhost:5000/istio/proxyv2"},
+				SidecarVersions: []string{"latest"},
+			},
+		},
+		{
+			"namespace without injection",
+			newNamespace(""),
+			newDeployment("ratings", "true"),
+			api.WorkloadInjection{
+				Kind: "deployment", Namespace: "default", Name: "ratings", Annotation: "true", Pods: 1,
+			},
+		},
+		{
+			"deployment without pods",
+			newNamespace("enabled"),
+			newDeployment("productpage", ""),
+			api.WorkloadInjection{Kind: "deployment", Namespace: "default", Name: "productpage", Expected: true},
+		},
+	}
+
+	for _, c := range cases {
+		actual := ToWorkloadInjection(c.namespace, c.deployment, pods)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: ToWorkloadInjection() == \n%#v\nexpected \n%#v", c.info, actual, c.expected)
+		}
+	}
+}
+
+func TestSetNamespaceInjection(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newNamespace(""),
+		newDeployment("reviews", ""),
+		newDeployment("ratings", ""),
+		newPod("reviews-1", "reviews", ""),
+	)
+
+	result, err := SetNamespaceInjection(client, "default", &api.InjectionSpec{Enabled: true, Restart: true})
+	if err != nil {
+		t.Fatalf("SetNamespaceInjection() returned error: %v", err)
+	}
+	if !result.Enabled || len(result.Workloads) != 2 {
+		t.Errorf("SetNamespaceInjection() == %#v, expected 2 workloads with injection enabled", result)
+	}
+
+	ns, _ := client.CoreV1().Namespaces().Get("default", metaV1.GetOptions{})
+	if ns.Labels[NamespaceLabel] != "enabled" {
+		t.Errorf("namespace label == %q, expected enabled", ns.Labels[NamespaceLabel])
+	}
+
+	// only the deployment with pods lacking the sidecar is restarted
+	reviews, _ := client.AppsV1().Deployments("default").Get("reviews", metaV1.GetOptions{})
+	if reviews.Spec.Template.Annotations[deployment.ReDeployCountKey] != "1" {
+		t.Errorf("reviews deployment was not restarted: %v", reviews.Spec.Template.Annotations)
+	}
+	ratings, _ := client.AppsV1().Deployments("default").Get("ratings", metaV1.GetOptions{})
+	if _, ok := ratings.Spec.Template.Annotations[deployment.ReDeployCountKey]; ok {
+		t.Errorf("ratings deployment without pods was restarted: %v", ratings.Spec.Template.Annotations)
+	}
+}
+
+func TestSetDeploymentInjection(t *testing.T) {
+	client := fake.NewSimpleClientset(newNamespace(""), newDeployment("reviews", ""))
+	_, err := SetDeploymentInjection(client, "default", "reviews", &api.InjectionSpec{Enabled: true})
+	if !k8sErrors.IsBadRequest(err) {
+		t.Errorf("SetDeploymentInjection() in namespace without injection == %v, expected bad request", err)
+	}
+
+	client = fake.NewSimpleClientset(newNamespace("enabled"), newDeployment("reviews", ""))
+	result, err := SetDeploymentInjection(client, "default", "reviews", &api.InjectionSpec{Enabled: false, Restart: true})
+	if err != nil {
+		t.Fatalf("SetDeploymentInjection() returned error: %v", err)
+	}
+	if result.Expected || result.Annotation != "false" {
+		t.Errorf("SetDeploymentInjection() == %#v, expected injection disabled", result)
+	}
+
+	reviews, _ := client.AppsV1().Deployments("default").Get("reviews", metaV1.GetOptions{})
+	expected := map[string]string{InjectAnnotation: "false", deployment.ReDeployCountKey: "1"}
+	if !reflect.DeepEqual(reviews.Spec.Template.Annotations, expected) {
+		t.Errorf("deployment template annotations == %v, expected %v", reviews.Spec.Template.Annotations, expected)
+	}
+}
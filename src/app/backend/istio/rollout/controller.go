@@ -0,0 +1,296 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/app"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/pod"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/kubernetes/dashboard/src/app/backend/settings"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultPeriod is how often the controller reconciles the rollouts.
+const DefaultPeriod = 10 * time.Second
+
+// ErrorRateSource provides the ratio (0-1) of failed requests served by one version of an application.
+type ErrorRateSource interface {
+	ErrorRate(namespace, appName, version string, window time.Duration) (float64, error)
+}
+
+// Controller advances the progressive deliveries of all applications in the background. The
+// rollouts are persisted in config maps, so the controller is stateless and resumes them after
+// dashboard restarts. The clients use the privileges of the dashboard, every change is reviewed
+// against the access of the user who started the rollout first.
+type Controller struct {
+	client      kubernetes.Interface
+	istioClient istio.Interface
+	errorRate   ErrorRateSource
+	sManager    settings.SettingsManager
+	// key verifies the signatures of the owners of the rollouts.
+	key string
+}
+
+// NewController creates rollout controller. The error rate gate is skipped when errorRate is nil.
+// The keys of the app & version labels are read from the global settings. Only the rollouts whose
+// owner has been signed with the key are advanced.
+func NewController(client kubernetes.Interface, istioClient istio.Interface, errorRate ErrorRateSource,
+	sManager settings.SettingsManager, key string) *Controller {
+	return &Controller{
+		client:      client,
+		istioClient: istioClient,
+		errorRate:   errorRate,
+		sManager:    sManager,
+		key:         key,
+	}
+}
+
+// Run reconciles all the rollouts every period until stopCh is closed.
+func (self *Controller) Run(period time.Duration, stopCh <-chan struct{}) {
+	log.Print("Starting rollout controller")
+	wait.Until(self.reconcileAll, period, stopCh)
+}
+
+func (self *Controller) reconcileAll() {
+	rollouts, err := listRollouts(self.client)
+	if err != nil {
+		log.Printf("Failed to list rollouts: %s", err)
+		return
+	}
+
+	keys := api.NewLabelKeys(self.sManager.GetGlobalSettings(self.client))
+	for _, rollout := range rollouts {
+		if err := self.reconcile(rollout.Rollout, rollout.owner, keys, time.Now()); err != nil {
+			log.Printf("Failed to reconcile rollout %s/%s: %s", rollout.ObjectMeta.Namespace,
+				rollout.ObjectMeta.Name, err)
+		}
+	}
+}
+
+// reconcile rolls the canary back when a health gate fails, otherwise advances it to the next
+// step once the current one has been held for the interval and a canary pod is ready.
+func (self *Controller) reconcile(rollout *api.Rollout, owner *owner, keys api.LabelKeys, now time.Time) error {
+	if rollout.Status.Phase != api.RolloutProgressing {
+		return nil
+	}
+	if owner == nil || !owner.verify(rollout, self.key) {
+		return fmt.Errorf("the owner of the rollout can't be verified")
+	}
+
+	namespace := rollout.ObjectMeta.Namespace
+	appName := rollout.ObjectMeta.Name
+	spec := &rollout.Spec
+	status := &rollout.Status
+
+	health, err := getCanaryHealth(self.client, self.errorRate, namespace, appName, spec.Canary, keys)
+	if err != nil {
+		return err
+	}
+
+	if reason := checkGates(&spec.Gates, health, status.BaselineRestarts); reason != "" {
+		if err := self.authorize(rollout, owner, app.ShiftAccess(namespace)); err != nil {
+			return err
+		}
+		if err := shiftTraffic(self.istioClient, namespace, appName, spec, 0); err != nil {
+			return err
+		}
+		status.Phase = api.RolloutRolledBack
+		status.Weight = 0
+		status.Message = reason
+		status.LastTransitionTime = metaV1.NewTime(now)
+		if err := saveRollout(self.client, rollout, nil); err != nil {
+			return err
+		}
+		return self.cleanup(rollout, owner, spec.Canary, keys)
+	}
+
+	if !holdElapsed(status, spec.Interval, now) {
+		return nil
+	}
+
+	// without ready pods the gates have observed nothing, so there is nothing to advance on
+	if health.ReadyPods == 0 {
+		message := fmt.Sprintf("waiting for a ready %s pod", spec.Canary)
+		if status.Message == message {
+			return nil
+		}
+		status.Message = message
+		return saveRollout(self.client, rollout, nil)
+	}
+
+	if err := self.authorize(rollout, owner, app.ShiftAccess(namespace)); err != nil {
+		return err
+	}
+
+	next := status.Step + 1
+	if next < len(spec.Steps) {
+		if err := shiftTraffic(self.istioClient, namespace, appName, spec, spec.Steps[next]); err != nil {
+			return err
+		}
+		status.Step = next
+		status.Weight = spec.Steps[next]
+		status.Message = fmt.Sprintf("shifted %d%% of traffic to %s", status.Weight, spec.Canary)
+		status.LastTransitionTime = metaV1.NewTime(now)
+		return saveRollout(self.client, rollout, nil)
+	}
+
+	if err := shiftTraffic(self.istioClient, namespace, appName, spec, 100); err != nil {
+		return err
+	}
+	status.Phase = api.RolloutSucceeded
+	status.Weight = 100
+	status.Message = fmt.Sprintf("%s took over all the traffic", spec.Canary)
+	status.LastTransitionTime = metaV1.NewTime(now)
+	if err := saveRollout(self.client, rollout, nil); err != nil {
+		return err
+	}
+	return self.cleanup(rollout, owner, spec.Stable, keys)
+}
+
+// authorize reviews the accesses the owner needs for the next change of the rollout. A denied
+// change is recorded in the status and retried on the next pass.
+func (self *Controller) authorize(rollout *api.Rollout, owner *owner,
+	accesses []authorizationv1.ResourceAttributes) error {
+	err := app.CheckUserAccess(self.client, &owner.User, accesses)
+	if !k8sErrors.IsForbidden(err) {
+		return err
+	}
+
+	message := fmt.Sprintf("%s is not allowed to continue the rollout: %s", owner.User.Username, err)
+	if rollout.Status.Message != message {
+		rollout.Status.Message = message
+		if err := saveRollout(self.client, rollout, nil); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// cleanup offlines the version losing the traffic when the rollout asks for it and its owner is
+// allowed to.
+func (self *Controller) cleanup(rollout *api.Rollout, owner *owner, version string, keys api.LabelKeys) error {
+	if !rollout.Spec.Cleanup {
+		return nil
+	}
+	if err := app.CheckUserAccess(self.client, &owner.User,
+		app.OfflineAccess(rollout.ObjectMeta.Namespace)); err != nil {
+		return err
+	}
+	return app.OfflineAppVersion(self.client, self.istioClient,
+		common.NewSameNamespaceQuery(rollout.ObjectMeta.Namespace), rollout.ObjectMeta.Name, version,
+		virtualservice.All, keys)
+}
+
+// canaryHealth is the observed health of the canary version.
+type canaryHealth struct {
+	ReadyPods   int32
+	FailingPods int32
+	Restarts    int32
+	// ErrorRate is negative when no error rate source is available.
+	ErrorRate float64
+}
+
+// getCanaryHealth collects the health of the pods labelled with the app & version by the keys.
+func getCanaryHealth(client kubernetes.Interface, errorRate ErrorRateSource, namespace, appName,
+	version string, keys api.LabelKeys) (*canaryHealth, error) {
+	selector := labels.SelectorFromSet(map[string]string{keys.App: appName, keys.Version: version})
+	pods, err := client.CoreV1().Pods(namespace).List(metaV1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	health := &canaryHealth{ErrorRate: -1}
+	for _, p := range pods.Items {
+		if isReady(&p) {
+			health.ReadyPods++
+		}
+		if isFailing(&p) {
+			health.FailingPods++
+		}
+		health.Restarts += pod.GetRestartCount(p)
+	}
+
+	if errorRate != nil {
+		rate, err := errorRate.ErrorRate(namespace, appName, version, time.Minute)
+		if err != nil {
+			log.Printf("Failed to get error rate of %s/%s %s: %s", namespace, appName, version, err)
+		} else {
+			health.ErrorRate = rate
+		}
+	}
+	return health, nil
+}
+
+// isReady checks whether the pod is running and ready to serve requests.
+func isReady(p *v1.Pod) bool {
+	if p.Status.Phase != v1.PodRunning {
+		return false
+	}
+
+	for _, condition := range p.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isFailing checks whether the pod failed or one of its containers can't be started.
+func isFailing(p *v1.Pod) bool {
+	if p.Status.Phase == v1.PodFailed {
+		return true
+	}
+
+	for _, status := range p.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		switch status.State.Waiting.Reason {
+		case "", "ContainerCreating", "PodInitializing":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// checkGates returns the reason of the first failed gate, empty when the canary is healthy.
+func checkGates(gates *api.RolloutGates, health *canaryHealth, baselineRestarts int32) string {
+	if health.FailingPods > gates.MaxFailingPods {
+		return fmt.Sprintf("%d canary pods are failing, %d tolerated", health.FailingPods, gates.MaxFailingPods)
+	}
+
+	if restarts := health.Restarts - baselineRestarts; restarts > gates.MaxRestarts {
+		return fmt.Sprintf("canary containers restarted %d times, %d tolerated", restarts, gates.MaxRestarts)
+	}
+
+	if gates.MaxErrorRate > 0 && health.ErrorRate > gates.MaxErrorRate {
+		return fmt.Sprintf("canary error rate is %.4f, %.4f tolerated", health.ErrorRate, gates.MaxErrorRate)
+	}
+	return ""
+}
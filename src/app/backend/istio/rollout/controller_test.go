@@ -0,0 +1,281 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"testing"
+	"time"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/args"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/settings"
+	settingsApi "github.com/kubernetes/dashboard/src/app/backend/settings/api"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testKey = "test-key"
+
+// newTestOwner signs a test user as the owner of the rollout.
+func newTestOwner(t *testing.T, rollout *api.Rollout) *owner {
+	owner, err := newOwner(rollout, &authenticationv1.UserInfo{Username: "jane", Groups: []string{"dev"}}, testKey)
+	if err != nil {
+		t.Fatalf("newOwner() returned error: %s", err)
+	}
+	return owner
+}
+
+func TestCheckGates(t *testing.T) {
+	gates := &api.RolloutGates{MaxFailingPods: 0, MaxRestarts: 2, MaxErrorRate: 0.05}
+	cases := []struct {
+		health   *canaryHealth
+		baseline int32
+		failed   bool
+	}{
+		{&canaryHealth{ErrorRate: -1}, 0, false},
+		{&canaryHealth{FailingPods: 1, ErrorRate: -1}, 0, true},
+		{&canaryHealth{Restarts: 5, ErrorRate: -1}, 3, false},
+		{&canaryHealth{Restarts: 6, ErrorRate: -1}, 3, true},
+		{&canaryHealth{ErrorRate: 0.01}, 0, false},
+		{&canaryHealth{ErrorRate: 0.1}, 0, true},
+	}
+
+	for _, c := range cases {
+		reason := checkGates(gates, c.health, c.baseline)
+		if (reason != "") != c.failed {
+			t.Errorf("checkGates(%+v, %d) == %q, expected failed: %t", c.health, c.baseline, reason, c.failed)
+		}
+	}
+}
+
+func TestIsFailing(t *testing.T) {
+	cases := []struct {
+		pod      v1.Pod
+		expected bool
+	}{
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}, false},
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed}}, true},
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{
+			{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+		}}}, false},
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: []v1.ContainerStatus{
+			{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+		}}}, true},
+	}
+
+	for _, c := range cases {
+		if actual := isFailing(&c.pod); actual != c.expected {
+			t.Errorf("isFailing(%+v) == %t, expected %t", c.pod.Status, actual, c.expected)
+		}
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	cases := []struct {
+		spec  api.RolloutSpec
+		valid bool
+	}{
+		{api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 25, 50, 100}, Interval: 60}, true},
+		{api.RolloutSpec{Stable: "v1", Canary: "v1", Steps: []int32{5}, Interval: 60}, false},
+		{api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{}, Interval: 60}, false},
+		{api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{50, 25}, Interval: 60}, false},
+		{api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{50, 120}, Interval: 60}, false},
+		{api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{50}, Interval: 0}, false},
+	}
+
+	for _, c := range cases {
+		if err := validateSpec(&c.spec); (err == nil) != c.valid {
+			t.Errorf("validateSpec(%+v) == %v, expected valid: %t", c.spec, err, c.valid)
+		}
+	}
+}
+
+func TestReconcileHoldsStep(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	now := time.Now()
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: api.RolloutSpec{
+			Stable:   "v1",
+			Canary:   "v2",
+			Steps:    []int32{5, 25, 100},
+			Interval: 60,
+		},
+		Status: api.RolloutStatus{
+			Phase:              api.RolloutProgressing,
+			Weight:             5,
+			LastTransitionTime: metaV1.NewTime(now.Add(-30 * time.Second)),
+		},
+	}
+
+	controller := NewController(client, nil, nil, settings.NewSettingsManager(nil), testKey)
+	if err := controller.reconcile(rollout, newTestOwner(t, rollout), api.NewLabelKeys(settingsApi.GetDefaultSettings()), now); err != nil {
+		t.Fatalf("reconcile() returned error: %s", err)
+	}
+
+	if rollout.Status.Step != 0 || rollout.Status.Weight != 5 {
+		t.Errorf("rollout advanced before the interval elapsed: %+v", rollout.Status)
+	}
+}
+
+func TestReconcileWaitsForReadyPods(t *testing.T) {
+	notReady := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "test-v2-0", Namespace: "default", Labels: map[string]string{
+			"app": "test", "version": "v2"}},
+		Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{
+			{Type: v1.PodReady, Status: v1.ConditionFalse},
+		}},
+	}
+	client := fake.NewSimpleClientset(notReady)
+	now := time.Now()
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: api.RolloutSpec{
+			Stable:   "v1",
+			Canary:   "v2",
+			Steps:    []int32{5, 25, 100},
+			Interval: 60,
+		},
+		Status: api.RolloutStatus{
+			Phase:              api.RolloutProgressing,
+			Weight:             5,
+			LastTransitionTime: metaV1.NewTime(now.Add(-2 * time.Minute)),
+		},
+	}
+
+	controller := NewController(client, nil, nil, settings.NewSettingsManager(nil), testKey)
+	if err := controller.reconcile(rollout, newTestOwner(t, rollout), api.NewLabelKeys(settingsApi.GetDefaultSettings()), now); err != nil {
+		t.Fatalf("reconcile() returned error: %s", err)
+	}
+
+	if rollout.Status.Phase != api.RolloutProgressing || rollout.Status.Step != 0 || rollout.Status.Weight != 5 {
+		t.Errorf("rollout advanced without ready canary pods: %+v", rollout.Status)
+	}
+	if rollout.Status.Message != "waiting for a ready v2 pod" {
+		t.Errorf("reconcile() message == %q, expected to wait for a ready pod", rollout.Status.Message)
+	}
+}
+
+func TestIsReady(t *testing.T) {
+	cases := []struct {
+		pod      v1.Pod
+		expected bool
+	}{
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending}}, false},
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}, false},
+		{v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{
+			{Type: v1.PodReady, Status: v1.ConditionTrue},
+		}}}, true},
+	}
+
+	for _, c := range cases {
+		if actual := isReady(&c.pod); actual != c.expected {
+			t.Errorf("isReady(%+v) == %t, expected %t", c.pod.Status, actual, c.expected)
+		}
+	}
+}
+
+func TestGetCanaryHealthLabelKeys(t *testing.T) {
+	s := settingsApi.GetDefaultSettings()
+	s.AppLabel = "app.kubernetes.io/name"
+	s.VersionLabel = "app.kubernetes.io/version"
+	settingsMap := settingsApi.GetDefaultSettingsConfigMap(args.Holder.GetNamespace())
+	settingsMap.Data[settingsApi.GlobalSettingsKey] = s.Marshal()
+	failing := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "reviews-v2-0", Namespace: "default", Labels: map[string]string{
+			"app.kubernetes.io/name": "reviews", "app.kubernetes.io/version": "v2"}},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	client := fake.NewSimpleClientset(settingsMap, failing)
+
+	controller := NewController(client, nil, nil, settings.NewSettingsManager(nil), testKey)
+	keys := api.NewLabelKeys(controller.sManager.GetGlobalSettings(client))
+	health, err := getCanaryHealth(client, nil, "default", "reviews", "v2", keys)
+	if err != nil {
+		t.Fatalf("getCanaryHealth() returned error: %s", err)
+	}
+	if health.FailingPods != 1 {
+		t.Errorf("getCanaryHealth() with keys %+v == %+v, expected 1 failing pod", keys, health)
+	}
+}
+
+func TestReconcileChecksOwner(t *testing.T) {
+	ready := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "test-v2-0", Namespace: "default", Labels: map[string]string{
+			"app": "test", "version": "v2"}},
+		Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{
+			{Type: v1.PodReady, Status: v1.ConditionTrue},
+		}},
+	}
+	client := fake.NewSimpleClientset(ready)
+	var reviewed []string
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object,
+		error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviewed = append(reviewed, review.Spec.User)
+		return true, review, nil
+	})
+	now := time.Now()
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 25, 100}, Interval: 60},
+		Status: api.RolloutStatus{
+			Phase:              api.RolloutProgressing,
+			Weight:             5,
+			LastTransitionTime: metaV1.NewTime(now.Add(-2 * time.Minute)),
+		},
+	}
+	owner := newTestOwner(t, rollout)
+	if err := saveRollout(client, rollout, owner); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+	controller := NewController(client, nil, nil, settings.NewSettingsManager(nil), testKey)
+	keys := api.NewLabelKeys(settingsApi.GetDefaultSettings())
+
+	// a spec changed by writing the config map invalidates the signature of the owner
+	forged := *rollout
+	forged.Spec.Stable = "v0"
+	if err := controller.reconcile(&forged, owner, keys, now); err == nil {
+		t.Errorf("reconcile() of a rollout with a forged owner returned no error")
+	}
+	if err := controller.reconcile(rollout, nil, keys, now); err == nil {
+		t.Errorf("reconcile() of a rollout without owner returned no error")
+	}
+
+	// the owner is denied by the fake reviews, the traffic is left untouched
+	if err := controller.reconcile(rollout, owner, keys, now); err == nil {
+		t.Errorf("reconcile() of a rollout whose owner is denied returned no error")
+	}
+	if rollout.Status.Step != 0 || rollout.Status.Weight != 5 {
+		t.Errorf("rollout advanced for a denied owner: %+v", rollout.Status)
+	}
+	if len(reviewed) == 0 || reviewed[0] != "jane" {
+		t.Errorf("reconcile() reviewed the access of %v, expected jane", reviewed)
+	}
+
+	persisted, err := getRollout(client, "default", "test")
+	if err != nil {
+		t.Fatalf("getRollout() returned error: %s", err)
+	}
+	if persisted.Status.Message != rollout.Status.Message || rollout.Status.Message == "" {
+		t.Errorf("persisted message == %q, expected the denial %q", persisted.Status.Message, rollout.Status.Message)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// owner is the user a rollout was started by. The controller advances the rollouts with the
+// privileges of the dashboard, so each step is reviewed against the access of the owner first.
+// The owner is signed along with the spec of the rollout, so the users who can write the config
+// map can neither forge it nor reuse it for another rollout.
+type owner struct {
+	User      authenticationv1.UserInfo `json:"user"`
+	Signature string                    `json:"signature"`
+}
+
+// signedFields are the fields of a rollout covered by the signature of its owner.
+type signedFields struct {
+	Namespace string                    `json:"namespace"`
+	Name      string                    `json:"name"`
+	Spec      api.RolloutSpec           `json:"spec"`
+	User      authenticationv1.UserInfo `json:"user"`
+}
+
+// newOwner signs the user as the owner of the rollout with the key.
+func newOwner(rollout *api.Rollout, user *authenticationv1.UserInfo, key string) (*owner, error) {
+	signature, err := sign(rollout, user, key)
+	if err != nil {
+		return nil, err
+	}
+	return &owner{User: *user, Signature: signature}, nil
+}
+
+// verify checks the owner has been signed with the key for the rollout as it is.
+func (self *owner) verify(rollout *api.Rollout, key string) bool {
+	signature, err := sign(rollout, &self.User, key)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(self.Signature))
+}
+
+func sign(rollout *api.Rollout, user *authenticationv1.UserInfo, key string) (string, error) {
+	data, err := json.Marshal(signedFields{
+		Namespace: rollout.ObjectMeta.Namespace,
+		Name:      rollout.ObjectMeta.Name,
+		Spec:      rollout.Spec,
+		User:      *user,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// ReviewUser identifies the user the config authenticates as. Bearer tokens are reviewed with the
+// client, which has to be allowed to create token reviews. Basic auth users are identified by
+// their name alone, their groups are unknown.
+func ReviewUser(client kubernetes.Interface, config *rest.Config) (*authenticationv1.UserInfo, error) {
+	if config.BearerToken == "" {
+		if config.Username != "" {
+			return &authenticationv1.UserInfo{Username: config.Username}, nil
+		}
+		return nil, k8sErrors.NewForbidden(schema.GroupResource{Resource: "rollouts"}, "",
+			errors.New("rollouts can only be started by users authenticated with a token or basic auth"))
+	}
+
+	review, err := client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: config.BearerToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, k8sErrors.NewUnauthorized(review.Status.Error)
+	}
+	return &review.Status.User, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"reflect"
+	"testing"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestOwnerVerify(t *testing.T) {
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 100}, Interval: 60, Cleanup: true},
+	}
+	signed := newTestOwner(t, rollout)
+
+	cases := []struct {
+		mutate   func(rollout *api.Rollout, owner *owner)
+		key      string
+		verified bool
+	}{
+		{func(*api.Rollout, *owner) {}, testKey, true},
+		{func(*api.Rollout, *owner) {}, "other-key", false},
+		{func(rollout *api.Rollout, _ *owner) { rollout.ObjectMeta.Namespace = "prod" }, testKey, false},
+		{func(rollout *api.Rollout, _ *owner) { rollout.Spec.Stable = "v0" }, testKey, false},
+		{func(_ *api.Rollout, owner *owner) { owner.User.Username = "admin" }, testKey, false},
+		{func(_ *api.Rollout, owner *owner) { owner.User.Groups = []string{"system:masters"} }, testKey, false},
+	}
+
+	for i, c := range cases {
+		r, o := *rollout, *signed
+		c.mutate(&r, &o)
+		if verified := o.verify(&r, c.key); verified != c.verified {
+			t.Errorf("case %d: verify() == %t, expected %t", i, verified, c.verified)
+		}
+	}
+}
+
+func TestReviewUser(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "valid" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "jane", Groups: []string{"dev"}}
+		}
+		return true, review, nil
+	})
+
+	cases := []struct {
+		config   *rest.Config
+		expected *authenticationv1.UserInfo
+		check    func(error) bool
+	}{
+		{&rest.Config{BearerToken: "valid"}, &authenticationv1.UserInfo{Username: "jane", Groups: []string{"dev"}}, nil},
+		{&rest.Config{BearerToken: "invalid"}, nil, k8sErrors.IsUnauthorized},
+		{&rest.Config{Username: "bob", Password: "secret"}, &authenticationv1.UserInfo{Username: "bob"}, nil},
+		{&rest.Config{}, nil, k8sErrors.IsForbidden},
+	}
+
+	for _, c := range cases {
+		user, err := ReviewUser(client, c.config)
+		if c.check != nil && !c.check(err) {
+			t.Errorf("ReviewUser(%+v) returned error %v", c.config, err)
+		}
+		if c.check == nil && err != nil {
+			t.Errorf("ReviewUser(%+v) returned error %v", c.config, err)
+		}
+		if !reflect.DeepEqual(user, c.expected) {
+			t.Errorf("ReviewUser(%+v) == %+v, expected %+v", c.config, user, c.expected)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"fmt"
+	"time"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/app"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetRollout returns the progressive delivery of the specified application.
+func GetRollout(client kubernetes.Interface, namespace, appName string) (*api.Rollout, error) {
+	rollout, err := getRollout(client, namespace, appName)
+	if err != nil {
+		return nil, err
+	}
+	if rollout == nil {
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "rollouts"}, appName)
+	}
+	return rollout, nil
+}
+
+// StartRollout starts the progressive delivery of the application's canary version.
+// 1. create the canary version when its deployment is given
+// 2. shift the traffic of the first step to the canary
+// 3. persist the rollout owned by the user, signed with the key, the controller advances it for
+// the user from now on
+func StartRollout(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName string, spec *api.RolloutSpec, keys api.LabelKeys, user *authenticationv1.UserInfo,
+	key string) (*api.Rollout, error) {
+	if err := validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	// the controller offlines a version at the end, check the user may do it before starting
+	if spec.Cleanup {
+		if err := app.CheckSelfAccess(client, app.OfflineAccess(namespace.ToRequestParam())); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := getRollout(client, namespace.ToRequestParam(), appName)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status.Phase == api.RolloutProgressing {
+		return nil, k8sErrors.NewAlreadyExists(schema.GroupResource{Resource: "rollouts"}, appName)
+	}
+
+	if spec.Deployment != nil {
+		spec.Deployment.Version = spec.Canary
+		if err := app.CanaryApp(client, istioClient, namespace, appName, spec.Deployment, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	health, err := getCanaryHealth(client, nil, namespace.ToRequestParam(), appName, spec.Canary, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := shiftTraffic(istioClient, namespace.ToRequestParam(), appName, spec, spec.Steps[0]); err != nil {
+		return nil, err
+	}
+
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{
+			Name:      appName,
+			Namespace: namespace.ToRequestParam(),
+		},
+		TypeMeta: api2.NewTypeMeta(api2.ResourceKindRollout),
+		Spec:     *spec,
+		Status: api.RolloutStatus{
+			Phase:              api.RolloutProgressing,
+			Step:               0,
+			Weight:             spec.Steps[0],
+			BaselineRestarts:   health.Restarts,
+			LastTransitionTime: metaV1.Now(),
+			Message:            fmt.Sprintf("shifted %d%% of traffic to %s", spec.Steps[0], spec.Canary),
+		},
+	}
+	// the deployment is created already, don't persist the pod template.
+	rollout.Spec.Deployment = nil
+
+	owner, err := newOwner(rollout, user, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveRollout(client, rollout, owner); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// AbortRollout stops the progressive delivery of the application. A rollout in progress gives all
+// the traffic back to the stable version.
+func AbortRollout(client kubernetes.Interface, istioClient istio.Interface, namespace *common.NamespaceQuery,
+	appName string) error {
+	rollout, err := GetRollout(client, namespace.ToRequestParam(), appName)
+	if err != nil {
+		return err
+	}
+
+	if rollout.Status.Phase == api.RolloutProgressing {
+		if err := shiftTraffic(istioClient, namespace.ToRequestParam(), appName, &rollout.Spec, 0); err != nil {
+			return err
+		}
+	}
+
+	return deleteRollout(client, namespace.ToRequestParam(), appName)
+}
+
+// validateSpec checks the versions are given and the steps are ascending percentages.
+func validateSpec(spec *api.RolloutSpec) error {
+	if spec.Stable == "" || spec.Canary == "" {
+		return k8sErrors.NewBadRequest("both stable and canary versions are required")
+	}
+	if spec.Stable == spec.Canary {
+		return k8sErrors.NewBadRequest("stable and canary versions must differ")
+	}
+	if len(spec.Steps) == 0 {
+		return k8sErrors.NewBadRequest("at least one step is required")
+	}
+	if spec.Interval <= 0 {
+		return k8sErrors.NewBadRequest("interval must be positive")
+	}
+
+	var previous int32
+	for _, step := range spec.Steps {
+		if step <= previous || step > 100 {
+			return k8sErrors.NewBadRequest(fmt.Sprintf("steps must be ascending percentages, %v given", spec.Steps))
+		}
+		previous = step
+	}
+
+	if spec.Gates.MaxErrorRate < 0 || spec.Gates.MaxErrorRate > 1 {
+		return k8sErrors.NewBadRequest("max error rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// shiftTraffic sends the given percentage of traffic to the canary and the rest to the stable version.
+func shiftTraffic(istioClient istio.Interface, namespace, appName string,
+	spec *api.RolloutSpec, weight int32) error {
+	return app.ShiftTraffic(istioClient, common.NewSameNamespaceQuery(namespace), appName,
+		&api.TrafficShifting{
+			Weights: map[string]int32{
+				spec.Stable: 100 - weight,
+				spec.Canary: weight,
+			},
+		}, virtualservice.All)
+}
+
+func holdElapsed(status *api.RolloutStatus, interval int64, now time.Time) bool {
+	return now.Sub(status.LastTransitionTime.Time) >= time.Duration(interval)*time.Second
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	kdErrors "github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RolloutLabel labels the config maps persisting rollouts, its value is the application name.
+	RolloutLabel = "dashboard.istio.io/rollout"
+	// rolloutDataKey is the config map data key holding the serialized rollout.
+	rolloutDataKey = "rollout"
+)
+
+// persistedRollout is the serialized form of a rollout stored in the config map.
+type persistedRollout struct {
+	Spec   api.RolloutSpec   `json:"spec"`
+	Status api.RolloutStatus `json:"status"`
+	Owner  *owner            `json:"owner,omitempty"`
+}
+
+// ownedRollout is a persisted rollout along with the owner it was started by.
+type ownedRollout struct {
+	*api.Rollout
+	owner *owner
+}
+
+func configMapName(appName string) string {
+	return fmt.Sprintf("%s-rollout", appName)
+}
+
+// getConfigMap reads the config map persisting the rollout of the application, nil is returned
+// when none exists. A config map of the same name without the rollout label belongs to someone
+// else, it's reported as a conflict so it's never read nor overwritten.
+func getConfigMap(client kubernetes.Interface, namespace, appName string) (*v1.ConfigMap, error) {
+	config, err := client.CoreV1().ConfigMaps(namespace).Get(configMapName(appName), metaV1.GetOptions{})
+	if err != nil {
+		if kdErrors.IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if config.Labels[RolloutLabel] != appName {
+		return nil, k8sErrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, config.Name)
+	}
+	return config, nil
+}
+
+// getRollout reads the rollout of the application, nil is returned when none exists.
+func getRollout(client kubernetes.Interface, namespace, appName string) (*api.Rollout, error) {
+	config, err := getConfigMap(client, namespace, appName)
+	if err != nil || config == nil {
+		return nil, err
+	}
+	rollout, _, err := fromConfigMap(config)
+	return rollout, err
+}
+
+// listRollouts reads the rollouts of all namespaces. The config maps which can't be parsed are
+// skipped, so they don't hold back the other rollouts.
+func listRollouts(client kubernetes.Interface) ([]ownedRollout, error) {
+	configs, err := client.CoreV1().ConfigMaps(v1.NamespaceAll).List(metaV1.ListOptions{LabelSelector: RolloutLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	rollouts := make([]ownedRollout, 0)
+	for i := range configs.Items {
+		rollout, owner, err := fromConfigMap(&configs.Items[i])
+		if err != nil {
+			log.Printf("Skipping rollout: %s", err)
+			continue
+		}
+		rollouts = append(rollouts, ownedRollout{Rollout: rollout, owner: owner})
+	}
+	return rollouts, nil
+}
+
+// saveRollout creates or updates the config map persisting the rollout. The persisted owner is
+// kept when none is given.
+func saveRollout(client kubernetes.Interface, rollout *api.Rollout, owner *owner) error {
+	namespace := rollout.ObjectMeta.Namespace
+	config, err := getConfigMap(client, namespace, rollout.ObjectMeta.Name)
+	if err != nil {
+		return err
+	}
+
+	if owner == nil && config != nil {
+		if _, persisted, err := fromConfigMap(config); err == nil {
+			owner = persisted
+		}
+	}
+	data, err := json.Marshal(persistedRollout{Spec: rollout.Spec, Status: rollout.Status, Owner: owner})
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		config = &v1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      configMapName(rollout.ObjectMeta.Name),
+				Namespace: namespace,
+				Labels:    map[string]string{RolloutLabel: rollout.ObjectMeta.Name},
+			},
+			Data: map[string]string{rolloutDataKey: string(data)},
+		}
+		_, err = client.CoreV1().ConfigMaps(namespace).Create(config)
+		return err
+	}
+
+	if config.Data == nil {
+		config.Data = make(map[string]string)
+	}
+	config.Data[rolloutDataKey] = string(data)
+	_, err = client.CoreV1().ConfigMaps(namespace).Update(config)
+	return err
+}
+
+// deleteRollout deletes the config map persisting the rollout of the application.
+func deleteRollout(client kubernetes.Interface, namespace, appName string) error {
+	if _, err := getConfigMap(client, namespace, appName); err != nil {
+		return err
+	}
+	return client.CoreV1().ConfigMaps(namespace).Delete(configMapName(appName), &metaV1.DeleteOptions{})
+}
+
+func fromConfigMap(config *v1.ConfigMap) (*api.Rollout, *owner, error) {
+	persisted := new(persistedRollout)
+	if err := json.Unmarshal([]byte(config.Data[rolloutDataKey]), persisted); err != nil {
+		return nil, nil, fmt.Errorf("invalid rollout in config map %s/%s: %s", config.Namespace, config.Name, err)
+	}
+
+	return &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{
+			Name:              config.Labels[RolloutLabel],
+			Namespace:         config.Namespace,
+			CreationTimestamp: config.CreationTimestamp,
+		},
+		TypeMeta: api2.NewTypeMeta(api2.ResourceKindRollout),
+		Spec:     persisted.Spec,
+		Status:   persisted.Status,
+	}, persisted.Owner, nil
+}
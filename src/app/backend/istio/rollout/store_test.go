@@ -0,0 +1,155 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"reflect"
+	"testing"
+
+	api2 "github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSaveRollout(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		TypeMeta:   api2.NewTypeMeta(api2.ResourceKindRollout),
+		Spec: api.RolloutSpec{
+			Stable:   "v1",
+			Canary:   "v2",
+			Steps:    []int32{5, 25, 100},
+			Interval: 60,
+		},
+		Status: api.RolloutStatus{Phase: api.RolloutProgressing, Weight: 5},
+	}
+
+	if err := saveRollout(client, rollout, nil); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+
+	rollout.Status.Step = 1
+	rollout.Status.Weight = 25
+	if err := saveRollout(client, rollout, nil); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+
+	actual, err := getRollout(client, "default", "test")
+	if err != nil {
+		t.Fatalf("getRollout() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Spec, rollout.Spec) || actual.Status.Weight != 25 || actual.Status.Step != 1 {
+		t.Errorf("getRollout() == %+v, expected %+v", actual, rollout)
+	}
+
+	if err := deleteRollout(client, "default", "test"); err != nil {
+		t.Fatalf("deleteRollout() returned error: %s", err)
+	}
+	if actual, _ := getRollout(client, "default", "test"); actual != nil {
+		t.Errorf("getRollout() == %+v after delete, expected nil", actual)
+	}
+}
+
+func TestListRolloutsSkipsInvalid(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      configMapName("broken"),
+			Namespace: "default",
+			Labels:    map[string]string{RolloutLabel: "broken"},
+		},
+		Data: map[string]string{rolloutDataKey: "{"},
+	})
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 100}, Interval: 60},
+		Status:     api.RolloutStatus{Phase: api.RolloutProgressing, Weight: 5},
+	}
+	if err := saveRollout(client, rollout, nil); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+
+	rollouts, err := listRollouts(client)
+	if err != nil {
+		t.Fatalf("listRollouts() returned error: %s", err)
+	}
+	if len(rollouts) != 1 || rollouts[0].ObjectMeta.Name != "test" {
+		t.Errorf("listRollouts() == %+v, expected only the test rollout", rollouts)
+	}
+}
+
+func TestSaveRolloutKeepsForeignConfigMap(t *testing.T) {
+	foreign := &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{Name: configMapName("test"), Namespace: "default"},
+		Data:       map[string]string{rolloutDataKey: "unrelated"},
+	}
+	client := fake.NewSimpleClientset(foreign)
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 100}, Interval: 60},
+		Status:     api.RolloutStatus{Phase: api.RolloutProgressing, Weight: 5},
+	}
+
+	if err := saveRollout(client, rollout, nil); !k8sErrors.IsAlreadyExists(err) {
+		t.Errorf("saveRollout() returned %v, expected already exists", err)
+	}
+	if _, err := getRollout(client, "default", "test"); !k8sErrors.IsAlreadyExists(err) {
+		t.Errorf("getRollout() returned %v, expected already exists", err)
+	}
+	if err := deleteRollout(client, "default", "test"); !k8sErrors.IsAlreadyExists(err) {
+		t.Errorf("deleteRollout() returned %v, expected already exists", err)
+	}
+
+	actual, err := client.CoreV1().ConfigMaps("default").Get(configMapName("test"), metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Data, foreign.Data) {
+		t.Errorf("config map data == %v, expected it untouched", actual.Data)
+	}
+}
+
+func TestSaveRolloutKeepsOwner(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	rollout := &api.Rollout{
+		ObjectMeta: api2.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       api.RolloutSpec{Stable: "v1", Canary: "v2", Steps: []int32{5, 100}, Interval: 60},
+		Status:     api.RolloutStatus{Phase: api.RolloutProgressing, Weight: 5},
+	}
+	owner := newTestOwner(t, rollout)
+	if err := saveRollout(client, rollout, owner); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+
+	// the controller saves the status without the owner
+	rollout.Status.Weight = 100
+	if err := saveRollout(client, rollout, nil); err != nil {
+		t.Fatalf("saveRollout() returned error: %s", err)
+	}
+
+	rollouts, err := listRollouts(client)
+	if err != nil {
+		t.Fatalf("listRollouts() returned error: %s", err)
+	}
+	if len(rollouts) != 1 || !reflect.DeepEqual(rollouts[0].owner, owner) {
+		t.Fatalf("listRollouts() == %+v, expected the owner %+v", rollouts, owner)
+	}
+	if !rollouts[0].owner.verify(rollouts[0].Rollout, testKey) {
+		t.Errorf("owner of the persisted rollout can't be verified")
+	}
+}
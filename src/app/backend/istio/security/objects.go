@@ -0,0 +1,213 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/destinationrule"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The vendored istio types have no authentication & rbac resources, and decode the destination
+// rules of kubectl badly (camel case fields, enum names), these objects are read as raw JSON.
+
+const (
+	authenticationAPI = "/apis/authentication.istio.io/v1alpha1"
+	rbacAPI           = "/apis/rbac.istio.io/v1alpha1"
+	networkingAPI     = "/apis/networking.istio.io/v1alpha3"
+)
+
+// AuthenticationPolicy is an authentication.istio.io Policy or MeshPolicy.
+type AuthenticationPolicy struct {
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		// Targets are the services the policy applies to, all of the namespace when empty.
+		Targets []struct {
+			Name string `json:"name"`
+		} `json:"targets"`
+		// Peers are the peer authentication methods, the mtls key enables mutual TLS.
+		Peers []map[string]*struct {
+			Mode string `json:"mode"`
+		} `json:"peers"`
+	} `json:"spec"`
+}
+
+// mtlsMode returns the mutual TLS mode of the policy. A mtls peer without mode is strict.
+func (self *AuthenticationPolicy) mtlsMode() string {
+	for _, peer := range self.Spec.Peers {
+		method, ok := peer["mtls"]
+		if !ok {
+			continue
+		}
+		if method != nil && strings.ToUpper(method.Mode) == api.MTLSPermissive {
+			return api.MTLSPermissive
+		}
+		return api.MTLSStrict
+	}
+	return api.MTLSDisabled
+}
+
+// DestinationRule is the host and TLS settings of a networking.istio.io DestinationRule.
+type DestinationRule struct {
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Host          string         `json:"host"`
+		TrafficPolicy *trafficPolicy `json:"trafficPolicy"`
+		// the typed client of dashboard writes the snake case field
+		LegacyTrafficPolicy *trafficPolicy `json:"traffic_policy"`
+	} `json:"spec"`
+}
+
+type trafficPolicy struct {
+	Tls *struct {
+		Mode tlsMode `json:"mode"`
+	} `json:"tls"`
+}
+
+// tlsMode is a TLS mode given by its name or its value.
+type tlsMode string
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (self *tlsMode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*self = tlsMode(strings.ToUpper(name))
+		return nil
+	}
+	value, err := strconv.ParseInt(string(data), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid TLS mode %s", data)
+	}
+	*self = tlsMode(strconv.FormatInt(value, 10))
+	for name, v := range destinationrule.TLSModes {
+		if int64(v) == value {
+			*self = tlsMode(name)
+		}
+	}
+	return nil
+}
+
+// clientTLS returns the TLS mode of the rule, DISABLE when the rule has no TLS settings.
+func (self *DestinationRule) clientTLS() string {
+	policy := self.Spec.TrafficPolicy
+	if policy == nil {
+		policy = self.Spec.LegacyTrafficPolicy
+	}
+	if policy == nil || policy.Tls == nil || policy.Tls.Mode == "" {
+		return "DISABLE"
+	}
+	return string(policy.Tls.Mode)
+}
+
+// RbacConfig is a rbac.istio.io RbacConfig or ClusterRbacConfig, enabling the istio RBAC.
+type RbacConfig struct {
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		// Mode is OFF, ON, ON_WITH_INCLUSION or ON_WITH_EXCLUSION.
+		Mode      string     `json:"mode"`
+		Inclusion *rbacScope `json:"inclusion"`
+		Exclusion *rbacScope `json:"exclusion"`
+	} `json:"spec"`
+}
+
+type rbacScope struct {
+	Services   []string `json:"services"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// ServiceRole is a rbac.istio.io ServiceRole, granting access to services.
+type ServiceRole struct {
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Rules []struct {
+			Services []string `json:"services"`
+		} `json:"rules"`
+	} `json:"spec"`
+}
+
+// ServiceRoleBinding is a rbac.istio.io ServiceRoleBinding, assigning a role to subjects.
+type ServiceRoleBinding struct {
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Subjects []struct {
+			User       string            `json:"user"`
+			Group      string            `json:"group"`
+			Properties map[string]string `json:"properties"`
+		} `json:"subjects"`
+		RoleRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"roleRef"`
+	} `json:"spec"`
+}
+
+// Input holds the istio objects the security of the services of a namespace is computed from.
+type Input struct {
+	MeshPolicies        []AuthenticationPolicy
+	Policies            []AuthenticationPolicy
+	DestinationRules    []DestinationRule
+	RbacConfigs         []RbacConfig
+	ServiceRoles        []ServiceRole
+	ServiceRoleBindings []ServiceRoleBinding
+}
+
+// GetInput reads the istio objects of the namespace, the destination rules and the rbac
+// configs of all the namespaces and the mesh policies. The resources whose definition is not
+// installed are empty.
+func GetInput(istioClient istio.Interface, namespace string) (*Input, error) {
+	input := &Input{}
+	var clusterRbacConfigs []RbacConfig
+	lists := []struct {
+		path  []string
+		items interface{}
+	}{
+		{[]string{authenticationAPI, "meshpolicies"}, &input.MeshPolicies},
+		{[]string{authenticationAPI, "namespaces", namespace, "policies"}, &input.Policies},
+		{[]string{networkingAPI, "destinationrules"}, &input.DestinationRules},
+		{[]string{rbacAPI, "clusterrbacconfigs"}, &clusterRbacConfigs},
+		{[]string{rbacAPI, "rbacconfigs"}, &input.RbacConfigs},
+		{[]string{rbacAPI, "namespaces", namespace, "serviceroles"}, &input.ServiceRoles},
+		{[]string{rbacAPI, "namespaces", namespace, "servicerolebindings"}, &input.ServiceRoleBindings},
+	}
+	for _, list := range lists {
+		if err := getList(istioClient, list.path, list.items); err != nil {
+			return nil, err
+		}
+	}
+	// the cluster scoped config replaces the rbac config of the older istio releases
+	input.RbacConfigs = append(clusterRbacConfigs, input.RbacConfigs...)
+	return input, nil
+}
+
+func getList(istioClient istio.Interface, path []string, items interface{}) error {
+	raw, err := istioClient.NetworkingV1alpha3().RESTClient().Get().AbsPath(path...).Do().Raw()
+	if k8sErrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	list := struct {
+		Items interface{} `json:"items"`
+	}{Items: items}
+	return json.Unmarshal(raw, &list)
+}
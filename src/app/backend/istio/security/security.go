@@ -0,0 +1,282 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetNamespaceSecurity returns the security of the services of the namespace.
+func GetNamespaceSecurity(client kubernetes.Interface, istioClient istio.Interface, namespace string) (*api.NamespaceSecurity, error) {
+	log.Printf("Getting security of services in %s namespace", namespace)
+
+	services, err := client.CoreV1().Services(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	input, err := GetInput(istioClient, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &api.NamespaceSecurity{
+		Namespace:         namespace,
+		Services:          make([]api.ServiceSecurity, 0, len(services.Items)),
+		PlaintextServices: make([]string, 0),
+	}
+	for _, svc := range services.Items {
+		security := GetServiceSecurity(input, namespace, svc.Name)
+		result.Services = append(result.Services, api.ServiceSecurity{Name: svc.Name, Security: *security})
+		if !security.MTLSEnforced {
+			result.PlaintextServices = append(result.PlaintextServices, svc.Name)
+		}
+	}
+	sort.Slice(result.Services, func(i, j int) bool { return result.Services[i].Name < result.Services[j].Name })
+	sort.Strings(result.PlaintextServices)
+	return result, nil
+}
+
+// GetServiceSecurity computes the mutual TLS and authorization state of the service. The port
+// level settings of the policies and destination rules are not taken into account.
+func GetServiceSecurity(input *Input, namespace, service string) *api.Security {
+	security := &api.Security{}
+	security.ServerMTLS, security.AuthenticationPolicy = serverMTLS(input, namespace, service)
+	security.ClientTLS, security.DestinationRule = clientTLS(input, namespace, service)
+	security.MTLSEnforced = security.ServerMTLS == api.MTLSStrict
+	security.Authorization = authorization(input, namespace, service)
+
+	mutual := security.ClientTLS == "ISTIO_MUTUAL" || security.ClientTLS == "MUTUAL"
+	clients := "clients"
+	if security.DestinationRule != "" {
+		clients = fmt.Sprintf("clients of destination rule %s", security.DestinationRule)
+	}
+	switch {
+	case security.ServerMTLS == api.MTLSStrict && !mutual:
+		security.Warnings = append(security.Warnings, fmt.Sprintf(
+			"%s don't originate mutual TLS, their requests are rejected by policy %s",
+			clients, security.AuthenticationPolicy))
+	case security.ServerMTLS == api.MTLSDisabled && security.ClientTLS == "ISTIO_MUTUAL":
+		security.Warnings = append(security.Warnings, fmt.Sprintf(
+			"%s originate mutual TLS, but no authentication policy enables it for the service", clients))
+	}
+	if security.Authorization.Enabled && len(security.Authorization.Principals) == 0 {
+		security.Warnings = append(security.Warnings, "RBAC is enabled, but no service role binding allows any caller")
+	}
+	return security
+}
+
+// serverMTLS returns the mode of the most specific authentication policy of the service: the one
+// targeting the service, the namespace default policy or the mesh policy.
+func serverMTLS(input *Input, namespace, service string) (string, string) {
+	var namespacePolicy *AuthenticationPolicy
+	for i := range input.Policies {
+		policy := &input.Policies[i]
+		if policy.Namespace != namespace {
+			continue
+		}
+		if len(policy.Spec.Targets) == 0 {
+			if policy.Name == "default" {
+				namespacePolicy = policy
+			}
+			continue
+		}
+		for _, target := range policy.Spec.Targets {
+			if target.Name == service {
+				return policy.mtlsMode(), policy.Namespace + "/" + policy.Name
+			}
+		}
+	}
+	if namespacePolicy != nil {
+		return namespacePolicy.mtlsMode(), namespacePolicy.Namespace + "/" + namespacePolicy.Name
+	}
+
+	for i := range input.MeshPolicies {
+		if input.MeshPolicies[i].Name == "default" {
+			return input.MeshPolicies[i].mtlsMode(), "MeshPolicy/default"
+		}
+	}
+	return api.MTLSDisabled, ""
+}
+
+// clientTLS returns the mode of the destination rule whose host matches the service best, an
+// exact host before the longest wildcard, a rule of the service's namespace first.
+func clientTLS(input *Input, namespace, service string) (string, string) {
+	fqdn := virtualservice.FQDN(service, namespace)
+	var best *DestinationRule
+	bestScore := -1
+	for i := range input.DestinationRules {
+		rule := &input.DestinationRules[i]
+		score := hostScore(ruleHost(rule), fqdn)
+		if score < 0 {
+			continue
+		}
+		// a rule of the service's namespace overrides the equally specific ones of other namespaces
+		score = score * 2
+		if rule.Namespace == namespace {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	if best == nil {
+		return "DISABLE", ""
+	}
+	return best.clientTLS(), best.Namespace + "/" + best.Name
+}
+
+func ruleHost(rule *DestinationRule) string {
+	if strings.HasPrefix(rule.Spec.Host, "*") {
+		return rule.Spec.Host
+	}
+	return virtualservice.FQDN(rule.Spec.Host, rule.Namespace)
+}
+
+// hostScore returns how specific the host matching the fqdn is, -1 when it doesn't match.
+func hostScore(host, fqdn string) int {
+	switch {
+	case host == fqdn:
+		return len(host) + 1
+	case host == "*":
+		return 0
+	case strings.HasPrefix(host, "*.") && strings.HasSuffix(fqdn, host[1:]):
+		return len(host)
+	}
+	return -1
+}
+
+// authorization returns the callers the istio RBAC allows to call the service.
+func authorization(input *Input, namespace, service string) api.Authorization {
+	fqdn := virtualservice.FQDN(service, namespace)
+	if !rbacEnabled(input.RbacConfigs, namespace, service, fqdn) {
+		return api.Authorization{Principals: []string{"*"}}
+	}
+
+	result := api.Authorization{Enabled: true, Principals: make([]string, 0)}
+	roles := make(map[string]bool)
+	for _, role := range input.ServiceRoles {
+		if role.Namespace != namespace {
+			continue
+		}
+		for _, rule := range role.Spec.Rules {
+			if matchesAny(rule.Services, service, fqdn) {
+				roles[role.Name] = true
+				result.Roles = append(result.Roles, role.Namespace+"/"+role.Name)
+				break
+			}
+		}
+	}
+
+	principals := make(map[string]bool)
+	for _, binding := range input.ServiceRoleBindings {
+		if binding.Namespace != namespace || !roles[binding.Spec.RoleRef.Name] ||
+			binding.Spec.RoleRef.Kind != "" && binding.Spec.RoleRef.Kind != "ServiceRole" {
+			continue
+		}
+		for _, subject := range binding.Spec.Subjects {
+			principals[subjectName(subject.User, subject.Group, subject.Properties)] = true
+		}
+	}
+	for principal := range principals {
+		result.Principals = append(result.Principals, principal)
+	}
+	sort.Strings(result.Principals)
+	sort.Strings(result.Roles)
+	return result
+}
+
+// rbacEnabled returns true if the default rbac config enables the RBAC for the service.
+func rbacEnabled(configs []RbacConfig, namespace, service, fqdn string) bool {
+	for _, config := range configs {
+		if config.Name != "default" {
+			continue
+		}
+		switch strings.ToUpper(config.Spec.Mode) {
+		case "ON":
+			return true
+		case "ON_WITH_INCLUSION":
+			return inScope(config.Spec.Inclusion, namespace, service, fqdn)
+		case "ON_WITH_EXCLUSION":
+			return !inScope(config.Spec.Exclusion, namespace, service, fqdn)
+		}
+		return false
+	}
+	return false
+}
+
+func inScope(scope *rbacScope, namespace, service, fqdn string) bool {
+	if scope == nil {
+		return false
+	}
+	for _, ns := range scope.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	for _, svc := range scope.Services {
+		if svc == fqdn || svc == service {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if one of the rbac service patterns matches the service. A pattern can
+// start or end with a wildcard.
+func matchesAny(patterns []string, service, fqdn string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*" || pattern == fqdn || pattern == service:
+			return true
+		case strings.HasPrefix(pattern, "*") && strings.HasSuffix(fqdn, pattern[1:]):
+			return true
+		case strings.HasSuffix(pattern, "*") && strings.HasPrefix(fqdn, pattern[:len(pattern)-1]):
+			return true
+		}
+	}
+	return false
+}
+
+// subjectName returns the user of the subject, e.g. cluster.local/ns/default/sa/productpage, or
+// its source principal, group or properties.
+func subjectName(user, group string, properties map[string]string) string {
+	if user != "" {
+		return user
+	}
+	if principal := properties["source.principal"]; principal != "" {
+		return principal
+	}
+	var parts []string
+	if group != "" {
+		parts = append(parts, "group="+group)
+	}
+	for key, value := range properties {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,163 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+)
+
+func decode(t *testing.T, data string, into interface{}) {
+	if err := json.Unmarshal([]byte(data), into); err != nil {
+		t.Fatalf("failed to decode %s: %v", data, err)
+	}
+}
+
+func newInput(t *testing.T) *Input {
+	input := &Input{}
+	decode(t, `[{"metadata":{"name":"default"},"spec":{"peers":[{"mtls":{"mode":"PERMISSIVE"}}]}}]`,
+		&input.MeshPolicies)
+	decode(t, `[
+		{"metadata":{"name":"default","namespace":"default"},"spec":{"peers":[{"mtls":null}]}},
+		{"metadata":{"name":"legacy","namespace":"default"},"spec":{"targets":[{"name":"legacy"}]}},
+		{"metadata":{"name":"default","namespace":"other"},"spec":{"peers":[{"jwt":{}}]}}
+	]`, &input.Policies)
+	decode(t, `[
+		{"metadata":{"name":"default","namespace":"istio-system"},
+		 "spec":{"host":"*.local","trafficPolicy":{"tls":{"mode":"ISTIO_MUTUAL"}}}},
+		{"metadata":{"name":"reviews","namespace":"default"},
+		 "spec":{"host":"reviews","traffic_policy":{"tls":{"mode":3}}}},
+		{"metadata":{"name":"ratings","namespace":"default"},"spec":{"host":"ratings"}},
+		{"metadata":{"name":"legacy","namespace":"default"},
+		 "spec":{"host":"legacy.default.svc.cluster.local","trafficPolicy":{"tls":{"mode":"DISABLE"}}}}
+	]`, &input.DestinationRules)
+	decode(t, `[{"metadata":{"name":"default"},
+		"spec":{"mode":"ON_WITH_INCLUSION","inclusion":{"services":["reviews.default.svc.cluster.local","ratings"]}}}]`,
+		&input.RbacConfigs)
+	decode(t, `[
+		{"metadata":{"name":"reviews-viewer","namespace":"default"},
+		 "spec":{"rules":[{"services":["reviews.default.svc.cluster.local"],"methods":["GET"]}]}},
+		{"metadata":{"name":"all-viewer","namespace":"default"},"spec":{"rules":[{"services":["*.default.svc.cluster.local"]}]}},
+		{"metadata":{"name":"other-viewer","namespace":"other"},"spec":{"rules":[{"services":["*"]}]}}
+	]`, &input.ServiceRoles)
+	decode(t, `[
+		{"metadata":{"name":"productpage","namespace":"default"},
+		 "spec":{"subjects":[{"user":"cluster.local/ns/default/sa/productpage"}],"roleRef":{"kind":"ServiceRole","name":"reviews-viewer"}}},
+		{"metadata":{"name":"admins","namespace":"default"},
+		 "spec":{"subjects":[{"group":"admins","properties":{"source.namespace":"ops"}},
+		                     {"properties":{"source.principal":"cluster.local/ns/ops/sa/ops"}}],
+		         "roleRef":{"kind":"ServiceRole","name":"all-viewer"}}},
+		{"metadata":{"name":"other","namespace":"other"},
+		 "spec":{"subjects":[{"user":"*"}],"roleRef":{"kind":"ServiceRole","name":"other-viewer"}}}
+	]`, &input.ServiceRoleBindings)
+	return input
+}
+
+func TestGetServiceSecurity(t *testing.T) {
+	input := newInput(t)
+
+	cases := []struct {
+		service  string
+		expected *api.Security
+		warning  string
+	}{
+		{
+			"reviews",
+			&api.Security{
+				ServerMTLS: api.MTLSStrict, AuthenticationPolicy: "default/default",
+				ClientTLS: "ISTIO_MUTUAL", DestinationRule: "default/reviews", MTLSEnforced: true,
+				Authorization: api.Authorization{
+					Enabled: true,
+					Principals: []string{"cluster.local/ns/default/sa/productpage", "cluster.local/ns/ops/sa/ops",
+						"group=admins,source.namespace=ops"},
+					Roles: []string{"default/all-viewer", "default/reviews-viewer"},
+				},
+			},
+			"",
+		},
+		{
+			"ratings",
+			&api.Security{
+				ServerMTLS: api.MTLSStrict, AuthenticationPolicy: "default/default",
+				ClientTLS: "DISABLE", DestinationRule: "default/ratings", MTLSEnforced: true,
+				Authorization: api.Authorization{
+					Enabled:    true,
+					Principals: []string{"cluster.local/ns/ops/sa/ops", "group=admins,source.namespace=ops"},
+					Roles:      []string{"default/all-viewer"},
+				},
+			},
+			"clients of destination rule default/ratings don't originate mutual TLS",
+		},
+		{
+			"legacy",
+			&api.Security{
+				ServerMTLS: api.MTLSDisabled, AuthenticationPolicy: "default/legacy",
+				ClientTLS: "DISABLE", DestinationRule: "default/legacy",
+				Authorization: api.Authorization{Principals: []string{"*"}},
+			},
+			"",
+		},
+		{
+			"details",
+			&api.Security{
+				ServerMTLS: api.MTLSStrict, AuthenticationPolicy: "default/default",
+				ClientTLS: "ISTIO_MUTUAL", DestinationRule: "istio-system/default", MTLSEnforced: true,
+				Authorization: api.Authorization{Principals: []string{"*"}},
+			},
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		actual := GetServiceSecurity(input, "default", c.service)
+		warnings := actual.Warnings
+		actual.Warnings = nil
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("GetServiceSecurity(%s) == \n%#v\nexpected \n%#v", c.service, actual, c.expected)
+		}
+		if c.warning == "" && len(warnings) > 0 || c.warning != "" && (len(warnings) != 1 || !strings.HasPrefix(warnings[0], c.warning)) {
+			t.Errorf("GetServiceSecurity(%s) warnings == %v, expected %q", c.service, warnings, c.warning)
+		}
+	}
+
+	// the mesh policy applies to the namespaces without a policy enabling mutual TLS
+	actual := GetServiceSecurity(input, "other", "httpbin")
+	if actual.ServerMTLS != api.MTLSDisabled || actual.AuthenticationPolicy != "other/default" {
+		t.Errorf("GetServiceSecurity(other/httpbin) == %s from %s, expected DISABLE from other/default",
+			actual.ServerMTLS, actual.AuthenticationPolicy)
+	}
+	if len(actual.Warnings) != 1 {
+		t.Errorf("GetServiceSecurity(other/httpbin) warnings == %v, expected the ISTIO_MUTUAL clients", actual.Warnings)
+	}
+	actual = GetServiceSecurity(&Input{MeshPolicies: input.MeshPolicies}, "other", "httpbin")
+	if actual.ServerMTLS != api.MTLSPermissive || actual.AuthenticationPolicy != "MeshPolicy/default" || actual.MTLSEnforced {
+		t.Errorf("GetServiceSecurity() with mesh policy only == %#v", actual)
+	}
+}
+
+func TestTLSModeDecoding(t *testing.T) {
+	var rule DestinationRule
+	decode(t, `{"spec":{"trafficPolicy":{"tls":{"mode":"istio_mutual"}}}}`, &rule)
+	if mode := rule.clientTLS(); mode != "ISTIO_MUTUAL" {
+		t.Errorf("clientTLS() == %s, expected ISTIO_MUTUAL", mode)
+	}
+	if err := json.Unmarshal([]byte(`{"spec":{"trafficPolicy":{"tls":{"mode":true}}}}`), &rule); err == nil {
+		t.Error("decoding of an invalid TLS mode expected to fail")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"log"
+
+	"github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetTopology returns the topology of the mesh in the namespaces.
+func GetTopology(client kubernetes.Interface, istioClient istio.Interface, nsQuery *common.NamespaceQuery) (*api.Graph, error) {
+	log.Printf("Getting mesh topology of %v namespaces", nsQuery.ToRequestParam())
+
+	channels := &common.ResourceChannels{
+		ServiceList:         common.GetServiceListChannel(client, nsQuery, 1),
+		VirtualServiceList:  common.GetVirtualServiceListChannel(istioClient, nsQuery, 1),
+		DestinationRuleList: common.GetDestinationRuleListChannel(istioClient, nsQuery, 1),
+		GatewayList:         common.GetGatewayListChannel(istioClient, nsQuery, 1),
+		ServiceEntryList:    common.GetServiceEntryListChannel(istioClient, nsQuery, 1),
+	}
+
+	return GetTopologyFromChannels(channels)
+}
+
+// GetTopologyFromChannels builds the topology of the mesh from the resources read from the channels.
+func GetTopologyFromChannels(channels *common.ResourceChannels) (*api.Graph, error) {
+	services := <-channels.ServiceList.List
+	err := <-channels.ServiceList.Error
+	nonCriticalErrors, criticalError := errors.HandleError(err)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	virtualServices := <-channels.VirtualServiceList.List
+	err = <-channels.VirtualServiceList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	destinationRules := <-channels.DestinationRuleList.List
+	err = <-channels.DestinationRuleList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	gateways := <-channels.GatewayList.List
+	err = <-channels.GatewayList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	serviceEntries := <-channels.ServiceEntryList.List
+	err = <-channels.ServiceEntryList.Error
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	graph := BuildGraph(&Input{
+		Services:         services.Items,
+		VirtualServices:  virtualServices.Items,
+		DestinationRules: destinationRules.Items,
+		Gateways:         gateways.Items,
+		ServiceEntries:   serviceEntries.Items,
+	})
+	graph.Errors = nonCriticalErrors
+	return graph, nil
+}
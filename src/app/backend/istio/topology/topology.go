@@ -0,0 +1,376 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// meshGateway is the reserved gateway name of the sidecars inside the mesh.
+const meshGateway = "mesh"
+
+// Input is the mesh configuration the topology is built from.
+type Input struct {
+	Services         []v1.Service
+	VirtualServices  []v1alpha3.VirtualService
+	DestinationRules []v1alpha3.DestinationRule
+	Gateways         []v1alpha3.Gateway
+	ServiceEntries   []v1alpha3.ServiceEntry
+}
+
+// builder assembles the graph, resolving the hosts the virtual services refer to.
+type builder struct {
+	nodes map[string]*api.GraphNode
+	edges map[string]*api.GraphEdge
+	// hosts maps the fully qualified hosts to the IDs of the app and service entry nodes.
+	hosts map[string]string
+	// wildcards maps the suffixes of the wildcard hosts of service entries to their node IDs.
+	wildcards map[string]string
+}
+
+// BuildGraph builds the topology of the mesh. The nodes and edges are ordered by their IDs.
+func BuildGraph(input *Input) *api.Graph {
+	b := &builder{
+		nodes:     make(map[string]*api.GraphNode),
+		edges:     make(map[string]*api.GraphEdge),
+		hosts:     make(map[string]string),
+		wildcards: make(map[string]string),
+	}
+
+	for _, svc := range input.Services {
+		id := b.addNode(api.GraphNodeApp, svc.Namespace, svc.Name, b.addNamespace(svc.Namespace))
+		b.hosts[virtualservice.FQDN(svc.Name, svc.Namespace)] = id
+	}
+
+	for _, se := range input.ServiceEntries {
+		id := b.addNode(api.GraphNodeServiceEntry, se.Namespace, se.Name, b.addNamespace(se.Namespace))
+		for _, host := range se.Spec.Hosts {
+			if strings.HasPrefix(host, "*") {
+				b.wildcards[host[1:]] = id
+				continue
+			}
+			b.hosts[virtualservice.FQDN(host, se.Namespace)] = id
+			// external names are used as they are from any namespace
+			if strings.Contains(host, ".") {
+				b.hosts[host] = id
+			}
+		}
+	}
+
+	for _, dr := range input.DestinationRules {
+		appID, ok := b.lookup(dr.Spec.Host, dr.Namespace)
+		if !ok || b.nodes[appID].Kind != api.GraphNodeApp {
+			continue
+		}
+		app := b.nodes[appID]
+		for _, subset := range dr.Spec.Subsets {
+			id := b.addNode(api.GraphNodeVersion, app.Namespace, app.Name+"/"+subset.Name, appID)
+			b.nodes[id].Name = subset.Name
+		}
+	}
+
+	gateways := make(map[string]string)
+	for _, gw := range input.Gateways {
+		gateways[gw.Namespace+"/"+gw.Name] = b.addNode(api.GraphNodeGateway, gw.Namespace, gw.Name, b.addNamespace(gw.Namespace))
+	}
+
+	for i := range input.VirtualServices {
+		b.addVirtualService(&input.VirtualServices[i], gateways)
+	}
+
+	return b.graph()
+}
+
+func (self *builder) addVirtualService(vs *v1alpha3.VirtualService, gateways map[string]string) {
+	name := vs.Namespace + "/" + vs.Name
+
+	type source struct {
+		id   string
+		kind api.GraphEdgeKind
+	}
+	var sources []source
+	refs := vs.Spec.Gateways
+	if len(refs) == 0 {
+		refs = []string{meshGateway}
+	}
+	for _, ref := range refs {
+		if ref == meshGateway {
+			// the sidecars route the traffic sent to the hosts of the virtual service
+			for _, host := range vs.Spec.Hosts {
+				sources = append(sources, source{self.resolve(host, vs.Namespace), api.GraphEdgeRoute})
+			}
+			continue
+		}
+		if id, ok := gateways[gatewayKey(ref, vs.Namespace)]; ok {
+			sources = append(sources, source{id, api.GraphEdgeGateway})
+		}
+	}
+
+	for _, http := range vs.Spec.Http {
+		for _, src := range sources {
+			self.addRoute(src.id, src.kind, vs.Namespace, name, http.Route)
+			if http.Mirror != nil {
+				self.addEdge(src.id, self.destination(http.Mirror, vs.Namespace), api.GraphEdgeMirror, 0, name)
+			}
+		}
+	}
+	for _, tcp := range vs.Spec.Tcp {
+		for _, src := range sources {
+			self.addRoute(src.id, src.kind, vs.Namespace, name, tcp.Route)
+		}
+	}
+	for _, tls := range vs.Spec.Tls {
+		for _, src := range sources {
+			self.addRoute(src.id, src.kind, vs.Namespace, name, tls.Route)
+		}
+	}
+}
+
+func (self *builder) addRoute(source string, kind api.GraphEdgeKind, namespace, vs string, route []*v1alpha3.DestinationWeight) {
+	for _, dest := range route {
+		if dest.Destination == nil {
+			continue
+		}
+		weight := dest.Weight
+		// a single destination without weight receives all the traffic
+		if len(route) == 1 && weight == 0 {
+			weight = 100
+		}
+		self.addEdge(source, self.destination(dest.Destination, namespace), kind, weight, vs)
+	}
+}
+
+// destination returns the ID of the node the destination refers to, the version of the app if
+// the destination has a subset.
+func (self *builder) destination(dest *v1alpha3.Destination, namespace string) string {
+	id := self.resolve(dest.Host, namespace)
+	if dest.Subset != "" && self.nodes[id].Kind == api.GraphNodeApp {
+		if version := "version" + strings.TrimPrefix(id, "app") + "/" + dest.Subset; self.nodes[version] != nil {
+			return version
+		}
+	}
+	return id
+}
+
+// resolve returns the ID of the node of the host referred to in the namespace. Hosts neither
+// matching a service nor a service entry are added as external nodes.
+func (self *builder) resolve(host, namespace string) string {
+	if id, ok := self.lookup(host, namespace); ok {
+		return id
+	}
+	if !strings.Contains(host, ".") {
+		host = virtualservice.FQDN(host, namespace)
+	}
+	return self.addNode(api.GraphNodeExternal, "", host, "")
+}
+
+// lookup returns the ID of the app or service entry node of the host referred to in the
+// namespace. The longest wildcard matching the host wins.
+func (self *builder) lookup(host, namespace string) (string, bool) {
+	if id, ok := self.hosts[virtualservice.FQDN(host, namespace)]; ok {
+		return id, true
+	}
+	if id, ok := self.hosts[host]; ok {
+		return id, true
+	}
+	var match string
+	for suffix := range self.wildcards {
+		if strings.HasSuffix(host, suffix) && len(suffix) > len(match) {
+			match = suffix
+		}
+	}
+	if match != "" {
+		return self.wildcards[match], true
+	}
+	return "", false
+}
+
+func (self *builder) addNamespace(namespace string) string {
+	return self.addNode(api.GraphNodeNamespace, "", namespace, "")
+}
+
+// addNode adds the node unless it exists and returns its ID.
+func (self *builder) addNode(kind api.GraphNodeKind, namespace, name, parent string) string {
+	id := nodeID(kind, namespace, name)
+	if _, ok := self.nodes[id]; !ok {
+		self.nodes[id] = &api.GraphNode{
+			ID:        id,
+			Kind:      kind,
+			Name:      name,
+			Namespace: namespace,
+			Parent:    parent,
+		}
+	}
+	return id
+}
+
+// addEdge adds the edge, or records the virtual service defining it again.
+func (self *builder) addEdge(source, target string, kind api.GraphEdgeKind, weight int32, vs string) {
+	if source == target {
+		return
+	}
+	id := string(kind) + ":" + source + "->" + target
+	edge, ok := self.edges[id]
+	if !ok {
+		edge = &api.GraphEdge{ID: id, Kind: kind, Source: source, Target: target}
+		self.edges[id] = edge
+	}
+	if weight > edge.Weight {
+		edge.Weight = weight
+	}
+	for _, name := range edge.VirtualServices {
+		if name == vs {
+			return
+		}
+	}
+	edge.VirtualServices = append(edge.VirtualServices, vs)
+}
+
+func (self *builder) graph() *api.Graph {
+	graph := &api.Graph{
+		Nodes: make([]api.GraphNode, 0, len(self.nodes)),
+		Edges: make([]api.GraphEdge, 0, len(self.edges)),
+	}
+	for _, node := range self.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	for _, edge := range self.edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool { return graph.Edges[i].ID < graph.Edges[j].ID })
+	return graph
+}
+
+// Neighborhood returns the subgraph of the nodes at most depth edges away from the roots,
+// regardless of the direction of the edges. The versions of an app are at the app's distance.
+func Neighborhood(graph *api.Graph, roots []string, depth int) *api.Graph {
+	nodes := make(map[string]api.GraphNode)
+	children := make(map[string][]string)
+	for _, node := range graph.Nodes {
+		nodes[node.ID] = node
+		if node.Kind == api.GraphNodeVersion {
+			children[node.Parent] = append(children[node.Parent], node.ID)
+		}
+	}
+	adjacent := make(map[string][]string)
+	for _, edge := range graph.Edges {
+		adjacent[edge.Source] = append(adjacent[edge.Source], edge.Target)
+		adjacent[edge.Target] = append(adjacent[edge.Target], edge.Source)
+	}
+
+	visited := make(map[string]bool)
+	var frontier []string
+	visit := func(id string) {
+		// an app and its versions are visited together
+		group := []string{id}
+		if node := nodes[id]; node.Kind == api.GraphNodeVersion {
+			group = append(group, node.Parent)
+			id = node.Parent
+		}
+		group = append(group, children[id]...)
+		for _, member := range group {
+			if _, ok := nodes[member]; ok && !visited[member] {
+				visited[member] = true
+				frontier = append(frontier, member)
+			}
+		}
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		current := frontier
+		frontier = nil
+		for _, id := range current {
+			for _, next := range adjacent[id] {
+				visit(next)
+			}
+		}
+	}
+
+	// keep the compound nodes containing the visited ones
+	kept := make(map[string]bool)
+	for id := range visited {
+		for ; id != "" && !kept[id]; id = nodes[id].Parent {
+			kept[id] = true
+		}
+	}
+
+	result := &api.Graph{
+		Nodes:  make([]api.GraphNode, 0),
+		Edges:  make([]api.GraphEdge, 0),
+		Errors: graph.Errors,
+	}
+	for _, node := range graph.Nodes {
+		if kept[node.ID] {
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	for _, edge := range graph.Edges {
+		if kept[edge.Source] && kept[edge.Target] {
+			result.Edges = append(result.Edges, edge)
+		}
+	}
+	return result
+}
+
+// AppNeighborhood returns the neighborhood of the apps named appName, in any namespace of the
+// graph, up to depth edges away.
+func AppNeighborhood(graph *api.Graph, appName string, depth int) (*api.Graph, error) {
+	if depth < 0 {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("depth must not be negative, %d given", depth))
+	}
+
+	var roots []string
+	for _, node := range graph.Nodes {
+		if node.Kind == api.GraphNodeApp && node.Name == appName {
+			roots = append(roots, node.ID)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, k8sErrors.NewNotFound(schema.GroupResource{Resource: "apps"}, appName)
+	}
+	return Neighborhood(graph, roots, depth), nil
+}
+
+func nodeID(kind api.GraphNodeKind, namespace, name string) string {
+	if namespace == "" {
+		return string(kind) + "/" + name
+	}
+	return string(kind) + "/" + namespace + "/" + name
+}
+
+// gatewayKey resolves the gateway reference of a virtual service to namespace/name.
+func gatewayKey(ref, namespace string) string {
+	if strings.Contains(ref, "/") {
+		return ref
+	}
+	// <gateway>.<namespace>.svc.cluster.local is accepted by istio as well
+	if parts := strings.Split(ref, "."); len(parts) > 1 {
+		return parts[1] + "/" + parts[0]
+	}
+	return namespace + "/" + ref
+}
@@ -0,0 +1,213 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/istio/api"
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newService(name string) v1.Service {
+	return v1.Service{ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+func newVirtualService(name string, hosts, gateways []string, routes ...[]*v1alpha3.DestinationWeight) v1alpha3.VirtualService {
+	vs := v1alpha3.VirtualService{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1alpha3.VirtualServiceSpec{Hosts: hosts, Gateways: gateways},
+	}
+	for _, route := range routes {
+		vs.Spec.Http = append(vs.Spec.Http, &v1alpha3.HTTPRoute{Route: route})
+	}
+	return vs
+}
+
+func destination(host, subset string, weight int32) *v1alpha3.DestinationWeight {
+	return &v1alpha3.DestinationWeight{
+		Destination: &v1alpha3.Destination{Host: host, Subset: subset},
+		Weight:      weight,
+	}
+}
+
+func newInput() *Input {
+	reviews := newVirtualService("reviews", []string{"reviews"}, nil,
+		[]*v1alpha3.DestinationWeight{destination("reviews", "v1", 90), destination("reviews", "v2", 10)})
+	reviews.Spec.Http[0].Mirror = &v1alpha3.Destination{Host: "reviews", Subset: "v3"}
+
+	return &Input{
+		Services: []v1.Service{newService("productpage"), newService("reviews"), newService("ratings")},
+		DestinationRules: []v1alpha3.DestinationRule{{
+			ObjectMeta: metaV1.ObjectMeta{Name: "reviews", Namespace: "default"},
+			Spec: v1alpha3.DestinationRuleSpec{
+				Host:    "reviews.default.svc.cluster.local",
+				Subsets: []*v1alpha3.Subset{{Name: "v1"}, {Name: "v2"}, {Name: "v3"}},
+			},
+		}},
+		Gateways: []v1alpha3.Gateway{
+			{ObjectMeta: metaV1.ObjectMeta{Name: "bookinfo", Namespace: "istio-system"}},
+		},
+		ServiceEntries: []v1alpha3.ServiceEntry{{
+			ObjectMeta: metaV1.ObjectMeta{Name: "googleapis", Namespace: "default"},
+			Spec:       v1alpha3.ServiceEntrySpec{Hosts: []string{"*.googleapis.com"}},
+		}},
+		VirtualServices: []v1alpha3.VirtualService{
+			newVirtualService("bookinfo", []string{"bookinfo.example.com"}, []string{"istio-system/bookinfo"},
+				[]*v1alpha3.DestinationWeight{destination("productpage", "", 0)}),
+			newVirtualService("productpage", []string{"productpage"}, nil,
+				[]*v1alpha3.DestinationWeight{destination("reviews", "", 0)}),
+			reviews,
+			newVirtualService("reviews-v2", []string{"reviews"}, nil,
+				[]*v1alpha3.DestinationWeight{destination("reviews", "v1", 50), destination("reviews", "v2", 50)}),
+			newVirtualService("ratings", []string{"ratings"}, []string{"mesh"},
+				[]*v1alpha3.DestinationWeight{destination("storage.googleapis.com", "", 0)},
+				[]*v1alpha3.DestinationWeight{destination("httpbin.org", "", 0)}),
+		},
+	}
+}
+
+func nodeIDs(graph *api.Graph) []string {
+	ids := make([]string, 0)
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+func TestBuildGraph(t *testing.T) {
+	graph := BuildGraph(newInput())
+
+	expectedNodes := []string{
+		"app/default/productpage",
+		"app/default/ratings",
+		"app/default/reviews",
+		"external/httpbin.org",
+		"gateway/istio-system/bookinfo",
+		"namespace/default",
+		"namespace/istio-system",
+		"serviceentry/default/googleapis",
+		"version/default/reviews/v1",
+		"version/default/reviews/v2",
+		"version/default/reviews/v3",
+	}
+	if actual := nodeIDs(graph); !reflect.DeepEqual(actual, expectedNodes) {
+		t.Errorf("BuildGraph() nodes == \n%v\nexpected \n%v", actual, expectedNodes)
+	}
+	for _, node := range graph.Nodes {
+		if node.ID == "version/default/reviews/v1" && (node.Name != "v1" || node.Parent != "app/default/reviews") {
+			t.Errorf("BuildGraph() version node == %#v", node)
+		}
+	}
+
+	expectedEdges := []api.GraphEdge{
+		{
+			ID: "gateway:gateway/istio-system/bookinfo->app/default/productpage", Kind: api.GraphEdgeGateway,
+			Source: "gateway/istio-system/bookinfo", Target: "app/default/productpage", Weight: 100,
+			VirtualServices: []string{"default/bookinfo"},
+		},
+		{
+			ID: "mirror:app/default/reviews->version/default/reviews/v3", Kind: api.GraphEdgeMirror,
+			Source: "app/default/reviews", Target: "version/default/reviews/v3",
+			VirtualServices: []string{"default/reviews"},
+		},
+		{
+			ID: "route:app/default/productpage->app/default/reviews", Kind: api.GraphEdgeRoute,
+			Source: "app/default/productpage", Target: "app/default/reviews", Weight: 100,
+			VirtualServices: []string{"default/productpage"},
+		},
+		{
+			ID: "route:app/default/ratings->external/httpbin.org", Kind: api.GraphEdgeRoute,
+			Source: "app/default/ratings", Target: "external/httpbin.org", Weight: 100,
+			VirtualServices: []string{"default/ratings"},
+		},
+		{
+			ID: "route:app/default/ratings->serviceentry/default/googleapis", Kind: api.GraphEdgeRoute,
+			Source: "app/default/ratings", Target: "serviceentry/default/googleapis", Weight: 100,
+			VirtualServices: []string{"default/ratings"},
+		},
+		{
+			ID: "route:app/default/reviews->version/default/reviews/v1", Kind: api.GraphEdgeRoute,
+			Source: "app/default/reviews", Target: "version/default/reviews/v1", Weight: 90,
+			VirtualServices: []string{"default/reviews", "default/reviews-v2"},
+		},
+		{
+			ID: "route:app/default/reviews->version/default/reviews/v2", Kind: api.GraphEdgeRoute,
+			Source: "app/default/reviews", Target: "version/default/reviews/v2", Weight: 50,
+			VirtualServices: []string{"default/reviews", "default/reviews-v2"},
+		},
+	}
+	if !reflect.DeepEqual(graph.Edges, expectedEdges) {
+		t.Errorf("BuildGraph() edges == \n%#v\nexpected \n%#v", graph.Edges, expectedEdges)
+	}
+}
+
+func TestAppNeighborhood(t *testing.T) {
+	graph := BuildGraph(newInput())
+
+	cases := []struct {
+		app      string
+		depth    int
+		expected []string
+	}{
+		{
+			"reviews", 0,
+			[]string{"app/default/reviews", "namespace/default", "version/default/reviews/v1",
+				"version/default/reviews/v2", "version/default/reviews/v3"},
+		},
+		{
+			"reviews", 1,
+			[]string{"app/default/productpage", "app/default/reviews", "namespace/default",
+				"version/default/reviews/v1", "version/default/reviews/v2", "version/default/reviews/v3"},
+		},
+		{
+			"reviews", 2,
+			[]string{"app/default/productpage", "app/default/reviews", "gateway/istio-system/bookinfo",
+				"namespace/default", "namespace/istio-system", "version/default/reviews/v1",
+				"version/default/reviews/v2", "version/default/reviews/v3"},
+		},
+		{
+			"ratings", 1,
+			[]string{"app/default/ratings", "external/httpbin.org", "namespace/default",
+				"serviceentry/default/googleapis"},
+		},
+	}
+	for _, c := range cases {
+		actual, err := AppNeighborhood(graph, c.app, c.depth)
+		if err != nil {
+			t.Errorf("AppNeighborhood(%s, %d) returned error: %v", c.app, c.depth, err)
+			continue
+		}
+		if ids := nodeIDs(actual); !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("AppNeighborhood(%s, %d) nodes == \n%v\nexpected \n%v", c.app, c.depth, ids, c.expected)
+		}
+		for _, edge := range actual.Edges {
+			if edge.Source == "gateway/istio-system/bookinfo" && c.depth < 2 {
+				t.Errorf("AppNeighborhood(%s, %d) kept edge %s", c.app, c.depth, edge.ID)
+			}
+		}
+	}
+
+	if _, err := AppNeighborhood(graph, "details", 1); !k8sErrors.IsNotFound(err) {
+		t.Errorf("AppNeighborhood(details) == %v, expected not found", err)
+	}
+	if _, err := AppNeighborhood(graph, "reviews", -1); !k8sErrors.IsBadRequest(err) {
+		t.Errorf("AppNeighborhood(reviews, -1) == %v, expected bad request", err)
+	}
+}
@@ -65,7 +65,7 @@ type DaemonSetDetail struct {
 
 // Returns detailed information about the given daemon set in the given namespace.
 func GetDaemonSetDetail(client k8sClient.Interface, metricClient metricapi.MetricClient,
-	namespace, name string) (*DaemonSetDetail, error) {
+	namespace, name string, metricQuery *ds.MetricQuery) (*DaemonSetDetail, error) {
 
 	log.Printf("Getting details of %s daemon set in %s namespace", name, namespace)
 	daemonSet, err := client.AppsV1beta2().DaemonSets(namespace).Get(name, metaV1.GetOptions{})
@@ -73,7 +73,7 @@ func GetDaemonSetDetail(client k8sClient.Interface, metricClient metricapi.Metri
 		return nil, err
 	}
 
-	podList, err := GetDaemonSetPods(client, metricClient, ds.DefaultDataSelectWithMetrics, name, namespace)
+	podList, err := GetDaemonSetPods(client, metricClient, ds.NewDefaultDataSelectWithMetrics(metricQuery), name, namespace)
 	nonCriticalErrors, criticalError := errors.HandleError(err)
 	if criticalError != nil {
 		return nil, criticalError
@@ -149,8 +149,16 @@ func (self *DataSelector) getMetrics(metricClient metricapi.MetricClient) (
 		selectors[i] = *metricDataCell.GetResourceSelector()
 	}
 
+	// The native resources of a data cell, e.g. the pods of a deployment, are combined with the
+	// first requested aggregation, the remaining ones only apply to the cumulative metrics.
+	aggregation := metricapi.AggregationMode(metricapi.DefaultAggregation)
+	if aggregations := self.DataSelectQuery.MetricQuery.Aggregations; len(aggregations) > 0 {
+		aggregation = aggregations[0]
+	}
+
 	for _, metricName := range metricNames {
-		promises := metricClient.DownloadMetric(selectors, metricName, self.CachedResources)
+		promises := metricClient.DownloadMetric(selectors, metricName,
+			self.DataSelectQuery.MetricQuery.TimeRange, aggregation, self.CachedResources)
 		metricPromises = append(metricPromises, promises)
 	}
 
@@ -17,6 +17,9 @@ package dataselect
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
 )
 
 type PaginationTestCase struct {
@@ -222,3 +225,29 @@ func TestPagination(t *testing.T) {
 	}
 
 }
+
+func TestNewStandardMetricQuery(t *testing.T) {
+	timeRange := metricapi.TimeRange{Start: time.Unix(1500000000, 0), Resolution: 5 * time.Minute}
+	requested := NewMetricQuery(nil, metricapi.AggregationModes{metricapi.P90Aggregation})
+	requested.TimeRange = timeRange
+
+	cases := []struct {
+		info     string
+		query    *MetricQuery
+		expected *MetricQuery
+	}{
+		{"no query", nil, StandardMetrics},
+		{"no aggregations", NewMetricQuery(nil, metricapi.AggregationModes{}), StandardMetrics},
+		{"aggregations and time range", requested, &MetricQuery{
+			MetricNames:  StandardMetrics.MetricNames,
+			Aggregations: metricapi.AggregationModes{metricapi.P90Aggregation},
+			TimeRange:    timeRange,
+		}},
+	}
+	for _, c := range cases {
+		actual := NewStandardMetricQuery(c.query)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Test Case: %s. NewStandardMetricQuery() == %#v, expected %#v", c.info, actual, c.expected)
+		}
+	}
+}
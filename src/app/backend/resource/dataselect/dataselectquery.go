@@ -38,6 +38,7 @@ var StandardMetrics = NewMetricQuery([]string{metricapi.CpuUsage, metricapi.Memo
 // MetricQuery holds parameters for metric extraction process.
 // It accepts list of metrics to be downloaded and a list of aggregations that should be performed for each metric.
 // Query has this format  metrics=metric1,metric2,...&aggregations=aggregation1,aggregation2,...
+// and may limit the time range with start=...&end=...&resolution=...
 type MetricQuery struct {
 	// Metrics to download, all available metric names can be found here:
 	// https://github.com/kubernetes/heapster/blob/master/docs/storage-schema.md
@@ -45,6 +46,9 @@ type MetricQuery struct {
 	// Aggregations to be performed for each metric. Check available aggregations in aggregation.go.
 	// If empty, default aggregation will be used (sum).
 	Aggregations metricapi.AggregationModes
+	// TimeRange holds the start, end and resolution of the downloaded metrics. If empty, the
+	// default window of the metric client will be used.
+	metricapi.TimeRange
 }
 
 // NewMetricQuery returns a metric query from provided settings.
@@ -55,6 +59,20 @@ func NewMetricQuery(metricNames []string, aggregations metricapi.AggregationMode
 	}
 }
 
+// NewStandardMetricQuery returns a query of the standard metrics, which keeps the aggregations and
+// the time range requested by given query, if any.
+func NewStandardMetricQuery(query *MetricQuery) *MetricQuery {
+	result := NewMetricQuery(StandardMetrics.MetricNames, StandardMetrics.Aggregations)
+	if query == nil {
+		return result
+	}
+	if len(query.Aggregations) > 0 {
+		result.Aggregations = query.Aggregations
+	}
+	result.TimeRange = query.TimeRange
+	return result
+}
+
 // SortQuery holds options for sort functionality of data select.
 type SortQuery struct {
 	SortByList []SortBy
@@ -96,6 +114,12 @@ var DefaultDataSelect = NewDataSelectQuery(DefaultPagination, NoSort, NoFilter,
 // DefaultDataSelectWithMetrics downloads first 10 items from page 1 with no sort. Also downloads and includes standard metrics.
 var DefaultDataSelectWithMetrics = NewDataSelectQuery(DefaultPagination, NoSort, NoFilter, StandardMetrics)
 
+// NewDefaultDataSelectWithMetrics downloads first 10 items from page 1 with no sort. Also downloads
+// and includes the metrics of given query.
+func NewDefaultDataSelectWithMetrics(metricQuery *MetricQuery) *DataSelectQuery {
+	return NewDataSelectQuery(DefaultPagination, NoSort, NoFilter, metricQuery)
+}
+
 // NewDataSelectQuery creates DataSelectQuery object from simpler data select queries.
 func NewDataSelectQuery(paginationQuery *PaginationQuery, sortQuery *SortQuery, filterQuery *FilterQuery, graphQuery *MetricQuery) *DataSelectQuery {
 	return &DataSelectQuery{
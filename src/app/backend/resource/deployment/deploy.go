@@ -309,64 +309,82 @@ func getLabelsMap(labels []Label) map[string]string {
 
 // DeployAppFromFile deploys an app based on the given yaml or json file.
 func DeployAppFromFile(cfg *rest.Config, spec *AppDeploymentFromFileSpec) (bool, error) {
-	reader := strings.NewReader(spec.Content)
 	log.Printf("Namespace for deploy from file: %s\n", spec.Namespace)
-	d := yaml.NewYAMLOrJSONDecoder(reader, 4096)
-	for {
-		data := unstructured.Unstructured{}
-		if err := d.Decode(&data); err != nil {
-			if err == io.EOF {
-				return true, nil
-			}
-			return false, err
+	err := DecodeObjects(spec.Content, func(data *unstructured.Unstructured) error {
+		groupVersionResource, err := ResourceFor(cfg, data)
+		if err != nil {
+			return err
 		}
 
-		version := data.GetAPIVersion()
-		kind := data.GetKind()
-
-		gv, err := schema.ParseGroupVersion(version)
+		dynamicClient, err := dynamic.NewForConfig(cfg)
 		if err != nil {
-			gv = schema.GroupVersion{Version: version}
+			return err
 		}
 
-		discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
-		if err != nil {
-			return false, err
+		if strings.Compare(spec.Namespace, "_all") == 0 {
+			_, err = dynamicClient.Resource(groupVersionResource).Namespace(data.GetNamespace()).Create(data, metaV1.CreateOptions{})
+		} else {
+			_, err = dynamicClient.Resource(groupVersionResource).Namespace(spec.Namespace).Create(data, metaV1.CreateOptions{})
 		}
 
-		apiResourceList, err := discoveryClient.ServerResourcesForGroupVersion(version)
 		if err != nil {
-			return false, err
+			return errors.LocalizeError(err)
 		}
-		apiResources := apiResourceList.APIResources
-		var resource *metaV1.APIResource
-		for _, apiResource := range apiResources {
-			if apiResource.Kind == kind && !strings.Contains(apiResource.Name, "/") {
-				resource = &apiResource
-				break
+		return nil
+	})
+	return err == nil, err
+}
+
+// DecodeObjects decodes the yaml or json documents of the content one by one and calls fn with
+// each of them. It stops at the first error.
+func DecodeObjects(content string, fn func(data *unstructured.Unstructured) error) error {
+	d := yaml.NewYAMLOrJSONDecoder(strings.NewReader(content), 4096)
+	for {
+		data := unstructured.Unstructured{}
+		if err := d.Decode(&data); err != nil {
+			if err == io.EOF {
+				return nil
 			}
+			return err
 		}
-		if resource == nil {
-			return false, fmt.Errorf("Unknown resource kind: %s", kind)
+		if err := fn(&data); err != nil {
+			return err
 		}
+	}
+}
 
-		dynamicClient, err := dynamic.NewForConfig(cfg)
-		if err != nil {
-			return false, err
-		}
+// ResourceFor discovers the resource serving the kind of the object.
+func ResourceFor(cfg *rest.Config, data *unstructured.Unstructured) (schema.GroupVersionResource, error) {
+	version := data.GetAPIVersion()
+	kind := data.GetKind()
 
-		groupVersionResource := schema.GroupVersionResource{Group: gv.Group, Version: gv.Version, Resource: resource.Name}
+	gv, err := schema.ParseGroupVersion(version)
+	if err != nil {
+		gv = schema.GroupVersion{Version: version}
+	}
 
-		if strings.Compare(spec.Namespace, "_all") == 0 {
-			_, err = dynamicClient.Resource(groupVersionResource).Namespace(data.GetNamespace()).Create(&data, metaV1.CreateOptions{})
-		} else {
-			_, err = dynamicClient.Resource(groupVersionResource).Namespace(spec.Namespace).Create(&data, metaV1.CreateOptions{})
-		}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
 
-		if err != nil {
-			return false, errors.LocalizeError(err)
+	apiResourceList, err := discoveryClient.ServerResourcesForGroupVersion(version)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	apiResources := apiResourceList.APIResources
+	var resource *metaV1.APIResource
+	for _, apiResource := range apiResources {
+		if apiResource.Kind == kind && !strings.Contains(apiResource.Name, "/") {
+			resource = &apiResource
+			break
 		}
 	}
+	if resource == nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("Unknown resource kind: %s", kind)
+	}
+
+	return schema.GroupVersionResource{Group: gv.Group, Version: gv.Version, Resource: resource.Name}, nil
 }
 
 func Redeploy(client client.Interface, namespace, deploymentName string) error {
@@ -375,11 +393,7 @@ func Redeploy(client client.Interface, namespace, deploymentName string) error {
 		return err
 	}
 
-	if val, ok := dp.Spec.Template.Annotations[ReDeployCountKey]; ok {
-		dp.Spec.Template.Annotations[ReDeployCountKey] = reDeployCounter(val)
-	} else {
-		dp.Spec.Template.Annotations = map[string]string{ReDeployCountKey: "1"}
-	}
+	BumpRedeployCount(&dp.Spec.Template)
 
 	_, err = client.AppsV1().Deployments(namespace).Update(dp)
 	if err != nil {
@@ -389,6 +403,19 @@ func Redeploy(client client.Interface, namespace, deploymentName string) error {
 	return nil
 }
 
+// BumpRedeployCount changes the pod template so that the pods are replaced by a rolling update,
+// the other annotations of the template are kept.
+func BumpRedeployCount(template *api.PodTemplateSpec) {
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	if val, ok := template.Annotations[ReDeployCountKey]; ok {
+		template.Annotations[ReDeployCountKey] = reDeployCounter(val)
+	} else {
+		template.Annotations[ReDeployCountKey] = "1"
+	}
+}
+
 func reDeployCounter(val string) string {
 	now, err := strconv.Atoi(val)
 	if err != nil {
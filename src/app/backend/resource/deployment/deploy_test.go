@@ -186,3 +186,28 @@ func TestGetAvailableProtocols(t *testing.T) {
 			expected, actual)
 	}
 }
+
+func TestBumpRedeployCount(t *testing.T) {
+	cases := []struct {
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		{nil, map[string]string{ReDeployCountKey: "1"}},
+		{
+			map[string]string{"sidecar.istio.io/inject": "true"},
+			map[string]string{"sidecar.istio.io/inject": "true", ReDeployCountKey: "1"},
+		},
+		{
+			map[string]string{"sidecar.istio.io/inject": "false", ReDeployCountKey: "2"},
+			map[string]string{"sidecar.istio.io/inject": "false", ReDeployCountKey: "3"},
+		},
+	}
+	for _, c := range cases {
+		template := &api.PodTemplateSpec{}
+		template.Annotations = c.annotations
+		BumpRedeployCount(template)
+		if !reflect.DeepEqual(template.Annotations, c.expected) {
+			t.Errorf("BumpRedeployCount(%v) == %v, expected %v", c.annotations, template.Annotations, c.expected)
+		}
+	}
+}
@@ -101,7 +101,7 @@ type DeploymentDetail struct {
 
 // GetDeploymentDetail returns model object of deployment and error, if any.
 func GetDeploymentDetail(client client.Interface, metricClient metricapi.MetricClient, namespace string,
-	deploymentName string) (*DeploymentDetail, error) {
+	deploymentName string, metricQuery *dataselect.MetricQuery) (*DeploymentDetail, error) {
 
 	log.Printf("Getting details of %s deployment in %s namespace", deploymentName, namespace)
 
@@ -137,7 +137,7 @@ func GetDeploymentDetail(client client.Interface, metricClient metricapi.MetricC
 		return nil, criticalError
 	}
 
-	podList, err := GetDeploymentPods(client, metricClient, dataselect.DefaultDataSelectWithMetrics, namespace, deploymentName)
+	podList, err := GetDeploymentPods(client, metricClient, dataselect.NewDefaultDataSelectWithMetrics(metricQuery), namespace, deploymentName)
 	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
 	if criticalError != nil {
 		return nil, criticalError
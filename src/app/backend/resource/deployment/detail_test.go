@@ -164,7 +164,7 @@ func TestGetDeploymentDetail(t *testing.T) {
 	for _, c := range cases {
 		fakeClient := fake.NewSimpleClientset(c.deployment, replicaSetList, podList, eventList)
 		dataselect.DefaultDataSelectWithMetrics.MetricQuery = dataselect.NoMetrics
-		actual, _ := GetDeploymentDetail(fakeClient, nil, c.namespace, c.name)
+		actual, _ := GetDeploymentDetail(fakeClient, nil, c.namespace, c.name, dataselect.NoMetrics)
 
 		actions := fakeClient.Actions()
 		if len(actions) != len(c.expectedActions) {
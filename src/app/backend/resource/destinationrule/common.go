@@ -15,6 +15,8 @@
 package destinationrule
 
 import (
+	"fmt"
+
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
 	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
@@ -22,9 +24,30 @@ import (
 
 type DestinationRuleCell istioApi.DestinationRule
 
+// TLSModes maps the names of the TLS modes of the traffic policies to their values.
+var TLSModes = map[string]int32{
+	"DISABLE":      0,
+	"SIMPLE":       1,
+	"MUTUAL":       2,
+	"ISTIO_MUTUAL": 3,
+}
+
+// TLSModeName returns the name of the TLS mode of the traffic policy, DISABLE when it has none.
+func TLSModeName(tls *istioApi.TLSSettings) string {
+	if tls == nil {
+		return "DISABLE"
+	}
+	for name, value := range TLSModes {
+		if value == tls.Mode {
+			return name
+		}
+	}
+	return fmt.Sprint(tls.Mode)
+}
+
 // ToDestinationRuleDetail returns api service object based on kubernetes destination rule object
 func ToDestinationRuleDetail(d *istioApi.DestinationRule, nonCriticalErrors []error) *DestinationRuleDetail {
-	return &DestinationRuleDetail{
+	detail := &DestinationRuleDetail{
 		ObjectMeta:    api.NewObjectMeta(d.ObjectMeta),
 		TypeMeta:      api.NewTypeMeta(api.ResourceKindDestinationRule),
 		Host:          d.Spec.Host,
@@ -32,6 +55,10 @@ func ToDestinationRuleDetail(d *istioApi.DestinationRule, nonCriticalErrors []er
 		Subsets:       d.Spec.Subsets,
 		Errors:        nonCriticalErrors,
 	}
+	if d.Spec.TrafficPolicy != nil {
+		detail.TLSMode = TLSModeName(d.Spec.TrafficPolicy.Tls)
+	}
+	return detail
 }
 
 func (self DestinationRuleCell) GetProperty(name dataselect.PropertyName) dataselect.ComparableValue {
@@ -30,6 +30,9 @@ type DestinationRuleDetail struct {
 	Host          string                  `json:"host,omitempty"`
 	TrafficPolicy *istioApi.TrafficPolicy `json:"traffic_policy,omitempty"`
 	Subsets       []*istioApi.Subset      `json:"subsets,omitempty"`
+	// TLSMode is the TLS mode the clients originate the requests to the host with, empty when
+	// the rule has no traffic policy.
+	TLSMode string `json:"tlsMode,omitempty"`
 
 	// List of non-critical errors, that occurred during resource retrieval.
 	Errors []error `json:"errors"`
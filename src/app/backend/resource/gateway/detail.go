@@ -15,6 +15,8 @@
 package gateway
 
 import (
+	"log"
+
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
 	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
@@ -35,6 +37,10 @@ type Gateway struct {
 	// all reachable namespaces.
 	Selector  map[string]string  `json:"selector,omitempty"`
 	Endpoints []*common.Endpoint `json:"endpoints"`
+	// The certificates served by the TLS servers, only filled in detail view.
+	Certificates []ServerCertificate `json:"certificates,omitempty"`
+	// CertificatesError is set when the certificates can't be inspected, only filled in detail view.
+	CertificatesError string `json:"certificatesError,omitempty"`
 }
 
 type Server struct {
@@ -55,5 +61,14 @@ func GetGateway(k8sClient kubernetes.Interface, istioClient istio.Interface, nam
 		return nil, err
 	}
 
-	return ToGateway(gateway), nil
+	result := ToGateway(gateway)
+	// the certificates are informative, the detail is returned with the error when they can't be read
+	certificates, err := GetServerCertificates(k8sClient, gateway)
+	if err != nil {
+		log.Printf("Failed to inspect certificates of %s gateway in %s namespace: %v", name, namespace, err)
+		result.CertificatesError = err.Error()
+	} else {
+		result.Certificates = certificates
+	}
+	return result, nil
 }
@@ -0,0 +1,256 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/cert"
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IngressGatewayNamespace is the namespace the istio ingress gateway workload is installed in.
+const IngressGatewayNamespace = "istio-system"
+
+// CertificateExpiryWarning is how long before its expiry a certificate is reported as expiring.
+const CertificateExpiryWarning = 30 * 24 * time.Hour
+
+// tlsPassthrough is the istio.networking.v1alpha3.Server_TLSOptions_TLSmode of the servers which
+// don't terminate TLS.
+const tlsPassthrough = 0
+
+// ServerCertificate is the certificate a TLS server of a gateway serves.
+type ServerCertificate struct {
+	Port  *istioApi.Port `json:"port"`
+	Hosts []string       `json:"hosts"`
+	// ServerCertificate is the file the gateway workload reads the certificate from.
+	ServerCertificate string `json:"serverCertificate"`
+	// Secret is the namespace/name of the secret mounted at the certificate's path.
+	Secret string `json:"secret,omitempty"`
+	// Chain is the certificate chain, the leaf certificate comes first.
+	Chain []Certificate `json:"chain,omitempty"`
+	// Warnings are the problems of the certificate, i.e. it is about to expire.
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is set when the certificate can't be read.
+	Error string `json:"error,omitempty"`
+}
+
+// Certificate describes a x509 certificate.
+type Certificate struct {
+	Subject     string      `json:"subject"`
+	Issuer      string      `json:"issuer"`
+	DNSNames    []string    `json:"dnsNames,omitempty"`
+	IPAddresses []string    `json:"ipAddresses,omitempty"`
+	NotBefore   metaV1.Time `json:"notBefore"`
+	NotAfter    metaV1.Time `json:"notAfter"`
+}
+
+// GetServerCertificates inspects the certificates of the gateway's TLS servers. The istio
+// gateway reads them from files, they are resolved to the secrets the gateway workload mounts.
+// The workload is looked up among the running pods of the ingress gateway namespace and of the
+// gateway's own namespace.
+func GetServerCertificates(k8sClient kubernetes.Interface, gateway *istioApi.Gateway) ([]ServerCertificate, error) {
+	var servers []*istioApi.Server
+	for _, server := range gateway.Spec.Servers {
+		if server.Tls != nil && server.Tls.Mode != tlsPassthrough && server.Tls.ServerCertificate != "" {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, nil
+	}
+
+	// an empty selector matches every pod, any of them would be taken for the gateway workload
+	if len(gateway.Spec.Selector) == 0 {
+		return nil, k8sErrors.NewBadRequest(fmt.Sprintf("gateway %s/%s has no selector", gateway.Namespace, gateway.Name))
+	}
+	selector, err := metaV1.LabelSelectorAsSelector(&metaV1.LabelSelector{MatchLabels: gateway.Spec.Selector})
+	if err != nil {
+		return nil, err
+	}
+	pod, err := findGatewayPod(k8sClient, gateway.Namespace, selector.String())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	certificates := make([]ServerCertificate, 0, len(servers))
+	for _, server := range servers {
+		certificate := ServerCertificate{
+			Port:              server.Port,
+			Hosts:             server.Hosts,
+			ServerCertificate: server.Tls.ServerCertificate,
+		}
+		if pod == nil {
+			certificate.Error = fmt.Sprintf("no running gateway workload matches selector %s", selector)
+			certificates = append(certificates, certificate)
+			continue
+		}
+
+		secretName, key, ok := FindMountedSecret(pod, server.Tls.ServerCertificate)
+		if !ok {
+			certificate.Error = fmt.Sprintf("%s is not mounted from a secret by gateway workload %s/%s",
+				server.Tls.ServerCertificate, pod.Namespace, pod.Name)
+			certificates = append(certificates, certificate)
+			continue
+		}
+		certificate.Secret = pod.Namespace + "/" + secretName
+
+		secret, err := k8sClient.CoreV1().Secrets(pod.Namespace).Get(secretName, metaV1.GetOptions{})
+		if err != nil {
+			log.Printf("Failed to get secret %s of gateway %s: %v", certificate.Secret, gateway.Name, err)
+			certificate.Error = err.Error()
+			certificates = append(certificates, certificate)
+			continue
+		}
+		inspectCertificate(&certificate, secret.Data[key], now)
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// findGatewayPod returns a running pod matching the selector in the ingress gateway namespace, or
+// in the gateway's namespace when there is none. Nil is returned when no running pod matches.
+func findGatewayPod(k8sClient kubernetes.Interface, namespace string, selector string) (*v1.Pod, error) {
+	namespaces := []string{IngressGatewayNamespace}
+	if namespace != IngressGatewayNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+
+	for _, ns := range namespaces {
+		pods, err := k8sClient.CoreV1().Pods(ns).List(metaV1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			if pods.Items[i].Status.Phase == v1.PodRunning {
+				return &pods.Items[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// inspectCertificate parses the PEM encoded chain and warns when the leaf certificate doesn't
+// cover the server's hosts or when a certificate of the chain is expired or about to expire.
+func inspectCertificate(certificate *ServerCertificate, data []byte, now time.Time) {
+	chain, err := cert.ParseCertificates(data)
+	if err != nil {
+		certificate.Error = fmt.Sprintf("failed to parse certificate: %v", err)
+		return
+	}
+
+	for _, c := range chain {
+		ips := make([]string, 0, len(c.IPAddresses))
+		for _, ip := range c.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		certificate.Chain = append(certificate.Chain, Certificate{
+			Subject:     c.Subject.String(),
+			Issuer:      c.Issuer.String(),
+			DNSNames:    c.DNSNames,
+			IPAddresses: ips,
+			NotBefore:   metaV1.NewTime(c.NotBefore),
+			NotAfter:    metaV1.NewTime(c.NotAfter),
+		})
+
+		switch {
+		case now.After(c.NotAfter):
+			certificate.Warnings = append(certificate.Warnings, fmt.Sprintf("certificate %s expired on %s",
+				c.Subject, c.NotAfter.Format(time.RFC3339)))
+		case c.NotAfter.Sub(now) < CertificateExpiryWarning:
+			certificate.Warnings = append(certificate.Warnings, fmt.Sprintf("certificate %s expires in %d days, on %s",
+				c.Subject, int(c.NotAfter.Sub(now).Hours()/24), c.NotAfter.Format(time.RFC3339)))
+		}
+	}
+
+	for _, host := range certificate.Hosts {
+		if host == "*" {
+			continue
+		}
+		if !cert.CoversHost(chain[0], host) {
+			certificate.Warnings = append(certificate.Warnings, fmt.Sprintf("certificate %s does not cover host %s",
+				chain[0].Subject, host))
+		}
+	}
+}
+
+// FindSecretMount returns the path the secret is mounted at by the pod's containers.
+func FindSecretMount(pod *v1.Pod, secret string) string {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret == nil || volume.Secret.SecretName != secret {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == volume.Name {
+					return mount.MountPath
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// FindMountedSecret returns the secret and its key the pod's containers read the file from.
+func FindMountedSecret(pod *v1.Pod, file string) (string, string, bool) {
+	volumes := make(map[string]*v1.SecretVolumeSource)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil {
+			volumes[volume.Name] = volume.Secret
+		}
+	}
+
+	for _, container := range pod.Spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			source, ok := volumes[mount.Name]
+			if !ok {
+				continue
+			}
+
+			var path string
+			if mount.SubPath != "" {
+				if file != mount.MountPath {
+					continue
+				}
+				path = mount.SubPath
+			} else {
+				dir := strings.TrimSuffix(mount.MountPath, "/") + "/"
+				if !strings.HasPrefix(file, dir) {
+					continue
+				}
+				path = strings.TrimPrefix(file, dir)
+			}
+
+			// the keys are mounted with their names unless the volume projects them to other paths
+			if len(source.Items) == 0 {
+				return source.SecretName, path, true
+			}
+			for _, item := range source.Items {
+				if item.Path == path {
+					return source.SecretName, item.Key, true
+				}
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,329 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newCertificatePEM(t *testing.T, commonName string, dnsNames []string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestFindMountedSecret(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{
+				{Name: "certs", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "ingress-certs"}}},
+				{Name: "ca", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{
+					SecretName: "ingress-ca",
+					Items:      []v1.KeyToPath{{Key: "ca.crt", Path: "ca-chain.cert.pem"}},
+				}}},
+				{Name: "config", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			},
+			Containers: []v1.Container{{
+				VolumeMounts: []v1.VolumeMount{
+					{Name: "certs", MountPath: "/etc/istio/ingressgateway-certs/"},
+					{Name: "ca", MountPath: "/etc/istio/ingressgateway-ca-certs"},
+					{Name: "certs", MountPath: "/etc/certs/server.pem", SubPath: "server.crt"},
+					{Name: "config", MountPath: "/etc/istio/config"},
+				},
+			}},
+		},
+	}
+
+	cases := []struct {
+		file   string
+		secret string
+		key    string
+		found  bool
+	}{
+		{"/etc/istio/ingressgateway-certs/tls.crt", "ingress-certs", "tls.crt", true},
+		{"/etc/istio/ingressgateway-ca-certs/ca-chain.cert.pem", "ingress-ca", "ca.crt", true},
+		{"/etc/istio/ingressgateway-ca-certs/tls.crt", "", "", false},
+		{"/etc/certs/server.pem", "ingress-certs", "server.crt", true},
+		{"/etc/istio/config/tls.crt", "", "", false},
+		{"/etc/istio/ingressgateway-certs-other/tls.crt", "", "", false},
+	}
+	for _, c := range cases {
+		secret, key, found := FindMountedSecret(pod, c.file)
+		if secret != c.secret || key != c.key || found != c.found {
+			t.Errorf("FindMountedSecret(%s) == %s, %s, %v, expected %s, %s, %v",
+				c.file, secret, key, found, c.secret, c.key, c.found)
+		}
+	}
+}
+
+func TestInspectCertificate(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		info     string
+		hosts    []string
+		data     []byte
+		subjects []string
+		warnings []string
+		err      string
+	}{
+		{
+			"valid certificate covering the hosts",
+			[]string{"bookinfo.example.com", "*.example.com", "*"},
+			newCertificatePEM(t, "bookinfo", []string{"bookinfo.example.com", "*.example.com"}, now.AddDate(1, 0, 0)),
+			[]string{"CN=bookinfo"},
+			nil,
+			"",
+		},
+		{
+			"chain with an expiring certificate not covering the hosts",
+			[]string{"bookinfo.example.com", "*.example.com", "reviews.example.org"},
+			append(newCertificatePEM(t, "leaf", []string{"*.example.com"}, now.AddDate(0, 0, 10)),
+				newCertificatePEM(t, "ca", nil, now.AddDate(0, 0, -1))...),
+			[]string{"CN=leaf", "CN=ca"},
+			[]string{
+				"certificate CN=leaf expires in 10 days",
+				"certificate CN=ca expired on",
+				"certificate CN=leaf does not cover host reviews.example.org",
+			},
+			"",
+		},
+		{
+			"no certificate",
+			[]string{"bookinfo.example.com"},
+			[]byte("not a certificate"),
+			nil,
+			nil,
+			"failed to parse certificate: no PEM encoded certificate found",
+		},
+	}
+
+	for _, c := range cases {
+		certificate := &ServerCertificate{Hosts: c.hosts}
+		inspectCertificate(certificate, c.data, now)
+
+		var subjects []string
+		for _, cert := range certificate.Chain {
+			subjects = append(subjects, cert.Subject)
+		}
+		if !reflect.DeepEqual(subjects, c.subjects) {
+			t.Errorf("%s: chain subjects == %v, expected %v", c.info, subjects, c.subjects)
+		}
+		if len(certificate.Warnings) != len(c.warnings) {
+			t.Errorf("%s: warnings == %v, expected %v", c.info, certificate.Warnings, c.warnings)
+		} else {
+			for i, warning := range c.warnings {
+				if !strings.HasPrefix(certificate.Warnings[i], warning) {
+					t.Errorf("%s: warning %d == %q, expected to start with %q", c.info, i, certificate.Warnings[i], warning)
+				}
+			}
+		}
+		if certificate.Error != c.err {
+			t.Errorf("%s: error == %q, expected %q", c.info, certificate.Error, c.err)
+		}
+	}
+}
+
+func TestGetServerCertificates(t *testing.T) {
+	selector := map[string]string{"istio": "ingressgateway"}
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "istio-ingressgateway-0", Namespace: "istio-system", Labels: selector},
+			Spec: v1.PodSpec{
+				Volumes: []v1.Volume{{Name: "certs", VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{SecretName: "istio-ingressgateway-certs"}}}},
+				Containers: []v1.Container{{
+					VolumeMounts: []v1.VolumeMount{{Name: "certs", MountPath: "/etc/istio/ingressgateway-certs"}},
+				}},
+			},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		},
+		&v1.Secret{
+			ObjectMeta: metaV1.ObjectMeta{Name: "istio-ingressgateway-certs", Namespace: "istio-system"},
+			Data: map[string][]byte{
+				"tls.crt": newCertificatePEM(t, "bookinfo", []string{"bookinfo.example.com"}, time.Now().AddDate(1, 0, 0)),
+			},
+		},
+	)
+
+	port := &istioApi.Port{Number: 443, Protocol: "HTTPS", Name: "https"}
+	gateway := &istioApi.Gateway{
+		ObjectMeta: metaV1.ObjectMeta{Name: "bookinfo", Namespace: "default"},
+		Spec: istioApi.GatewaySpec{
+			Selector: selector,
+			Servers: []*istioApi.Server{
+				{Port: &istioApi.Port{Number: 80, Protocol: "HTTP", Name: "http"}, Hosts: []string{"*"}},
+				{
+					Port:  port,
+					Hosts: []string{"bookinfo.example.com"},
+					Tls: &istioApi.Server_TLSOptions{
+						Mode:              1,
+						ServerCertificate: "/etc/istio/ingressgateway-certs/tls.crt",
+						PrivateKey:        "/etc/istio/ingressgateway-certs/tls.key",
+					},
+				},
+				{
+					Port:  &istioApi.Port{Number: 8443, Protocol: "HTTPS", Name: "https-other"},
+					Hosts: []string{"other.example.com"},
+					Tls: &istioApi.Server_TLSOptions{
+						Mode:              1,
+						ServerCertificate: "/etc/certs/other.crt",
+					},
+				},
+			},
+		},
+	}
+
+	certificates, err := GetServerCertificates(client, gateway)
+	if err != nil {
+		t.Fatalf("GetServerCertificates() returned error: %v", err)
+	}
+	if len(certificates) != 2 {
+		t.Fatalf("GetServerCertificates() returned %d certificates, expected 2", len(certificates))
+	}
+
+	served := certificates[0]
+	if served.Port != port || served.Secret != "istio-system/istio-ingressgateway-certs" || served.Error != "" ||
+		len(served.Chain) != 1 || served.Chain[0].Subject != "CN=bookinfo" || len(served.Warnings) != 0 {
+		t.Errorf("GetServerCertificates() certificate of port 443 == %#v", served)
+	}
+	if certificates[1].Error == "" || len(certificates[1].Chain) != 0 {
+		t.Errorf("GetServerCertificates() expected error for the unmounted certificate, got %#v", certificates[1])
+	}
+}
+
+func TestGetServerCertificatesWorkload(t *testing.T) {
+	tlsServer := &istioApi.Server{
+		Port:  &istioApi.Port{Number: 443, Protocol: "HTTPS", Name: "https"},
+		Hosts: []string{"bookinfo.example.com"},
+		Tls:   &istioApi.Server_TLSOptions{Mode: 1, ServerCertificate: "/etc/certs/tls.crt"},
+	}
+	newPod := func(name, namespace string, labels map[string]string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec: v1.PodSpec{
+				Volumes: []v1.Volume{{Name: "certs", VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{SecretName: name + "-certs"}}}},
+				Containers: []v1.Container{{
+					VolumeMounts: []v1.VolumeMount{{Name: "certs", MountPath: "/etc/certs"}},
+				}},
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	selector := map[string]string{"app": "bookinfo-gateway"}
+
+	cases := []struct {
+		info     string
+		selector map[string]string
+		pods     []*v1.Pod
+		secret   string
+		err      bool
+		certErr  bool
+	}{
+		{
+			info:     "empty selector",
+			selector: nil,
+			pods:     []*v1.Pod{newPod("gateway", IngressGatewayNamespace, selector, v1.PodRunning)},
+			err:      true,
+		},
+		{
+			info:     "only the running pod is considered",
+			selector: selector,
+			pods: []*v1.Pod{
+				newPod("gateway-a", IngressGatewayNamespace, selector, v1.PodFailed),
+				newPod("gateway-b", IngressGatewayNamespace, selector, v1.PodRunning),
+			},
+			secret: IngressGatewayNamespace + "/gateway-b-certs",
+		},
+		{
+			info:     "workload in the gateway namespace",
+			selector: selector,
+			pods:     []*v1.Pod{newPod("gateway", "bookinfo", selector, v1.PodRunning)},
+			secret:   "bookinfo/gateway-certs",
+		},
+		{
+			info:     "workload in another namespace is ignored",
+			selector: selector,
+			pods:     []*v1.Pod{newPod("gateway", "other", selector, v1.PodRunning)},
+			certErr:  true,
+		},
+		{
+			info:     "no running workload",
+			selector: selector,
+			pods:     []*v1.Pod{newPod("gateway", IngressGatewayNamespace, selector, v1.PodPending)},
+			certErr:  true,
+		},
+	}
+
+	for _, c := range cases {
+		objects := make([]runtime.Object, len(c.pods))
+		for i, pod := range c.pods {
+			objects[i] = pod
+		}
+		client := fake.NewSimpleClientset(objects...)
+		gateway := &istioApi.Gateway{
+			ObjectMeta: metaV1.ObjectMeta{Name: "bookinfo", Namespace: "bookinfo"},
+			Spec:       istioApi.GatewaySpec{Selector: c.selector, Servers: []*istioApi.Server{tlsServer}},
+		}
+
+		certificates, err := GetServerCertificates(client, gateway)
+		if (err != nil) != c.err {
+			t.Errorf("%s: GetServerCertificates() returned error %v, expected error: %t", c.info, err, c.err)
+			continue
+		}
+		if c.err {
+			continue
+		}
+		if len(certificates) != 1 {
+			t.Errorf("%s: GetServerCertificates() returned %d certificates, expected 1", c.info, len(certificates))
+			continue
+		}
+		if certificates[0].Secret != c.secret {
+			t.Errorf("%s: secret == %q, expected %q", c.info, certificates[0].Secret, c.secret)
+		}
+		if c.certErr && !strings.HasPrefix(certificates[0].Error, "no running gateway workload") {
+			t.Errorf("%s: error == %q, expected no running gateway workload", c.info, certificates[0].Error)
+		}
+	}
+}
@@ -59,15 +59,15 @@ type JobDetail struct {
 }
 
 // GetJobDetail gets job details.
-func GetJobDetail(client k8sClient.Interface, metricClient metricapi.MetricClient, namespace, name string) (
-	*JobDetail, error) {
+func GetJobDetail(client k8sClient.Interface, metricClient metricapi.MetricClient, namespace, name string,
+	metricQuery *dataselect.MetricQuery) (*JobDetail, error) {
 
 	jobData, err := client.BatchV1().Jobs(namespace).Get(name, metaV1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	podList, err := GetJobPods(client, metricClient, dataselect.DefaultDataSelectWithMetrics, namespace, name)
+	podList, err := GetJobPods(client, metricClient, dataselect.NewDefaultDataSelectWithMetrics(metricQuery), namespace, name)
 	nonCriticalErrors, criticalError := errors.HandleError(err)
 	if criticalError != nil {
 		return nil, criticalError
@@ -23,8 +23,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-// Gets restart count of given pod (total number of its containers restarts).
-func getRestartCount(pod v1.Pod) int32 {
+// GetRestartCount returns restart count of given pod (total number of its containers restarts).
+func GetRestartCount(pod v1.Pod) int32 {
 	var restartCount int32 = 0
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		restartCount += containerStatus.RestartCount
@@ -91,8 +91,8 @@ type EnvVar struct {
 }
 
 // GetPodDetail returns the details of a named Pod from a particular namespace.
-func GetPodDetail(client kubernetes.Interface, metricClient metricapi.MetricClient, namespace, name string) (
-	*PodDetail, error) {
+func GetPodDetail(client kubernetes.Interface, metricClient metricapi.MetricClient, namespace, name string,
+	metricQuery *dataselect.MetricQuery) (*PodDetail, error) {
 	log.Printf("Getting details of %s pod in %s namespace", name, namespace)
 
 	channels := &common.ResourceChannels{
@@ -112,7 +112,8 @@ func GetPodDetail(client kubernetes.Interface, metricClient metricapi.MetricClie
 	}
 
 	_, metricPromises := dataselect.GenericDataSelectWithMetrics(toCells([]v1.Pod{*pod}),
-		dataselect.StdMetricsDataSelect, metricapi.NoResourceCache, metricClient)
+		dataselect.NewDataSelectQuery(dataselect.NoPagination, dataselect.NoSort,
+			dataselect.NoFilter, metricQuery), metricapi.NoResourceCache, metricClient)
 	metrics, _ := metricPromises.GetMetrics()
 
 	configMapList := <-channels.ConfigMapList.List
@@ -214,7 +215,7 @@ func toPodDetail(pod *v1.Pod, metrics []metricapi.Metric, configMaps *v1.ConfigM
 		TypeMeta:                  api.NewTypeMeta(api.ResourceKindPod),
 		PodPhase:                  pod.Status.Phase,
 		PodIP:                     pod.Status.PodIP,
-		RestartCount:              getRestartCount(*pod),
+		RestartCount:              GetRestartCount(*pod),
 		QOSClass:                  string(pod.Status.QOSClass),
 		NodeName:                  pod.Spec.NodeName,
 		Controller:                controller,
@@ -66,7 +66,7 @@ func TestGetPodDetail(t *testing.T) {
 		fakeClient := fake.NewSimpleClientset(c.pod)
 
 		dataselect.DefaultDataSelectWithMetrics.MetricQuery = dataselect.NoMetrics
-		actual, err := GetPodDetail(fakeClient, nil, "test-namespace", "test-pod")
+		actual, err := GetPodDetail(fakeClient, nil, "test-namespace", "test-pod", dataselect.NoMetrics)
 
 		if err != nil {
 			t.Errorf("GetPodDetail(%#v) == \ngot err %#v", c.pod, err)
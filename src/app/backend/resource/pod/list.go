@@ -154,7 +154,7 @@ func toPod(pod *v1.Pod, metrics *MetricsByPod, warnings []common.Event) Pod {
 		TypeMeta:     api.NewTypeMeta(api.ResourceKindPod),
 		Warnings:     warnings,
 		PodStatus:    getPodStatus(*pod, warnings),
-		RestartCount: getRestartCount(*pod),
+		RestartCount: GetRestartCount(*pod),
 		NodeName:     pod.Spec.NodeName,
 	}
 
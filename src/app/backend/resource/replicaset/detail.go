@@ -68,7 +68,7 @@ type ReplicaSetDetail struct {
 
 // GetReplicaSetDetail gets replica set details.
 func GetReplicaSetDetail(client k8sClient.Interface, metricClient metricapi.MetricClient,
-	namespace, name string) (*ReplicaSetDetail, error) {
+	namespace, name string, metricQuery *ds.MetricQuery) (*ReplicaSetDetail, error) {
 	log.Printf("Getting details of %s service in %s namespace", name, namespace)
 
 	rs, err := client.AppsV1beta2().ReplicaSets(namespace).Get(name, metaV1.GetOptions{})
@@ -82,7 +82,7 @@ func GetReplicaSetDetail(client k8sClient.Interface, metricClient metricapi.Metr
 		return nil, criticalError
 	}
 
-	podList, err := GetReplicaSetPods(client, metricClient, ds.DefaultDataSelectWithMetrics, name, namespace)
+	podList, err := GetReplicaSetPods(client, metricClient, ds.NewDefaultDataSelectWithMetrics(metricQuery), name, namespace)
 	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
 	if criticalError != nil {
 		return nil, criticalError
@@ -88,7 +88,7 @@ func TestGetReplicaSetDetail(t *testing.T) {
 		fakeClient := fake.NewSimpleClientset(c.replicaSet)
 
 		dataselect.DefaultDataSelectWithMetrics.MetricQuery = dataselect.NoMetrics
-		actual, _ := GetReplicaSetDetail(fakeClient, nil, c.namespace, c.name)
+		actual, _ := GetReplicaSetDetail(fakeClient, nil, c.namespace, c.name, dataselect.NoMetrics)
 
 		actions := fakeClient.Actions()
 		if len(actions) != len(c.expectedActions) {
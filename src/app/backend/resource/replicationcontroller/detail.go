@@ -76,7 +76,7 @@ type ReplicationControllerSpec struct {
 // GetReplicationControllerDetail returns detailed information about the given replication controller
 // in the given namespace.
 func GetReplicationControllerDetail(client k8sClient.Interface, metricClient metricapi.MetricClient,
-	namespace, name string) (*ReplicationControllerDetail, error) {
+	namespace, name string, metricQuery *ds.MetricQuery) (*ReplicationControllerDetail, error) {
 	log.Printf("Getting details of %s replication controller in %s namespace", name, namespace)
 
 	replicationController, err := client.CoreV1().ReplicationControllers(namespace).Get(name, metaV1.GetOptions{})
@@ -90,7 +90,7 @@ func GetReplicationControllerDetail(client k8sClient.Interface, metricClient met
 		return nil, criticalError
 	}
 
-	podList, err := GetReplicationControllerPods(client, metricClient, ds.DefaultDataSelectWithMetrics,
+	podList, err := GetReplicationControllerPods(client, metricClient, ds.NewDefaultDataSelectWithMetrics(metricQuery),
 		name, namespace)
 	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
 	if criticalError != nil {
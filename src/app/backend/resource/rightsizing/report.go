@@ -0,0 +1,409 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rightsizing
+
+import (
+	"log"
+	"math"
+	"strings"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/errors"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/controller"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	client "k8s.io/client-go/kubernetes"
+)
+
+// Status tells how the requests of a workload fit its observed usage.
+type Status string
+
+const (
+	// StatusOverProvisioned means that the usage stays well below the request.
+	StatusOverProvisioned Status = "over-provisioned"
+	// StatusUnderProvisioned means that the usage exceeds the request, or that there is no request.
+	StatusUnderProvisioned Status = "under-provisioned"
+	// StatusOK means that the request fits the usage.
+	StatusOK Status = "ok"
+	// StatusNoData means that no usage was observed, e.g. because there is no metric client.
+	StatusNoData Status = "no-data"
+)
+
+const (
+	// DefaultPercentile is the usage percentile the suggested requests are based on by default.
+	DefaultPercentile = 90
+	// headroom is added on top of the usage percentile to suggest a request.
+	headroom = 0.15
+	// overProvisionedRatio is the ratio of usage to request below which a workload is
+	// over-provisioned.
+	overProvisionedRatio = 0.5
+	// mebibyte is the unit suggested memory requests are rounded up to.
+	mebibyte = 1024 * 1024
+)
+
+// RightSizingReport compares the requests and limits of the workloads of a namespace with their
+// observed usage.
+type RightSizingReport struct {
+	Namespace string `json:"namespace"`
+
+	// Percentile of the usage the suggested requests are based on.
+	Percentile int `json:"percentile"`
+
+	// Workloads with at least one pod that has not finished yet.
+	Workloads []Workload `json:"workloads"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors"`
+}
+
+// Workload is the top level controller of a group of pods, e.g. a deployment, or a pod without
+// controller.
+type Workload struct {
+	ObjectMeta api.ObjectMeta `json:"objectMeta"`
+	TypeMeta   api.TypeMeta   `json:"typeMeta"`
+
+	// Number of pods of the workload.
+	Pods int `json:"pods"`
+
+	// CPU requests, limits and usage of a pod in millicores.
+	CPU ResourceUsage `json:"cpu"`
+
+	// Memory requests, limits and usage of a pod in bytes.
+	Memory ResourceUsage `json:"memory"`
+
+	// Containers of the most recent pod, including injected sidecars.
+	Containers []Container `json:"containers"`
+}
+
+// ResourceUsage compares the request and limit of a resource of a pod with its usage.
+type ResourceUsage struct {
+	// Sum of the requests of the containers, 0 if none of them has a request.
+	Request int64 `json:"request"`
+
+	// Sum of the limits of the containers, 0 if any of them is unlimited.
+	Limit int64 `json:"limit"`
+
+	// Usage at the percentile of the report, over the samples of all the pods.
+	Usage int64 `json:"usage"`
+
+	// Highest observed usage of a pod.
+	MaxUsage int64 `json:"maxUsage"`
+
+	// Request fitting the usage, with some headroom.
+	SuggestedRequest int64 `json:"suggestedRequest"`
+
+	Status Status `json:"status"`
+}
+
+// Container holds the requests and limits of a container and the requests suggested for it.
+// Metric clients provide the usage of pods only, so the suggested requests of a pod are split
+// between its containers in proportion to their current requests.
+type Container struct {
+	Name              string          `json:"name"`
+	Requests          v1.ResourceList `json:"requests"`
+	Limits            v1.ResourceList `json:"limits"`
+	SuggestedRequests v1.ResourceList `json:"suggestedRequests"`
+}
+
+// workload groups the pods of a top level controller.
+type workload struct {
+	objectMeta api.ObjectMeta
+	typeMeta   api.TypeMeta
+	pods       []v1.Pod
+}
+
+// podUsage holds the usage samples of a pod by metric name.
+type podUsage map[string][]int64
+
+// GetRightSizingReport returns the right-sizing report of the workloads of given namespace, based
+// on the usage observed by the metric client within the time range.
+func GetRightSizingReport(client client.Interface, metricClient metricapi.MetricClient, namespace string,
+	percentile int, timeRange metricapi.TimeRange) (*RightSizingReport, error) {
+	log.Printf("Getting right-sizing report of %s namespace", namespace)
+
+	channels := &common.ResourceChannels{
+		PodList: common.GetPodListChannel(client, common.NewSameNamespaceQuery(namespace), 1),
+	}
+	pods := <-channels.PodList.List
+	err := <-channels.PodList.Error
+	nonCriticalErrors, criticalError := errors.HandleError(err)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+
+	activePods := make([]v1.Pod, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			activePods = append(activePods, pod)
+		}
+	}
+
+	// the report is returned without usage when the metrics can't be downloaded
+	usage, err := getPodUsage(metricClient, activePods, timeRange)
+	if err != nil {
+		log.Printf("Skipping metrics because of error: %s", err)
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
+
+	report := &RightSizingReport{
+		Namespace:  namespace,
+		Percentile: percentile,
+		Workloads:  make([]Workload, 0),
+		Errors:     nonCriticalErrors,
+	}
+	for _, w := range getWorkloads(client, namespace, activePods) {
+		report.Workloads = append(report.Workloads, toWorkload(w, usage, percentile))
+	}
+	return report, nil
+}
+
+// getWorkloads groups the pods by their top level controller. Pods of the replica sets of a
+// deployment are grouped by the deployment.
+func getWorkloads(client client.Interface, namespace string, pods []v1.Pod) []*workload {
+	result := make([]*workload, 0)
+	byUID := make(map[types.UID]*workload)
+	owners := make(map[types.UID]*workload)
+	for _, pod := range pods {
+		ref := metaV1.GetControllerOf(&pod)
+		if ref == nil {
+			w := &workload{
+				objectMeta: api.NewObjectMeta(pod.ObjectMeta),
+				typeMeta:   api.NewTypeMeta(api.ResourceKindPod),
+			}
+			w.pods = append(w.pods, pod)
+			result = append(result, w)
+			continue
+		}
+
+		owner, ok := owners[ref.UID]
+		if !ok {
+			owner = getOwner(client, namespace, *ref, pods)
+			owners[ref.UID] = owner
+		}
+		w, ok := byUID[owner.objectMeta.UID]
+		if !ok {
+			w = owner
+			byUID[owner.objectMeta.UID] = w
+			result = append(result, w)
+		}
+		w.pods = append(w.pods, pod)
+	}
+	return result
+}
+
+// getOwner returns the empty workload of the top level controller of given controller reference.
+// If the controller cannot be retrieved, the reference itself identifies the workload.
+func getOwner(client client.Interface, namespace string, ref metaV1.OwnerReference, pods []v1.Pod) *workload {
+	rc, err := controller.NewResourceController(ref, namespace, client)
+	if err != nil {
+		log.Printf("Failed to get controller %s %s: %s", ref.Kind, ref.Name, err)
+		return ownerFromReference(namespace, ref)
+	}
+
+	if rs, ok := rc.(controller.ReplicaSetController); ok {
+		if deploymentRef := metaV1.GetControllerOf(&rs); deploymentRef != nil &&
+			deploymentRef.Kind == "Deployment" {
+			return ownerFromReference(namespace, *deploymentRef)
+		}
+	}
+
+	owner := rc.Get(pods, nil)
+	return &workload{objectMeta: owner.ObjectMeta, typeMeta: owner.TypeMeta}
+}
+
+func ownerFromReference(namespace string, ref metaV1.OwnerReference) *workload {
+	return &workload{
+		objectMeta: api.ObjectMeta{Name: ref.Name, Namespace: namespace, UID: ref.UID},
+		typeMeta:   api.NewTypeMeta(api.ResourceKind(strings.ToLower(ref.Kind))),
+	}
+}
+
+// getPodUsage downloads the CPU and memory usage samples of the pods within the time range.
+func getPodUsage(metricClient metricapi.MetricClient, pods []v1.Pod,
+	timeRange metricapi.TimeRange) (map[types.UID]podUsage, error) {
+	result := make(map[types.UID]podUsage)
+	if metricClient == nil || len(pods) == 0 {
+		return result, nil
+	}
+
+	selectors := make([]metricapi.ResourceSelector, len(pods))
+	for i, pod := range pods {
+		selectors[i] = metricapi.ResourceSelector{
+			Namespace:    pod.Namespace,
+			ResourceType: api.ResourceKindPod,
+			ResourceName: pod.Name,
+			UID:          pod.UID,
+		}
+	}
+	metrics, err := metricClient.DownloadMetrics(selectors,
+		[]string{metricapi.CpuUsage, metricapi.MemoryUsage}, timeRange, metricapi.SumAggregation,
+		&metricapi.CachedResources{Pods: pods}).GetMetrics()
+	if err != nil {
+		return result, err
+	}
+
+	for _, metric := range metrics {
+		uids := metric.Label[api.ResourceKindPod]
+		if len(uids) != 1 {
+			continue
+		}
+		usage, ok := result[uids[0]]
+		if !ok {
+			usage = make(podUsage)
+			result[uids[0]] = usage
+		}
+		for _, point := range metric.DataPoints {
+			usage[metric.MetricName] = append(usage[metric.MetricName], point.Y)
+		}
+	}
+	return result, nil
+}
+
+// toWorkload compares the requests of the most recent pod of the workload with the usage of all
+// its pods.
+func toWorkload(w *workload, usage map[types.UID]podUsage, percentile int) Workload {
+	latest := w.pods[0]
+	for _, pod := range w.pods[1:] {
+		if latest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latest = pod
+		}
+	}
+
+	var cpuSamples, memorySamples []int64
+	for _, pod := range w.pods {
+		cpuSamples = append(cpuSamples, usage[pod.UID][metricapi.CpuUsage]...)
+		memorySamples = append(memorySamples, usage[pod.UID][metricapi.MemoryUsage]...)
+	}
+
+	containers := getContainers(&latest.Spec)
+	result := Workload{
+		ObjectMeta: w.objectMeta,
+		TypeMeta:   w.typeMeta,
+		Pods:       len(w.pods),
+		CPU:        cpuUnits.toResourceUsage(containers, cpuSamples, percentile),
+		Memory:     memoryUnits.toResourceUsage(containers, memorySamples, percentile),
+		Containers: containers,
+	}
+	cpuUnits.suggestContainerRequests(containers, result.CPU)
+	memoryUnits.suggestContainerRequests(containers, result.Memory)
+	return result
+}
+
+// getContainers returns the requests and limits of the containers of the pod spec.
+func getContainers(spec *v1.PodSpec) []Container {
+	containers := make([]Container, 0, len(spec.Containers))
+	for i, name := range common.GetContainerNames(spec) {
+		resources := spec.Containers[i].Resources
+		containers = append(containers, Container{
+			Name:              name,
+			Requests:          resources.Requests,
+			Limits:            resources.Limits,
+			SuggestedRequests: v1.ResourceList{},
+		})
+	}
+	return containers
+}
+
+// resourceUnits converts the quantities of a resource to the units of its metric and back.
+type resourceUnits struct {
+	name     v1.ResourceName
+	value    func(resource.Quantity) int64
+	quantity func(int64) *resource.Quantity
+	// step is the multiple suggested requests are rounded up to.
+	step int64
+}
+
+var cpuUnits = resourceUnits{
+	name:     v1.ResourceCPU,
+	value:    func(q resource.Quantity) int64 { return q.MilliValue() },
+	quantity: func(value int64) *resource.Quantity { return resource.NewMilliQuantity(value, resource.DecimalSI) },
+	step:     1,
+}
+
+var memoryUnits = resourceUnits{
+	name:     v1.ResourceMemory,
+	value:    func(q resource.Quantity) int64 { return q.Value() },
+	quantity: func(value int64) *resource.Quantity { return resource.NewQuantity(value, resource.BinarySI) },
+	step:     mebibyte,
+}
+
+// toResourceUsage compares the sum of the requests and limits of the containers with the usage
+// samples of the pods.
+func (self resourceUnits) toResourceUsage(containers []Container, samples []int64,
+	percentile int) ResourceUsage {
+	result := ResourceUsage{Status: StatusNoData}
+	limited := len(containers) > 0
+	for _, container := range containers {
+		if quantity, ok := container.Requests[self.name]; ok {
+			result.Request += self.value(quantity)
+		}
+		if quantity, ok := container.Limits[self.name]; ok {
+			result.Limit += self.value(quantity)
+		} else {
+			limited = false
+		}
+	}
+	if !limited {
+		result.Limit = 0
+	}
+
+	if len(samples) == 0 {
+		return result
+	}
+	result.Usage = metricapi.PercentileAggregate(percentile)(samples)
+	result.MaxUsage = metricapi.MaxAggregate(samples)
+	result.SuggestedRequest = self.roundUp(float64(result.Usage) * (1 + headroom))
+
+	switch {
+	case result.Request == 0 || result.Usage > result.Request:
+		result.Status = StatusUnderProvisioned
+	case float64(result.Usage) < float64(result.Request)*overProvisionedRatio:
+		result.Status = StatusOverProvisioned
+	default:
+		result.Status = StatusOK
+	}
+	return result
+}
+
+// suggestContainerRequests splits the suggested request of the pod between the containers in
+// proportion to their requests, or evenly if none of them has a request.
+func (self resourceUnits) suggestContainerRequests(containers []Container, usage ResourceUsage) {
+	if usage.Status == StatusNoData {
+		return
+	}
+	for _, container := range containers {
+		share := 1 / float64(len(containers))
+		if usage.Request > 0 {
+			share = float64(self.value(container.Requests[self.name])) / float64(usage.Request)
+		}
+		if share > 0 {
+			container.SuggestedRequests[self.name] = *self.quantity(
+				self.roundUp(float64(usage.SuggestedRequest) * share))
+		}
+	}
+}
+
+// roundUp rounds given value up to a multiple of the step, which is at least one step.
+func (self resourceUnits) roundUp(value float64) int64 {
+	steps := int64(math.Ceil(value / float64(self.step)))
+	if steps < 1 {
+		steps = 1
+	}
+	return steps * self.step
+}
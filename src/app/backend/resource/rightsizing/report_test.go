@@ -0,0 +1,206 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rightsizing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kubernetes/dashboard/src/app/backend/api"
+	integrationapi "github.com/kubernetes/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/kubernetes/dashboard/src/app/backend/integration/metric/api"
+	apps "k8s.io/api/apps/v1beta2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeMetricClient returns the samples of the pods by their UID, or the error when it is set.
+type fakeMetricClient struct {
+	samples map[types.UID]map[string][]int64
+	err     error
+}
+
+func (self fakeMetricClient) DownloadMetric(selectors []metricapi.ResourceSelector, metricName string,
+	timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.NewMetricPromises(len(selectors))
+	for i, selector := range selectors {
+		if self.err != nil {
+			result[i].Metric <- nil
+			result[i].Error <- self.err
+			continue
+		}
+		metric := &metricapi.Metric{
+			MetricName: metricName,
+			Label:      metricapi.Label{api.ResourceKindPod: []types.UID{selector.UID}},
+		}
+		for j, value := range self.samples[selector.UID][metricName] {
+			metric.DataPoints = append(metric.DataPoints, metricapi.DataPoint{X: int64(j * 60), Y: value})
+		}
+		result[i].Metric <- metric
+		result[i].Error <- nil
+	}
+	return result
+}
+
+func (self fakeMetricClient) DownloadMetrics(selectors []metricapi.ResourceSelector, metricNames []string,
+	timeRange metricapi.TimeRange, aggregation metricapi.AggregationMode,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		result = append(result, self.DownloadMetric(selectors, metricName, timeRange, aggregation, cachedResources)...)
+	}
+	return result
+}
+
+func (self fakeMetricClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return metrics
+}
+
+func (self fakeMetricClient) HealthCheck() error {
+	return nil
+}
+
+func (self fakeMetricClient) ID() integrationapi.IntegrationID {
+	return "fake"
+}
+
+func resources(cpu, memory string) v1.ResourceList {
+	return v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+}
+
+func controllerRef(kind, name string, uid types.UID) []metaV1.OwnerReference {
+	isController := true
+	return []metaV1.OwnerReference{{Kind: kind, Name: name, UID: uid, Controller: &isController}}
+}
+
+func TestGetRightSizingReport(t *testing.T) {
+	created := metaV1.NewTime(time.Unix(1500000000, 0))
+	replicaSets := []apps.ReplicaSet{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-1", Namespace: "default", UID: "rs-1",
+			OwnerReferences: controllerRef("Deployment", "reviews", "deploy-1")}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "reviews-2", Namespace: "default", UID: "rs-2",
+			OwnerReferences: controllerRef("Deployment", "reviews", "deploy-1")}},
+	}
+	reviews := v1.Container{Name: "reviews",
+		Resources: v1.ResourceRequirements{Requests: resources("500m", "256Mi"), Limits: resources("1", "512Mi")}}
+	proxy := v1.Container{Name: "istio-proxy",
+		Resources: v1.ResourceRequirements{Requests: resources("100m", "128Mi")}}
+	pods := &v1.PodList{Items: []v1.Pod{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "reviews-1-a", Namespace: "default", UID: "pod-1",
+				CreationTimestamp: created, OwnerReferences: controllerRef("ReplicaSet", "reviews-1", "rs-1")},
+			Spec: v1.PodSpec{Containers: []v1.Container{reviews}},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "reviews-2-a", Namespace: "default", UID: "pod-2",
+				CreationTimestamp: metaV1.NewTime(created.Add(time.Hour)),
+				OwnerReferences:   controllerRef("ReplicaSet", "reviews-2", "rs-2")},
+			Spec: v1.PodSpec{Containers: []v1.Container{reviews, proxy}},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "debug", Namespace: "default", UID: "pod-3"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "shell"}}},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "migrate", Namespace: "default", UID: "pod-4"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "migrate"}}},
+			Status:     v1.PodStatus{Phase: v1.PodSucceeded},
+		},
+	}}
+	fakeClient := fake.NewSimpleClientset(pods, &replicaSets[0], &replicaSets[1])
+	metricClient := fakeMetricClient{samples: map[types.UID]map[string][]int64{
+		"pod-1": {metricapi.CpuUsage: {100, 120}, metricapi.MemoryUsage: {400 * mebibyte}},
+		"pod-2": {metricapi.CpuUsage: {140, 160}, metricapi.MemoryUsage: {420 * mebibyte}},
+		"pod-3": {metricapi.CpuUsage: {10}},
+	}}
+
+	report, err := GetRightSizingReport(fakeClient, metricClient, "default", DefaultPercentile,
+		metricapi.DefaultTimeRange)
+	if err != nil {
+		t.Fatalf("GetRightSizingReport() returned error: %v", err)
+	}
+	if len(report.Workloads) != 2 {
+		t.Fatalf("GetRightSizingReport() == %d workloads, expected 2: %#v", len(report.Workloads), report.Workloads)
+	}
+
+	workloads := make(map[string]Workload)
+	for _, workload := range report.Workloads {
+		workloads[workload.ObjectMeta.Name] = workload
+	}
+
+	deployment := workloads["reviews"]
+	if deployment.TypeMeta.Kind != api.ResourceKindDeployment || deployment.ObjectMeta.Name != "reviews" ||
+		deployment.Pods != 2 {
+		t.Errorf("GetRightSizingReport() workload == %#v, expected deployment reviews with 2 pods", deployment)
+	}
+	expectedCPU := ResourceUsage{Request: 600, Limit: 0, Usage: 160, MaxUsage: 160, SuggestedRequest: 184,
+		Status: StatusOverProvisioned}
+	if deployment.CPU != expectedCPU {
+		t.Errorf("GetRightSizingReport() cpu == %#v, expected %#v", deployment.CPU, expectedCPU)
+	}
+	expectedMemory := ResourceUsage{Request: 384 * mebibyte, Limit: 0, Usage: 420 * mebibyte,
+		MaxUsage: 420 * mebibyte, SuggestedRequest: 483 * mebibyte, Status: StatusUnderProvisioned}
+	if deployment.Memory != expectedMemory {
+		t.Errorf("GetRightSizingReport() memory == %#v, expected %#v", deployment.Memory, expectedMemory)
+	}
+	expectedSuggestions := []v1.ResourceList{resources("154m", "322Mi"), resources("31m", "161Mi")}
+	for i, container := range deployment.Containers {
+		for name, expected := range expectedSuggestions[i] {
+			if actual := container.SuggestedRequests[name]; actual.Cmp(expected) != 0 {
+				t.Errorf("GetRightSizingReport() container %s suggested %s == %s, expected %s", container.Name,
+					name, actual.String(), expected.String())
+			}
+		}
+	}
+
+	pod := workloads["debug"]
+	if pod.TypeMeta.Kind != api.ResourceKindPod {
+		t.Errorf("GetRightSizingReport() workload == %#v, expected pod debug", pod)
+	}
+	if pod.CPU.Status != StatusUnderProvisioned || pod.Memory.Status != StatusNoData {
+		t.Errorf("GetRightSizingReport() pod statuses == %s, %s, expected %s, %s", pod.CPU.Status,
+			pod.Memory.Status, StatusUnderProvisioned, StatusNoData)
+	}
+	suggested := pod.Containers[0].SuggestedRequests
+	if cpu := suggested[v1.ResourceCPU]; len(suggested) != 1 || cpu.Cmp(resource.MustParse("12m")) != 0 {
+		t.Errorf("GetRightSizingReport() pod suggestions == %v, expected 12m of CPU", suggested)
+	}
+}
+
+func TestGetRightSizingReportMetricError(t *testing.T) {
+	pods := &v1.PodList{Items: []v1.Pod{{
+		ObjectMeta: metaV1.ObjectMeta{Name: "debug", Namespace: "default", UID: "pod-1"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "shell"}}},
+	}}}
+	metricClient := fakeMetricClient{err: errors.New("heapster unavailable")}
+
+	report, err := GetRightSizingReport(fake.NewSimpleClientset(pods), metricClient, "default", DefaultPercentile,
+		metricapi.DefaultTimeRange)
+	if err != nil {
+		t.Fatalf("GetRightSizingReport() returned error: %v", err)
+	}
+	if len(report.Errors) != 1 || report.Errors[0] != metricClient.err {
+		t.Errorf("GetRightSizingReport() errors == %v, expected the metric error", report.Errors)
+	}
+	if len(report.Workloads) != 1 || report.Workloads[0].CPU.Status != StatusNoData {
+		t.Errorf("GetRightSizingReport() workloads == %#v, expected debug pod without data", report.Workloads)
+	}
+}
@@ -15,6 +15,8 @@
 package serviceentry
 
 import (
+	"fmt"
+
 	"github.com/kubernetes/dashboard/src/app/backend/api"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
 	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
@@ -28,10 +30,23 @@ func ToServiceEntry(serviceEntry *istioApi.ServiceEntry) *ServiceEntry {
 		TypeMeta:   api.NewTypeMeta(api.ResourceKindServiceEntry),
 		Hosts:      serviceEntry.Spec.Hosts,
 		Addresses:  serviceEntry.Spec.Addresses,
+		Ports:      serviceEntry.Spec.Ports,
+		Location:   enumName(locations, serviceEntry.Spec.Location),
+		Resolution: enumName(resolutions, serviceEntry.Spec.Resolution),
 		Endpoints:  serviceEntry.Spec.Endpoints,
 	}
 }
 
+// enumName returns the name of the enum value, or the value itself if it is unknown.
+func enumName(names map[string]int32, value int32) string {
+	for name, v := range names {
+		if v == value {
+			return name
+		}
+	}
+	return fmt.Sprint(value)
+}
+
 func (self ServiceEntryCell) GetProperty(name dataselect.PropertyName) dataselect.ComparableValue {
 	switch name {
 	case dataselect.NameProperty:
@@ -0,0 +1,351 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Locations of the service entry.
+const (
+	MeshExternal = "MESH_EXTERNAL"
+	MeshInternal = "MESH_INTERNAL"
+)
+
+// Resolutions of the service entry's endpoints.
+const (
+	ResolutionNone   = "NONE"
+	ResolutionStatic = "STATIC"
+	ResolutionDNS    = "DNS"
+)
+
+var locations = map[string]int32{
+	MeshExternal: 0,
+	MeshInternal: 1,
+}
+
+var resolutions = map[string]int32{
+	ResolutionNone:   0,
+	ResolutionStatic: 1,
+	ResolutionDNS:    2,
+}
+
+var protocols = map[string]bool{
+	"HTTP":  true,
+	"HTTPS": true,
+	"GRPC":  true,
+	"HTTP2": true,
+	"MONGO": true,
+	"TCP":   true,
+	"TLS":   true,
+}
+
+// ServiceEntrySpec is the user input to create or update a service entry.
+type ServiceEntrySpec struct {
+	// Name of the service entry, ignored on update.
+	Name string `json:"name"`
+	// REQUIRED. The hosts associated with the service entry, external hosts may have a wildcard
+	// prefix, i.e. *.example.com.
+	Hosts []string `json:"hosts"`
+	// The virtual IP addresses or CIDR prefixes associated with the service.
+	Addresses []string `json:"addresses,omitempty"`
+	// REQUIRED. The ports associated with the service.
+	Ports []*v1alpha3.Port `json:"ports"`
+	// MESH_EXTERNAL (default) or MESH_INTERNAL.
+	Location string `json:"location,omitempty"`
+	// NONE (default), STATIC or DNS.
+	Resolution string `json:"resolution,omitempty"`
+	// The endpoints of the service, required by the STATIC resolution.
+	Endpoints []*v1alpha3.ServiceEntry_Endpoint `json:"endpoints,omitempty"`
+	// The namespaces the service entry is visible in, "." for its own namespace or "*" for all.
+	// Empty means all namespaces.
+	ExportTo []string `json:"exportTo,omitempty"`
+}
+
+// CreateServiceEntry creates a service entry in the namespace.
+func CreateServiceEntry(istioClient istio.Interface, namespace string, spec *ServiceEntrySpec) (*ServiceEntry, error) {
+	log.Printf("Creating service entry %s in %s namespace", spec.Name, namespace)
+
+	if err := validateServiceEntrySpec(spec, true); err != nil {
+		return nil, err
+	}
+
+	serviceEntry := &v1alpha3.ServiceEntry{
+		TypeMeta: metaV1.TypeMeta{
+			APIVersion: "networking.istio.io/v1alpha3",
+			Kind:       "ServiceEntry",
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: namespace,
+		},
+		Spec: toServiceEntrySpec(spec),
+	}
+
+	raw, err := encodeServiceEntry(serviceEntry, spec.ExportTo)
+	if err != nil {
+		return nil, err
+	}
+	result, err := istioClient.NetworkingV1alpha3().RESTClient().Post().
+		Namespace(namespace).
+		Resource("serviceentries").
+		Body(raw).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeServiceEntry(result)
+}
+
+// UpdateServiceEntry replaces the spec of an existing service entry.
+func UpdateServiceEntry(istioClient istio.Interface, namespace, name string, spec *ServiceEntrySpec) (*ServiceEntry, error) {
+	log.Printf("Updating service entry %s in %s namespace", name, namespace)
+
+	if err := validateServiceEntrySpec(spec, false); err != nil {
+		return nil, err
+	}
+
+	serviceEntry, err := istioClient.NetworkingV1alpha3().ServiceEntries(namespace).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	serviceEntry.Spec = toServiceEntrySpec(spec)
+	serviceEntry.APIVersion = "networking.istio.io/v1alpha3"
+	serviceEntry.Kind = "ServiceEntry"
+
+	raw, err := encodeServiceEntry(serviceEntry, spec.ExportTo)
+	if err != nil {
+		return nil, err
+	}
+	result, err := istioClient.NetworkingV1alpha3().RESTClient().Put().
+		Namespace(namespace).
+		Resource("serviceentries").
+		Name(name).
+		Body(raw).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeServiceEntry(result)
+}
+
+// DeleteServiceEntry deletes the service entry.
+func DeleteServiceEntry(istioClient istio.Interface, namespace, name string) error {
+	log.Printf("Deleting service entry %s in %s namespace", name, namespace)
+	return istioClient.NetworkingV1alpha3().ServiceEntries(namespace).Delete(name, &metaV1.DeleteOptions{})
+}
+
+func toServiceEntrySpec(spec *ServiceEntrySpec) v1alpha3.ServiceEntrySpec {
+	return v1alpha3.ServiceEntrySpec{
+		Hosts:      spec.Hosts,
+		Addresses:  spec.Addresses,
+		Ports:      spec.Ports,
+		Location:   locations[defaultString(spec.Location, MeshExternal)],
+		Resolution: resolutions[defaultString(spec.Resolution, ResolutionNone)],
+		Endpoints:  spec.Endpoints,
+	}
+}
+
+// exportToObject holds the fields of a service entry the vendored istio types don't define.
+type exportToObject struct {
+	Spec struct {
+		ExportTo []string `json:"exportTo,omitempty"`
+	} `json:"spec"`
+}
+
+// encodeServiceEntry marshals the service entry along with its exportTo field, which the
+// vendored istio types don't define.
+func encodeServiceEntry(serviceEntry *v1alpha3.ServiceEntry, exportTo []string) ([]byte, error) {
+	raw, err := json.Marshal(serviceEntry)
+	if err != nil {
+		return nil, err
+	}
+	if len(exportTo) == 0 {
+		return raw, nil
+	}
+
+	object := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	spec, ok := object["spec"].(map[string]interface{})
+	if !ok {
+		spec = make(map[string]interface{})
+		object["spec"] = spec
+	}
+	spec["exportTo"] = exportTo
+	return json.Marshal(object)
+}
+
+// decodeServiceEntry unmarshals a service entry returned by the API server, keeping its exportTo field.
+func decodeServiceEntry(raw []byte) (*ServiceEntry, error) {
+	serviceEntry := new(v1alpha3.ServiceEntry)
+	if err := json.Unmarshal(raw, serviceEntry); err != nil {
+		return nil, err
+	}
+	extra := new(exportToObject)
+	if err := json.Unmarshal(raw, extra); err != nil {
+		return nil, err
+	}
+
+	result := ToServiceEntry(serviceEntry)
+	result.ExportTo = extra.Spec.ExportTo
+	return result, nil
+}
+
+func validateServiceEntrySpec(spec *ServiceEntrySpec, create bool) error {
+	var messages []string
+	if create {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.Name) {
+			messages = append(messages, fmt.Sprintf("invalid name %q: %s", spec.Name, msg))
+		}
+	}
+
+	location := defaultString(spec.Location, MeshExternal)
+	if _, ok := locations[location]; !ok {
+		messages = append(messages, fmt.Sprintf("unknown location %s, must be %s or %s", spec.Location, MeshExternal, MeshInternal))
+	}
+	resolution := defaultString(spec.Resolution, ResolutionNone)
+	if _, ok := resolutions[resolution]; !ok {
+		messages = append(messages, fmt.Sprintf("unknown resolution %s, must be %s, %s or %s", spec.Resolution,
+			ResolutionNone, ResolutionStatic, ResolutionDNS))
+	}
+
+	if len(spec.Hosts) == 0 {
+		messages = append(messages, "at least one host is required")
+	}
+	for _, host := range spec.Hosts {
+		name := host
+		if strings.HasPrefix(host, "*.") {
+			name = host[2:]
+			if location == MeshInternal {
+				messages = append(messages, fmt.Sprintf("wildcard host %s is only allowed for %s", host, MeshExternal))
+			}
+			// without endpoints, the wildcard would be resolved as it is
+			if resolution == ResolutionDNS && len(spec.Endpoints) == 0 {
+				messages = append(messages, fmt.Sprintf("wildcard host %s requires endpoints with the %s resolution", host, ResolutionDNS))
+			}
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			messages = append(messages, fmt.Sprintf("invalid host %q: %s", host, msg))
+		}
+	}
+
+	for _, address := range spec.Addresses {
+		if net.ParseIP(address) == nil {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				messages = append(messages, fmt.Sprintf("invalid address %q: must be an IP address or a CIDR prefix", address))
+			}
+		}
+	}
+
+	if len(spec.Ports) == 0 {
+		messages = append(messages, "at least one port is required")
+	}
+	portNames := make(map[string]bool)
+	for _, port := range spec.Ports {
+		if port == nil {
+			messages = append(messages, "port must not be empty")
+			continue
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.Number)) {
+			messages = append(messages, fmt.Sprintf("invalid port %d: %s", port.Number, msg))
+		}
+		if !protocols[strings.ToUpper(port.Protocol)] {
+			messages = append(messages, fmt.Sprintf("unsupported protocol %q of port %d, must be one of HTTP|HTTPS|GRPC|HTTP2|MONGO|TCP|TLS",
+				port.Protocol, port.Number))
+		}
+		if port.Name == "" {
+			messages = append(messages, fmt.Sprintf("name of port %d is required", port.Number))
+			continue
+		}
+		if portNames[port.Name] {
+			messages = append(messages, fmt.Sprintf("duplicate port name %s", port.Name))
+		}
+		portNames[port.Name] = true
+	}
+
+	switch resolution {
+	case ResolutionNone:
+		if len(spec.Endpoints) > 0 {
+			messages = append(messages, fmt.Sprintf("endpoints are not allowed with the %s resolution", ResolutionNone))
+		}
+	case ResolutionStatic:
+		if len(spec.Endpoints) == 0 {
+			messages = append(messages, fmt.Sprintf("at least one endpoint is required with the %s resolution", ResolutionStatic))
+		}
+	}
+	for _, endpoint := range spec.Endpoints {
+		if endpoint == nil {
+			messages = append(messages, "endpoint must not be empty")
+			continue
+		}
+		switch {
+		case strings.HasPrefix(endpoint.Address, "unix://"):
+			if len(spec.Ports) != 1 {
+				messages = append(messages, fmt.Sprintf("unix domain socket endpoint %s requires exactly one port", endpoint.Address))
+			}
+		case resolution == ResolutionStatic:
+			if net.ParseIP(endpoint.Address) == nil {
+				messages = append(messages, fmt.Sprintf("invalid endpoint %q: must be an IP address with the %s resolution",
+					endpoint.Address, ResolutionStatic))
+			}
+		default:
+			if net.ParseIP(endpoint.Address) == nil && len(validation.IsDNS1123Subdomain(endpoint.Address)) > 0 {
+				messages = append(messages, fmt.Sprintf("invalid endpoint %q: must be an IP address or a host name", endpoint.Address))
+			}
+		}
+		for name, number := range endpoint.Ports {
+			if !portNames[name] {
+				messages = append(messages, fmt.Sprintf("endpoint %s refers to unknown port %s", endpoint.Address, name))
+			}
+			for _, msg := range validation.IsValidPortNum(int(number)) {
+				messages = append(messages, fmt.Sprintf("invalid port %d of endpoint %s: %s", number, endpoint.Address, msg))
+			}
+		}
+	}
+
+	for _, export := range spec.ExportTo {
+		if export != "." && export != "*" {
+			messages = append(messages, fmt.Sprintf("invalid exportTo %q: must be \".\" or \"*\"", export))
+		}
+	}
+
+	if len(messages) > 0 {
+		return k8sErrors.NewBadRequest(strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+func defaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateServiceEntrySpec(t *testing.T) {
+	httpsPort := []*v1alpha3.Port{{Number: 443, Protocol: "HTTPS", Name: "https"}}
+	cases := []struct {
+		spec     *ServiceEntrySpec
+		expected []string
+	}{
+		{
+			&ServiceEntrySpec{Name: "httpbin", Hosts: []string{"httpbin.org"}, Ports: httpsPort, Resolution: ResolutionDNS},
+			nil,
+		},
+		{
+			&ServiceEntrySpec{Name: "wildcard", Hosts: []string{"*.example.com"}, Ports: httpsPort, ExportTo: []string{"."}},
+			nil,
+		},
+		{
+			&ServiceEntrySpec{
+				Name:       "db",
+				Hosts:      []string{"db.internal"},
+				Addresses:  []string{"10.0.0.0/24"},
+				Ports:      []*v1alpha3.Port{{Number: 5432, Protocol: "TCP", Name: "postgres"}},
+				Resolution: ResolutionStatic,
+				Endpoints: []*v1alpha3.ServiceEntry_Endpoint{
+					{Address: "10.0.0.1", Ports: map[string]uint32{"postgres": 15432}},
+				},
+			},
+			nil,
+		},
+		{
+			&ServiceEntrySpec{Name: "Invalid_Name", Hosts: []string{"httpbin.org"}, Ports: httpsPort},
+			[]string{`invalid name "Invalid_Name"`},
+		},
+		{
+			&ServiceEntrySpec{Name: "empty"},
+			[]string{"at least one host is required", "at least one port is required"},
+		},
+		{
+			&ServiceEntrySpec{Name: "enums", Hosts: []string{"httpbin.org"}, Ports: httpsPort,
+				Location: "OUTSIDE", Resolution: "LOOKUP"},
+			[]string{"unknown location OUTSIDE", "unknown resolution LOOKUP"},
+		},
+		{
+			&ServiceEntrySpec{Name: "ports", Hosts: []string{"httpbin.org"}, Ports: []*v1alpha3.Port{
+				{Number: 80, Protocol: "UDP", Name: "http"},
+				{Number: 0, Protocol: "HTTP", Name: "http"},
+				{Number: 8080, Protocol: "HTTP"},
+			}},
+			[]string{`unsupported protocol "UDP" of port 80`, "invalid port 0", "duplicate port name http",
+				"name of port 8080 is required"},
+		},
+		{
+			&ServiceEntrySpec{Name: "static", Hosts: []string{"db.internal"}, Ports: httpsPort, Resolution: ResolutionStatic},
+			[]string{"at least one endpoint is required with the STATIC resolution"},
+		},
+		{
+			&ServiceEntrySpec{Name: "static", Hosts: []string{"db.internal"}, Ports: httpsPort, Resolution: ResolutionStatic,
+				Endpoints: []*v1alpha3.ServiceEntry_Endpoint{{Address: "db.example.com", Ports: map[string]uint32{"grpc": 80}}}},
+			[]string{`invalid endpoint "db.example.com"`, "endpoint db.example.com refers to unknown port grpc"},
+		},
+		{
+			&ServiceEntrySpec{Name: "none", Hosts: []string{"httpbin.org"}, Ports: httpsPort,
+				Endpoints: []*v1alpha3.ServiceEntry_Endpoint{{Address: "1.2.3.4"}}},
+			[]string{"endpoints are not allowed with the NONE resolution"},
+		},
+		{
+			&ServiceEntrySpec{Name: "wildcard", Hosts: []string{"*.example.com"}, Ports: httpsPort,
+				Location: MeshInternal, Resolution: ResolutionDNS, Addresses: []string{"example"}, ExportTo: []string{"default"}},
+			[]string{"wildcard host *.example.com is only allowed for MESH_EXTERNAL",
+				"wildcard host *.example.com requires endpoints with the DNS resolution", `invalid address "example"`,
+				`invalid exportTo "default"`},
+		},
+	}
+
+	for _, c := range cases {
+		err := validateServiceEntrySpec(c.spec, true)
+		if c.expected == nil {
+			if err != nil {
+				t.Errorf("validateServiceEntrySpec(%s) returned unexpected error: %v", c.spec.Name, err)
+			}
+			continue
+		}
+		if !k8sErrors.IsBadRequest(err) {
+			t.Errorf("validateServiceEntrySpec(%s) == %v, expected a bad request", c.spec.Name, err)
+			continue
+		}
+		messages := strings.Split(err.Error(), "; ")
+		if len(messages) != len(c.expected) {
+			t.Errorf("validateServiceEntrySpec(%s) == %v, expected %d messages", c.spec.Name, err, len(c.expected))
+			continue
+		}
+		for i, expected := range c.expected {
+			if !strings.HasPrefix(messages[i], expected) {
+				t.Errorf("validateServiceEntrySpec(%s) message %d == %q, expected to start with %q",
+					c.spec.Name, i, messages[i], expected)
+			}
+		}
+	}
+}
+
+func TestEncodeServiceEntry(t *testing.T) {
+	spec := &ServiceEntrySpec{
+		Name:       "httpbin",
+		Hosts:      []string{"httpbin.org"},
+		Ports:      []*v1alpha3.Port{{Number: 443, Protocol: "HTTPS", Name: "https"}},
+		Location:   MeshExternal,
+		Resolution: ResolutionDNS,
+		ExportTo:   []string{"."},
+	}
+	serviceEntry := &v1alpha3.ServiceEntry{
+		ObjectMeta: metaV1.ObjectMeta{Name: "httpbin", Namespace: "default"},
+		Spec:       toServiceEntrySpec(spec),
+	}
+
+	raw, err := encodeServiceEntry(serviceEntry, spec.ExportTo)
+	if err != nil {
+		t.Fatalf("encodeServiceEntry() returned unexpected error: %v", err)
+	}
+	actual, err := decodeServiceEntry(raw)
+	if err != nil {
+		t.Fatalf("decodeServiceEntry() returned unexpected error: %v", err)
+	}
+
+	if actual.ObjectMeta.Name != "httpbin" || actual.ObjectMeta.Namespace != "default" {
+		t.Errorf("decodeServiceEntry() object meta == %#v, expected default/httpbin", actual.ObjectMeta)
+	}
+	if actual.Location != MeshExternal || actual.Resolution != ResolutionDNS {
+		t.Errorf("decodeServiceEntry() location, resolution == %s, %s, expected %s, %s",
+			actual.Location, actual.Resolution, MeshExternal, ResolutionDNS)
+	}
+	if !reflect.DeepEqual(actual.Hosts, spec.Hosts) || !reflect.DeepEqual(actual.Ports, spec.Ports) {
+		t.Errorf("decodeServiceEntry() hosts, ports == %v, %v, expected %v, %v",
+			actual.Hosts, actual.Ports, spec.Hosts, spec.Ports)
+	}
+	if !reflect.DeepEqual(actual.ExportTo, spec.ExportTo) {
+		t.Errorf("decodeServiceEntry() exportTo == %v, expected %v", actual.ExportTo, spec.ExportTo)
+	}
+}
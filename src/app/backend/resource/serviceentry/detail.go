@@ -15,9 +15,16 @@
 package serviceentry
 
 import (
+	"log"
+
 	"github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/errors"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/destinationrule"
+	"github.com/kubernetes/dashboard/src/app/backend/resource/virtualservice"
 	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
 	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -51,16 +58,123 @@ type ServiceEntry struct {
 	// Endpoints are unix domain socket addresses, there must be exactly one
 	// port.
 	Ports []*v1alpha3.Port `json:"ports,omitempty"`
-	// TODO more fields
+	// Whether the service is outside of the mesh (MESH_EXTERNAL) or part of it (MESH_INTERNAL).
+	Location string `json:"location,omitempty"`
+	// How the endpoints of the service are discovered: NONE, STATIC or DNS.
+	Resolution string `json:"resolution,omitempty"`
 	// One or more endpoints associated with the service.
 	Endpoints []*v1alpha3.ServiceEntry_Endpoint `json:"endpoints,omitempty"`
+	// The namespaces the service entry is visible in, all of them if empty.
+	ExportTo []string `json:"exportTo,omitempty"`
+
+	// The virtual services routing the hosts of the service entry, only filled in detail view.
+	VirtualServiceList *virtualservice.List `json:"virtualServiceList,omitempty"`
+	// The destination rules of the hosts of the service entry, only filled in detail view.
+	DestinationRuleList *destinationrule.List `json:"destinationRuleList,omitempty"`
+
+	// List of non-critical errors, that occurred during resource retrieval.
+	Errors []error `json:"errors,omitempty"`
 }
 
+// GetServiceEntry returns the service entry with the virtual services and destination rules of its hosts.
 func GetServiceEntry(k8sClient kubernetes.Interface, istioClient istio.Interface, name, namespace string) (*ServiceEntry, error) {
-	serviceEntry, err := istioClient.NetworkingV1alpha3().ServiceEntries(namespace).Get(name, metaV1.GetOptions{})
+	log.Printf("Getting details of %s service entry in %s namespace", name, namespace)
+
+	// the typed client drops the fields the vendored istio types don't define, i.e. exportTo
+	raw, err := istioClient.NetworkingV1alpha3().RESTClient().Get().
+		Namespace(namespace).
+		Resource("serviceentries").
+		Name(name).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	serviceEntry, err := decodeServiceEntry(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(serviceEntry.Hosts))
+	for _, host := range serviceEntry.Hosts {
+		hosts = append(hosts, virtualservice.FQDN(host, namespace))
+	}
+
+	nonCriticalErrors := make([]error, 0)
+	vServices, err := virtualservice.GetVirtualServices(istioClient, hosts, virtualservice.All)
+	nonCriticalErrors, criticalError := errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+	serviceEntry.VirtualServiceList = virtualservice.ToVirtualServiceList(vServices, dataselect.NoDataSelect, nil)
+
+	dRules, err := getDestinationRules(istioClient, namespace, hosts)
+	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
+	if criticalError != nil {
+		return nil, criticalError
+	}
+	serviceEntry.DestinationRuleList = destinationrule.ToDestinationRuleList(dRules, nil, dataselect.NoDataSelect)
+
+	serviceEntry.Errors = nonCriticalErrors
+	return serviceEntry, nil
+}
+
+// getDestinationRules returns the destination rules whose host is one of the fully qualified
+// hosts. When the caller may not list destination rules across all namespaces, only the namespace
+// of the service entry and the namespaces of the hosts are looked up.
+func getDestinationRules(istioClient istio.Interface, namespace string, hosts []string) ([]v1alpha3.DestinationRule, error) {
+	dRules, err := listDestinationRules(istioClient, hostNamespaces(namespace, hosts))
 	if err != nil {
 		return nil, err
 	}
 
-	return ToServiceEntry(serviceEntry), nil
+	result := make([]v1alpha3.DestinationRule, 0)
+	for _, dRule := range dRules {
+		fqdn := virtualservice.FQDN(dRule.Spec.Host, dRule.Namespace)
+		for _, host := range hosts {
+			if fqdn == host {
+				result = append(result, dRule)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// listDestinationRules lists the destination rules of all namespaces, or of the given namespaces
+// when the caller is forbidden to list all of them.
+func listDestinationRules(istioClient istio.Interface, namespaces []string) ([]v1alpha3.DestinationRule, error) {
+	dRules, err := istioClient.NetworkingV1alpha3().DestinationRules("").List(metaV1.ListOptions{})
+	if err == nil {
+		return dRules.Items, nil
+	}
+	if !k8sErrors.IsForbidden(err) {
+		return nil, err
+	}
+
+	log.Printf("Not allowed to list destination rules of all namespaces, listing them in %v", namespaces)
+	items := make([]v1alpha3.DestinationRule, 0)
+	for _, ns := range namespaces {
+		dRules, err := istioClient.NetworkingV1alpha3().DestinationRules(ns).List(metaV1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, dRules.Items...)
+	}
+	return items, nil
+}
+
+// hostNamespaces returns the namespace of the service entry and the distinct namespaces of the
+// fully qualified hosts.
+func hostNamespaces(namespace string, hosts []string) []string {
+	namespaces := []string{namespace}
+	seen := map[string]bool{namespace: true}
+	for _, host := range hosts {
+		ns := virtualservice.HostNamespace(host)
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
 }
@@ -0,0 +1,61 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetDestinationRulesForbidden(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apis/networking.istio.io/v1alpha3/destinationrules":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`))
+		case "/apis/networking.istio.io/v1alpha3/namespaces/default/destinationrules":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"httpbin","namespace":"default"},"spec":{"host":"httpbin.org"}},` +
+				`{"metadata":{"name":"reviews","namespace":"default"},"spec":{"host":"reviews"}}]}`))
+		case "/apis/networking.istio.io/v1alpha3/namespaces/prod/destinationrules":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"ratings","namespace":"prod"},"spec":{"host":"ratings"}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	istioClient, err := istio.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() returned error: %v", err)
+	}
+
+	hosts := []string{"httpbin.org.default.svc.cluster.local", "ratings.prod.svc.cluster.local"}
+	dRules, err := getDestinationRules(istioClient, "default", hosts)
+	if err != nil {
+		t.Fatalf("getDestinationRules() returned error: %v", err)
+	}
+	if len(dRules) != 2 || dRules[0].Name != "httpbin" || dRules[1].Name != "ratings" {
+		t.Errorf("getDestinationRules() == %+v, expected httpbin and ratings", dRules)
+	}
+	if len(paths) != 3 {
+		t.Errorf("getDestinationRules() requested %v, expected the default and prod namespaces after the forbidden list", paths)
+	}
+}
@@ -46,7 +46,7 @@ type StatefulSetDetail struct {
 
 // GetStatefulSetDetail gets Stateful Set details.
 func GetStatefulSetDetail(client kubernetes.Interface, metricClient metricapi.MetricClient, namespace,
-	name string) (*StatefulSetDetail, error) {
+	name string, metricQuery *ds.MetricQuery) (*StatefulSetDetail, error) {
 	log.Printf("Getting details of %s statefulset in %s namespace", name, namespace)
 
 	ss, err := client.AppsV1beta2().StatefulSets(namespace).Get(name, metaV1.GetOptions{})
@@ -54,7 +54,7 @@ func GetStatefulSetDetail(client kubernetes.Interface, metricClient metricapi.Me
 		return nil, err
 	}
 
-	podList, err := GetStatefulSetPods(client, metricClient, ds.DefaultDataSelectWithMetrics, name, namespace)
+	podList, err := GetStatefulSetPods(client, metricClient, ds.NewDefaultDataSelectWithMetrics(metricQuery), name, namespace)
 	nonCriticalErrors, criticalError := errors.HandleError(err)
 	if criticalError != nil {
 		return nil, criticalError
@@ -15,18 +15,20 @@
 package virtualservice
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
 
 	"github.com/kubernetes/dashboard/src/app/backend/api"
+	"github.com/kubernetes/dashboard/src/app/backend/args"
 	"github.com/kubernetes/dashboard/src/app/backend/errors"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/common"
 	"github.com/kubernetes/dashboard/src/app/backend/resource/dataselect"
 	"github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
 	istioApi "github.com/wallstreetcn/istio-k8s/apis/networking.istio.io/v1alpha3"
 	istio "github.com/wallstreetcn/istio-k8s/client/clientset/versioned"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -110,26 +112,32 @@ func intersected(col1 []string, col2 []string) bool {
 	return false
 }
 
-// GetVirtualServices fetches virtualServices under specified namespace by filtering hosts.
+// GetVirtualServices fetches the virtual services routing any of the hosts, which are full qualified
+// service names. Only the virtual services exported to the namespaces of the hosts are considered.
+// When the caller may not list virtual services across all namespaces, only the namespaces of the
+// hosts are looked up.
 func GetVirtualServices(istioClient istio.Interface, hosts []string, targetType string) ([]istioApi.VirtualService, error) {
 	var vServices = make([]istioApi.VirtualService, 0)
 
-	virtualServices, err := istioClient.NetworkingV1alpha3().VirtualServices("").
-		List(metaV1.ListOptions{})
+	namespaces := hostNamespaces(hosts)
+	items, err := listVirtualServices(istioClient, namespaces)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, item := range virtualServices.Items {
+	for _, item := range items {
+		if !ExportedTo(item.exportTo, item.Namespace, namespaces) {
+			continue
+		}
 		if targetType == OnlyHost || targetType == All {
 			if intersected(toFQDNs(item.Spec.Hosts, item.Namespace), hosts) {
-				vServices = append(vServices, item)
+				vServices = append(vServices, item.VirtualService)
 				continue
 			}
 		}
 		if targetType == OnlyGateway || targetType == All {
-			if intersected(getDestinations(item), hosts) {
-				vServices = append(vServices, item)
+			if intersected(getDestinations(item.VirtualService), hosts) {
+				vServices = append(vServices, item.VirtualService)
 				continue
 			}
 		}
@@ -138,6 +146,102 @@ func GetVirtualServices(istioClient istio.Interface, hosts []string, targetType
 	return vServices, nil
 }
 
+// virtualServiceItem is a virtual service with its exportTo, which the typed client does not know.
+type virtualServiceItem struct {
+	istioApi.VirtualService
+	exportTo []string
+}
+
+// listVirtualServices lists the virtual services of all namespaces, or of the given namespaces when
+// the caller is forbidden to list all of them.
+func listVirtualServices(istioClient istio.Interface, namespaces []string) ([]virtualServiceItem, error) {
+	items, err := listNamespaceVirtualServices(istioClient, "")
+	if !k8sErrors.IsForbidden(err) || len(namespaces) == 0 {
+		return items, err
+	}
+
+	log.Printf("Not allowed to list virtual services of all namespaces, listing them in %v", namespaces)
+	items = nil
+	for _, namespace := range namespaces {
+		namespaceItems, err := listNamespaceVirtualServices(istioClient, namespace)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, namespaceItems...)
+	}
+	return items, nil
+}
+
+func listNamespaceVirtualServices(istioClient istio.Interface, namespace string) ([]virtualServiceItem, error) {
+	path := []string{"/apis/networking.istio.io/v1alpha3"}
+	if namespace != "" {
+		path = append(path, "namespaces", namespace)
+	}
+	raw, err := istioClient.NetworkingV1alpha3().RESTClient().Get().AbsPath(append(path, "virtualservices")...).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := istioApi.VirtualServiceList{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	exports := struct {
+		Items []struct {
+			Spec struct {
+				ExportTo []string `json:"exportTo"`
+			} `json:"spec"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw, &exports); err != nil {
+		return nil, err
+	}
+
+	items := make([]virtualServiceItem, len(list.Items))
+	for i := range list.Items {
+		items[i] = virtualServiceItem{VirtualService: list.Items[i], exportTo: exports.Items[i].Spec.ExportTo}
+	}
+	return items, nil
+}
+
+// ExportedTo tells whether an istio object, i.e. a virtual service or a service entry, applies to
+// any of the namespaces. Without exportTo it applies to all namespaces, "." restricts it to its own
+// namespace and "*" exports it to all of them. Every object is considered when the namespaces are
+// unknown.
+func ExportedTo(exportTo []string, objectNamespace string, namespaces []string) bool {
+	if len(exportTo) == 0 || len(namespaces) == 0 {
+		return true
+	}
+	for _, export := range exportTo {
+		switch export {
+		case "*":
+			return true
+		case ".":
+			export = objectNamespace
+		}
+		for _, namespace := range namespaces {
+			if export == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostNamespaces returns the distinct namespaces of the full qualified service hosts.
+func hostNamespaces(hosts []string) []string {
+	var namespaces []string
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		namespace := HostNamespace(host)
+		if namespace != "" && !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
 func toFQDNs(hosts []string, namespace string) []string {
 	newHosts := make([]string, 0)
 	for _, h := range hosts {
@@ -156,10 +260,38 @@ func getDestinations(v istioApi.VirtualService) []string {
 	return destinationHosts
 }
 
+// DefaultClusterDomain is the domain of the cluster when none is configured.
+const DefaultClusterDomain = "cluster.local"
+
+// ClusterDomainSuffix returns the suffix of the full qualified service names of the cluster, e.g.
+// .svc.cluster.local, as configured by the 'cluster-domain' argument.
+func ClusterDomainSuffix() string {
+	domain := args.Holder.GetClusterDomain()
+	if domain == "" {
+		domain = DefaultClusterDomain
+	}
+	return ".svc." + domain
+}
+
 // FQDN interprets the service host to full qualified domain name.
 func FQDN(svc string, namespace string) string {
-	if strings.HasSuffix(svc, "svc.cluster.local") {
+	suffix := ClusterDomainSuffix()
+	if strings.HasSuffix(svc, suffix) {
 		return svc
 	}
-	return fmt.Sprintf("%s.%s.svc.cluster.local", svc, namespace)
+	return fmt.Sprintf("%s.%s%s", svc, namespace, suffix)
+}
+
+// HostNamespace returns the namespace of a full qualified service name, or an empty string when
+// the host is not a service of the cluster.
+func HostNamespace(host string) string {
+	suffix := ClusterDomainSuffix()
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	parts := strings.Split(strings.TrimSuffix(host, suffix), ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-1]
 }
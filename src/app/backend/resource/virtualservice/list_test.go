@@ -0,0 +1,77 @@
+// Copyright 2017 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualservice
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/dashboard/src/app/backend/args"
+)
+
+func TestFQDN(t *testing.T) {
+	cases := []struct {
+		domain    string
+		svc       string
+		expected  string
+		namespace string
+	}{
+		{"", "reviews", "reviews.default.svc.cluster.local", "default"},
+		{"", "reviews.default.svc.cluster.local", "reviews.default.svc.cluster.local", "default"},
+		{"corp.example", "reviews", "reviews.default.svc.corp.example", "default"},
+		{"corp.example", "reviews.default.svc.corp.example", "reviews.default.svc.corp.example", "default"},
+	}
+	defer args.GetHolderBuilder().SetClusterDomain("")
+	for _, c := range cases {
+		args.GetHolderBuilder().SetClusterDomain(c.domain)
+		if actual := FQDN(c.svc, "default"); actual != c.expected {
+			t.Errorf("FQDN(%q) with domain %q == %q, expected %q", c.svc, c.domain, actual, c.expected)
+		}
+		if actual := HostNamespace(c.expected); actual != c.namespace {
+			t.Errorf("HostNamespace(%q) == %q, expected %q", c.expected, actual, c.namespace)
+		}
+	}
+}
+
+func TestHostNamespaces(t *testing.T) {
+	hosts := []string{"reviews.default.svc.cluster.local", "ratings.default.svc.cluster.local",
+		"details.bookinfo.svc.cluster.local", "reviews", "example.com"}
+	expected := []string{"default", "bookinfo"}
+	if actual := hostNamespaces(hosts); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("hostNamespaces() == %v, expected %v", actual, expected)
+	}
+}
+
+func TestExportedTo(t *testing.T) {
+	cases := []struct {
+		exportTo    []string
+		vsNamespace string
+		namespaces  []string
+		expected    bool
+	}{
+		{nil, "istio-system", []string{"default"}, true},
+		{[]string{"*"}, "istio-system", []string{"default"}, true},
+		{[]string{"."}, "istio-system", []string{"default"}, false},
+		{[]string{"."}, "default", []string{"default"}, true},
+		{[]string{"bookinfo"}, "istio-system", []string{"default", "bookinfo"}, true},
+		{[]string{"."}, "istio-system", nil, true},
+	}
+	for _, c := range cases {
+		if actual := ExportedTo(c.exportTo, c.vsNamespace, c.namespaces); actual != c.expected {
+			t.Errorf("ExportedTo(%v, %q, %v) == %v, expected %v", c.exportTo, c.vsNamespace, c.namespaces,
+				actual, c.expected)
+		}
+	}
+}
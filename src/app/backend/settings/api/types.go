@@ -53,6 +53,13 @@ type Settings struct {
 	ClusterName             string `json:"clusterName"`
 	ItemsPerPage            int    `json:"itemsPerPage"`
 	AutoRefreshTimeInterval int    `json:"autoRefreshTimeInterval"`
+	// AppLabel is the key of the label holding the application name of the workloads and pods.
+	AppLabel string `json:"appLabel"`
+	// VersionLabel is the key of the label holding the version of the application.
+	VersionLabel string `json:"versionLabel"`
+	// ExtraAppLabels are the keys of additional labels set to the application name on the
+	// workloads created by dashboard.
+	ExtraAppLabels []string `json:"extraAppLabels"`
 }
 
 // Marshal settings into JSON object.
@@ -61,11 +68,12 @@ func (s Settings) Marshal() string {
 	return string(bytes)
 }
 
-// Unmarshal settings from JSON string into object.
+// Unmarshal settings from JSON string into object. The settings missing in the JSON string,
+// e.g. the ones saved before they were introduced, get their default value.
 func Unmarshal(data string) (*Settings, error) {
-	s := new(Settings)
-	err := json.Unmarshal([]byte(data), s)
-	return s, err
+	s := GetDefaultSettings()
+	err := json.Unmarshal([]byte(data), &s)
+	return &s, err
 }
 
 // defaultSettings contains default values for every setting.
@@ -73,12 +81,17 @@ var defaultSettings = Settings{
 	ClusterName:             "",
 	ItemsPerPage:            10,
 	AutoRefreshTimeInterval: 5,
+	AppLabel:                "app",
+	VersionLabel:            "version",
+	ExtraAppLabels:          []string{"qcloud-app"},
 }
 
 // GetDefaultSettings returns settings structure, that should be used if there are no
 // global or local settings overriding them. It should not change during runtime.
 func GetDefaultSettings() Settings {
-	return defaultSettings
+	s := defaultSettings
+	s.ExtraAppLabels = append([]string(nil), defaultSettings.ExtraAppLabels...)
+	return s
 }
 
 // GetDefaultSettingsConfigMap returns config map with default settings.
@@ -1084,6 +1084,9 @@ export interface GlobalSettings {
   clusterName: string;
   itemsPerPage: number;
   autoRefreshTimeInterval: number;
+  appLabel: string;
+  versionLabel: string;
+  extraAppLabels: string[];
 }
 
 export interface APIVersion {